@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS invoice_code_counters (
+  department_id INT PRIMARY KEY,
+  last_seq INTEGER NOT NULL
+);
+
+ALTER TABLE invoices
+	ADD COLUMN IF NOT EXISTS code_norm text GENERATED ALWAYS AS (unaccent_immutable(lower(code))) STORED;
+
+ALTER TABLE invoices
+	ADD COLUMN IF NOT EXISTS clinic_name_norm text GENERATED ALWAYS AS (unaccent_immutable(lower(clinic_name))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_invoice_code_trgm_norm ON invoices USING gin (code_norm gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_invoice_clinic_name_trgm_norm ON invoices USING gin (clinic_name_norm gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_orders_invoice_id ON orders (invoice_id);
+
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Hoá đơn - Xem', 'invoice.view'),
+  ('Hoá đơn - Xuất', 'invoice.issue'),
+  ('Hoá đơn - Huỷ', 'invoice.void'),
+  ('Hoá đơn - Tìm kiếm', 'invoice.search')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'invoice.view',
+  'invoice.issue',
+  'invoice.void',
+  'invoice.search'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
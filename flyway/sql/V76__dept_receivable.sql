@@ -0,0 +1,34 @@
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Công nợ - Xem', 'receivable.view'),
+  ('Công nợ - Ghi nhận thanh toán', 'receivable.create'),
+  ('Công nợ - Xoá thanh toán', 'receivable.delete')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'receivable.view',
+  'receivable.create',
+  'receivable.delete'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,36 @@
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Bảng giá - Xem', 'price_list.view'),
+  ('Bảng giá - Tạo', 'price_list.create'),
+  ('Bảng giá - Cập nhật', 'price_list.update'),
+  ('Bảng giá - Xoá', 'price_list.delete')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'price_list.view',
+  'price_list.create',
+  'price_list.update',
+  'price_list.delete'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
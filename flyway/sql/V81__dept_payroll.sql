@@ -0,0 +1,39 @@
+CREATE INDEX IF NOT EXISTS idx_oipip_assigned_completed ON order_item_process_in_progresses (assigned_id, completed_at)
+  WHERE completed_at IS NOT NULL;
+
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Bảng lương - Xem', 'payroll.view'),
+  ('Bảng lương - Cập nhật', 'payroll.update'),
+  ('Bảng lương - Duyệt', 'payroll.approve'),
+  ('Bảng lương - Xuất Excel', 'payroll.export')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'payroll.view',
+  'payroll.update',
+  'payroll.approve',
+  'payroll.export'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
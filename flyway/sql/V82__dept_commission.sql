@@ -0,0 +1,37 @@
+CREATE INDEX IF NOT EXISTS idx_orders_ref_user_id ON orders (department_id, ref_user_id)
+  WHERE ref_user_id IS NOT NULL;
+
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Hoa hồng giới thiệu - Xem', 'commission.view'),
+  ('Hoa hồng giới thiệu - Cập nhật', 'commission.update'),
+  ('Hoa hồng giới thiệu - Duyệt', 'commission.approve')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'commission.view',
+  'commission.update',
+  'commission.approve'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
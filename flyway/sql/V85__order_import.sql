@@ -0,0 +1,81 @@
+-- ============================================
+-- RBAC PERMISSIONS + ADMIN ROLE UPSERT SCRIPT
+-- ============================================
+
+-- 1. Ensure role "admin" exists
+INSERT INTO roles (role_name)
+VALUES ('admin')
+ON CONFLICT (role_name)
+DO UPDATE SET role_name = EXCLUDED.role_name;
+
+-- ============================================
+-- PERMISSIONS UPSERT
+-- ============================================
+INSERT INTO permissions (permission_name, permission_value)
+VALUES
+  ('Đơn hàng - Nhập Excel', 'order.import')
+ON CONFLICT (permission_value)
+DO UPDATE SET permission_name = EXCLUDED.permission_name;
+
+-- ============================================
+-- LINK ALL PERMISSIONS TO ADMIN ROLE
+-- ============================================
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.permission_value IN (
+  'order.import'
+)
+WHERE r.role_name = 'admin'
+ON CONFLICT DO NOTHING;
+
+-- ============================================
+-- DEFAULT ORDER IMPORT PROFILE
+-- ============================================
+-- rows sharing "Order Key" form one order; "products.*" / "materials.*"
+-- columns give one product / material line per row
+INSERT INTO import_field_profiles (scope, code, name, description, is_default, pivot_field, permission)
+VALUES (
+  'orders',
+  'default',
+  'Default Order Import',
+  'One row per product or material line, grouped by Order Key',
+  TRUE,
+  'order_key',
+  'order.import'
+)
+ON CONFLICT (scope, code) DO NOTHING;
+
+INSERT INTO import_field_mappings (
+  profile_id, internal_kind, internal_path, internal_label,
+  metadata_collection_slug, metadata_field_name, data_type,
+  excel_header, excel_column, required
+)
+SELECT p.id, m.internal_kind, m.internal_path, m.internal_label,
+       m.metadata_collection_slug, m.metadata_field_name, m.data_type,
+       m.excel_header, m.excel_column, m.required
+FROM import_field_profiles p
+CROSS JOIN (
+  VALUES
+    ('external', 'order_key',               'Order Key',       NULL,         NULL,            'text',   'Order Key',       1,  TRUE),
+    ('core',     'clinic_name',             'Clinic',          NULL,         NULL,            'text',   'Clinic',          2,  TRUE),
+    ('core',     'dentist_name',            'Dentist',         NULL,         NULL,            'text',   'Dentist',         3,  FALSE),
+    ('core',     'patient_name',            'Patient',         NULL,         NULL,            'text',   'Patient',         4,  FALSE),
+    ('metadata', 'delivery_date',           'Delivery Date',   'order-item', 'delivery_date', 'date',   'Delivery Date',   5,  FALSE),
+    ('metadata', 'priority',                'Priority',        'order-item', 'priority',      'text',   'Priority',        6,  FALSE),
+    ('core',     'products.product_code',   'Product Code',    NULL,         NULL,            'text',   'Product Code',    7,  FALSE),
+    ('core',     'products.product_name',   'Product',         NULL,         NULL,            'text',   'Product',         8,  FALSE),
+    ('core',     'products.quantity',       'Quantity',        NULL,         NULL,            'number', 'Quantity',        9,  FALSE),
+    ('core',     'products.teeth_position', 'Teeth',           NULL,         NULL,            'text',   'Teeth',           10, FALSE),
+    ('core',     'products.retail_price',   'Price',           NULL,         NULL,            'number', 'Price',           11, FALSE),
+    ('core',     'products.note',           'Product Note',    NULL,         NULL,            'text',   'Product Note',    12, FALSE),
+    ('core',     'materials.material_code', 'Material Code',   NULL,         NULL,            'text',   'Material Code',   13, FALSE),
+    ('core',     'materials.material_name', 'Material',        NULL,         NULL,            'text',   'Material',        14, FALSE),
+    ('core',     'materials.quantity',      'Material Qty',    NULL,         NULL,            'number', 'Material Qty',    15, FALSE)
+) AS m (
+  internal_kind, internal_path, internal_label,
+  metadata_collection_slug, metadata_field_name, data_type,
+  excel_header, excel_column, required
+)
+WHERE p.scope = 'orders' AND p.code = 'default'
+ON CONFLICT (profile_id, internal_kind, internal_path) DO NOTHING;
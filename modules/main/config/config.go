@@ -8,6 +8,24 @@ type ModuleConfig struct {
 	Features struct {
 		Enabled []string `mapstructure:"enabled"` // ["section","clinic"]
 	} `mapstructure:"features"`
+	Print struct {
+		FontPath     string `mapstructure:"font_path"`      // UTF-8 TTF used for PDF tickets and labels
+		FontBoldPath string `mapstructure:"font_bold_path"` // falls back to font_path
+	} `mapstructure:"print"`
+	Order struct {
+		UndoWindowMinutes int `mapstructure:"undo_window_minutes"` // how long a check-in/out can be undone, default 30
+		HoldAlertDays     int `mapstructure:"hold_alert_days"`     // tell the clinic's account owner after a hold this long, default 3
+	} `mapstructure:"order"`
+	Files struct {
+		// order item files are kept on disk like photos; uploads are also
+		// bounded by server.body_limit_mb
+		StoragePath string `mapstructure:"storage_path"`
+		MaxScanMB   int    `mapstructure:"max_scan_mb"`  // stl, ply, obj, default 100
+		MaxCadMB    int    `mapstructure:"max_cad_mb"`   // zip, dcm, default 200
+		MaxVideoMB  int    `mapstructure:"max_video_mb"` // default 200
+		// scan previews go to the photo module's storage, served by its file route
+		PhotoPath string `mapstructure:"photo_path"`
+	} `mapstructure:"files"`
 }
 
 func (c *ModuleConfig) GetServer() config.ServerConfig {
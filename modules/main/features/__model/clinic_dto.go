@@ -5,18 +5,23 @@ import (
 )
 
 type ClinicDTO struct {
-	ID           int            `json:"id,omitempty"`
-	Name         string         `json:"name,omitempty"`
-	Address      *string        `json:"address,omitempty"`
-	PhoneNumber  *string        `json:"phone_number,omitempty"`
-	Brief        *string        `json:"brief,omitempty"`
-	Logo         *string        `json:"logo,omitempty"`
-	Active       bool           `json:"active,omitempty"`
-	Dentists     []*DentistDTO  `json:"dentists,omitempty"`
-	DentistIDs   []int          `json:"dentist_ids,omitempty"`
-	Patients     []*PatientDTO  `json:"patients,omitempty"`
-	PatientIDs   []int          `json:"patient_ids,omitempty"`
-	CustomFields map[string]any `json:"custom_fields,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID          int     `json:"id,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	Address     *string `json:"address,omitempty"`
+	PhoneNumber *string `json:"phone_number,omitempty"`
+	Brief       *string `json:"brief,omitempty"`
+	Logo        *string `json:"logo,omitempty"`
+	Active      bool    `json:"active,omitempty"`
+	// staff member in charge of the clinic relationship
+	AccountOwnerID   *int           `json:"account_owner_id,omitempty"`
+	AccountOwnerName *string        `json:"account_owner_name,omitempty"`
+	Dentists         []*DentistDTO  `json:"dentists,omitempty"`
+	DentistIDs       []int          `json:"dentist_ids,omitempty"`
+	Patients         []*PatientDTO  `json:"patients,omitempty"`
+	PatientIDs       []int          `json:"patient_ids,omitempty"`
+	CustomFields     map[string]any `json:"custom_fields,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	// Receivable
+	Receivable *ClinicReceivableSummaryDTO `json:"receivable,omitempty"`
 }
@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+type CommissionRuleDTO struct {
+	ID              int       `json:"id,omitempty"`
+	DepartmentID    int       `json:"department_id,omitempty"`
+	Name            *string   `json:"name,omitempty"`
+	RefUserID       *int      `json:"ref_user_id,omitempty"`
+	ProductID       *int      `json:"product_id,omitempty"`
+	CategoryID      *int      `json:"category_id,omitempty"`
+	RateType        string    `json:"rate_type"` // percent | fixed
+	Rate            float64   `json:"rate"`
+	FirstOrderBonus float64   `json:"first_order_bonus"`
+	CapPerOrder     *float64  `json:"cap_per_order,omitempty"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at,omitempty"`
+}
+
+type CommissionSettingDTO struct {
+	Basis      string    `json:"basis"` // invoiced | paid
+	MonthlyCap *float64  `json:"monthly_cap,omitempty"`
+	UpdatedBy  *int      `json:"updated_by,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+type CommissionGenerateDTO struct {
+	// any day of the month
+	Month time.Time `json:"month"`
+	// nil = every referrer with activity in the month
+	RefUserID *int `json:"ref_user_id,omitempty"`
+}
+
+type CommissionStatementDTO struct {
+	ID           int64                 `json:"id"`
+	DepartmentID int                   `json:"department_id"`
+	RefUserID    int                   `json:"ref_user_id"`
+	RefUserName  *string               `json:"ref_user_name,omitempty"`
+	PeriodStart  time.Time             `json:"period_start"`
+	PeriodEnd    time.Time             `json:"period_end"`
+	Basis        string                `json:"basis"`
+	Status       string                `json:"status"`
+	BaseTotal    float64               `json:"base_total"`
+	Commission   float64               `json:"commission"`
+	Bonus        float64               `json:"bonus"`
+	Capped       float64               `json:"capped"`
+	Reversals    float64               `json:"reversals"`
+	Total        float64               `json:"total"`
+	GeneratedBy  *int                  `json:"generated_by,omitempty"`
+	ApprovedAt   *time.Time            `json:"approved_at,omitempty"`
+	ApprovedBy   *int                  `json:"approved_by,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	Entries      []*CommissionEntryDTO `json:"entries,omitempty"`
+}
+
+type CommissionEntryDTO struct {
+	ID              int64     `json:"id,omitempty"`
+	StatementID     int64     `json:"statement_id,omitempty"`
+	Kind            string    `json:"kind"`   // commission | bonus | reversal
+	Source          string    `json:"source"` // invoice_item | payment_allocation | order | order_item
+	SourceID        int64     `json:"source_id"`
+	OccurredAt      time.Time `json:"occurred_at"`
+	OrderID         int64     `json:"order_id"`
+	OrderCode       *string   `json:"order_code,omitempty"`
+	OrderItemID     *int64    `json:"order_item_id,omitempty"`
+	ProductID       *int      `json:"product_id,omitempty"`
+	ProductName     *string   `json:"product_name,omitempty"`
+	RuleID          *int      `json:"rule_id,omitempty"`
+	BaseAmount      float64   `json:"base_amount"`
+	Amount          float64   `json:"amount"`
+	ReversesEntryID *int64    `json:"reverses_entry_id,omitempty"`
+	Note            *string   `json:"note,omitempty"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type AtRiskItem struct {
+	ID             int64     `json:"id,omitempty"`
+	OrderItemID    int64     `json:"order_item_id,omitempty"`
+	Code           string    `json:"code,omitempty"`
+	Dentist        string    `json:"dentist,omitempty"`
+	Patient        string    `json:"patient,omitempty"`
+	DeliveryAt     time.Time `json:"delivery_at,omitempty"`
+	EstimatedAt    time.Time `json:"estimated_at,omitempty"`
+	DelayHours     float64   `json:"delay_hours"`
+	RemainingSteps int       `json:"remaining_steps"`
+	CurrentProcess string    `json:"current_process,omitempty"`
+	Priority       string    `json:"priority,omitempty"`
+	// time on hold, added to the delivery date
+	HeldHours float64 `json:"held_hours,omitempty"`
+}
@@ -11,4 +11,6 @@ type CaseDailyRemakeStatsUpsert struct {
 	CompletedAt  time.Time
 	DepartmentID int
 	IsRemake     bool
+	// takes back an earlier completion, e.g. an undone check-out
+	Revert bool
 }
@@ -11,4 +11,8 @@ type CaseDailyStatsUpsert struct {
 	ReceivedAt   time.Time
 	CompletedAt  time.Time
 	DepartmentID int
+	// time the item spent on hold is left out of the turnaround
+	OrderItemID int64
+	// takes back an earlier completion, e.g. an undone check-out
+	Revert bool
 }
@@ -0,0 +1,23 @@
+package model
+
+type ReceivableAgingBucket struct {
+	Key    string  `json:"key"` // 0_30 | 31_60 | 61_90 | 90_plus
+	Amount float64 `json:"amount"`
+	Count  int     `json:"count"`
+}
+
+type ReceivableAgingClinic struct {
+	ClinicID   int     `json:"clinic_id"`
+	ClinicName *string `json:"clinic_name,omitempty"`
+	Days0To30  float64 `json:"days_0_30"`
+	Days31To60 float64 `json:"days_31_60"`
+	Days61To90 float64 `json:"days_61_90"`
+	Days90Plus float64 `json:"days_90_plus"`
+	Total      float64 `json:"total"`
+}
+
+type ReceivableAgingResult struct {
+	Buckets []*ReceivableAgingBucket `json:"buckets"`
+	Clinics []*ReceivableAgingClinic `json:"clinics"`
+	Total   float64                  `json:"total"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// RemakeAnalysisFilter scopes the remake logs an analysis looks at. Dimension
+// and Key narrow it to one breakdown row, e.g. dimension=reason key=fit.
+type RemakeAnalysisFilter struct {
+	DepartmentID *int // nil = all departments
+	FromDate     time.Time
+	ToDate       time.Time
+	Action       *string // adjust | remake
+	Dimension    *string
+	Key          *string
+}
+
+type RemakeBreakdownResult struct {
+	GroupBy   string                `json:"group_by"`
+	Total     int                   `json:"total"`
+	TotalCost float64               `json:"total_cost"`
+	Rows      []*RemakeBreakdownRow `json:"rows"`
+}
+
+type RemakeBreakdownRow struct {
+	Key     string  `json:"key"` // "none" when the remake has no value for the dimension
+	Label   *string `json:"label,omitempty"`
+	Count   int     `json:"count"`
+	Adjusts int     `json:"adjusts"`
+	Remakes int     `json:"remakes"`
+	Cost    float64 `json:"cost"`
+	Share   float64 `json:"share"` // 0.25 = 25% of the remakes in the range
+}
+
+type RemakeTrendPoint struct {
+	Period  time.Time `json:"period"`
+	Count   int       `json:"count"`
+	Adjusts int       `json:"adjusts"`
+	Remakes int       `json:"remakes"`
+	Cost    float64   `json:"cost"`
+	Items   int       `json:"items"` // order items created in the period
+	Rate    float64   `json:"rate"`  // count / items
+}
+
+type RemakeAnalysisOrderDTO struct {
+	ID               int64     `json:"id"`
+	Action           string    `json:"action"`
+	Reason           *string   `json:"reason,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	OrderID          int64     `json:"order_id"`
+	OrderCode        *string   `json:"order_code,omitempty"`
+	OrderItemID      int64     `json:"order_item_id"`
+	OrderItemCode    *string   `json:"order_item_code,omitempty"`
+	OriginalItemID   *int64    `json:"original_item_id,omitempty"`
+	OriginalItemCode *string   `json:"original_item_code,omitempty"`
+	ClinicID         *int      `json:"clinic_id,omitempty"`
+	ClinicName       *string   `json:"clinic_name,omitempty"`
+	DentistID        *int      `json:"dentist_id,omitempty"`
+	DentistName      *string   `json:"dentist_name,omitempty"`
+	ProcessID        *int      `json:"process_id,omitempty"`
+	ProcessName      *string   `json:"process_name,omitempty"`
+	TechnicianID     *int64    `json:"technician_id,omitempty"`
+	TechnicianName   *string   `json:"technician_name,omitempty"`
+	Cost             float64   `json:"cost"`
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+type TechnicianProductivityQuery struct {
+	DepartmentID *int // nil = all departments
+	SectionID    *int
+	StaffID      *int64
+	GroupBy      string // staff | section | process | staff_process
+	FromDate     time.Time
+	ToDate       time.Time
+	PreviousFrom time.Time
+	PreviousTo   time.Time
+}
+
+type TechnicianProductivityResult struct {
+	GroupBy string                       `json:"group_by"`
+	Total   *TechnicianProductivityRow   `json:"total"`
+	Rows    []*TechnicianProductivityRow `json:"rows"`
+}
+
+// TechnicianProductivityRow aggregates the process steps completed in the
+// period. Delta fields are current minus previous period; average deltas are
+// 0 when either period has no completed step, as in AvgTurnaroundResult.
+type TechnicianProductivityRow struct {
+	Key         string  `json:"key,omitempty"`
+	StaffID     *int64  `json:"staff_id,omitempty"`
+	StaffName   *string `json:"staff_name,omitempty"`
+	SectionID   *int    `json:"section_id,omitempty"`
+	SectionName *string `json:"section_name,omitempty"`
+	ProcessID   *int    `json:"process_id,omitempty"`
+	ProcessName *string `json:"process_name,omitempty"`
+
+	Completed     int     `json:"completed"`
+	Units         int     `json:"units"` // product quantity of the items, e.g. teeth
+	AvgHandsOnSec float64 `json:"avg_hands_on_sec"`
+	AvgWaitSec    float64 `json:"avg_wait_sec"`
+	ReworkRatio   float64 `json:"rework_ratio"` // 0.05 = 5% of the steps were repeats
+
+	DeltaCompleted     int     `json:"delta_completed"`
+	DeltaUnits         int     `json:"delta_units"`
+	DeltaAvgHandsOnSec float64 `json:"delta_avg_hands_on_sec"`
+	DeltaAvgWaitSec    float64 `json:"delta_avg_wait_sec"`
+	DeltaReworkRatio   float64 `json:"delta_rework_ratio"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+)
+
+type DeliveryRunDTO struct {
+	ID           int64              `json:"id,omitempty"`
+	DepartmentID int                `json:"department_id,omitempty"`
+	Route        string             `json:"route,omitempty"`
+	CourierID    *int               `json:"courier_id,omitempty"`
+	CourierName  *string            `json:"courier_name,omitempty"`
+	Vehicle      *string            `json:"vehicle,omitempty"`
+	RunDate      time.Time          `json:"run_date"`
+	Status       string             `json:"status,omitempty"`
+	Note         *string            `json:"note,omitempty"`
+	CreatedBy    *int               `json:"created_by,omitempty"`
+	Items        []*DeliveryItemDTO `json:"items,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+type DeliveryRunUpsertDTO struct {
+	DTO DeliveryRunDTO `json:"dto"`
+}
+
+type DeliveryItemDTO struct {
+	ID            int64      `json:"id,omitempty"`
+	RunID         int64      `json:"run_id,omitempty"`
+	OrderID       int64      `json:"order_id,omitempty"`
+	OrderItemID   int64      `json:"order_item_id,omitempty"`
+	OrderItemCode *string    `json:"order_item_code,omitempty"`
+	ClinicID      *int       `json:"clinic_id,omitempty"`
+	ClinicName    *string    `json:"clinic_name,omitempty"`
+	Status        string     `json:"status,omitempty"`
+	ShippingFee   float64    `json:"shipping_fee"`
+	RecipientName *string    `json:"recipient_name,omitempty"`
+	FailureReason *string    `json:"failure_reason,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	ProofPhotoIDs []int      `json:"proof_photo_ids,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type DeliveryAssignItemDTO struct {
+	OrderItemID int64   `json:"order_item_id"`
+	ShippingFee float64 `json:"shipping_fee"`
+}
+
+type DeliveryAssignDTO struct {
+	Items []DeliveryAssignItemDTO `json:"items"`
+}
+
+type DeliveryItemStatusDTO struct {
+	Status        string   `json:"status"`
+	RecipientName *string  `json:"recipient_name,omitempty"`
+	FailureReason *string  `json:"failure_reason,omitempty"`
+	ShippingFee   *float64 `json:"shipping_fee,omitempty"`
+}
+
+type DeliveryProofDTO struct {
+	PhotoIDs []int `json:"photo_ids"`
+}
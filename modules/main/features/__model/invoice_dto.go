@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+)
+
+type InvoiceDTO struct {
+	ID            int64             `json:"id,omitempty"`
+	DepartmentID  int               `json:"department_id,omitempty"`
+	Code          string            `json:"code,omitempty"`
+	ClinicID      int               `json:"clinic_id,omitempty"`
+	ClinicName    *string           `json:"clinic_name,omitempty"`
+	PeriodStart   time.Time         `json:"period_start"`
+	PeriodEnd     time.Time         `json:"period_end"`
+	Status        string            `json:"status,omitempty"`
+	Subtotal      float64           `json:"subtotal"`
+	DiscountTotal float64           `json:"discount_total"`
+	Total         float64           `json:"total"`
+	Note          *string           `json:"note,omitempty"`
+	IssuedAt      time.Time         `json:"issued_at"`
+	IssuedBy      *int              `json:"issued_by,omitempty"`
+	VoidedAt      *time.Time        `json:"voided_at,omitempty"`
+	VoidedBy      *int              `json:"voided_by,omitempty"`
+	VoidReason    *string           `json:"void_reason,omitempty"`
+	Items         []*InvoiceItemDTO `json:"items,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type InvoiceItemDTO struct {
+	ID             int64   `json:"id,omitempty"`
+	InvoiceID      int64   `json:"invoice_id,omitempty"`
+	OrderID        int64   `json:"order_id,omitempty"`
+	OrderCode      *string `json:"order_code,omitempty"`
+	OrderItemID    int64   `json:"order_item_id,omitempty"`
+	OrderItemCode  *string `json:"order_item_code,omitempty"`
+	ProductID      int     `json:"product_id,omitempty"`
+	ProductCode    *string `json:"product_code,omitempty"`
+	ProductName    *string `json:"product_name,omitempty"`
+	TeethPosition  *string `json:"teeth_position,omitempty"`
+	Quantity       int     `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+	DiscountAmount float64 `json:"discount_amount"`
+	LineTotal      float64 `json:"line_total"`
+}
+
+type InvoiceIssueDTO struct {
+	ClinicID    int       `json:"clinic_id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Note        *string   `json:"note,omitempty"`
+}
+
+type InvoiceVoidDTO struct {
+	Reason *string `json:"reason,omitempty"`
+}
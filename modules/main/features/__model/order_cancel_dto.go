@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+type OrderCancelReasonDTO struct {
+	ID           int       `json:"id,omitempty"`
+	DepartmentID int       `json:"department_id,omitempty"`
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	DefaultFee   float64   `json:"default_fee"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// OrderCancelDTO cancels the whole order, or only OrderItemIDs when given.
+// Fee defaults to the fee of the reason.
+type OrderCancelDTO struct {
+	ReasonID     *int     `json:"reason_id,omitempty"`
+	ReasonCode   *string  `json:"reason_code,omitempty"`
+	Note         *string  `json:"note,omitempty"`
+	Fee          *float64 `json:"fee,omitempty"`
+	OrderItemIDs []int64  `json:"order_item_ids,omitempty"`
+}
+
+type OrderCancellationDTO struct {
+	ID                 int64     `json:"id"`
+	DepartmentID       int       `json:"department_id"`
+	OrderID            int64     `json:"order_id"`
+	OrderCode          *string   `json:"order_code,omitempty"`
+	Scope              string    `json:"scope"`
+	OrderItemIDs       []int64   `json:"order_item_ids,omitempty"`
+	ReasonID           *int      `json:"reason_id,omitempty"`
+	ReasonCode         string    `json:"reason_code"`
+	ReasonName         *string   `json:"reason_name,omitempty"`
+	Note               *string   `json:"note,omitempty"`
+	Fee                float64   `json:"fee"`
+	ReleasedDiscount   int       `json:"released_discount"`
+	ClosedInProgresses int       `json:"closed_in_progresses"`
+	ClosedLoaners      int       `json:"closed_loaners"`
+	CancelledBy        *int      `json:"cancelled_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	// status of the order after the cancellation
+	StatusLatest *string `json:"status_latest,omitempty"`
+}
@@ -45,9 +45,12 @@ type OrderDTO struct {
 	ProductName *string  `json:"product_name,omitempty"`
 	Quantity    *int     `json:"quantity,omitempty"`
 	TotalPrice  *float64 `json:"total_price,omitempty"`
+	ShippingFee *float64 `json:"shipping_fee,omitempty"`
 	// Remake
 	RemakeType  *string `json:"remake_type,omitempty"`
 	RemakeCount *int    `json:"remake_count,omitempty"`
+	// Invoice
+	InvoiceID *int64 `json:"invoice_id,omitempty"`
 }
 
 type OrderUpsertDTO struct {
@@ -0,0 +1,19 @@
+package model
+
+// OrderImportResultDTO reports an order sheet import: one entry per order
+// key, created or not.
+type OrderImportResultDTO struct {
+	Created int                    `json:"created"`
+	Failed  int                    `json:"failed"`
+	Orders  []*OrderImportOrderDTO `json:"orders"`
+	// rows that could not be read or grouped
+	Errors []string `json:"errors"`
+}
+
+type OrderImportOrderDTO struct {
+	Key     string   `json:"key"`
+	Rows    []int    `json:"rows"`
+	OrderID int64    `json:"order_id,omitempty"`
+	Code    *string  `json:"code,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
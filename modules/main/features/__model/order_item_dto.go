@@ -23,11 +23,15 @@ type OrderItemDTO struct {
 	LoanerMaterials     []*OrderItemMaterialDTO `json:"loaner_materials,omitempty"`
 	// processes
 	OrderItemProcesses []*OrderItemProcessDTO `json:"order_item_processes,omitempty"`
+	// current scans, CAD files and videos
+	Files []*OrderItemFileDTO `json:"files,omitempty"`
 }
 
 type OrderItemUpsertDTO struct {
 	DTO         OrderItemDTO `json:"dto"`
 	Collections *[]string    `json:"collections,omitempty"`
+	// Routing replaces the catalog routing of a new item (reorders only)
+	Routing []*OrderItemProcessDTO `json:"-"`
 }
 
 type OrderItemHistoricalDTO struct {
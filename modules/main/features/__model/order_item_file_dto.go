@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+type OrderItemFileDTO struct {
+	ID          int64          `json:"id"`
+	OrderItemID int64          `json:"order_item_id"`
+	FileURL     string         `json:"file_url"`
+	FileType    string         `json:"file_type,omitempty"` // scan_stl | scan_ply | scan_obj | cad | video
+	Description string         `json:"description,omitempty"`
+	FileName    *string        `json:"file_name,omitempty"`
+	MimeType    *string        `json:"mime_type,omitempty"`
+	Size        int64          `json:"size"`
+	Checksum    *string        `json:"checksum,omitempty"`
+	Version     int            `json:"version"`
+	PreviousID  *int64         `json:"previous_id,omitempty"`
+	ReplacedAt  *time.Time     `json:"replaced_at,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+	// previews of scans; each file is served by the photo module in the
+	// thumbnail and medium sizes
+	PreviewStatus *string             `json:"preview_status,omitempty"`
+	Previews      []map[string]string `json:"previews,omitempty"`
+	UploadedBy    *int                `json:"uploaded_by,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+type OrderItemFilePreviewRequest struct {
+	FileID int64 `json:"file_id"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+type OrderItemHoldInputDTO struct {
+	Reason           string     `json:"reason"`
+	Note             *string    `json:"note,omitempty"`
+	ExpectedResumeAt *time.Time `json:"expected_resume_at,omitempty"`
+}
+
+type OrderItemResumeDTO struct {
+	Note *string `json:"note,omitempty"`
+}
+
+type OrderItemHoldDTO struct {
+	ID               int64      `json:"id"`
+	DepartmentID     int        `json:"department_id"`
+	OrderID          int64      `json:"order_id"`
+	OrderItemID      int64      `json:"order_item_id"`
+	OrderItemCode    *string    `json:"order_item_code,omitempty"`
+	FromStatus       string     `json:"from_status"`
+	Reason           string     `json:"reason"`
+	Note             *string    `json:"note,omitempty"`
+	ExpectedResumeAt *time.Time `json:"expected_resume_at,omitempty"`
+	HeldAt           time.Time  `json:"held_at"`
+	HeldBy           *int       `json:"held_by,omitempty"`
+	ResumedAt        *time.Time `json:"resumed_at,omitempty"`
+	ResumedBy        *int       `json:"resumed_by,omitempty"`
+	ResumeNote       *string    `json:"resume_note,omitempty"`
+	AlertedAt        *time.Time `json:"alerted_at,omitempty"`
+	// time spent on hold so far
+	HeldHours float64 `json:"held_hours"`
+	// status of the order item after the hold or resume
+	Status *string `json:"status,omitempty"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// OrderItemSplitDTO moves the given product and material rows of an order
+// item to a new child item of the same order.
+type OrderItemSplitDTO struct {
+	OrderItemProductIDs  []int   `json:"order_item_product_ids"`
+	OrderItemMaterialIDs []int   `json:"order_item_material_ids,omitempty"`
+	Note                 *string `json:"note,omitempty"`
+}
+
+// OrderItemMergeDTO moves every product and material row of the source item
+// into the item of the route; the emptied source item is removed.
+type OrderItemMergeDTO struct {
+	SourceOrderItemID int64   `json:"source_order_item_id"`
+	Note              *string `json:"note,omitempty"`
+}
+
+type OrderItemLineageDTO struct {
+	ID                   int64     `json:"id"`
+	DepartmentID         int       `json:"department_id"`
+	Kind                 string    `json:"kind"`
+	SourceOrderID        int64     `json:"source_order_id"`
+	SourceOrderItemID    int64     `json:"source_order_item_id"`
+	SourceCode           *string   `json:"source_code,omitempty"`
+	TargetOrderID        int64     `json:"target_order_id"`
+	TargetOrderItemID    int64     `json:"target_order_item_id"`
+	TargetCode           *string   `json:"target_code,omitempty"`
+	OrderItemProductIDs  []int     `json:"order_item_product_ids,omitempty"`
+	OrderItemMaterialIDs []int     `json:"order_item_material_ids,omitempty"`
+	Note                 *string   `json:"note,omitempty"`
+	CreatedBy            *int      `json:"created_by,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	// whether the process rows of the item were rebuilt from its products
+	SourceProcessesRebuilt bool `json:"source_processes_rebuilt"`
+	TargetProcessesRebuilt bool `json:"target_processes_rebuilt"`
+	// the merge emptied and removed the source order
+	SourceOrderRemoved bool `json:"source_order_removed"`
+}
@@ -0,0 +1,24 @@
+package model
+
+type OrderItemProcessBatchScanDTO struct {
+	Codes  []string `json:"codes"`
+	Action string   `json:"action"` // check_in | check_out
+	Note   *string  `json:"note,omitempty"`
+	// check-in only: hands the scanned items to one technician
+	AssignedID   *int64  `json:"assigned_id,omitempty"`
+	AssignedName *string `json:"assigned_name,omitempty"`
+}
+
+type OrderItemProcessBatchScanItemDTO struct {
+	Code       string                         `json:"code"`
+	Success    bool                           `json:"success"`
+	Error      *string                        `json:"error,omitempty"`
+	InProgress *OrderItemProcessInProgressDTO `json:"in_progress,omitempty"`
+}
+
+type OrderItemProcessBatchScanResultDTO struct {
+	Action    string                              `json:"action"`
+	Succeeded int                                 `json:"succeeded"`
+	Failed    int                                 `json:"failed"`
+	Items     []*OrderItemProcessBatchScanItemDTO `json:"items"`
+}
@@ -7,8 +7,10 @@ type OrderItemProcessDTO struct {
 	OrderID      *int64     `json:"order_id,omitempty"`
 	OrderItemID  int64      `json:"order_item_id,omitempty"`
 	OrderCode    *string    `json:"order_code,omitempty"`
+	ProcessID    *int       `json:"process_id,omitempty"`
 	ProcessName  *string    `json:"process_name,omitempty"`
 	StepNumber   int        `json:"step_number,omitempty"`
+	DependsOn    []int      `json:"depends_on,omitempty"`
 	StartedAt    *time.Time `json:"started_at,omitempty"`
 	CompletedAt  *time.Time `json:"completed_at,omitempty"`
 	Note         *string    `json:"note,omitempty"`
@@ -23,5 +23,26 @@ type OrderItemProcessInProgressDTO struct {
 	AssignedName    *string    `json:"assigned_name,omitempty"`
 	StartedAt       *time.Time `json:"started_at,omitempty"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	SLAStatus       *string    `json:"sla_status,omitempty"`
 	UpdatedAt       time.Time  `json:"updated_at,omitempty"`
 }
+
+type OrderItemProcessUndoDTO struct {
+	Action        string                         `json:"action"` // check_in | check_out | assign, the transition that was reverted
+	InProgressID  int64                          `json:"in_progress_id"`
+	OrderItemID   int64                          `json:"order_item_id"`
+	OrderID       *int64                         `json:"order_id,omitempty"`
+	OrderItemCode *string                        `json:"order_item_code,omitempty"`
+	ProcessID     *int64                         `json:"process_id,omitempty"`
+	ProcessName   *string                        `json:"process_name,omitempty"`
+	TransitionAt  time.Time                      `json:"transition_at"`
+	Restored      *OrderItemProcessInProgressDTO `json:"restored,omitempty"`
+}
+
+// OrderItemProcessOptionsDTO lists every transition currently possible for an
+// order item: running steps that can be checked out and unblocked steps that
+// can be checked in.
+type OrderItemProcessOptionsDTO struct {
+	CheckOut []*OrderItemProcessInProgressDTO `json:"check_out"`
+	CheckIn  []*OrderItemProcessInProgressDTO `json:"check_in"`
+}
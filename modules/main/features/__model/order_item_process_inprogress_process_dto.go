@@ -13,6 +13,7 @@ type OrderItemProcessInProgressAndProcessDTO struct {
 	AssignedName  *string    `json:"assigned_name,omitempty"`
 	StartedAt     *time.Time `json:"started_at,omitempty"`
 	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	SLAStatus     *string    `json:"sla_status,omitempty"`
 	ProcessName   *string    `json:"process_name,omitempty"`
 	SectionID     *int       `json:"section_id,omitempty"`
 	SectionName   *string    `json:"section_name,omitempty"`
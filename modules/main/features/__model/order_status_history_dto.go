@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type OrderStatusHistoryDTO struct {
+	ID          int64     `json:"id"`
+	Entity      string    `json:"entity"` // order | order_item | order_item_process
+	EntityID    int64     `json:"entity_id"`
+	OrderID     *int64    `json:"order_id,omitempty"`
+	OrderItemID *int64    `json:"order_item_id,omitempty"`
+	FromStatus  *string   `json:"from_status,omitempty"`
+	ToStatus    string    `json:"to_status"`
+	ChangedBy   *int      `json:"changed_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
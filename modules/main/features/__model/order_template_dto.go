@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// OrderTemplateContentDTO is what a new order copies from a previous case or
+// from a template. Processes keep only the routing (process, step, section,
+// leader, dependencies and skipped steps); progress is never carried over.
+type OrderTemplateContentDTO struct {
+	CustomFields        map[string]any          `json:"custom_fields,omitempty"`
+	ItemCustomFields    map[string]any          `json:"item_custom_fields,omitempty"`
+	Products            []*OrderItemProductDTO  `json:"products,omitempty"`
+	ConsumableMaterials []*OrderItemMaterialDTO `json:"consumable_materials,omitempty"`
+	LoanerMaterials     []*OrderItemMaterialDTO `json:"loaner_materials,omitempty"`
+	Processes           []*OrderItemProcessDTO  `json:"processes,omitempty"`
+}
+
+type OrderTemplateDTO struct {
+	ID           int                     `json:"id,omitempty"`
+	DepartmentID int                     `json:"department_id,omitempty"`
+	Name         string                  `json:"name"`
+	ClinicID     *int                    `json:"clinic_id,omitempty"`
+	ClinicName   *string                 `json:"clinic_name,omitempty"`
+	DentistID    *int                    `json:"dentist_id,omitempty"`
+	DentistName  *string                 `json:"dentist_name,omitempty"`
+	Content      OrderTemplateContentDTO `json:"content"`
+	// when set on create, content is taken from this order item
+	SourceOrderItemID *int64    `json:"source_order_item_id,omitempty"`
+	CreatedBy         *int      `json:"created_by,omitempty"`
+	CreatedAt         time.Time `json:"created_at,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at,omitempty"`
+}
+
+// OrderReorderDTO overrides what a new order takes from its source. Custom
+// fields are merged over the copied ones.
+type OrderReorderDTO struct {
+	ClinicID         *int           `json:"clinic_id,omitempty"`
+	ClinicName       *string        `json:"clinic_name,omitempty"`
+	DentistID        *int           `json:"dentist_id,omitempty"`
+	DentistName      *string        `json:"dentist_name,omitempty"`
+	PatientID        *int           `json:"patient_id,omitempty"`
+	PatientName      *string        `json:"patient_name,omitempty"`
+	RefUserID        *int           `json:"ref_user_id,omitempty"`
+	RefUserName      *string        `json:"ref_user_name,omitempty"`
+	DeliveryDate     *time.Time     `json:"delivery_date,omitempty"`
+	CustomFields     map[string]any `json:"custom_fields,omitempty"`
+	ItemCustomFields map[string]any `json:"item_custom_fields,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+type OrderTimelineEventDTO struct {
+	Type          string         `json:"type"` // check_in | check_out | assign | status_change | remake | adjust | price_sync | promotion | file_upload | custom_field_edit | undo
+	At            time.Time      `json:"at"`
+	ActorID       *int64         `json:"actor_id,omitempty"`
+	ActorName     *string        `json:"actor_name,omitempty"`
+	OrderItemID   *int64         `json:"order_item_id,omitempty"`
+	OrderItemCode *string        `json:"order_item_code,omitempty"`
+	ProcessID     *int64         `json:"process_id,omitempty"`
+	ProcessName   *string        `json:"process_name,omitempty"`
+	Data          map[string]any `json:"data,omitempty"`
+}
+
+type OrderTimelineQuery struct {
+	Types  []string
+	Limit  int
+	Offset int
+}
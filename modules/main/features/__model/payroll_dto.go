@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+type PieceRateDTO struct {
+	ID                  int       `json:"id,omitempty"`
+	DepartmentID        int       `json:"department_id,omitempty"`
+	ProcessID           int       `json:"process_id"`
+	ProcessName         *string   `json:"process_name,omitempty"`
+	ProductID           *int      `json:"product_id,omitempty"`
+	ProductName         *string   `json:"product_name,omitempty"`
+	RestorationTypeID   *int      `json:"restoration_type_id,omitempty"`
+	RestorationTypeName *string   `json:"restoration_type_name,omitempty"`
+	Rate                float64   `json:"rate"`
+	Active              bool      `json:"active"`
+	CreatedAt           time.Time `json:"created_at,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at,omitempty"`
+}
+
+type PayrollPolicyDTO struct {
+	RemakeDeduction      string    `json:"remake_deduction"` // none | percent | fixed
+	RemakeDeductionValue float64   `json:"remake_deduction_value"`
+	RemakeAttribution    string    `json:"remake_attribution"` // last | all
+	RemakeWindowDays     int       `json:"remake_window_days"`
+	IncludeAdjust        bool      `json:"include_adjust"`
+	UpdatedBy            *int      `json:"updated_by,omitempty"`
+	UpdatedAt            time.Time `json:"updated_at,omitempty"`
+}
+
+type PayrollGenerateDTO struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	// nil = every technician with completed steps or remakes in the period
+	StaffID *int64 `json:"staff_id,omitempty"`
+}
+
+type PayrollStatementDTO struct {
+	ID           int64                      `json:"id"`
+	DepartmentID int                        `json:"department_id"`
+	StaffID      int64                      `json:"staff_id"`
+	StaffName    *string                    `json:"staff_name,omitempty"`
+	PeriodStart  time.Time                  `json:"period_start"`
+	PeriodEnd    time.Time                  `json:"period_end"`
+	Status       string                     `json:"status"`
+	Units        int                        `json:"units"`
+	Gross        float64                    `json:"gross"`
+	Deductions   float64                    `json:"deductions"`
+	Net          float64                    `json:"net"`
+	GeneratedBy  *int                       `json:"generated_by,omitempty"`
+	ApprovedAt   *time.Time                 `json:"approved_at,omitempty"`
+	ApprovedBy   *int                       `json:"approved_by,omitempty"`
+	CreatedAt    time.Time                  `json:"created_at"`
+	UpdatedAt    time.Time                  `json:"updated_at"`
+	Lines        []*PayrollStatementLineDTO `json:"lines,omitempty"`
+}
+
+type PayrollStatementLineDTO struct {
+	ID            int64     `json:"id,omitempty"`
+	StatementID   int64     `json:"statement_id,omitempty"`
+	Kind          string    `json:"kind"` // earning | deduction
+	InProgressID  int64     `json:"in_progress_id"`
+	CompletedAt   time.Time `json:"completed_at"`
+	OrderID       int64     `json:"order_id"`
+	OrderCode     *string   `json:"order_code,omitempty"`
+	OrderItemID   int64     `json:"order_item_id"`
+	OrderItemCode *string   `json:"order_item_code,omitempty"`
+	ProcessID     *int      `json:"process_id,omitempty"`
+	ProcessName   *string   `json:"process_name,omitempty"`
+	ProductID     *int      `json:"product_id,omitempty"`
+	ProductName   *string   `json:"product_name,omitempty"`
+	Units         int       `json:"units"`
+	Rate          float64   `json:"rate"`
+	Amount        float64   `json:"amount"`
+	RemakeLogID   *int64    `json:"remake_log_id,omitempty"`
+	Note          *string   `json:"note,omitempty"`
+}
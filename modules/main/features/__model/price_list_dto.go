@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+type PriceListDTO struct {
+	ID            int                 `json:"id,omitempty"`
+	DepartmentID  int                 `json:"department_id,omitempty"`
+	Name          string              `json:"name"`
+	ClinicID      *int                `json:"clinic_id,omitempty"`
+	ClinicName    *string             `json:"clinic_name,omitempty"`
+	DentistID     *int                `json:"dentist_id,omitempty"`
+	DentistName   *string             `json:"dentist_name,omitempty"`
+	EffectiveFrom time.Time           `json:"effective_from"`
+	EffectiveTo   *time.Time          `json:"effective_to,omitempty"`
+	Active        bool                `json:"active"`
+	Note          *string             `json:"note,omitempty"`
+	Items         []*PriceListItemDTO `json:"items,omitempty"`
+	// time
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PriceListItemDTO struct {
+	ID          int     `json:"id,omitempty"`
+	PriceListID int     `json:"price_list_id,omitempty"`
+	ProductID   int     `json:"product_id"`
+	ProductCode *string `json:"product_code,omitempty"`
+	ProductName *string `json:"product_name,omitempty"`
+	MinQuantity int     `json:"min_quantity"`
+	Price       float64 `json:"price"`
+}
+
+type PriceListUpsertDTO struct {
+	DTO PriceListDTO `json:"dto"`
+}
+
+type ResolvedPriceDTO struct {
+	ProductID   int     `json:"product_id"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price"`
+	PriceListID *int    `json:"price_list_id,omitempty"`
+	Source      string  `json:"source"` // price_list | base
+}
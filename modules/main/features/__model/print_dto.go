@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type PrintTicketDTO struct {
+	OrderID       int64                  `json:"order_id"`
+	OrderCode     *string                `json:"order_code,omitempty"`
+	OrderItemID   int64                  `json:"order_item_id"`
+	OrderItemCode string                 `json:"order_item_code"`
+	ClinicName    *string                `json:"clinic_name,omitempty"`
+	DentistName   *string                `json:"dentist_name,omitempty"`
+	PatientName   *string                `json:"patient_name,omitempty"`
+	DeliveryDate  *time.Time             `json:"delivery_date,omitempty"`
+	Priority      *string                `json:"priority,omitempty"`
+	RemakeCount   int                    `json:"remake_count,omitempty"`
+	Products      []*OrderItemProductDTO `json:"products,omitempty"`
+	Processes     []*OrderItemProcessDTO `json:"processes,omitempty"`
+}
+
+type PrintBatchDTO struct {
+	OrderItemIDs []int64 `json:"order_item_ids"`
+}
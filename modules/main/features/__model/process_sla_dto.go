@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type ProcessSLADTO struct {
+	ID              int       `json:"id,omitempty"`
+	ProcessID       int       `json:"process_id,omitempty"`
+	ProductID       *int      `json:"product_id,omitempty"`
+	ProductName     *string   `json:"product_name,omitempty"`
+	StandardMinutes int       `json:"standard_minutes"`
+	WarningMinutes  int       `json:"warning_minutes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ProcessSLABreachDTO struct {
+	InProgressID    int64      `json:"in_progress_id"`
+	OrderID         *int64     `json:"order_id,omitempty"`
+	OrderItemID     int64      `json:"order_item_id"`
+	OrderItemCode   *string    `json:"order_item_code,omitempty"`
+	ProcessName     *string    `json:"process_name,omitempty"`
+	SectionName     *string    `json:"section_name,omitempty"`
+	LeaderID        *int       `json:"leader_id,omitempty"`
+	LeaderName      *string    `json:"leader_name,omitempty"`
+	AssignedID      *int64     `json:"assigned_id,omitempty"`
+	AssignedName    *string    `json:"assigned_name,omitempty"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	SLAStatus       string     `json:"sla_status"`
+	ElapsedMinutes  int        `json:"elapsed_minutes"`
+	StandardMinutes int        `json:"standard_minutes"`
+}
@@ -0,0 +1,23 @@
+package model
+
+type ProductPriceItemDTO struct {
+	ProductID       int     `json:"product_id"`
+	Quantity        int     `json:"quantity"`
+	PreOrderPercent float64 `json:"pre_order_percent"`
+}
+
+type ProductPriceTotalRequestDTO struct {
+	Items []ProductPriceItemDTO `json:"items"`
+	// the clinic and dentist whose price lists apply, if any
+	ClinicID  *int `json:"clinic_id,omitempty"`
+	DentistID *int `json:"dentist_id,omitempty"`
+}
+
+type ProductPriceTotalDTO struct {
+	TotalPrice float64 `json:"total_price"`
+}
+
+type ProductPriceDTO struct {
+	ProductID int     `json:"product_id"`
+	Price     float64 `json:"price"`
+}
@@ -0,0 +1,14 @@
+package model
+
+type ProductProcessStepDTO struct {
+	ProcessID    int            `json:"process_id"`
+	ProcessName  *string        `json:"process_name,omitempty"`
+	DisplayOrder *int           `json:"display_order,omitempty"`
+	DependsOn    []int          `json:"depends_on"`
+	Condition    map[string]any `json:"condition,omitempty"`
+}
+
+type ProductProcessGraphDTO struct {
+	ProductID int                      `json:"product_id"`
+	Steps     []*ProductProcessStepDTO `json:"steps"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+)
+
+type ClinicPaymentDTO struct {
+	ID              int64                         `json:"id,omitempty"`
+	DepartmentID    int                           `json:"department_id,omitempty"`
+	ClinicID        int                           `json:"clinic_id,omitempty"`
+	ClinicName      *string                       `json:"clinic_name,omitempty"`
+	Method          string                        `json:"method,omitempty"`
+	Amount          float64                       `json:"amount"`
+	ReceivedAt      time.Time                     `json:"received_at"`
+	Reference       *string                       `json:"reference,omitempty"`
+	Note            *string                       `json:"note,omitempty"`
+	CreatedBy       *int                          `json:"created_by,omitempty"`
+	AllocatedAmount float64                       `json:"allocated_amount"`
+	UnappliedAmount float64                       `json:"unapplied_amount"`
+	Allocations     []*ClinicPaymentAllocationDTO `json:"allocations,omitempty"`
+	CreatedAt       time.Time                     `json:"created_at"`
+	UpdatedAt       time.Time                     `json:"updated_at"`
+}
+
+type ClinicPaymentAllocationDTO struct {
+	ID        int64   `json:"id,omitempty"`
+	PaymentID int64   `json:"payment_id,omitempty"`
+	OrderID   *int64  `json:"order_id,omitempty"`
+	InvoiceID *int64  `json:"invoice_id,omitempty"`
+	Amount    float64 `json:"amount"`
+}
+
+type ClinicPaymentUpsertDTO struct {
+	DTO ClinicPaymentDTO `json:"dto"`
+	// allocate to the oldest open documents when no allocation is given
+	AutoAllocate bool `json:"auto_allocate,omitempty"`
+}
+
+type ReceivableDocumentDTO struct {
+	DocType     string    `json:"doc_type"` // invoice | order
+	DocID       int64     `json:"doc_id"`
+	DocCode     *string   `json:"doc_code,omitempty"`
+	DocDate     time.Time `json:"doc_date"`
+	Amount      float64   `json:"amount"`
+	Paid        float64   `json:"paid"`
+	Outstanding float64   `json:"outstanding"`
+	AgeDays     int       `json:"age_days"`
+}
+
+type ReceivableLedgerEntryDTO struct {
+	EntryDate time.Time `json:"entry_date"`
+	EntryType string    `json:"entry_type"` // invoice | order | cash | bank_transfer | credit_note
+	RefID     int64     `json:"ref_id"`
+	RefCode   *string   `json:"ref_code,omitempty"`
+	Debit     float64   `json:"debit"`
+	Credit    float64   `json:"credit"`
+	Balance   float64   `json:"balance"`
+}
+
+type ReceivableLedgerDTO struct {
+	ClinicID       int                         `json:"clinic_id"`
+	OpeningBalance float64                     `json:"opening_balance"`
+	ClosingBalance float64                     `json:"closing_balance"`
+	Entries        []*ReceivableLedgerEntryDTO `json:"entries"`
+}
+
+type ClinicReceivableSummaryDTO struct {
+	Outstanding     float64 `json:"outstanding"`
+	Overdue         float64 `json:"overdue"`
+	UnappliedCredit float64 `json:"unapplied_credit"`
+	Balance         float64 `json:"balance"`
+	PaymentTermDays int     `json:"payment_term_days"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "github.com/khiemnd777/andy_api/shared/toothchart"
+
+type ToothChartPreviewDTO struct {
+	Chart     *toothchart.Chart `json:"chart"`
+	Quantity  int               `json:"quantity"`
+	FDI       string            `json:"fdi"`
+	Universal string            `json:"universal"`
+}
@@ -6,10 +6,12 @@ import (
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
 	"github.com/khiemnd777/andy_api/modules/main/features/clinic/service"
+	receivableservice "github.com/khiemnd777/andy_api/modules/main/features/receivable/service"
 	"github.com/khiemnd777/andy_api/shared/app"
 	"github.com/khiemnd777/andy_api/shared/app/client_error"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
+	"github.com/khiemnd777/andy_api/shared/logger"
 	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
 	"github.com/khiemnd777/andy_api/shared/module"
 	"github.com/khiemnd777/andy_api/shared/utils"
@@ -17,12 +19,13 @@ import (
 )
 
 type ClinicHandler struct {
-	svc  service.ClinicService
-	deps *module.ModuleDeps[config.ModuleConfig]
+	svc           service.ClinicService
+	receivableSvc receivableservice.ReceivableService
+	deps          *module.ModuleDeps[config.ModuleConfig]
 }
 
-func NewClinicHandler(svc service.ClinicService, deps *module.ModuleDeps[config.ModuleConfig]) *ClinicHandler {
-	return &ClinicHandler{svc: svc, deps: deps}
+func NewClinicHandler(svc service.ClinicService, receivableSvc receivableservice.ReceivableService, deps *module.ModuleDeps[config.ModuleConfig]) *ClinicHandler {
+	return &ClinicHandler{svc: svc, receivableSvc: receivableSvc, deps: deps}
 }
 
 func (h *ClinicHandler) RegisterRoutes(router fiber.Router) {
@@ -99,7 +102,21 @@ func (h *ClinicHandler) GetByID(c *fiber.Ctx) error {
 	if err != nil {
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
-	return c.Status(fiber.StatusOK).JSON(dto)
+
+	// overdue balance, so sales staff see it before accepting new orders; the
+	// clinic is still returned when the summary cannot be computed
+	out := *dto
+	if perms, _ := utils.GetPermSetFromClaims(c); rbac.HasAnyPerm(perms, "receivable.view") {
+		deptID, _ := utils.GetDeptIDInt(c)
+		receivable, err := h.receivableSvc.Summary(c.UserContext(), deptID, id)
+		if err != nil {
+			logger.Error("clinic.receivable.summary", "clinic_id", id, "err", err)
+		} else {
+			out.Receivable = receivable
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(out)
 }
 
 func (h *ClinicHandler) Create(c *fiber.Ctx) error {
@@ -6,6 +6,8 @@ import (
 	"github.com/khiemnd777/andy_api/modules/main/features/clinic/handler"
 	"github.com/khiemnd777/andy_api/modules/main/features/clinic/repository"
 	"github.com/khiemnd777/andy_api/modules/main/features/clinic/service"
+	receivablerepo "github.com/khiemnd777/andy_api/modules/main/features/receivable/repository"
+	receivableservice "github.com/khiemnd777/andy_api/modules/main/features/receivable/service"
 	"github.com/khiemnd777/andy_api/modules/main/registry"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
@@ -18,9 +20,14 @@ func (feature) ID() string    { return "clinic" }
 func (feature) Priority() int { return 60 }
 
 func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
-	repo := repository.NewClinicRepository(deps.Ent.(*generated.Client), deps, cfMgr)
+	entClient := deps.Ent.(*generated.Client)
+	repo := repository.NewClinicRepository(entClient, deps, cfMgr)
 	svc := service.NewClinicService(repo, deps, cfMgr)
-	h := handler.NewClinicHandler(svc, deps)
+
+	receivableRepo := receivablerepo.NewReceivableRepository(entClient, deps)
+	receivableSvc := receivableservice.NewReceivableService(receivableRepo, deps)
+
+	h := handler.NewClinicHandler(svc, receivableSvc, deps)
 	h.RegisterRoutes(router)
 	return nil
 }
@@ -59,7 +59,9 @@ func (r *clinicRepo) Create(ctx context.Context, input model.ClinicDTO) (*model.
 		SetNillableAddress(input.Address).
 		SetNillablePhoneNumber(input.PhoneNumber).
 		SetNillableBrief(input.Brief).
-		SetNillableLogo(input.Logo)
+		SetNillableLogo(input.Logo).
+		SetNillableAccountOwnerID(input.AccountOwnerID).
+		SetNillableAccountOwnerName(input.AccountOwnerName)
 
 	// customfields
 	_, err = customfields.PrepareCustomFields(ctx,
@@ -134,7 +136,9 @@ func (r *clinicRepo) Update(ctx context.Context, input model.ClinicDTO) (*model.
 		SetNillableAddress(input.Address).
 		SetNillablePhoneNumber(input.PhoneNumber).
 		SetNillableBrief(input.Brief).
-		SetNillableLogo(input.Logo)
+		SetNillableLogo(input.Logo).
+		SetNillableAccountOwnerID(input.AccountOwnerID).
+		SetNillableAccountOwnerName(input.AccountOwnerName)
 
 	// customfields
 	_, err = customfields.PrepareCustomFields(ctx,
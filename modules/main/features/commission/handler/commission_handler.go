@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type CommissionHandler struct {
+	svc  service.CommissionService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewCommissionHandler(svc service.CommissionService, deps *module.ModuleDeps[config.ModuleConfig]) *CommissionHandler {
+	return &CommissionHandler{svc: svc, deps: deps}
+}
+
+func (h *CommissionHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/commission/rules", h.ListRules)
+	app.RouterPost(router, "/:dept_id<int>/commission/rules", h.CreateRule)
+	app.RouterPut(router, "/:dept_id<int>/commission/rules/:id<int>", h.UpdateRule)
+	app.RouterDelete(router, "/:dept_id<int>/commission/rules/:id<int>", h.DeleteRule)
+
+	app.RouterGet(router, "/:dept_id<int>/commission/setting", h.GetSetting)
+	app.RouterPut(router, "/:dept_id<int>/commission/setting", h.SaveSetting)
+
+	app.RouterGet(router, "/:dept_id<int>/commission/statements", h.List)
+	app.RouterPost(router, "/:dept_id<int>/commission/statements/generate", h.Generate)
+	app.RouterGet(router, "/:dept_id<int>/commission/statements/:id<int>", h.GetByID)
+	app.RouterPost(router, "/:dept_id<int>/commission/statements/:id<int>/approve", h.Approve)
+}
+
+func (h *CommissionHandler) ListRules(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	refUserID, err := utils.GetQueryAsNillableInt(c, "ref_user_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid ref_user_id")
+	}
+	res, err := h.svc.ListRules(c.UserContext(), deptID, refUserID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *CommissionHandler) CreateRule(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.CommissionRuleDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.CreateRule(c.UserContext(), deptID, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRule) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *CommissionHandler) UpdateRule(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.CommissionRuleDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.UpdateRule(c.UserContext(), deptID, id, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRule) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "commission rule not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *CommissionHandler) DeleteRule(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	if err := h.svc.DeleteRule(c.UserContext(), deptID, id); err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "commission rule not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *CommissionHandler) GetSetting(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.GetSetting(c.UserContext(), deptID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *CommissionHandler) SaveSetting(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.CommissionSettingDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+	dto, err := h.svc.SaveSetting(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSetting) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *CommissionHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := table.ParseTableQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	refUserID, err := utils.GetQueryAsNillableInt(c, "ref_user_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid ref_user_id")
+	}
+	month, err := utils.ParseNillableDate(utils.GetQueryAsString(c, "month"))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid month")
+	}
+
+	res, err := h.svc.List(c.UserContext(), deptID, refUserID, month, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *CommissionHandler) Generate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.CommissionGenerateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	res, err := h.svc.Generate(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMonth):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrNothingToGenerate), errors.Is(err, repository.ErrStatementLocked):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *CommissionHandler) GetByID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "commission statement not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *CommissionHandler) Approve(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "commission.approve"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Approve(c.UserContext(), deptID, int64(id), userID)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "commission statement not found")
+		}
+		if errors.Is(err, repository.ErrStatementLocked) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
@@ -0,0 +1,31 @@
+package commission
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "commission" }
+func (feature) Priority() int { return 75 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	entClient := deps.Ent.(*generated.Client)
+	ruleRepo := repository.NewCommissionRuleRepository(entClient, deps)
+	repo := repository.NewCommissionRepository(entClient, deps.DB, deps)
+	svc := service.NewCommissionService(ruleRepo, repo, deps)
+	h := handler.NewCommissionHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
@@ -0,0 +1,628 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/commissionentry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/commissionsetting"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/commissionstatement"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+const (
+	StatementStatusDraft    = "draft"
+	StatementStatusApproved = "approved"
+
+	BasisInvoiced = "invoiced"
+	BasisPaid     = "paid"
+)
+
+var ErrStatementLocked = errors.New("commission statement is approved and locked")
+
+// SaleRow is the net amount of one invoiced product of a referred order,
+// or its share of a payment on the paid basis.
+type SaleRow struct {
+	Source      string
+	SourceID    int64
+	OccurredAt  time.Time
+	RefUserID   int
+	RefUserName *string
+	OrderID     int64
+	OrderCode   *string
+	OrderItemID int64
+	ProductID   int
+	ProductName *string
+	CategoryIDs []int64
+	Quantity    float64
+	BaseAmount  float64
+	// the first order of its clinic whose bonus has not been paid yet
+	FirstOrder bool
+}
+
+// ReversalRow is an earlier commission or bonus whose order has since been
+// cancelled or whose item has been remade for free.
+type ReversalRow struct {
+	EntryID     int64
+	RefUserID   int
+	RefUserName *string
+	OrderID     int64
+	OrderCode   *string
+	OrderItemID *int64
+	ProductID   *int
+	ProductName *string
+	Amount      float64
+	Reason      string // order_cancelled | free_remake
+	OccurredAt  time.Time
+}
+
+type CommissionRepository interface {
+	GetSetting(ctx context.Context, deptID int) (*model.CommissionSettingDTO, error)
+	SaveSetting(ctx context.Context, deptID, userID int, input *model.CommissionSettingDTO) (*model.CommissionSettingDTO, error)
+
+	SaleRows(ctx context.Context, deptID int, basis string, from, to time.Time, refUserID *int) ([]*SaleRow, error)
+	ReversalRows(ctx context.Context, deptID int, from, to time.Time, refUserID *int) ([]*ReversalRow, error)
+
+	// SaveStatements replaces the draft statements of the month in scope;
+	// approved statements are kept as they are.
+	SaveStatements(ctx context.Context, deptID, userID int, from, to time.Time, refUserID *int, statements []*model.CommissionStatementDTO) error
+	Approve(ctx context.Context, deptID int, id int64, userID int) (*model.CommissionStatementDTO, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.CommissionStatementDTO, error)
+	List(ctx context.Context, deptID int, refUserID *int, periodStart *time.Time, query table.TableQuery) (table.TableListResult[model.CommissionStatementDTO], error)
+	ListWithEntries(ctx context.Context, deptID int, periodStart time.Time) ([]*model.CommissionStatementDTO, error)
+}
+
+type commissionRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewCommissionRepository(db *generated.Client, sqlDB *sql.DB, deps *module.ModuleDeps[config.ModuleConfig]) CommissionRepository {
+	return &commissionRepository{db: db, sqlDB: sqlDB, deps: deps}
+}
+
+func (r *commissionRepository) GetSetting(ctx context.Context, deptID int) (*model.CommissionSettingDTO, error) {
+	entity, err := r.db.CommissionSetting.Query().
+		Where(commissionsetting.DepartmentIDEQ(deptID)).
+		Only(ctx)
+	if generated.IsNotFound(err) {
+		return &model.CommissionSettingDTO{Basis: BasisInvoiced}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.CommissionSetting, *model.CommissionSettingDTO](entity), nil
+}
+
+func (r *commissionRepository) SaveSetting(ctx context.Context, deptID, userID int, input *model.CommissionSettingDTO) (*model.CommissionSettingDTO, error) {
+	up := r.db.CommissionSetting.Update().
+		Where(commissionsetting.DepartmentIDEQ(deptID)).
+		SetBasis(input.Basis).
+		SetUpdatedBy(userID)
+	if input.MonthlyCap != nil {
+		up.SetMonthlyCap(*input.MonthlyCap)
+	} else {
+		up.ClearMonthlyCap()
+	}
+	n, err := up.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		err = r.db.CommissionSetting.Create().
+			SetDepartmentID(deptID).
+			SetBasis(input.Basis).
+			SetNillableMonthlyCap(input.MonthlyCap).
+			SetUpdatedBy(userID).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.GetSetting(ctx, deptID)
+}
+
+// Drafts of the month being generated are replaced, so the entries they
+// hold must not count as paid or reversed already. $1 department, $2 month.
+const regeneratedStatementSQL = `(cs.department_id = $1 AND cs.status = 'draft' AND cs.period_start = $2)`
+
+// Orders deleted or cancelled before $3, the end of the month.
+const orderCancelledSQL = `(
+  (o.deleted_at IS NOT NULL AND o.deleted_at < $3)
+  OR EXISTS (
+    SELECT 1 FROM order_status_histories h
+    WHERE h.entity = 'order' AND h.entity_id = o.id AND h.to_status = 'cancelled' AND h.created_at < $3
+  )
+)`
+
+// $1 department, $2..$3 month, $4 referrer
+const saleSelectSQL = `
+SELECT
+  s.source,
+  s.source_id,
+  s.occurred_at,
+  o.ref_user_id,
+  o.ref_user_name,
+  o.id,
+  o.code,
+  ii.order_item_id,
+  ii.product_id,
+  ii.product_name,
+  COALESCE((SELECT array_agg(cp.category_id) FROM category_products cp WHERE cp.product_id = ii.product_id), '{}'),
+  ii.quantity * s.share,
+  ii.line_total * s.share,
+  (
+    (o.clinic_id IS NOT NULL OR o.customer_id IS NOT NULL)
+    AND NOT EXISTS (
+      SELECT 1 FROM orders o2
+      WHERE
+        o2.department_id = o.department_id
+        AND o2.deleted_at IS NULL
+        AND o2.id <> o.id
+        AND (o2.created_at, o2.id) < (o.created_at, o.id)
+        AND CASE
+          WHEN o.clinic_id IS NOT NULL THEN o2.clinic_id = o.clinic_id
+          ELSE o2.customer_id = o.customer_id
+        END
+    )
+    AND NOT EXISTS (
+      SELECT 1
+      FROM commission_entries e
+      JOIN commission_statements cs ON cs.id = e.statement_id
+      WHERE e.kind = 'bonus' AND e.order_id = o.id AND NOT ` + regeneratedStatementSQL + `
+    )
+  )
+FROM sources s
+JOIN invoice_items ii ON ii.id = s.invoice_item_id
+JOIN orders o ON o.id = ii.order_id
+WHERE
+  o.ref_user_id IS NOT NULL
+  AND ii.order_item_id <> 0 -- cancellation fees earn nothing
+  AND ($4::INT IS NULL OR o.ref_user_id = $4::INT)
+  AND NOT ` + orderCancelledSQL + `
+  AND NOT EXISTS (` + freeRemakeSQLItem + `)
+ORDER BY o.ref_user_id, s.occurred_at, s.source_id, ii.id;
+`
+
+// Items remade at no charge before $3.
+const freeRemakeSQLItem = `
+  SELECT 1
+  FROM order_item_remake_logs rl
+  JOIN order_items rmk ON rmk.id = rl.item_id AND rmk.deleted_at IS NULL
+  WHERE rmk.parent_item_id = ii.order_item_id AND COALESCE(rmk.total_price, 0) = 0 AND rl.created_at < $3`
+
+// Invoice lines are already net of the promotion discounts.
+const invoicedSourcesSQL = `
+WITH sources AS (
+  SELECT
+    'invoice_item' AS source,
+    ii.id          AS source_id,
+    i.issued_at    AS occurred_at,
+    ii.id          AS invoice_item_id,
+    1::FLOAT8      AS share
+  FROM invoice_items ii
+  JOIN invoices i ON i.id = ii.invoice_id AND i.deleted_at IS NULL AND i.status <> 'void'
+  WHERE i.department_id = $1 AND i.issued_at >= $2 AND i.issued_at < $3
+)`
+
+// A payment allocated to an uninvoiced order is spread over the invoiced
+// lines of the order, one allocated to an invoice over the lines of that
+// invoice, in proportion to their net amount. Cancellation fees take their
+// share of the payment but earn nothing. Credit notes are not money received.
+const paidSourcesSQL = `
+WITH paid AS (
+  SELECT a.id, a.order_id, a.invoice_id, a.amount, p.received_at
+  FROM clinic_payment_allocations a
+  JOIN clinic_payments p ON p.id = a.payment_id AND p.deleted_at IS NULL
+  WHERE
+    p.department_id = $1
+    AND p.method <> 'credit_note'
+    AND p.received_at >= $2
+    AND p.received_at <  $3
+    AND (a.order_id IS NOT NULL OR a.invoice_id IS NOT NULL)
+),
+order_lines AS (
+  SELECT
+    ii.id,
+    ii.order_id,
+    ii.order_item_id,
+    ii.line_total,
+    SUM(ii.line_total) OVER (PARTITION BY ii.order_id) AS net
+  FROM invoice_items ii
+  JOIN invoices i ON i.id = ii.invoice_id AND i.deleted_at IS NULL AND i.status <> 'void'
+  WHERE ii.order_id IN (SELECT order_id FROM paid)
+),
+invoice_lines AS (
+  SELECT
+    ii.id,
+    ii.invoice_id,
+    ii.order_item_id,
+    ii.line_total,
+    SUM(ii.line_total) OVER (PARTITION BY ii.invoice_id) AS net
+  FROM invoice_items ii
+  JOIN invoices i ON i.id = ii.invoice_id AND i.deleted_at IS NULL AND i.status <> 'void'
+  WHERE ii.invoice_id IN (SELECT invoice_id FROM paid)
+),
+sources AS (
+  SELECT
+    'payment_allocation'  AS source,
+    pd.id                 AS source_id,
+    pd.received_at        AS occurred_at,
+    l.id                  AS invoice_item_id,
+    pd.amount / l.net     AS share
+  FROM paid pd
+  JOIN order_lines l ON l.order_id = pd.order_id AND l.net > 0 AND l.order_item_id <> 0
+  UNION ALL
+  SELECT
+    'payment_allocation',
+    pd.id,
+    pd.received_at,
+    l.id,
+    pd.amount / l.net
+  FROM paid pd
+  JOIN invoice_lines l ON l.invoice_id = pd.invoice_id AND l.net > 0 AND l.order_item_id <> 0
+)`
+
+func (r *commissionRepository) SaleRows(
+	ctx context.Context,
+	deptID int,
+	basis string,
+	from, to time.Time,
+	refUserID *int,
+) ([]*SaleRow, error) {
+	q := invoicedSourcesSQL + saleSelectSQL
+	if basis == BasisPaid {
+		q = paidSourcesSQL + saleSelectSQL
+	}
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, deptID, from, to, refUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*SaleRow{}
+	for rows.Next() {
+		var s SaleRow
+		if err := rows.Scan(
+			&s.Source,
+			&s.SourceID,
+			&s.OccurredAt,
+			&s.RefUserID,
+			&s.RefUserName,
+			&s.OrderID,
+			&s.OrderCode,
+			&s.OrderItemID,
+			&s.ProductID,
+			&s.ProductName,
+			(*pq.Int64Array)(&s.CategoryIDs),
+			&s.Quantity,
+			&s.BaseAmount,
+			&s.FirstOrder,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, &s)
+	}
+	return out, rows.Err()
+}
+
+// $1 department, $2..$3 month, $4 referrer
+const reversalSQL = `
+SELECT
+  e.id,
+  cs.ref_user_id,
+  cs.ref_user_name,
+  e.order_id,
+  e.order_code,
+  e.order_item_id,
+  e.product_id,
+  e.product_name,
+  e.amount,
+  ev.reason,
+  ev.at
+FROM commission_entries e
+JOIN commission_statements cs ON cs.id = e.statement_id
+JOIN orders o ON o.id = e.order_id
+CROSS JOIN LATERAL (
+  SELECT x.reason, x.at
+  FROM (
+    SELECT
+      'order_cancelled' AS reason,
+      COALESCE(
+        o.deleted_at,
+        (SELECT MIN(h.created_at) FROM order_status_histories h
+         WHERE h.entity = 'order' AND h.entity_id = o.id AND h.to_status = 'cancelled')
+      ) AS at
+    WHERE ` + orderCancelledSQL + `
+    UNION ALL
+    SELECT 'free_remake', fr.at
+    FROM (` + freeRemakeSQLEntry + `) fr
+    WHERE fr.at IS NOT NULL
+  ) x
+  ORDER BY x.at
+  LIMIT 1
+) ev
+WHERE
+  cs.department_id = $1
+  AND cs.period_start < $2
+  AND e.kind IN ('commission', 'bonus')
+  AND ($4::INT IS NULL OR cs.ref_user_id = $4::INT)
+  AND NOT EXISTS (
+    SELECT 1
+    FROM commission_entries rv
+    JOIN commission_statements cs ON cs.id = rv.statement_id
+    WHERE rv.reverses_entry_id = e.id AND NOT ` + regeneratedStatementSQL + `
+  )
+ORDER BY cs.ref_user_id, ev.at, e.id;
+`
+
+const freeRemakeSQLEntry = `
+  SELECT MIN(rl.created_at) AS at
+  FROM order_item_remake_logs rl
+  JOIN order_items rmk ON rmk.id = rl.item_id AND rmk.deleted_at IS NULL
+  WHERE rmk.parent_item_id = e.order_item_id AND COALESCE(rmk.total_price, 0) = 0 AND rl.created_at < $3`
+
+func (r *commissionRepository) ReversalRows(ctx context.Context, deptID int, from, to time.Time, refUserID *int) ([]*ReversalRow, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, reversalSQL, deptID, from, to, refUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*ReversalRow{}
+	for rows.Next() {
+		var rv ReversalRow
+		if err := rows.Scan(
+			&rv.EntryID,
+			&rv.RefUserID,
+			&rv.RefUserName,
+			&rv.OrderID,
+			&rv.OrderCode,
+			&rv.OrderItemID,
+			&rv.ProductID,
+			&rv.ProductName,
+			&rv.Amount,
+			&rv.Reason,
+			&rv.OccurredAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, &rv)
+	}
+	return out, rows.Err()
+}
+
+func (r *commissionRepository) SaveStatements(
+	ctx context.Context,
+	deptID, userID int,
+	from, to time.Time,
+	refUserID *int,
+	statements []*model.CommissionStatementDTO,
+) error {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	scope := tx.CommissionStatement.Query().
+		Where(
+			commissionstatement.DepartmentIDEQ(deptID),
+			commissionstatement.PeriodStartEQ(from),
+		)
+	if refUserID != nil {
+		scope = scope.Where(commissionstatement.RefUserIDEQ(*refUserID))
+	}
+	existing, err := scope.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	locked := make(map[int]struct{})
+	var drafts []int64
+	for _, st := range existing {
+		if st.Status == StatementStatusApproved {
+			locked[st.RefUserID] = struct{}{}
+			continue
+		}
+		drafts = append(drafts, st.ID)
+	}
+	if refUserID != nil && len(locked) > 0 {
+		err = ErrStatementLocked
+		return err
+	}
+
+	if len(drafts) > 0 {
+		if _, err = tx.CommissionEntry.Delete().
+			Where(commissionentry.StatementIDIn(drafts...)).
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err = tx.CommissionStatement.Delete().
+			Where(commissionstatement.IDIn(drafts...)).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, st := range statements {
+		if _, ok := locked[st.RefUserID]; ok {
+			continue
+		}
+
+		var entity *generated.CommissionStatement
+		entity, err = tx.CommissionStatement.Create().
+			SetDepartmentID(deptID).
+			SetRefUserID(st.RefUserID).
+			SetNillableRefUserName(st.RefUserName).
+			SetPeriodStart(from).
+			SetPeriodEnd(to).
+			SetBasis(st.Basis).
+			SetStatus(StatementStatusDraft).
+			SetBaseTotal(st.BaseTotal).
+			SetCommission(st.Commission).
+			SetBonus(st.Bonus).
+			SetCapped(st.Capped).
+			SetReversals(st.Reversals).
+			SetTotal(st.Total).
+			SetGeneratedBy(userID).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		bulk := make([]*generated.CommissionEntryCreate, 0, len(st.Entries))
+		for _, e := range st.Entries {
+			bulk = append(bulk, tx.CommissionEntry.Create().
+				SetStatementID(entity.ID).
+				SetKind(e.Kind).
+				SetSource(e.Source).
+				SetSourceID(e.SourceID).
+				SetOccurredAt(e.OccurredAt).
+				SetOrderID(e.OrderID).
+				SetNillableOrderCode(e.OrderCode).
+				SetNillableOrderItemID(e.OrderItemID).
+				SetNillableProductID(e.ProductID).
+				SetNillableProductName(e.ProductName).
+				SetNillableRuleID(e.RuleID).
+				SetBaseAmount(e.BaseAmount).
+				SetAmount(e.Amount).
+				SetNillableReversesEntryID(e.ReversesEntryID).
+				SetNillableNote(e.Note))
+		}
+		if len(bulk) > 0 {
+			if _, err = tx.CommissionEntry.CreateBulk(bulk...).Save(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *commissionRepository) Approve(ctx context.Context, deptID int, id int64, userID int) (*model.CommissionStatementDTO, error) {
+	n, err := r.db.CommissionStatement.Update().
+		Where(
+			commissionstatement.IDEQ(id),
+			commissionstatement.DepartmentIDEQ(deptID),
+			commissionstatement.StatusEQ(StatementStatusDraft),
+		).
+		SetStatus(StatementStatusApproved).
+		SetApprovedAt(time.Now()).
+		SetApprovedBy(userID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dto, err := r.GetByID(ctx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrStatementLocked
+	}
+	return dto, nil
+}
+
+func withEntries(q *generated.CommissionEntryQuery) {
+	q.Order(
+		generated.Asc(commissionentry.FieldKind),
+		generated.Asc(commissionentry.FieldOccurredAt),
+		generated.Asc(commissionentry.FieldID),
+	)
+}
+
+func mapStatement(entity *generated.CommissionStatement) *model.CommissionStatementDTO {
+	dto := mapper.MapAs[*generated.CommissionStatement, *model.CommissionStatementDTO](entity)
+	if entity.Edges.Entries != nil {
+		dto.Entries = mapper.MapListAs[*generated.CommissionEntry, *model.CommissionEntryDTO](entity.Edges.Entries)
+	}
+	return dto
+}
+
+func (r *commissionRepository) GetByID(ctx context.Context, deptID int, id int64) (*model.CommissionStatementDTO, error) {
+	entity, err := r.db.CommissionStatement.Query().
+		Where(
+			commissionstatement.IDEQ(id),
+			commissionstatement.DepartmentIDEQ(deptID),
+		).
+		WithEntries(withEntries).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapStatement(entity), nil
+}
+
+func (r *commissionRepository) List(
+	ctx context.Context,
+	deptID int,
+	refUserID *int,
+	periodStart *time.Time,
+	query table.TableQuery,
+) (table.TableListResult[model.CommissionStatementDTO], error) {
+	q := r.db.CommissionStatement.Query().
+		Where(commissionstatement.DepartmentIDEQ(deptID))
+	if refUserID != nil {
+		q = q.Where(commissionstatement.RefUserIDEQ(*refUserID))
+	}
+	if periodStart != nil {
+		q = q.Where(commissionstatement.PeriodStartEQ(*periodStart))
+	}
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		commissionstatement.Table,
+		commissionstatement.FieldID,
+		commissionstatement.FieldPeriodStart,
+		func(src []*generated.CommissionStatement) []*model.CommissionStatementDTO {
+			return mapper.MapListAs[*generated.CommissionStatement, *model.CommissionStatementDTO](src)
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.CommissionStatementDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+func (r *commissionRepository) ListWithEntries(ctx context.Context, deptID int, periodStart time.Time) ([]*model.CommissionStatementDTO, error) {
+	list, err := r.db.CommissionStatement.Query().
+		Where(
+			commissionstatement.DepartmentIDEQ(deptID),
+			commissionstatement.PeriodStartEQ(periodStart),
+		).
+		WithEntries(withEntries).
+		Order(generated.Asc(commissionstatement.FieldRefUserName), generated.Asc(commissionstatement.FieldRefUserID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*model.CommissionStatementDTO, 0, len(list))
+	for _, it := range list {
+		out = append(out, mapStatement(it))
+	}
+	return out, nil
+}
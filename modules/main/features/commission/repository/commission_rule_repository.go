@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/commissionrule"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type CommissionRuleRepository interface {
+	List(ctx context.Context, deptID int, refUserID *int) ([]*model.CommissionRuleDTO, error)
+	Create(ctx context.Context, deptID int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+	// Active returns the active rules used to compute the commissions.
+	Active(ctx context.Context, deptID int) ([]*model.CommissionRuleDTO, error)
+}
+
+type commissionRuleRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewCommissionRuleRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) CommissionRuleRepository {
+	return &commissionRuleRepository{db: db, deps: deps}
+}
+
+func (r *commissionRuleRepository) List(ctx context.Context, deptID int, refUserID *int) ([]*model.CommissionRuleDTO, error) {
+	q := r.db.CommissionRule.Query().
+		Where(commissionrule.DepartmentIDEQ(deptID))
+	if refUserID != nil {
+		q = q.Where(commissionrule.RefUserIDEQ(*refUserID))
+	}
+	list, err := q.
+		Order(generated.Asc(commissionrule.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.CommissionRule, *model.CommissionRuleDTO](list), nil
+}
+
+func (r *commissionRuleRepository) Active(ctx context.Context, deptID int) ([]*model.CommissionRuleDTO, error) {
+	list, err := r.db.CommissionRule.Query().
+		Where(
+			commissionrule.DepartmentIDEQ(deptID),
+			commissionrule.ActiveEQ(true),
+		).
+		Order(generated.Asc(commissionrule.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.CommissionRule, *model.CommissionRuleDTO](list), nil
+}
+
+func (r *commissionRuleRepository) Create(ctx context.Context, deptID int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error) {
+	entity, err := r.db.CommissionRule.Create().
+		SetDepartmentID(deptID).
+		SetNillableName(input.Name).
+		SetNillableRefUserID(input.RefUserID).
+		SetNillableProductID(input.ProductID).
+		SetNillableCategoryID(input.CategoryID).
+		SetRateType(input.RateType).
+		SetRate(input.Rate).
+		SetFirstOrderBonus(input.FirstOrderBonus).
+		SetNillableCapPerOrder(input.CapPerOrder).
+		SetActive(input.Active).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.CommissionRule, *model.CommissionRuleDTO](entity), nil
+}
+
+func (r *commissionRuleRepository) Update(ctx context.Context, deptID int, id int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error) {
+	up := r.db.CommissionRule.UpdateOneID(id).
+		Where(commissionrule.DepartmentIDEQ(deptID)).
+		SetRateType(input.RateType).
+		SetRate(input.Rate).
+		SetFirstOrderBonus(input.FirstOrderBonus).
+		SetActive(input.Active)
+
+	if input.Name != nil {
+		up.SetName(*input.Name)
+	} else {
+		up.ClearName()
+	}
+	if input.RefUserID != nil {
+		up.SetRefUserID(*input.RefUserID)
+	} else {
+		up.ClearRefUserID()
+	}
+	if input.ProductID != nil {
+		up.SetProductID(*input.ProductID)
+	} else {
+		up.ClearProductID()
+	}
+	if input.CategoryID != nil {
+		up.SetCategoryID(*input.CategoryID)
+	} else {
+		up.ClearCategoryID()
+	}
+	if input.CapPerOrder != nil {
+		up.SetCapPerOrder(*input.CapPerOrder)
+	} else {
+		up.ClearCapPerOrder()
+	}
+
+	entity, err := up.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.CommissionRule, *model.CommissionRuleDTO](entity), nil
+}
+
+func (r *commissionRuleRepository) Delete(ctx context.Context, deptID int, id int) error {
+	n, err := r.db.CommissionRule.Delete().
+		Where(
+			commissionrule.IDEQ(id),
+			commissionrule.DepartmentIDEQ(deptID),
+		).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &generated.NotFoundError{}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/repository"
+)
+
+const (
+	entryKindCommission = "commission"
+	entryKindBonus      = "bonus"
+	entryKindReversal   = "reversal"
+)
+
+// matchRule returns the most specific active rule of a sale: a product rule
+// beats a category rule, which beats a catch-all; a rule for the referrer
+// beats one for everybody. Ties go to the oldest rule.
+func matchRule(rules []*model.CommissionRuleDTO, s *repository.SaleRow) *model.CommissionRuleDTO {
+	var best *model.CommissionRuleDTO
+	bestScore := -1
+	for _, r := range rules {
+		score := 0
+		if r.RefUserID != nil {
+			if *r.RefUserID != s.RefUserID {
+				continue
+			}
+			score++
+		}
+		if r.ProductID != nil {
+			if *r.ProductID != s.ProductID {
+				continue
+			}
+			score += 4
+		}
+		if r.CategoryID != nil {
+			if !containsID(s.CategoryIDs, int64(*r.CategoryID)) {
+				continue
+			}
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, it := range ids {
+		if it == id {
+			return true
+		}
+	}
+	return false
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v)
+}
+
+type orderRuleKey struct {
+	orderID int64
+	ruleID  int
+}
+
+// calculateCommissions builds one statement per referrer from the sales of
+// the month and the reversals of earlier months.
+func calculateCommissions(
+	rules []*model.CommissionRuleDTO,
+	setting *model.CommissionSettingDTO,
+	sales []*repository.SaleRow,
+	reversals []*repository.ReversalRow,
+) []*model.CommissionStatementDTO {
+	byRef := map[int]*model.CommissionStatementDTO{}
+	statement := func(id int, name *string) *model.CommissionStatementDTO {
+		st, ok := byRef[id]
+		if !ok {
+			st = &model.CommissionStatementDTO{RefUserID: id, RefUserName: name, Basis: setting.Basis}
+			byRef[id] = st
+		}
+		if st.RefUserName == nil {
+			st.RefUserName = name
+		}
+		return st
+	}
+
+	paidPerOrder := map[orderRuleKey]float64{}
+	bonusPaid := map[int64]bool{}
+	for _, s := range sales {
+		st := statement(s.RefUserID, s.RefUserName)
+		st.BaseTotal += s.BaseAmount
+
+		rule := matchRule(rules, s)
+		if rule == nil {
+			continue
+		}
+
+		amount := 0.0
+		switch rule.RateType {
+		case "percent":
+			amount = s.BaseAmount * rule.Rate / 100
+		case "fixed":
+			amount = s.Quantity * rule.Rate
+		}
+		amount = roundAmount(amount)
+
+		if rule.CapPerOrder != nil {
+			key := orderRuleKey{s.OrderID, rule.ID}
+			amount = math.Max(0, math.Min(amount, *rule.CapPerOrder-paidPerOrder[key]))
+			paidPerOrder[key] += amount
+		}
+
+		orderItemID, productID, ruleID := s.OrderItemID, s.ProductID, rule.ID
+		if amount != 0 {
+			st.Entries = append(st.Entries, &model.CommissionEntryDTO{
+				Kind:        entryKindCommission,
+				Source:      s.Source,
+				SourceID:    s.SourceID,
+				OccurredAt:  s.OccurredAt,
+				OrderID:     s.OrderID,
+				OrderCode:   s.OrderCode,
+				OrderItemID: &orderItemID,
+				ProductID:   &productID,
+				ProductName: s.ProductName,
+				RuleID:      &ruleID,
+				BaseAmount:  roundAmount(s.BaseAmount),
+				Amount:      amount,
+			})
+			st.Commission += amount
+		}
+
+		if s.FirstOrder && rule.FirstOrderBonus > 0 && !bonusPaid[s.OrderID] {
+			bonusPaid[s.OrderID] = true
+			bonus := roundAmount(rule.FirstOrderBonus)
+			st.Entries = append(st.Entries, &model.CommissionEntryDTO{
+				Kind:       entryKindBonus,
+				Source:     "order",
+				SourceID:   s.OrderID,
+				OccurredAt: s.OccurredAt,
+				OrderID:    s.OrderID,
+				OrderCode:  s.OrderCode,
+				RuleID:     &ruleID,
+				Amount:     bonus,
+			})
+			st.Bonus += bonus
+		}
+	}
+
+	for _, rv := range reversals {
+		st := statement(rv.RefUserID, rv.RefUserName)
+		entryID, note := rv.EntryID, rv.Reason
+		source, sourceID := "order", rv.OrderID
+		if rv.Reason == "free_remake" && rv.OrderItemID != nil {
+			source, sourceID = "order_item", *rv.OrderItemID
+		}
+		st.Entries = append(st.Entries, &model.CommissionEntryDTO{
+			Kind:            entryKindReversal,
+			Source:          source,
+			SourceID:        sourceID,
+			OccurredAt:      rv.OccurredAt,
+			OrderID:         rv.OrderID,
+			OrderCode:       rv.OrderCode,
+			OrderItemID:     rv.OrderItemID,
+			ProductID:       rv.ProductID,
+			ProductName:     rv.ProductName,
+			Amount:          -rv.Amount,
+			ReversesEntryID: &entryID,
+			Note:            &note,
+		})
+		st.Reversals -= rv.Amount
+	}
+
+	out := make([]*model.CommissionStatementDTO, 0, len(byRef))
+	for _, st := range byRef {
+		st.BaseTotal = roundAmount(st.BaseTotal)
+		earned := st.Commission + st.Bonus
+		if setting.MonthlyCap != nil && earned > *setting.MonthlyCap {
+			st.Capped = earned - *setting.MonthlyCap
+		}
+		st.Total = earned - st.Capped + st.Reversals
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RefUserID < out[j].RefUserID })
+	return out
+}
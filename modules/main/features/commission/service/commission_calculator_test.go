@@ -0,0 +1,178 @@
+package service
+
+import (
+	"testing"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/repository"
+)
+
+func intPtr(v int) *int { return &v }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestMatchRule(t *testing.T) {
+	catchAll := &model.CommissionRuleDTO{ID: 1}
+	category := &model.CommissionRuleDTO{ID: 2, CategoryID: intPtr(7)}
+	product := &model.CommissionRuleDTO{ID: 3, ProductID: intPtr(100)}
+	referrer := &model.CommissionRuleDTO{ID: 4, RefUserID: intPtr(9)}
+	referrerCategory := &model.CommissionRuleDTO{ID: 5, RefUserID: intPtr(9), CategoryID: intPtr(7)}
+	catchAllLater := &model.CommissionRuleDTO{ID: 6}
+	rules := []*model.CommissionRuleDTO{catchAll, category, product, referrer, referrerCategory, catchAllLater}
+
+	tests := []struct {
+		name     string
+		rules    []*model.CommissionRuleDTO
+		sale     repository.SaleRow
+		expected int
+	}{
+		{"catch-all, oldest wins the tie", rules, repository.SaleRow{RefUserID: 1, ProductID: 200}, 1},
+		{"category beats catch-all", rules, repository.SaleRow{RefUserID: 1, ProductID: 200, CategoryIDs: []int64{3, 7}}, 2},
+		{"product beats category", rules, repository.SaleRow{RefUserID: 1, ProductID: 100, CategoryIDs: []int64{7}}, 3},
+		{"referrer beats everybody", rules, repository.SaleRow{RefUserID: 9, ProductID: 200}, 4},
+		{"referrer and category", rules, repository.SaleRow{RefUserID: 9, ProductID: 200, CategoryIDs: []int64{7}}, 5},
+		{"product beats referrer and category", rules, repository.SaleRow{RefUserID: 9, ProductID: 100, CategoryIDs: []int64{7}}, 3},
+		{"no rule", []*model.CommissionRuleDTO{product, referrer}, repository.SaleRow{RefUserID: 1, ProductID: 200}, 0},
+	}
+
+	for _, tt := range tests {
+		got := 0
+		if r := matchRule(tt.rules, &tt.sale); r != nil {
+			got = r.ID
+		}
+		if got != tt.expected {
+			t.Errorf("%s: rule = %d; want %d", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestCalculateCommissions(t *testing.T) {
+	percent := &model.CommissionRuleDTO{ID: 1, RateType: "percent", Rate: 10}
+	fixed := &model.CommissionRuleDTO{ID: 2, ProductID: intPtr(200), RateType: "fixed", Rate: 50}
+	capped := &model.CommissionRuleDTO{ID: 3, ProductID: intPtr(300), RateType: "percent", Rate: 10, CapPerOrder: floatPtr(150)}
+	bonus := &model.CommissionRuleDTO{ID: 4, ProductID: intPtr(400), RateType: "percent", Rate: 10, FirstOrderBonus: 500}
+	rules := []*model.CommissionRuleDTO{percent, fixed, capped, bonus}
+
+	type totals struct {
+		base, commission, bonus, capped, reversals, total float64
+		entries                                           int
+	}
+	tests := []struct {
+		name      string
+		rules     []*model.CommissionRuleDTO
+		setting   model.CommissionSettingDTO
+		sales     []*repository.SaleRow
+		reversals []*repository.ReversalRow
+		expected  totals
+	}{
+		{
+			name:     "percent of the base",
+			rules:    rules,
+			sales:    []*repository.SaleRow{{RefUserID: 1, OrderID: 1, ProductID: 100, BaseAmount: 1234}},
+			expected: totals{base: 1234, commission: 123, total: 123, entries: 1},
+		},
+		{
+			name:     "fixed per unit",
+			rules:    rules,
+			sales:    []*repository.SaleRow{{RefUserID: 1, OrderID: 1, ProductID: 200, Quantity: 3, BaseAmount: 900}},
+			expected: totals{base: 900, commission: 150, total: 150, entries: 1},
+		},
+		{
+			name:  "cap shared by the items of an order",
+			rules: rules,
+			sales: []*repository.SaleRow{
+				{RefUserID: 1, OrderID: 1, OrderItemID: 1, ProductID: 300, BaseAmount: 1000},
+				{RefUserID: 1, OrderID: 1, OrderItemID: 2, ProductID: 300, BaseAmount: 1000},
+				{RefUserID: 1, OrderID: 1, OrderItemID: 3, ProductID: 300, BaseAmount: 1000},
+				{RefUserID: 1, OrderID: 2, OrderItemID: 4, ProductID: 300, BaseAmount: 1000},
+			},
+			expected: totals{base: 4000, commission: 250, total: 250, entries: 3},
+		},
+		{
+			name:  "first order bonus paid once",
+			rules: rules,
+			sales: []*repository.SaleRow{
+				{RefUserID: 1, OrderID: 1, OrderItemID: 1, ProductID: 400, BaseAmount: 1000, FirstOrder: true},
+				{RefUserID: 1, OrderID: 1, OrderItemID: 2, ProductID: 400, BaseAmount: 1000, FirstOrder: true},
+			},
+			expected: totals{base: 2000, commission: 200, bonus: 500, total: 700, entries: 3},
+		},
+		{
+			name:     "sale without a rule counts toward the base only",
+			rules:    []*model.CommissionRuleDTO{fixed},
+			sales:    []*repository.SaleRow{{RefUserID: 1, OrderID: 1, ProductID: 100, BaseAmount: 1000}},
+			expected: totals{base: 1000},
+		},
+		{
+			name:     "monthly cap",
+			rules:    rules,
+			setting:  model.CommissionSettingDTO{MonthlyCap: floatPtr(600)},
+			sales:    []*repository.SaleRow{{RefUserID: 1, OrderID: 1, ProductID: 400, BaseAmount: 2000, FirstOrder: true}},
+			expected: totals{base: 2000, commission: 200, bonus: 500, capped: 100, total: 600, entries: 2},
+		},
+		{
+			name:    "reversal of an earlier month",
+			rules:   rules,
+			setting: model.CommissionSettingDTO{MonthlyCap: floatPtr(100)},
+			sales:   []*repository.SaleRow{{RefUserID: 1, OrderID: 2, ProductID: 100, BaseAmount: 1000}},
+			reversals: []*repository.ReversalRow{
+				{EntryID: 10, RefUserID: 1, OrderID: 1, Amount: 40, Reason: "order_cancelled"},
+			},
+			expected: totals{base: 1000, commission: 100, reversals: -40, total: 60, entries: 2},
+		},
+		{
+			name: "reversal only",
+			reversals: []*repository.ReversalRow{
+				{EntryID: 10, RefUserID: 1, OrderID: 1, Amount: 40, Reason: "free_remake"},
+			},
+			expected: totals{reversals: -40, total: -40, entries: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		out := calculateCommissions(tt.rules, &tt.setting, tt.sales, tt.reversals)
+		if len(out) != 1 {
+			t.Errorf("%s: got %d statements; want 1", tt.name, len(out))
+			continue
+		}
+		st := out[0]
+		got := totals{st.BaseTotal, st.Commission, st.Bonus, st.Capped, st.Reversals, st.Total, len(st.Entries)}
+		if got != tt.expected {
+			t.Errorf("%s: got %+v; want %+v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestCalculateCommissionsReversalSource(t *testing.T) {
+	itemID := int64(5)
+	reversals := []*repository.ReversalRow{
+		{EntryID: 10, RefUserID: 1, OrderID: 1, OrderItemID: &itemID, Amount: 40, Reason: "free_remake"},
+		{EntryID: 11, RefUserID: 1, OrderID: 1, OrderItemID: &itemID, Amount: 20, Reason: "order_cancelled"},
+		{EntryID: 12, RefUserID: 2, OrderID: 3, Amount: 30, Reason: "order_cancelled"},
+	}
+
+	out := calculateCommissions(nil, &model.CommissionSettingDTO{}, nil, reversals)
+	if len(out) != 2 || out[0].RefUserID != 1 || out[1].RefUserID != 2 {
+		t.Fatalf("statements not one per referrer in id order")
+	}
+
+	tests := []struct {
+		entry    *model.CommissionEntryDTO
+		source   string
+		sourceID int64
+		reverses int64
+		amount   float64
+	}{
+		{out[0].Entries[0], "order_item", 5, 10, -40},
+		{out[0].Entries[1], "order", 1, 11, -20},
+		{out[1].Entries[0], "order", 3, 12, -30},
+	}
+	for _, tt := range tests {
+		e := tt.entry
+		if e.Kind != entryKindReversal || e.Source != tt.source || e.SourceID != tt.sourceID ||
+			e.ReversesEntryID == nil || *e.ReversesEntryID != tt.reverses || e.Amount != tt.amount {
+			t.Errorf("entry = %s %s #%d reverses %v amount %v; want reversal %s #%d reverses %d amount %v",
+				e.Kind, e.Source, e.SourceID, e.ReversesEntryID, e.Amount, tt.source, tt.sourceID, tt.reverses, tt.amount)
+		}
+	}
+}
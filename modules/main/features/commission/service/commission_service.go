@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/commission/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+var (
+	ErrInvalidRule       = errors.New("rate_type must be percent or fixed and amounts must not be negative")
+	ErrInvalidSetting    = errors.New("basis must be invoiced or paid and monthly_cap must not be negative")
+	ErrInvalidMonth      = errors.New("month is required")
+	ErrNothingToGenerate = errors.New("no referred sales or reversals in the month")
+)
+
+type CommissionService interface {
+	ListRules(ctx context.Context, deptID int, refUserID *int) ([]*model.CommissionRuleDTO, error)
+	CreateRule(ctx context.Context, deptID int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error)
+	UpdateRule(ctx context.Context, deptID int, id int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error)
+	DeleteRule(ctx context.Context, deptID int, id int) error
+
+	GetSetting(ctx context.Context, deptID int) (*model.CommissionSettingDTO, error)
+	SaveSetting(ctx context.Context, deptID, userID int, input *model.CommissionSettingDTO) (*model.CommissionSettingDTO, error)
+
+	Generate(ctx context.Context, deptID, userID int, input *model.CommissionGenerateDTO) ([]*model.CommissionStatementDTO, error)
+	Approve(ctx context.Context, deptID int, id int64, userID int) (*model.CommissionStatementDTO, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.CommissionStatementDTO, error)
+	List(ctx context.Context, deptID int, refUserID *int, month *time.Time, query table.TableQuery) (table.TableListResult[model.CommissionStatementDTO], error)
+}
+
+type commissionService struct {
+	rules repository.CommissionRuleRepository
+	repo  repository.CommissionRepository
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewCommissionService(
+	rules repository.CommissionRuleRepository,
+	repo repository.CommissionRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) CommissionService {
+	return &commissionService{rules: rules, repo: repo, deps: deps}
+}
+
+// monthRange returns the calendar month of t as [start, end).
+func monthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+func validateRule(input *model.CommissionRuleDTO) error {
+	if input.RateType != "percent" && input.RateType != "fixed" {
+		return ErrInvalidRule
+	}
+	if input.Rate < 0 || input.FirstOrderBonus < 0 || (input.CapPerOrder != nil && *input.CapPerOrder < 0) {
+		return ErrInvalidRule
+	}
+	if input.RateType == "percent" && input.Rate > 100 {
+		return ErrInvalidRule
+	}
+	return nil
+}
+
+func (s *commissionService) ListRules(ctx context.Context, deptID int, refUserID *int) ([]*model.CommissionRuleDTO, error) {
+	return s.rules.List(ctx, deptID, refUserID)
+}
+
+func (s *commissionService) CreateRule(ctx context.Context, deptID int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error) {
+	if err := validateRule(input); err != nil {
+		return nil, err
+	}
+	return s.rules.Create(ctx, deptID, input)
+}
+
+func (s *commissionService) UpdateRule(ctx context.Context, deptID int, id int, input *model.CommissionRuleDTO) (*model.CommissionRuleDTO, error) {
+	if err := validateRule(input); err != nil {
+		return nil, err
+	}
+	return s.rules.Update(ctx, deptID, id, input)
+}
+
+func (s *commissionService) DeleteRule(ctx context.Context, deptID int, id int) error {
+	return s.rules.Delete(ctx, deptID, id)
+}
+
+func (s *commissionService) GetSetting(ctx context.Context, deptID int) (*model.CommissionSettingDTO, error) {
+	return s.repo.GetSetting(ctx, deptID)
+}
+
+func (s *commissionService) SaveSetting(ctx context.Context, deptID, userID int, input *model.CommissionSettingDTO) (*model.CommissionSettingDTO, error) {
+	if input.Basis != repository.BasisInvoiced && input.Basis != repository.BasisPaid {
+		return nil, ErrInvalidSetting
+	}
+	if input.MonthlyCap != nil && *input.MonthlyCap < 0 {
+		return nil, ErrInvalidSetting
+	}
+	return s.repo.SaveSetting(ctx, deptID, userID, input)
+}
+
+// Generate recomputes the draft statements of the month. Commissions of
+// orders cancelled or remade for free are reversed on the first statement
+// generated after it happened; approved statements are locked.
+func (s *commissionService) Generate(ctx context.Context, deptID, userID int, input *model.CommissionGenerateDTO) ([]*model.CommissionStatementDTO, error) {
+	if input.Month.IsZero() {
+		return nil, ErrInvalidMonth
+	}
+	from, to := monthRange(input.Month)
+
+	rules, err := s.rules.Active(ctx, deptID)
+	if err != nil {
+		return nil, err
+	}
+	setting, err := s.repo.GetSetting(ctx, deptID)
+	if err != nil {
+		return nil, err
+	}
+	sales, err := s.repo.SaleRows(ctx, deptID, setting.Basis, from, to, input.RefUserID)
+	if err != nil {
+		return nil, err
+	}
+	reversals, err := s.repo.ReversalRows(ctx, deptID, from, to, input.RefUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := calculateCommissions(rules, setting, sales, reversals)
+	if len(statements) == 0 {
+		return nil, ErrNothingToGenerate
+	}
+	if err := s.repo.SaveStatements(ctx, deptID, userID, from, to, input.RefUserID, statements); err != nil {
+		return nil, err
+	}
+	return s.repo.ListWithEntries(ctx, deptID, from)
+}
+
+func (s *commissionService) Approve(ctx context.Context, deptID int, id int64, userID int) (*model.CommissionStatementDTO, error) {
+	return s.repo.Approve(ctx, deptID, id, userID)
+}
+
+func (s *commissionService) GetByID(ctx context.Context, deptID int, id int64) (*model.CommissionStatementDTO, error) {
+	return s.repo.GetByID(ctx, deptID, id)
+}
+
+func (s *commissionService) List(
+	ctx context.Context,
+	deptID int,
+	refUserID *int,
+	month *time.Time,
+	query table.TableQuery,
+) (table.TableListResult[model.CommissionStatementDTO], error) {
+	var periodStart *time.Time
+	if month != nil {
+		from, _ := monthRange(*month)
+		periodStart = &from
+	}
+	return s.repo.List(ctx, deptID, refUserID, periodStart, query)
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+// ProcessDuration is the median wall-clock time a process step took recently.
+type ProcessDuration struct {
+	ProcessName string
+	Seconds     float64
+	Samples     int
+}
+
+// OpenStep is a not yet completed process step of an in-progress order item.
+type OpenStep struct {
+	OrderID     int64
+	OrderItemID int64
+	Code        string
+	Dentist     string
+	Patient     string
+	DeliveryAt  time.Time
+	Priority    string
+	ProcessName string
+	StepNumber  int
+	StartedAt   *time.Time // set when the step is checked in and running
+	HeldSeconds float64    // time the item spent on hold so far
+}
+
+type AtRiskRepository interface {
+	ProcessDurations(
+		ctx context.Context,
+		deptID int,
+		since time.Time,
+	) ([]*ProcessDuration, error)
+	OpenSteps(
+		ctx context.Context,
+		deptID int,
+	) ([]*OpenStep, error)
+}
+
+type atRiskRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewAtRiskRepository(
+	db *generated.Client,
+	sqlDB *sql.DB,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) AtRiskRepository {
+	return &atRiskRepository{
+		db:    db,
+		sqlDB: sqlDB,
+		deps:  deps,
+	}
+}
+
+func (r *atRiskRepository) ProcessDurations(
+	ctx context.Context,
+	deptID int,
+	since time.Time,
+) ([]*ProcessDuration, error) {
+
+	const q = `
+SELECT
+  COALESCE(oip.process_name, '') AS process_name,
+  percentile_cont(0.5) WITHIN GROUP (
+    ORDER BY EXTRACT(EPOCH FROM (ip.completed_at - ip.started_at))
+  ) AS median_seconds,
+  COUNT(*) AS samples
+FROM order_item_process_in_progresses ip
+JOIN order_item_processes oip ON oip.id = ip.process_id
+JOIN orders o ON o.id = oip.order_id
+WHERE
+  o.department_id = $1::INT
+  AND ip.started_at IS NOT NULL
+  AND ip.completed_at IS NOT NULL
+  AND ip.completed_at > ip.started_at
+  AND ip.completed_at >= $2
+GROUP BY COALESCE(oip.process_name, '');
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, deptID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*ProcessDuration
+	for rows.Next() {
+		var it ProcessDuration
+		if err := rows.Scan(&it.ProcessName, &it.Seconds, &it.Samples); err != nil {
+			return nil, err
+		}
+		result = append(result, &it)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *atRiskRepository) OpenSteps(
+	ctx context.Context,
+	deptID int,
+) ([]*OpenStep, error) {
+
+	const q = `
+SELECT
+  o.id,
+  oi.id,
+  COALESCE(oi.code, ''),
+  COALESCE(o.dentist_name, ''),
+  COALESCE(o.patient_name, ''),
+  (oi.custom_fields->>'delivery_date')::timestamptz AS delivery_at,
+  COALESCE(oi.custom_fields->>'priority', ''),
+  COALESCE(oip.process_name, ''),
+  oip.step_number,
+  running.started_at,
+  COALESCE(held.seconds, 0)
+FROM order_items oi
+JOIN orders o ON o.id = oi.order_id
+JOIN order_item_processes oip ON oip.order_item_id = oi.id
+LEFT JOIN LATERAL (
+  SELECT ip.started_at
+  FROM order_item_process_in_progresses ip
+  WHERE
+    ip.process_id = oip.id
+    AND ip.started_at IS NOT NULL
+    AND ip.completed_at IS NULL
+  ORDER BY ip.started_at DESC
+  LIMIT 1
+) running ON TRUE
+LEFT JOIN LATERAL (
+  SELECT SUM(EXTRACT(EPOCH FROM (COALESCE(h.resumed_at, now()) - h.held_at)))::float8 AS seconds
+  FROM order_item_holds h
+  WHERE h.order_item_id = oi.id
+) held ON TRUE
+WHERE
+  o.department_id = $1::INT
+  AND o.deleted_at IS NULL AND oi.deleted_at IS NULL
+  AND oi.custom_fields->>'delivery_date' IS NOT NULL
+  AND oi.custom_fields->>'status' IN (
+    'received',
+    'in_progress',
+    'qc',
+    'issue',
+    'rework',
+    'on_hold'
+  )
+  AND COALESCE(oip.custom_fields->>'status', '') NOT IN ('completed', 'skipped')
+ORDER BY oi.id, oip.step_number;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, deptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*OpenStep
+	for rows.Next() {
+		var (
+			it        OpenStep
+			startedAt sql.NullTime
+		)
+		if err := rows.Scan(
+			&it.OrderID,
+			&it.OrderItemID,
+			&it.Code,
+			&it.Dentist,
+			&it.Patient,
+			&it.DeliveryAt,
+			&it.Priority,
+			&it.ProcessName,
+			&it.StepNumber,
+			&startedAt,
+			&it.HeldSeconds,
+		); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			it.StartedAt = &startedAt.Time
+		}
+		result = append(result, &it)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_at_risk/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+const (
+	// history window used to learn per-process durations
+	durationLookback = 90 * 24 * time.Hour
+	// used when the department has no finished step at all yet
+	defaultStepDuration = 8 * time.Hour
+)
+
+type AtRiskService interface {
+	AtRisk(
+		ctx context.Context,
+		deptID int,
+	) ([]*model.AtRiskItem, error)
+}
+
+type atRiskService struct {
+	repo repository.AtRiskRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewAtRiskService(
+	repo repository.AtRiskRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) AtRiskService {
+	return &atRiskService{repo: repo, deps: deps}
+}
+
+// AtRisk lists in-progress order items whose estimated completion is later
+// than their promised delivery date, the most delayed first. Time spent on
+// hold pushes the delivery date back by as much.
+func (s *atRiskService) AtRisk(
+	ctx context.Context,
+	deptID int,
+) ([]*model.AtRiskItem, error) {
+	now := time.Now()
+
+	durations, err := s.repo.ProcessDurations(ctx, deptID, now.Add(-durationLookback))
+	if err != nil {
+		return nil, err
+	}
+	steps, err := s.repo.OpenSteps(ctx, deptID)
+	if err != nil {
+		return nil, err
+	}
+
+	estimates := EstimateCompletion(steps, durations, now)
+
+	out := make([]*model.AtRiskItem, 0)
+	for _, it := range estimates {
+		if it.DelayHours > 0 {
+			out = append(out, it)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DelayHours > out[j].DelayHours })
+
+	return out, nil
+}
+
+// EstimateCompletion projects a completion time per order item by adding the
+// median duration of every remaining step to now. A running step only counts
+// for the part of its median that has not elapsed yet. Processes without
+// history use the department-wide median.
+func EstimateCompletion(
+	steps []*repository.OpenStep,
+	durations []*repository.ProcessDuration,
+	now time.Time,
+) []*model.AtRiskItem {
+	byProcess := make(map[string]time.Duration, len(durations))
+	all := make([]float64, 0, len(durations))
+	for _, d := range durations {
+		byProcess[d.ProcessName] = time.Duration(d.Seconds * float64(time.Second))
+		all = append(all, d.Seconds)
+	}
+
+	fallback := defaultStepDuration
+	if len(all) > 0 {
+		sort.Float64s(all)
+		fallback = time.Duration(all[len(all)/2] * float64(time.Second))
+	}
+
+	items := make(map[int64]*model.AtRiskItem)
+	remaining := make(map[int64]time.Duration)
+	held := make(map[int64]time.Duration)
+	order := make([]int64, 0)
+
+	for _, st := range steps {
+		it, ok := items[st.OrderItemID]
+		if !ok {
+			it = &model.AtRiskItem{
+				ID:          st.OrderID,
+				OrderItemID: st.OrderItemID,
+				Code:        st.Code,
+				Dentist:     st.Dentist,
+				Patient:     st.Patient,
+				DeliveryAt:  st.DeliveryAt,
+				Priority:    st.Priority,
+				HeldHours:   math.Round(st.HeldSeconds/3600*10) / 10,
+			}
+			held[st.OrderItemID] = time.Duration(st.HeldSeconds * float64(time.Second))
+			items[st.OrderItemID] = it
+			order = append(order, st.OrderItemID)
+		}
+
+		d, ok := byProcess[st.ProcessName]
+		if !ok {
+			d = fallback
+		}
+		if st.StartedAt != nil {
+			d -= now.Sub(*st.StartedAt)
+			if d < 0 {
+				d = 0
+			}
+			it.CurrentProcess = st.ProcessName
+		}
+		if it.CurrentProcess == "" {
+			it.CurrentProcess = st.ProcessName
+		}
+
+		remaining[st.OrderItemID] += d
+		it.RemainingSteps++
+	}
+
+	out := make([]*model.AtRiskItem, 0, len(order))
+	for _, id := range order {
+		it := items[id]
+		it.EstimatedAt = now.Add(remaining[id])
+		due := it.DeliveryAt.Add(held[id])
+		it.DelayHours = math.Round(it.EstimatedAt.Sub(due).Hours()*10) / 10
+		out = append(out, it)
+	}
+	return out
+}
@@ -19,6 +19,14 @@ type CaseDailyRemakeStatsRepository interface {
 		isRemake bool,
 	) error
 
+	// RevertOne takes back a completion counted by UpsertOne.
+	RevertOne(
+		ctx context.Context,
+		completedAt time.Time,
+		departmentID int,
+		isRemake bool,
+	) error
+
 	RebuildRange(
 		ctx context.Context,
 		fromDate time.Time,
@@ -90,6 +98,34 @@ SET
 	return err
 }
 
+func (r *caseDailyRemakeStatsRepository) RevertOne(
+	ctx context.Context,
+	completedAt time.Time,
+	departmentID int,
+	isRemake bool,
+) error {
+	const q = `
+UPDATE case_daily_remake_stats
+SET
+  completed_cases = GREATEST(completed_cases - 1, 0),
+  remake_cases    = GREATEST(remake_cases - CASE WHEN $3 THEN 1 ELSE 0 END, 0),
+  updated_at      = now()
+WHERE
+  stat_date = $1
+  AND department_id = $2;
+`
+
+	_, err := r.sqlDB.ExecContext(
+		ctx,
+		q,
+		completedAt.UTC().Truncate(24*time.Hour),
+		departmentID,
+		isRemake,
+	)
+
+	return err
+}
+
 func (r *caseDailyRemakeStatsRepository) RebuildRange(
 	ctx context.Context,
 	fromDate time.Time,
@@ -19,6 +19,13 @@ type CaseDailyRemakeStatsService interface {
 		isRemake bool,
 	) error
 
+	RevertOne(
+		ctx context.Context,
+		completedAt time.Time,
+		departmentID int,
+		isRemake bool,
+	) error
+
 	RebuildRange(
 		ctx context.Context,
 		fromDate time.Time,
@@ -48,6 +55,9 @@ func NewCaseDailyRemakeStatsService(
 
 	pubsub.SubscribeAsync("dashboard:daily:remake:stats", func(payload *model.CaseDailyRemakeStatsUpsert) error {
 		ctx := context.Background()
+		if payload.Revert {
+			return svc.RevertOne(ctx, payload.CompletedAt, payload.DepartmentID, payload.IsRemake)
+		}
 		return svc.UpsertOne(ctx, payload.CompletedAt, payload.DepartmentID, payload.IsRemake)
 	})
 
@@ -63,6 +73,15 @@ func (s *caseDailyRemakeStatsService) UpsertOne(
 	return s.repo.UpsertOne(ctx, completedAt, departmentID, isRemake)
 }
 
+func (s *caseDailyRemakeStatsService) RevertOne(
+	ctx context.Context,
+	completedAt time.Time,
+	departmentID int,
+	isRemake bool,
+) error {
+	return s.repo.RevertOne(ctx, completedAt, departmentID, isRemake)
+}
+
 func (s *caseDailyRemakeStatsService) RebuildRange(
 	ctx context.Context,
 	fromDate time.Time,
@@ -19,12 +19,27 @@ type CaseDailyStatsRepository interface {
 		turnaroundSec int64,
 	) error
 
+	// RevertOne takes back a completion counted by UpsertOne.
+	RevertOne(
+		ctx context.Context,
+		completedAt time.Time,
+		departmentID int,
+		turnaroundSec int64,
+	) error
+
 	RebuildRange(
 		ctx context.Context,
 		fromDate time.Time,
 		toDate time.Time,
 	) error
 
+	// HeldSeconds sums the time an order item spent on hold up to "until".
+	HeldSeconds(
+		ctx context.Context,
+		orderItemID int64,
+		until time.Time,
+	) (float64, error)
+
 	AvgTurnaround(
 		ctx context.Context,
 		departmentID *int, // nil = all departments
@@ -90,6 +105,34 @@ SET
 	return err
 }
 
+func (r *caseDailyStatsRepository) RevertOne(
+	ctx context.Context,
+	completedAt time.Time,
+	departmentID int,
+	turnaroundSec int64,
+) error {
+	const q = `
+UPDATE case_daily_stats
+SET
+  completed_cases      = GREATEST(completed_cases - 1, 0),
+  total_turnaround_sec = GREATEST(total_turnaround_sec - $3, 0),
+  updated_at           = now()
+WHERE
+  stat_date = $1
+  AND department_id = $2;
+`
+
+	_, err := r.sqlDB.ExecContext(
+		ctx,
+		q,
+		completedAt.UTC().Truncate(24*time.Hour),
+		departmentID,
+		turnaroundSec,
+	)
+
+	return err
+}
+
 func (r *caseDailyStatsRepository) RebuildRange(
 	ctx context.Context,
 	fromDate time.Time,
@@ -133,6 +176,27 @@ SET
 	return err
 }
 
+func (r *caseDailyStatsRepository) HeldSeconds(
+	ctx context.Context,
+	orderItemID int64,
+	until time.Time,
+) (float64, error) {
+	const q = `
+SELECT
+  COALESCE(SUM(EXTRACT(EPOCH FROM (
+    LEAST(COALESCE(resumed_at, $2), $2) - held_at
+  ))), 0)::float8
+FROM order_item_holds
+WHERE
+  order_item_id = $1
+  AND held_at < $2;
+`
+
+	var sec float64
+	err := r.sqlDB.QueryRowContext(ctx, q, orderItemID, until).Scan(&sec)
+	return sec, err
+}
+
 func (r *caseDailyStatsRepository) AvgTurnaround(
 	ctx context.Context,
 	departmentID *int,
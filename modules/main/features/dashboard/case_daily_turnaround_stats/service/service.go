@@ -19,6 +19,13 @@ type CaseDailyStatsService interface {
 		turnaroundSec int64,
 	) error
 
+	RevertOne(
+		ctx context.Context,
+		completedAt time.Time,
+		departmentID int,
+		turnaroundSec int64,
+	) error
+
 	RebuildRange(
 		ctx context.Context,
 		fromDate time.Time,
@@ -49,6 +56,16 @@ func NewCaseDailyStatsService(
 	pubsub.SubscribeAsync("dashboard:daily:turnaround:stats", func(payload *model.CaseDailyStatsUpsert) error {
 		ctx := context.Background()
 		turnaroundsec := payload.CompletedAt.Sub(payload.ReceivedAt).Seconds()
+		if payload.OrderItemID > 0 {
+			heldsec, err := repo.HeldSeconds(ctx, payload.OrderItemID, payload.CompletedAt)
+			if err != nil {
+				return err
+			}
+			turnaroundsec -= heldsec
+		}
+		if payload.Revert {
+			return svc.RevertOne(ctx, payload.CompletedAt, payload.DepartmentID, int64(turnaroundsec))
+		}
 		return svc.UpsertOne(ctx, payload.CompletedAt, payload.DepartmentID, int64(turnaroundsec))
 	})
 
@@ -64,6 +81,15 @@ func (s *caseDailyStatsService) UpsertOne(
 	return s.repo.UpsertOne(ctx, completedAt, departmentID, turnaroundSec)
 }
 
+func (s *caseDailyStatsService) RevertOne(
+	ctx context.Context,
+	completedAt time.Time,
+	departmentID int,
+	turnaroundSec int64,
+) error {
+	return s.repo.RevertOne(ctx, completedAt, departmentID, turnaroundSec)
+}
+
 func (s *caseDailyStatsService) RebuildRange(
 	ctx context.Context,
 	fromDate time.Time,
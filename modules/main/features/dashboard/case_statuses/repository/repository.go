@@ -52,7 +52,9 @@ WHERE
     'in_progress',
     'qc',
     'issue',
-    'rework'
+    'rework',
+    'on_hold',
+    'cancelled'
   )
   AND (oi.custom_fields->>'delivery_date')::timestamptz >= date_trunc('day', now())
   AND (oi.custom_fields->>'delivery_date')::timestamptz <  date_trunc('day', now()) + interval '1 day'
@@ -66,7 +68,7 @@ GROUP BY
 	}
 	defer rows.Close()
 
-	res := make([]*model.CaseStatusCount, 0, 5)
+	res := make([]*model.CaseStatusCount, 0, 7)
 	for rows.Next() {
 		var rcd model.CaseStatusCount
 		if err := rows.Scan(&rcd.Status, &rcd.Count); err != nil {
@@ -15,6 +15,8 @@ var CaseStatusTargets = map[string]int{
 	"qc":          12,
 	"issue":       8,
 	"rework":      10,
+	"on_hold":     0,
+	"cancelled":   0,
 }
 
 type caseStatusMetaItem struct {
@@ -49,6 +51,16 @@ var caseStatusMeta = map[string]caseStatusMetaItem{
 		Color:  "#D97706",
 		Helper: "Cases requiring rework",
 	},
+	"on_hold": {
+		Label:  "on_hold",
+		Color:  "#7C3AED",
+		Helper: "Cases due today waiting on the clinic",
+	},
+	"cancelled": {
+		Label:  "cancelled",
+		Color:  "#6B7280",
+		Helper: "Cases due today that were cancelled",
+	},
 }
 
 type CaseStatusesService interface {
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/receivable_aging/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type ReceivableAgingHandler struct {
+	svc  service.ReceivableAgingService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableAgingHandler(svc service.ReceivableAgingService, deps *module.ModuleDeps[config.ModuleConfig]) *ReceivableAgingHandler {
+	return &ReceivableAgingHandler{svc: svc, deps: deps}
+}
+
+func (h *ReceivableAgingHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/dashboard/receivable-aging", h.ReceivableAging)
+}
+
+func (h *ReceivableAgingHandler) ReceivableAging(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	clinicID := utils.GetQueryAsInt(c, "clinic_id")
+
+	res, err := h.svc.ReceivableAging(c.UserContext(), deptID, clinicID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type AgingRow struct {
+	ClinicID   int
+	ClinicName *string
+	Bucket     string
+	Amount     float64
+	Count      int
+}
+
+type ReceivableAgingRepository interface {
+	AgingByClinic(
+		ctx context.Context,
+		departmentID int,
+		clinicID int,
+	) ([]*AgingRow, error)
+}
+
+type receivableAgingRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableAgingRepository(
+	db *generated.Client,
+	sqlDB *sql.DB,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) ReceivableAgingRepository {
+	return &receivableAgingRepository{
+		db:    db,
+		sqlDB: sqlDB,
+		deps:  deps,
+	}
+}
+
+// AgingByClinic buckets the outstanding amount of issued invoices and
+// uninvoiced completed orders by age; clinicID = 0 means all clinics.
+func (r *receivableAgingRepository) AgingByClinic(
+	ctx context.Context,
+	departmentID int,
+	clinicID int,
+) ([]*AgingRow, error) {
+	const q = `
+WITH alloc AS (
+  SELECT a.order_id, a.invoice_id, a.amount
+  FROM clinic_payment_allocations a
+  JOIN clinic_payments p ON p.id = a.payment_id
+  WHERE
+    p.deleted_at IS NULL
+    AND p.department_id = $1
+),
+docs AS (
+  SELECT
+    i.clinic_id,
+    i.clinic_name,
+    i.issued_at AS doc_date,
+    i.total     AS amount,
+    COALESCE((SELECT SUM(al.amount) FROM alloc al WHERE al.invoice_id = i.id), 0)
+      + COALESCE((
+        SELECT SUM(al.amount)
+        FROM alloc al
+        JOIN orders o ON o.id = al.order_id
+        WHERE o.invoice_id = i.id
+      ), 0) AS paid
+  FROM invoices i
+  WHERE
+    i.department_id = $1
+    AND i.status = 'issued'
+    AND i.deleted_at IS NULL
+  UNION ALL
+  SELECT
+    o.clinic_id,
+    o.clinic_name,
+    COALESCE(o.delivery_date, o.created_at),
+    COALESCE(o.total_price, 0),
+    COALESCE((SELECT SUM(al.amount) FROM alloc al WHERE al.order_id = o.id), 0)
+  FROM orders o
+  WHERE
+    o.department_id = $1
+    AND o.clinic_id IS NOT NULL
+    AND o.status_latest = 'completed'
+    AND o.invoice_id IS NULL
+    AND o.deleted_at IS NULL
+),
+open_docs AS (
+  SELECT
+    clinic_id,
+    clinic_name,
+    amount - paid AS outstanding,
+    FLOOR(EXTRACT(EPOCH FROM (now() - doc_date)) / 86400) AS age_days
+  FROM docs
+  WHERE
+    amount - paid > 0.005
+    AND ($2 = 0 OR clinic_id = $2)
+)
+SELECT
+  clinic_id,
+  MAX(clinic_name) AS clinic_name,
+  CASE
+    WHEN age_days <= 30 THEN '0_30'
+    WHEN age_days <= 60 THEN '31_60'
+    WHEN age_days <= 90 THEN '61_90'
+    ELSE '90_plus'
+  END               AS bucket,
+  SUM(outstanding)  AS amount,
+  COUNT(*)          AS count
+FROM open_docs
+GROUP BY clinic_id, bucket
+ORDER BY clinic_id;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, departmentID, clinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*AgingRow, 0)
+	for rows.Next() {
+		var (
+			rcd  AgingRow
+			name sql.NullString
+		)
+		if err := rows.Scan(&rcd.ClinicID, &name, &rcd.Bucket, &rcd.Amount, &rcd.Count); err != nil {
+			return nil, err
+		}
+		if name.Valid {
+			rcd.ClinicName = &name.String
+		}
+		res = append(res, &rcd)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/receivable_aging/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+var ReceivableAgingBuckets = []string{"0_30", "31_60", "61_90", "90_plus"}
+
+type ReceivableAgingService interface {
+	ReceivableAging(
+		ctx context.Context,
+		deptID int,
+		clinicID int,
+	) (*model.ReceivableAgingResult, error)
+}
+
+type receivableAgingService struct {
+	repo repository.ReceivableAgingRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableAgingService(
+	repo repository.ReceivableAgingRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) ReceivableAgingService {
+	return &receivableAgingService{repo: repo, deps: deps}
+}
+
+func kReceivableAging(deptID, clinicID int) string {
+	return fmt.Sprintf("dashboard:receivable_aging:dpt%d:c%d", deptID, clinicID)
+}
+
+func BuildReceivableAging(raw []*repository.AgingRow) *model.ReceivableAgingResult {
+	buckets := make(map[string]*model.ReceivableAgingBucket, len(ReceivableAgingBuckets))
+	out := &model.ReceivableAgingResult{
+		Buckets: make([]*model.ReceivableAgingBucket, 0, len(ReceivableAgingBuckets)),
+		Clinics: make([]*model.ReceivableAgingClinic, 0),
+	}
+	for _, key := range ReceivableAgingBuckets {
+		b := &model.ReceivableAgingBucket{Key: key}
+		buckets[key] = b
+		out.Buckets = append(out.Buckets, b)
+	}
+
+	clinics := make(map[int]*model.ReceivableAgingClinic)
+	for _, r := range raw {
+		if b, ok := buckets[r.Bucket]; ok {
+			b.Amount += r.Amount
+			b.Count += r.Count
+		}
+
+		cl, ok := clinics[r.ClinicID]
+		if !ok {
+			cl = &model.ReceivableAgingClinic{ClinicID: r.ClinicID, ClinicName: r.ClinicName}
+			clinics[r.ClinicID] = cl
+			out.Clinics = append(out.Clinics, cl)
+		}
+		switch r.Bucket {
+		case "0_30":
+			cl.Days0To30 += r.Amount
+		case "31_60":
+			cl.Days31To60 += r.Amount
+		case "61_90":
+			cl.Days61To90 += r.Amount
+		default:
+			cl.Days90Plus += r.Amount
+		}
+		cl.Total += r.Amount
+		out.Total += r.Amount
+	}
+
+	// largest debtors first
+	sort.SliceStable(out.Clinics, func(i, j int) bool {
+		return out.Clinics[i].Total > out.Clinics[j].Total
+	})
+
+	return out
+}
+
+func (s *receivableAgingService) ReceivableAging(
+	ctx context.Context,
+	deptID int,
+	clinicID int,
+) (*model.ReceivableAgingResult, error) {
+	return cache.Get(kReceivableAging(deptID, clinicID), cache.TTLShort, func() (*model.ReceivableAgingResult, error) {
+		raw, err := s.repo.AgingByClinic(ctx, deptID, clinicID)
+		if err != nil {
+			return nil, err
+		}
+		return BuildReceivableAging(raw), nil
+	})
+}
@@ -7,6 +7,9 @@ import (
 	activetodayhlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_active_today/handler"
 	activetodayrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_active_today/repository"
 	activetodaysvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_active_today/service"
+	atriskhlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_at_risk/handler"
+	atriskrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_at_risk/repository"
+	atrisksvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_at_risk/service"
 	duetodayhlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_due_today/handler"
 	duetodayrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_due_today/repository"
 	duetodaysvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/activity_due_today/service"
@@ -25,6 +28,15 @@ import (
 	casestatuseshlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/case_statuses/handler"
 	casestatusesrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/case_statuses/repository"
 	casestatusessvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/case_statuses/service"
+	receivableaginghlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/receivable_aging/handler"
+	receivableagingrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/receivable_aging/repository"
+	receivableagingsvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/receivable_aging/service"
+	remakeanalysishlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/remake_analysis/handler"
+	remakeanalysisrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/remake_analysis/repository"
+	remakeanalysissvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/remake_analysis/service"
+	technicianproductivityhlr "github.com/khiemnd777/andy_api/modules/main/features/dashboard/technician_productivity/handler"
+	technicianproductivityrepo "github.com/khiemnd777/andy_api/modules/main/features/dashboard/technician_productivity/repository"
+	technicianproductivitysvc "github.com/khiemnd777/andy_api/modules/main/features/dashboard/technician_productivity/service"
 	"github.com/khiemnd777/andy_api/modules/main/registry"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
@@ -52,6 +64,18 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	caseDailyRemakeStatsHandler.RegisterRoutes(router)
 	// cron.RegisterJob(dailyremakejobs.NewCaseDailyRemakeStatsRebuildRangeJob(caseDailyRemakeStatsSvc))
 
+	// Remake Analysis
+	remakeAnalysisRepo := remakeanalysisrepo.NewRemakeAnalysisRepository(entClient, deps.DB, deps)
+	remakeAnalysisSvc := remakeanalysissvc.NewRemakeAnalysisService(remakeAnalysisRepo, deps)
+	remakeAnalysisHandler := remakeanalysishlr.NewRemakeAnalysisHandler(remakeAnalysisSvc, deps)
+	remakeAnalysisHandler.RegisterRoutes(router)
+
+	// Technician Productivity
+	technicianProductivityRepo := technicianproductivityrepo.NewTechnicianProductivityRepository(entClient, deps.DB, deps)
+	technicianProductivitySvc := technicianproductivitysvc.NewTechnicianProductivityService(technicianProductivityRepo, deps)
+	technicianProductivityHandler := technicianproductivityhlr.NewTechnicianProductivityHandler(technicianProductivitySvc, deps)
+	technicianProductivityHandler.RegisterRoutes(router)
+
 	// Case Daily Completed Stats
 	caseDailyCompletedStatsRepo := dailycompletedrepo.NewCaseDailyCompletedStatsRepository(entClient, deps.DB, deps)
 	caseDailyCompletedStatsSvc := dailycompletedsvc.NewCaseDailyCompletedStatsService(caseDailyCompletedStatsRepo, deps)
@@ -71,6 +95,12 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	duetodayHandler := duetodayhlr.NewDueTodayHandler(duetodaySvc, deps)
 	duetodayHandler.RegisterRoutes(router)
 
+	// Activity At Risk
+	atriskRepo := atriskrepo.NewAtRiskRepository(entClient, deps.DB, deps)
+	atriskSvc := atrisksvc.NewAtRiskService(atriskRepo, deps)
+	atriskHandler := atriskhlr.NewAtRiskHandler(atriskSvc, deps)
+	atriskHandler.RegisterRoutes(router)
+
 	// Activity Active Today
 	activetodayRepo := activetodayrepo.NewActiveTodayRepository(entClient, deps.DB, deps)
 	activetodaySvc := activetodaysvc.NewActiveTodayService(activetodayRepo, deps)
@@ -83,6 +113,12 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	caseStatusesHandler := casestatuseshlr.NewCaseStatusesHandler(caseStatusesSvc, deps)
 	caseStatusesHandler.RegisterRoutes(router)
 
+	// Receivable Aging
+	receivableAgingRepo := receivableagingrepo.NewReceivableAgingRepository(entClient, deps.DB, deps)
+	receivableAgingSvc := receivableagingsvc.NewReceivableAgingService(receivableAgingRepo, deps)
+	receivableAgingHandler := receivableaginghlr.NewReceivableAgingHandler(receivableAgingSvc, deps)
+	receivableAgingHandler.RegisterRoutes(router)
+
 	return nil
 }
 
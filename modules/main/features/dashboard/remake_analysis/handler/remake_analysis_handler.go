@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/remake_analysis/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type RemakeAnalysisHandler struct {
+	svc  service.RemakeAnalysisService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewRemakeAnalysisHandler(svc service.RemakeAnalysisService, deps *module.ModuleDeps[config.ModuleConfig]) *RemakeAnalysisHandler {
+	return &RemakeAnalysisHandler{svc: svc, deps: deps}
+}
+
+func (h *RemakeAnalysisHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/dashboard/remake-analysis/breakdown", h.Breakdown)
+	app.RouterGet(router, "/:dept_id<int>/dashboard/remake-analysis/trend", h.Trend)
+	app.RouterGet(router, "/:dept_id<int>/dashboard/remake-analysis/orders", h.Orders)
+}
+
+// parseFilter reads department_id, from_date, to_date (exclusive), action and
+// the optional drill-down pair dimension/key.
+func parseFilter(c *fiber.Ctx) (model.RemakeAnalysisFilter, string, error) {
+	var filter model.RemakeAnalysisFilter
+
+	departmentID, err := utils.GetQueryAsNillableInt(c, "department_id")
+	if err != nil || (departmentID != nil && *departmentID <= 0) {
+		return filter, "invalid department_id", err
+	}
+	if departmentID == nil {
+		if deptID, ok := utils.GetDeptIDInt(c); ok && deptID > 0 {
+			departmentID = &deptID
+		}
+	}
+	filter.DepartmentID = departmentID
+
+	filter.FromDate, err = utils.ParseDate(utils.GetQueryAsString(c, "from_date"))
+	if err != nil {
+		return filter, "invalid from_date", err
+	}
+	filter.ToDate, err = utils.ParseDate(utils.GetQueryAsString(c, "to_date"))
+	if err != nil {
+		return filter, "invalid to_date", err
+	}
+	if !filter.ToDate.After(filter.FromDate) {
+		return filter, "to_date must be after from_date", nil
+	}
+
+	if action := utils.GetQueryAsString(c, "action"); action != "" {
+		filter.Action = &action
+	}
+	if dimension := utils.GetQueryAsString(c, "dimension"); dimension != "" {
+		filter.Dimension = &dimension
+	}
+	if key := utils.GetQueryAsString(c, "key"); key != "" {
+		filter.Key = &key
+	}
+	return filter, "", nil
+}
+
+func responseAnalysisError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrInvalidDimension) ||
+		errors.Is(err, service.ErrInvalidInterval) ||
+		errors.Is(err, service.ErrInvalidAction) {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
+
+func (h *RemakeAnalysisHandler) Breakdown(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	filter, msg, err := parseFilter(c)
+	if msg != "" {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, msg)
+	}
+
+	groupBy := utils.GetQueryAsString(c, "group_by", "reason")
+	limit := utils.GetQueryAsInt(c, "limit", 20)
+	if limit <= 0 || limit > 500 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid limit")
+	}
+
+	res, err := h.svc.Breakdown(c.UserContext(), filter, groupBy, limit)
+	if err != nil {
+		return responseAnalysisError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *RemakeAnalysisHandler) Trend(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	filter, msg, err := parseFilter(c)
+	if msg != "" {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, msg)
+	}
+
+	res, err := h.svc.Trend(c.UserContext(), filter, utils.GetQueryAsString(c, "interval", "week"))
+	if err != nil {
+		return responseAnalysisError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *RemakeAnalysisHandler) Orders(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	filter, msg, err := parseFilter(c)
+	if msg != "" {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, msg)
+	}
+
+	res, err := h.svc.Orders(c.UserContext(), filter, table.ParseTableQuery(c, 20))
+	if err != nil {
+		return responseAnalysisError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
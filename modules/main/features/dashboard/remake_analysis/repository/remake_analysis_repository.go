@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+// remakeDimensions yields one (remake_id, key, label, cost) row per value of
+// the dimension a remake falls under. The process and technician are those of
+// the last check-in or check-out on the original item. Product and restoration type split
+// the remake into its product lines, so their costs are the line prices.
+var remakeDimensions = map[string]string{
+	"reason": `
+  SELECT r.id, r.reason, r.reason, r.cost FROM remakes r`,
+	"clinic": `
+  SELECT r.id, COALESCE(r.clinic_id::text, 'none'), r.clinic_name, r.cost FROM remakes r`,
+	"dentist": `
+  SELECT r.id, COALESCE(r.dentist_id::text, 'none'), r.dentist_name, r.cost FROM remakes r`,
+	"process": `
+  SELECT r.id, COALESCE(r.process_id::text, 'none'), r.process_name, r.cost FROM remakes r`,
+	"technician": `
+  SELECT r.id, COALESCE(r.technician_id::text, 'none'), r.technician_name, r.cost FROM remakes r`,
+	"product": `
+  SELECT
+    r.id,
+    COALESCE(p.product_id::text, 'none'),
+    MAX(COALESCE(pr.name, p.product_code)),
+    CASE WHEN p.product_id IS NULL THEN r.cost
+         ELSE SUM(p.quantity * COALESCE(p.retail_price, 0)) END
+  FROM remakes r
+  LEFT JOIN order_item_products p ON p.order_item_id = r.item_id
+  LEFT JOIN products pr ON pr.id = p.product_id
+  GROUP BY r.id, r.cost, p.product_id`,
+	"restoration_type": `
+  SELECT
+    r.id,
+    COALESCE(rt.id::text, 'none'),
+    MAX(rt.name),
+    CASE WHEN COUNT(p.id) = 0 THEN r.cost
+         ELSE SUM(p.quantity * COALESCE(p.retail_price, 0)) END
+  FROM remakes r
+  LEFT JOIN order_item_products p ON p.order_item_id = r.item_id
+  LEFT JOIN product_restoration_types prt ON prt.product_id = p.product_id
+  LEFT JOIN restoration_types rt ON rt.id = prt.restoration_type_id AND rt.deleted_at IS NULL
+  GROUP BY r.id, r.cost, rt.id`,
+}
+
+// $1 from, $2 to, $3 department, $4 action, $5 key of the filter dimension
+const remakeScopeSQL = `
+WITH remakes AS (
+  SELECT
+    rl.id,
+    rl.action,
+    COALESCE(NULLIF(btrim(rl.reason), ''), 'none') AS reason,
+    rl.created_at,
+    oi.id                         AS item_id,
+    oi.code                       AS item_code,
+    COALESCE(oi.total_price, 0)   AS cost,
+    orig.id                       AS original_item_id,
+    orig.code                     AS original_item_code,
+    o.id                          AS order_id,
+    o.code                        AS order_code,
+    o.clinic_id,
+    o.clinic_name,
+    o.dentist_id,
+    o.dentist_name,
+    lp.process_id,
+    lp.process_name,
+    lp.assigned_id                AS technician_id,
+    lp.assigned_name              AS technician_name
+  FROM order_item_remake_logs rl
+  JOIN order_items oi ON oi.id = rl.item_id AND oi.deleted_at IS NULL
+  JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL
+  LEFT JOIN order_items orig ON orig.id = oi.parent_item_id
+  LEFT JOIN LATERAL (
+    SELECT p.process_id, p.process_name, ip.assigned_id, ip.assigned_name
+    FROM order_item_process_in_progresses ip
+    LEFT JOIN order_item_processes p ON p.id = ip.process_id
+    WHERE ip.order_item_id = oi.parent_item_id
+      AND COALESCE(ip.completed_at, ip.started_at) IS NOT NULL
+    ORDER BY COALESCE(ip.completed_at, ip.started_at) DESC, ip.id DESC
+    LIMIT 1
+  ) lp ON true
+  WHERE
+    rl.created_at >= $1
+    AND rl.created_at <  $2
+    AND ($3::INT IS NULL OR o.department_id = $3::INT)
+    AND ($4::TEXT IS NULL OR rl.action = $4::TEXT)
+),
+filter_dims (remake_id, key, label, cost) AS (%s
+),
+scoped AS (
+  SELECT r.*
+  FROM remakes r
+  WHERE $5::TEXT IS NULL
+     OR EXISTS (SELECT 1 FROM filter_dims d WHERE d.remake_id = r.id AND d.key = $5::TEXT)
+)`
+
+type RemakeAnalysisRepository interface {
+	Breakdown(ctx context.Context, filter model.RemakeAnalysisFilter, groupBy string, limit int) (*model.RemakeBreakdownResult, error)
+	Trend(ctx context.Context, filter model.RemakeAnalysisFilter, interval string) ([]*model.RemakeTrendPoint, error)
+	Orders(ctx context.Context, filter model.RemakeAnalysisFilter, query table.TableQuery) (table.TableListResult[model.RemakeAnalysisOrderDTO], error)
+}
+
+type remakeAnalysisRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewRemakeAnalysisRepository(
+	db *generated.Client,
+	sqlDB *sql.DB,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) RemakeAnalysisRepository {
+	return &remakeAnalysisRepository{
+		db:    db,
+		sqlDB: sqlDB,
+		deps:  deps,
+	}
+}
+
+func IsRemakeDimension(dimension string) bool {
+	_, ok := remakeDimensions[dimension]
+	return ok
+}
+
+func scopeSQL(filter model.RemakeAnalysisFilter) string {
+	dim := "reason"
+	if filter.Dimension != nil {
+		dim = *filter.Dimension
+	}
+	return fmt.Sprintf(remakeScopeSQL, remakeDimensions[dim])
+}
+
+func scopeArgs(filter model.RemakeAnalysisFilter) []any {
+	return []any{filter.FromDate, filter.ToDate, filter.DepartmentID, filter.Action, filter.Key}
+}
+
+func (r *remakeAnalysisRepository) Breakdown(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	groupBy string,
+	limit int,
+) (*model.RemakeBreakdownResult, error) {
+	q := scopeSQL(filter) + fmt.Sprintf(`,
+group_dims (remake_id, key, label, cost) AS (%s
+)
+SELECT
+  d.key,
+  MAX(d.label),
+  COUNT(DISTINCT s.id),
+  COUNT(DISTINCT s.id) FILTER (WHERE s.action = 'adjust'),
+  COUNT(DISTINCT s.id) FILTER (WHERE s.action = 'remake'),
+  COALESCE(SUM(d.cost), 0),
+  (SELECT COUNT(*) FROM scoped),
+  (SELECT COALESCE(SUM(cost), 0) FROM scoped)
+FROM group_dims d
+JOIN scoped s ON s.id = d.remake_id
+GROUP BY d.key
+ORDER BY 3 DESC, 6 DESC
+LIMIT $6;
+`, remakeDimensions[groupBy])
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, append(scopeArgs(filter), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &model.RemakeBreakdownResult{
+		GroupBy: groupBy,
+		Rows:    []*model.RemakeBreakdownRow{},
+	}
+	for rows.Next() {
+		var row model.RemakeBreakdownRow
+		if err := rows.Scan(
+			&row.Key,
+			&row.Label,
+			&row.Count,
+			&row.Adjusts,
+			&row.Remakes,
+			&row.Cost,
+			&res.Total,
+			&res.TotalCost,
+		); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, &row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, row := range res.Rows {
+		if res.Total > 0 {
+			row.Share = float64(row.Count) / float64(res.Total)
+		}
+	}
+	return res, nil
+}
+
+func (r *remakeAnalysisRepository) Trend(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	interval string,
+) ([]*model.RemakeTrendPoint, error) {
+	q := scopeSQL(filter) + `,
+buckets AS (
+  SELECT generate_series(
+    date_trunc($6::TEXT, $1::TIMESTAMPTZ),
+    $2::TIMESTAMPTZ - INTERVAL '1 microsecond',
+    ('1 ' || $6::TEXT)::INTERVAL
+  ) AS period
+),
+items AS (
+  SELECT date_trunc($6::TEXT, oi.created_at) AS period, COUNT(*) AS n
+  FROM order_items oi
+  JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL
+  WHERE
+    oi.deleted_at IS NULL
+    AND oi.created_at >= $1
+    AND oi.created_at <  $2
+    AND ($3::INT IS NULL OR o.department_id = $3::INT)
+  GROUP BY 1
+)
+SELECT
+  b.period,
+  COUNT(s.id),
+  COUNT(s.id) FILTER (WHERE s.action = 'adjust'),
+  COUNT(s.id) FILTER (WHERE s.action = 'remake'),
+  COALESCE(SUM(s.cost), 0),
+  COALESCE(MAX(i.n), 0)
+FROM buckets b
+LEFT JOIN scoped s ON date_trunc($6::TEXT, s.created_at) = b.period
+LEFT JOIN items i ON i.period = b.period
+GROUP BY b.period
+ORDER BY b.period;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, append(scopeArgs(filter), interval)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*model.RemakeTrendPoint{}
+	for rows.Next() {
+		var p model.RemakeTrendPoint
+		if err := rows.Scan(
+			&p.Period,
+			&p.Count,
+			&p.Adjusts,
+			&p.Remakes,
+			&p.Cost,
+			&p.Items,
+		); err != nil {
+			return nil, err
+		}
+		if p.Items > 0 {
+			p.Rate = float64(p.Count) / float64(p.Items)
+		}
+		out = append(out, &p)
+	}
+	return out, rows.Err()
+}
+
+func (r *remakeAnalysisRepository) Orders(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	query table.TableQuery,
+) (table.TableListResult[model.RemakeAnalysisOrderDTO], error) {
+	var out table.TableListResult[model.RemakeAnalysisOrderDTO]
+	out.Items = []*model.RemakeAnalysisOrderDTO{}
+
+	q := scopeSQL(filter) + `
+SELECT
+  s.id,
+  s.action,
+  NULLIF(s.reason, 'none'),
+  s.created_at,
+  s.order_id,
+  s.order_code,
+  s.item_id,
+  s.item_code,
+  s.original_item_id,
+  s.original_item_code,
+  s.clinic_id,
+  s.clinic_name,
+  s.dentist_id,
+  s.dentist_name,
+  s.process_id,
+  s.process_name,
+  s.technician_id,
+  s.technician_name,
+  s.cost,
+  COUNT(*) OVER ()
+FROM scoped s
+ORDER BY s.created_at DESC, s.id DESC
+LIMIT $6 OFFSET $7;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, append(scopeArgs(filter), query.Limit, query.Offset)...)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it model.RemakeAnalysisOrderDTO
+		if err := rows.Scan(
+			&it.ID,
+			&it.Action,
+			&it.Reason,
+			&it.CreatedAt,
+			&it.OrderID,
+			&it.OrderCode,
+			&it.OrderItemID,
+			&it.OrderItemCode,
+			&it.OriginalItemID,
+			&it.OriginalItemCode,
+			&it.ClinicID,
+			&it.ClinicName,
+			&it.DentistID,
+			&it.DentistName,
+			&it.ProcessID,
+			&it.ProcessName,
+			&it.TechnicianID,
+			&it.TechnicianName,
+			&it.Cost,
+			&out.Total,
+		); err != nil {
+			return out, err
+		}
+		out.Items = append(out.Items, &it)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+)
+
+// The breakdown queries run against Postgres; TEST_DATABASE_URL points at a
+// scratch database. Every table is created as a temporary table, which
+// shadows the real one for the single connection the test uses.
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+const remakeTestSchema = `
+CREATE TEMP TABLE orders (
+  id BIGINT PRIMARY KEY, code TEXT, department_id INT,
+  clinic_id BIGINT, clinic_name TEXT, dentist_id BIGINT, dentist_name TEXT,
+  deleted_at TIMESTAMPTZ
+);
+CREATE TEMP TABLE order_items (
+  id BIGINT PRIMARY KEY, code TEXT, order_id BIGINT, parent_item_id BIGINT,
+  total_price NUMERIC, deleted_at TIMESTAMPTZ
+);
+CREATE TEMP TABLE order_item_remake_logs (
+  id BIGINT PRIMARY KEY, item_id BIGINT, action TEXT, reason TEXT, created_at TIMESTAMPTZ
+);
+CREATE TEMP TABLE order_item_processes (
+  id BIGINT PRIMARY KEY, order_item_id BIGINT, process_id INT, process_name TEXT,
+  assigned_id BIGINT, assigned_name TEXT, step_number INT,
+  started_at TIMESTAMPTZ, completed_at TIMESTAMPTZ
+);
+CREATE TEMP TABLE order_item_process_in_progresses (
+  id BIGINT PRIMARY KEY, order_item_id BIGINT, process_id BIGINT,
+  assigned_id BIGINT, assigned_name TEXT,
+  started_at TIMESTAMPTZ, completed_at TIMESTAMPTZ
+);
+
+INSERT INTO orders (id, code, department_id) VALUES (1, 'O1', 5), (2, 'O2', 5);
+INSERT INTO order_items (id, code, order_id, parent_item_id, total_price) VALUES
+  (10, 'I10', 1, NULL, 100), (11, 'I11', 1, 10, 100),
+  (20, 'I20', 2, NULL, 50), (21, 'I21', 2, 20, 50);
+INSERT INTO order_item_remake_logs (id, item_id, action, reason, created_at) VALUES
+  (1, 11, 'remake', 'fit', '2026-03-05'),
+  (2, 21, 'adjust', 'shade', '2026-03-06');
+
+-- check-in/out leaves the step's own timestamps empty
+INSERT INTO order_item_processes (id, order_item_id, process_id, process_name, step_number) VALUES
+  (100, 10, 1, 'Design', 1), (101, 10, 2, 'Milling', 2), (102, 10, 3, 'Glazing', 3);
+INSERT INTO order_item_process_in_progresses (id, order_item_id, process_id, assigned_id, assigned_name, started_at, completed_at) VALUES
+  (1000, 10, 100, 7, 'An', '2026-03-01', '2026-03-01 10:00'),
+  (1001, 10, 101, 8, 'Binh', '2026-03-02', '2026-03-03'),
+  (1002, 10, 102, 9, 'Chi', '2026-03-02 12:00', NULL);
+`
+
+func TestBreakdownLastStepFromInProgress(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, remakeTestSchema); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+
+	repo := NewRemakeAnalysisRepository(nil, db, nil)
+	dept := 5
+	filter := model.RemakeAnalysisFilter{
+		DepartmentID: &dept,
+		FromDate:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		ToDate:       time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		groupBy  string
+		expected map[string]string
+	}{
+		// the check-out of milling is the latest move on item 10; item 20
+		// never went through the workflow
+		{"process", map[string]string{"2": "Milling", "none": ""}},
+		{"technician", map[string]string{"8": "Binh", "none": ""}},
+	}
+
+	for _, tt := range tests {
+		res, err := repo.Breakdown(ctx, filter, tt.groupBy, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.groupBy, err)
+		}
+		if res.Total != 2 || len(res.Rows) != len(tt.expected) {
+			t.Errorf("%s: total = %d, rows = %d; want 2, %d", tt.groupBy, res.Total, len(res.Rows), len(tt.expected))
+			continue
+		}
+		for _, row := range res.Rows {
+			label, ok := tt.expected[row.Key]
+			if !ok || row.Count != 1 || (label != "" && (row.Label == nil || *row.Label != label)) {
+				t.Errorf("%s: row %s %v count %d; want one of %v", tt.groupBy, row.Key, row.Label, row.Count, tt.expected)
+			}
+		}
+	}
+}
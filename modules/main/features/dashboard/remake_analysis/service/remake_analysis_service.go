@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/remake_analysis/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+var (
+	ErrInvalidDimension = errors.New("invalid dimension")
+	ErrInvalidInterval  = errors.New("invalid interval")
+	ErrInvalidAction    = errors.New("invalid action")
+)
+
+var (
+	RemakeTrendIntervals = []string{"day", "week", "month"}
+	RemakeActions        = []string{"adjust", "remake"}
+)
+
+type RemakeAnalysisService interface {
+	Breakdown(ctx context.Context, filter model.RemakeAnalysisFilter, groupBy string, limit int) (*model.RemakeBreakdownResult, error)
+	Trend(ctx context.Context, filter model.RemakeAnalysisFilter, interval string) ([]*model.RemakeTrendPoint, error)
+	Orders(ctx context.Context, filter model.RemakeAnalysisFilter, query table.TableQuery) (table.TableListResult[model.RemakeAnalysisOrderDTO], error)
+}
+
+type remakeAnalysisService struct {
+	repo repository.RemakeAnalysisRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewRemakeAnalysisService(
+	repo repository.RemakeAnalysisRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) RemakeAnalysisService {
+	return &remakeAnalysisService{repo: repo, deps: deps}
+}
+
+func validateFilter(filter model.RemakeAnalysisFilter) error {
+	if filter.Action != nil && !slices.Contains(RemakeActions, *filter.Action) {
+		return ErrInvalidAction
+	}
+	if filter.Dimension != nil && !repository.IsRemakeDimension(*filter.Dimension) {
+		return ErrInvalidDimension
+	}
+	if filter.Key != nil && filter.Dimension == nil {
+		return ErrInvalidDimension
+	}
+	return nil
+}
+
+func (s *remakeAnalysisService) Breakdown(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	groupBy string,
+	limit int,
+) (*model.RemakeBreakdownResult, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	if !repository.IsRemakeDimension(groupBy) {
+		return nil, ErrInvalidDimension
+	}
+	return s.repo.Breakdown(ctx, filter, groupBy, limit)
+}
+
+func (s *remakeAnalysisService) Trend(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	interval string,
+) ([]*model.RemakeTrendPoint, error) {
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(RemakeTrendIntervals, interval) {
+		return nil, ErrInvalidInterval
+	}
+	return s.repo.Trend(ctx, filter, interval)
+}
+
+func (s *remakeAnalysisService) Orders(
+	ctx context.Context,
+	filter model.RemakeAnalysisFilter,
+	query table.TableQuery,
+) (table.TableListResult[model.RemakeAnalysisOrderDTO], error) {
+	if err := validateFilter(filter); err != nil {
+		return table.TableListResult[model.RemakeAnalysisOrderDTO]{}, err
+	}
+	return s.repo.Orders(ctx, filter, query)
+}
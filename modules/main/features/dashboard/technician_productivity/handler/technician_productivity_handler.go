@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/technician_productivity/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type TechnicianProductivityHandler struct {
+	svc  service.TechnicianProductivityService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewTechnicianProductivityHandler(svc service.TechnicianProductivityService, deps *module.ModuleDeps[config.ModuleConfig]) *TechnicianProductivityHandler {
+	return &TechnicianProductivityHandler{svc: svc, deps: deps}
+}
+
+func (h *TechnicianProductivityHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/dashboard/technician-productivity", h.Productivity)
+}
+
+func (h *TechnicianProductivityHandler) Productivity(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "staff.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	departmentID, err := utils.GetQueryAsNillableInt(c, "department_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid department_id")
+	}
+	if departmentID != nil && *departmentID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid department_id")
+	}
+	if departmentID == nil {
+		if deptID, ok := utils.GetDeptIDInt(c); ok && deptID > 0 {
+			departmentID = &deptID
+		}
+	}
+
+	sectionID, err := utils.GetQueryAsNillableInt(c, "section_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid section_id")
+	}
+
+	var staffID *int64
+	if id := utils.GetQueryAsInt(c, "staff_id"); id > 0 {
+		v := int64(id)
+		staffID = &v
+	}
+
+	query := model.TechnicianProductivityQuery{
+		DepartmentID: departmentID,
+		SectionID:    sectionID,
+		StaffID:      staffID,
+		GroupBy:      utils.GetQueryAsString(c, "group_by", "staff"),
+	}
+
+	dates := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"from_date", &query.FromDate},
+		{"to_date", &query.ToDate},
+		{"previous_from_date", &query.PreviousFrom},
+		{"previous_to_date", &query.PreviousTo},
+	}
+	for _, d := range dates {
+		raw := utils.GetQueryAsString(c, d.name)
+		if raw == "" {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid "+d.name)
+		}
+		*d.dst, err = utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid "+d.name)
+		}
+	}
+
+	res, err := h.svc.Productivity(c.UserContext(), query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGroupBy) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
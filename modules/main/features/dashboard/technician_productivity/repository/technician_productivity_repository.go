@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type productivityGroup struct {
+	key  string
+	dims string // staff id, staff name, section id, section name, process id, process name
+}
+
+const (
+	staffKey   = `COALESCE(d.assigned_id::TEXT, 'none')`
+	processKey = `COALESCE(d.catalog_process_id::TEXT, d.process_name, 'none')`
+	staffDims  = `MAX(k.assigned_id), MAX(k.assigned_name)`
+	noStaff    = `NULL::BIGINT, NULL::TEXT`
+	procDims   = `MAX(k.catalog_process_id), MAX(k.process_name)`
+	noProc     = `NULL::INT, NULL::TEXT`
+)
+
+var productivityGroups = map[string]productivityGroup{
+	"staff": {
+		key:  staffKey,
+		dims: staffDims + `, NULL::INT, NULL::TEXT, ` + noProc,
+	},
+	"section": {
+		key:  `COALESCE(d.section_id::TEXT, d.section_name, 'none')`,
+		dims: noStaff + `, MAX(k.section_id), MAX(k.section_name), ` + noProc,
+	},
+	"process": {
+		key:  processKey,
+		dims: noStaff + `, NULL::INT, NULL::TEXT, ` + procDims,
+	},
+	"staff_process": {
+		key:  staffKey + ` || ':' || ` + processKey,
+		dims: staffDims + `, NULL::INT, NULL::TEXT, ` + procDims,
+	},
+}
+
+func IsProductivityGroup(groupBy string) bool {
+	_, ok := productivityGroups[groupBy]
+	return ok
+}
+
+// Every completed in-progress row is one step done by its assignee.
+// Hands-on time runs from check-in to check-out; queue wait from the previous
+// step's check-out (or the item's creation for the first step) to check-in.
+// A step is rework when it belongs to a remade item or repeats a process the
+// item already completed.
+//
+// $1..$4 current and previous ranges, $5 department, $6 section, $7 staff
+const technicianProductivitySQL = `
+WITH touched AS (
+  SELECT DISTINCT order_item_id
+  FROM order_item_process_in_progresses
+  WHERE
+    completed_at >= LEAST($1::TIMESTAMPTZ, $3::TIMESTAMPTZ)
+    AND completed_at <  GREATEST($2::TIMESTAMPTZ, $4::TIMESTAMPTZ)
+),
+base AS (
+  SELECT
+    ip.id,
+    ip.order_item_id,
+    ip.process_id,
+    ip.assigned_id,
+    ip.assigned_name,
+    ip.section_id,
+    ip.section_name,
+    ip.started_at,
+    ip.completed_at,
+    LAG(ip.id) OVER w           AS prev_id,
+    LAG(ip.completed_at) OVER w AS prev_completed_at,
+    oi.created_at               AS item_created_at,
+    oi.remake_count
+  FROM order_item_process_in_progresses ip
+  JOIN touched t ON t.order_item_id = ip.order_item_id
+  JOIN order_items oi ON oi.id = ip.order_item_id AND oi.deleted_at IS NULL
+  JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL
+  WHERE ($5::INT IS NULL OR o.department_id = $5::INT)
+  WINDOW w AS (PARTITION BY ip.order_item_id ORDER BY ip.started_at, ip.id)
+),
+done AS (
+  SELECT
+    b.id,
+    b.assigned_id,
+    b.assigned_name,
+    COALESCE(b.section_id, p.section_id)     AS section_id,
+    COALESCE(b.section_name, p.section_name) AS section_name,
+    p.process_id                             AS catalog_process_id,
+    p.process_name,
+    CASE
+      WHEN b.completed_at >= $1 AND b.completed_at < $2 THEN 'current'
+      WHEN b.completed_at >= $3 AND b.completed_at < $4 THEN 'previous'
+    END AS period,
+    EXTRACT(EPOCH FROM b.completed_at - b.started_at) AS hands_on_sec,
+    GREATEST(EXTRACT(EPOCH FROM b.started_at - CASE
+      WHEN b.prev_id IS NULL THEN b.item_created_at
+      ELSE b.prev_completed_at
+    END), 0) AS wait_sec,
+    (
+      b.remake_count > 0
+      OR ROW_NUMBER() OVER (PARTITION BY b.order_item_id, b.process_id ORDER BY b.completed_at, b.id) > 1
+    ) AS is_rework,
+    GREATEST(COALESCE(u.units, 0), 1) AS units
+  FROM base b
+  LEFT JOIN order_item_processes p ON p.id = b.process_id
+  LEFT JOIN LATERAL (
+    SELECT SUM(quantity) AS units
+    FROM order_item_products
+    WHERE order_item_id = b.order_item_id
+  ) u ON true
+  WHERE b.completed_at IS NOT NULL AND b.started_at IS NOT NULL
+),
+keyed AS (
+  SELECT d.*, %s AS key
+  FROM done d
+  WHERE
+    d.period IS NOT NULL
+    AND ($6::INT IS NULL OR d.section_id = $6::INT)
+    AND ($7::BIGINT IS NULL OR d.assigned_id = $7::BIGINT)
+)
+SELECT
+  GROUPING(k.key) = 1,
+  COALESCE(k.key, ''),
+  %s,
+  COUNT(*) FILTER (WHERE k.period = 'current'),
+  COALESCE(SUM(k.units) FILTER (WHERE k.period = 'current'), 0)::BIGINT,
+  COALESCE(AVG(k.hands_on_sec) FILTER (WHERE k.period = 'current'), 0),
+  COALESCE(AVG(k.wait_sec) FILTER (WHERE k.period = 'current'), 0),
+  COALESCE(AVG(k.is_rework::INT) FILTER (WHERE k.period = 'current'), 0),
+  COUNT(*) FILTER (WHERE k.period = 'previous'),
+  COALESCE(SUM(k.units) FILTER (WHERE k.period = 'previous'), 0)::BIGINT,
+  COALESCE(AVG(k.hands_on_sec) FILTER (WHERE k.period = 'previous'), 0),
+  COALESCE(AVG(k.wait_sec) FILTER (WHERE k.period = 'previous'), 0),
+  COALESCE(AVG(k.is_rework::INT) FILTER (WHERE k.period = 'previous'), 0)
+FROM keyed k
+GROUP BY GROUPING SETS ((k.key), ())
+ORDER BY 1 DESC, 9 DESC, 2;
+`
+
+type TechnicianProductivityRepository interface {
+	Productivity(ctx context.Context, query model.TechnicianProductivityQuery) (*model.TechnicianProductivityResult, error)
+}
+
+type technicianProductivityRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewTechnicianProductivityRepository(
+	db *generated.Client,
+	sqlDB *sql.DB,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) TechnicianProductivityRepository {
+	return &technicianProductivityRepository{
+		db:    db,
+		sqlDB: sqlDB,
+		deps:  deps,
+	}
+}
+
+func (r *technicianProductivityRepository) Productivity(
+	ctx context.Context,
+	query model.TechnicianProductivityQuery,
+) (*model.TechnicianProductivityResult, error) {
+	group := productivityGroups[query.GroupBy]
+	q := fmt.Sprintf(technicianProductivitySQL, group.key, group.dims)
+
+	rows, err := r.sqlDB.QueryContext(
+		ctx,
+		q,
+		query.FromDate,
+		query.ToDate,
+		query.PreviousFrom,
+		query.PreviousTo,
+		query.DepartmentID, // $5
+		query.SectionID,
+		query.StaffID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &model.TechnicianProductivityResult{
+		GroupBy: query.GroupBy,
+		Total:   &model.TechnicianProductivityRow{},
+		Rows:    []*model.TechnicianProductivityRow{},
+	}
+	for rows.Next() {
+		var (
+			row     model.TechnicianProductivityRow
+			isTotal bool
+			prev    model.TechnicianProductivityRow
+		)
+		if err := rows.Scan(
+			&isTotal,
+			&row.Key,
+			&row.StaffID,
+			&row.StaffName,
+			&row.SectionID,
+			&row.SectionName,
+			&row.ProcessID,
+			&row.ProcessName,
+			&row.Completed,
+			&row.Units,
+			&row.AvgHandsOnSec,
+			&row.AvgWaitSec,
+			&row.ReworkRatio,
+			&prev.Completed,
+			&prev.Units,
+			&prev.AvgHandsOnSec,
+			&prev.AvgWaitSec,
+			&prev.ReworkRatio,
+		); err != nil {
+			return nil, err
+		}
+		applyDelta(&row, &prev)
+
+		if isTotal {
+			res.Total = &model.TechnicianProductivityRow{
+				Completed:          row.Completed,
+				Units:              row.Units,
+				AvgHandsOnSec:      row.AvgHandsOnSec,
+				AvgWaitSec:         row.AvgWaitSec,
+				ReworkRatio:        row.ReworkRatio,
+				DeltaCompleted:     row.DeltaCompleted,
+				DeltaUnits:         row.DeltaUnits,
+				DeltaAvgHandsOnSec: row.DeltaAvgHandsOnSec,
+				DeltaAvgWaitSec:    row.DeltaAvgWaitSec,
+				DeltaReworkRatio:   row.DeltaReworkRatio,
+			}
+			continue
+		}
+		res.Rows = append(res.Rows, &row)
+	}
+
+	return res, rows.Err()
+}
+
+func applyDelta(cur, prev *model.TechnicianProductivityRow) {
+	cur.DeltaCompleted = cur.Completed - prev.Completed
+	cur.DeltaUnits = cur.Units - prev.Units
+	if cur.Completed == 0 || prev.Completed == 0 {
+		return
+	}
+	cur.DeltaAvgHandsOnSec = cur.AvgHandsOnSec - prev.AvgHandsOnSec
+	cur.DeltaAvgWaitSec = cur.AvgWaitSec - prev.AvgWaitSec
+	cur.DeltaReworkRatio = cur.ReworkRatio - prev.ReworkRatio
+}
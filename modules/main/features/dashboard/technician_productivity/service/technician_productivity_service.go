@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/dashboard/technician_productivity/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+var ErrInvalidGroupBy = errors.New("invalid group_by")
+
+type TechnicianProductivityService interface {
+	Productivity(ctx context.Context, query model.TechnicianProductivityQuery) (*model.TechnicianProductivityResult, error)
+}
+
+type technicianProductivityService struct {
+	repo repository.TechnicianProductivityRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewTechnicianProductivityService(
+	repo repository.TechnicianProductivityRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) TechnicianProductivityService {
+	return &technicianProductivityService{repo: repo, deps: deps}
+}
+
+func (s *technicianProductivityService) Productivity(
+	ctx context.Context,
+	query model.TechnicianProductivityQuery,
+) (*model.TechnicianProductivityResult, error) {
+	if !repository.IsProductivityGroup(query.GroupBy) {
+		return nil, ErrInvalidGroupBy
+	}
+	return s.repo.Productivity(ctx, query)
+}
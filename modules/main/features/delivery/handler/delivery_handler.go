@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	photoApi "github.com/khiemnd777/andy_api/shared/modules/photo"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+var errInvalidProofPhoto = errors.New("proof photos must be uploaded through the photo module first")
+
+type DeliveryHandler struct {
+	svc  service.DeliveryService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewDeliveryHandler(svc service.DeliveryService, deps *module.ModuleDeps[config.ModuleConfig]) *DeliveryHandler {
+	return &DeliveryHandler{svc: svc, deps: deps}
+}
+
+func (h *DeliveryHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/delivery/run/list", h.ListRuns)
+	app.RouterGet(router, "/:dept_id<int>/delivery/run/:id<int>", h.GetRun)
+	app.RouterPost(router, "/:dept_id<int>/delivery/run", h.CreateRun)
+	app.RouterPut(router, "/:dept_id<int>/delivery/run/:id<int>", h.UpdateRun)
+	app.RouterDelete(router, "/:dept_id<int>/delivery/run/:id<int>", h.DeleteRun)
+	app.RouterPost(router, "/:dept_id<int>/delivery/run/:id<int>/dispatch", h.Dispatch)
+	app.RouterPost(router, "/:dept_id<int>/delivery/run/:id<int>/items", h.AssignItems)
+	app.RouterDelete(router, "/:dept_id<int>/delivery/item/:id<int>", h.RemoveItem)
+	app.RouterPut(router, "/:dept_id<int>/delivery/item/:id<int>/status", h.UpdateItemStatus)
+	app.RouterPost(router, "/:dept_id<int>/delivery/item/:id<int>/proof", h.AttachProof)
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/delivery", h.GetByOrderID)
+}
+
+func (h *DeliveryHandler) ListRuns(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := table.ParseTableQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	var runDate *time.Time
+	if raw := utils.GetQueryAsString(c, "run_date"); raw != "" {
+		d, err := utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid run_date")
+		}
+		runDate = &d
+	}
+
+	res, err := h.svc.ListRuns(c.UserContext(), deptID, runDate, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *DeliveryHandler) GetRun(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	dto, err := h.svc.GetRun(c.UserContext(), int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "delivery run not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) CreateRun(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	payload, err := app.ParseBody[model.DeliveryRunUpsertDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.CreateRun(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *DeliveryHandler) UpdateRun(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.DeliveryRunUpsertDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	payload.DTO.ID = int64(id)
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.UpdateRun(c.UserContext(), deptID, payload)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) DeleteRun(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.delete"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.DeleteRun(c.UserContext(), deptID, int64(id)); err != nil {
+		return h.responseError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *DeliveryHandler) Dispatch(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Dispatch(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) AssignItems(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.DeliveryAssignDTO](c)
+	if err != nil || len(payload.Items) == 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.AssignItems(c.UserContext(), deptID, int64(id), payload)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "order item not found")
+		}
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) RemoveItem(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.RemoveItem(c.UserContext(), deptID, int64(id)); err != nil {
+		return h.responseError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *DeliveryHandler) UpdateItemStatus(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.DeliveryItemStatusDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.UpdateItemStatus(c.UserContext(), deptID, int64(id), payload)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) AttachProof(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.DeliveryProofDTO](c)
+	if err != nil || len(payload.PhotoIDs) == 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	// photos are stored by the photo module; only accept ids it knows about
+	photos, err := photoApi.BatchGetPhotosByIDs(c.UserContext(), utils.GetAccessToken(c), payload.PhotoIDs)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadGateway, err, err.Error())
+	}
+	found := make(map[int]struct{}, len(photos))
+	for _, p := range photos {
+		found[p.ID] = struct{}{}
+	}
+	for _, pid := range payload.PhotoIDs {
+		if _, ok := found[pid]; !ok {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, errInvalidProofPhoto, errInvalidProofPhoto.Error())
+		}
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.AttachProof(c.UserContext(), deptID, int64(id), payload.PhotoIDs)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *DeliveryHandler) GetByOrderID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "delivery.view", "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	if orderID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid order_id")
+	}
+
+	res, err := h.svc.GetItemsByOrderID(c.UserContext(), int64(orderID))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *DeliveryHandler) responseError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrRunNotFound),
+		errors.Is(err, repository.ErrItemNotFound):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+	case errors.Is(err, repository.ErrInvalidRun),
+		errors.Is(err, repository.ErrInvalidItemStatus),
+		errors.Is(err, repository.ErrInvalidShippingFee):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, repository.ErrRunCompleted),
+		errors.Is(err, repository.ErrRunHasDelivered),
+		errors.Is(err, repository.ErrOrderItemNotCompleted),
+		errors.Is(err, repository.ErrOrderItemAssigned),
+		errors.Is(err, repository.ErrItemDelivered),
+		errors.Is(err, repository.ErrOrderInvoiced):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -0,0 +1,36 @@
+package delivery
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/service"
+	orderrepo "github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	orderservice "github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "delivery" }
+func (feature) Priority() int { return 78 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	entClient := deps.Ent.(*generated.Client)
+	repo := repository.NewDeliveryRepository(entClient, deps)
+
+	orderRepo := orderrepo.NewOrderRepository(entClient, deps, cfMgr)
+	orderSvc := orderservice.NewOrderService(orderRepo, deps, cfMgr)
+
+	svc := service.NewDeliveryService(repo, orderSvc, deps)
+	h := handler.NewDeliveryHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
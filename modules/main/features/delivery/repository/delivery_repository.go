@@ -0,0 +1,662 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	orderrepo "github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/deliveryitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/deliveryrun"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+const (
+	RunStatusPlanned    = "planned"
+	RunStatusDispatched = "dispatched"
+	RunStatusCompleted  = "completed"
+
+	ItemStatusPacked         = "packed"
+	ItemStatusOutForDelivery = "out_for_delivery"
+	ItemStatusDelivered      = "delivered"
+	ItemStatusFailed         = "failed"
+)
+
+var (
+	ErrInvalidRun            = errors.New("route and run_date are required")
+	ErrRunNotFound           = errors.New("delivery run not found")
+	ErrRunCompleted          = errors.New("delivery run is already completed")
+	ErrRunHasDelivered       = errors.New("delivery run has delivered items")
+	ErrItemNotFound          = errors.New("delivery item not found")
+	ErrInvalidItemStatus     = errors.New("invalid delivery item status")
+	ErrInvalidShippingFee    = errors.New("shipping fee must not be negative")
+	ErrOrderItemNotCompleted = errors.New("order item is not completed")
+	ErrOrderItemAssigned     = errors.New("order item is already assigned to a delivery run")
+	ErrItemDelivered         = errors.New("delivery item is already delivered")
+	ErrOrderInvoiced         = orderrepo.ErrOrderInvoiced
+)
+
+type DeliveryRepository interface {
+	CreateRun(ctx context.Context, deptID int, userID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error)
+	UpdateRun(ctx context.Context, deptID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error)
+	GetRun(ctx context.Context, id int64) (*model.DeliveryRunDTO, error)
+	ListRuns(ctx context.Context, deptID int, runDate *time.Time, query table.TableQuery) (table.TableListResult[model.DeliveryRunDTO], error)
+	DeleteRun(ctx context.Context, deptID int, id int64) ([]int64, error)
+	Dispatch(ctx context.Context, deptID int, id int64) (*model.DeliveryRunDTO, error)
+	AssignItems(ctx context.Context, deptID int, runID int64, input *model.DeliveryAssignDTO) ([]int64, error)
+	RemoveItem(ctx context.Context, deptID int, id int64) (*model.DeliveryItemDTO, error)
+	UpdateItemStatus(ctx context.Context, deptID int, id int64, input *model.DeliveryItemStatusDTO) (*model.DeliveryItemDTO, error)
+	AttachProof(ctx context.Context, deptID int, id int64, photoIDs []int) (*model.DeliveryItemDTO, error)
+	GetItemsByOrderID(ctx context.Context, orderID int64) ([]*model.DeliveryItemDTO, error)
+	OrderShippingFee(ctx context.Context, orderID int64) (float64, error)
+}
+
+type deliveryRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewDeliveryRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) DeliveryRepository {
+	return &deliveryRepository{db: db, deps: deps}
+}
+
+func (r *deliveryRepository) CreateRun(ctx context.Context, deptID int, userID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error) {
+	dto := &input.DTO
+	if strings.TrimSpace(dto.Route) == "" || dto.RunDate.IsZero() {
+		return nil, ErrInvalidRun
+	}
+
+	entity, err := r.db.DeliveryRun.Create().
+		SetDepartmentID(deptID).
+		SetRoute(strings.TrimSpace(dto.Route)).
+		SetNillableCourierID(dto.CourierID).
+		SetNillableCourierName(dto.CourierName).
+		SetNillableVehicle(dto.Vehicle).
+		SetRunDate(dto.RunDate).
+		SetStatus(RunStatusPlanned).
+		SetNillableNote(dto.Note).
+		SetCreatedBy(userID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.mapRun(entity), nil
+}
+
+func (r *deliveryRepository) UpdateRun(ctx context.Context, deptID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error) {
+	dto := &input.DTO
+	if strings.TrimSpace(dto.Route) == "" || dto.RunDate.IsZero() {
+		return nil, ErrInvalidRun
+	}
+
+	run, err := r.loadRun(ctx, r.db, deptID, dto.ID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == RunStatusCompleted {
+		return nil, ErrRunCompleted
+	}
+
+	_, err = r.db.DeliveryRun.UpdateOneID(run.ID).
+		SetRoute(strings.TrimSpace(dto.Route)).
+		SetNillableCourierID(dto.CourierID).
+		SetNillableCourierName(dto.CourierName).
+		SetNillableVehicle(dto.Vehicle).
+		SetRunDate(dto.RunDate).
+		SetNillableNote(dto.Note).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetRun(ctx, run.ID)
+}
+
+func (r *deliveryRepository) GetRun(ctx context.Context, id int64) (*model.DeliveryRunDTO, error) {
+	entity, err := r.db.DeliveryRun.Query().
+		Where(
+			deliveryrun.ID(id),
+			deliveryrun.DeletedAtIsNil(),
+		).
+		WithItems(func(q *generated.DeliveryItemQuery) {
+			q.Order(generated.Asc(deliveryitem.FieldID))
+		}).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.mapRun(entity), nil
+}
+
+func (r *deliveryRepository) ListRuns(ctx context.Context, deptID int, runDate *time.Time, query table.TableQuery) (table.TableListResult[model.DeliveryRunDTO], error) {
+	q := r.db.DeliveryRun.Query().
+		Where(
+			deliveryrun.DepartmentIDEQ(deptID),
+			deliveryrun.DeletedAtIsNil(),
+		).
+		WithItems()
+
+	if runDate != nil {
+		from := time.Date(runDate.Year(), runDate.Month(), runDate.Day(), 0, 0, 0, 0, runDate.Location())
+		q = q.Where(
+			deliveryrun.RunDateGTE(from),
+			deliveryrun.RunDateLT(from.AddDate(0, 0, 1)),
+		)
+	}
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		deliveryrun.Table,
+		deliveryrun.FieldID,
+		deliveryrun.FieldRunDate,
+		func(src []*generated.DeliveryRun) []*model.DeliveryRunDTO {
+			out := make([]*model.DeliveryRunDTO, 0, len(src))
+			for _, run := range src {
+				out = append(out, r.mapRun(run))
+			}
+			return out
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.DeliveryRunDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+// DeleteRun soft-deletes the run and releases its items.
+// It returns the orders whose shipping fee must be recomputed.
+func (r *deliveryRepository) DeleteRun(ctx context.Context, deptID int, id int64) ([]int64, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	run, err := r.loadRun(ctx, tx.Client(), deptID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := tx.DeliveryItem.Query().
+		Where(deliveryitem.RunIDEQ(run.ID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]int64, 0, len(items))
+	for _, it := range items {
+		if it.Status == ItemStatusDelivered {
+			err = ErrRunHasDelivered
+			return nil, err
+		}
+		if !slices.Contains(orderIDs, it.OrderID) {
+			orderIDs = append(orderIDs, it.OrderID)
+		}
+	}
+	if err = r.ensureOrdersNotInvoiced(ctx, tx, orderIDs); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.DeliveryItem.Delete().
+		Where(deliveryitem.RunIDEQ(run.ID)).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.DeliveryRun.UpdateOneID(run.ID).
+		SetDeletedAt(time.Now()).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return orderIDs, nil
+}
+
+// Dispatch hands the run to the courier: packed items go out for delivery.
+func (r *deliveryRepository) Dispatch(ctx context.Context, deptID int, id int64) (*model.DeliveryRunDTO, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	run, err := r.loadRun(ctx, tx.Client(), deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == RunStatusCompleted {
+		err = ErrRunCompleted
+		return nil, err
+	}
+
+	if _, err = tx.DeliveryItem.Update().
+		Where(
+			deliveryitem.RunIDEQ(run.ID),
+			deliveryitem.StatusEQ(ItemStatusPacked),
+		).
+		SetStatus(ItemStatusOutForDelivery).
+		Save(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.DeliveryRun.UpdateOneID(run.ID).
+		SetStatus(RunStatusDispatched).
+		Save(ctx); err != nil {
+		return nil, err
+	}
+
+	updated, err := tx.DeliveryRun.Query().
+		Where(deliveryrun.ID(run.ID)).
+		WithItems().
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.mapRun(updated), nil
+}
+
+// AssignItems puts completed order items on the run. An item that failed on
+// another run is moved over and packed again.
+// It returns the orders whose shipping fee must be recomputed.
+func (r *deliveryRepository) AssignItems(ctx context.Context, deptID int, runID int64, input *model.DeliveryAssignDTO) ([]int64, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	run, err := r.loadRun(ctx, tx.Client(), deptID, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == RunStatusCompleted {
+		err = ErrRunCompleted
+		return nil, err
+	}
+
+	status := ItemStatusPacked
+	if run.Status == RunStatusDispatched {
+		status = ItemStatusOutForDelivery
+	}
+
+	orderIDs := make([]int64, 0, len(input.Items))
+	for _, in := range input.Items {
+		if in.ShippingFee < 0 {
+			err = ErrInvalidShippingFee
+			return nil, err
+		}
+
+		var oi *generated.OrderItem
+		oi, err = tx.OrderItem.Query().
+			Where(
+				orderitem.ID(in.OrderItemID),
+				orderitem.DeletedAtIsNil(),
+			).
+			Only(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !isOrderItemCompleted(oi) {
+			err = ErrOrderItemNotCompleted
+			return nil, err
+		}
+
+		var ord *generated.Order
+		ord, err = tx.Order.Query().
+			Where(
+				order.ID(oi.OrderID),
+				order.DepartmentIDEQ(deptID),
+				order.DeletedAtIsNil(),
+			).
+			Only(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ord.InvoiceID != nil {
+			err = ErrOrderInvoiced
+			return nil, err
+		}
+
+		var existing *generated.DeliveryItem
+		existing, err = tx.DeliveryItem.Query().
+			Where(deliveryitem.OrderItemIDEQ(oi.ID)).
+			Only(ctx)
+		if err != nil && !generated.IsNotFound(err) {
+			return nil, err
+		}
+
+		if existing != nil {
+			if existing.Status != ItemStatusFailed {
+				err = ErrOrderItemAssigned
+				return nil, err
+			}
+			_, err = tx.DeliveryItem.UpdateOneID(existing.ID).
+				SetRunID(run.ID).
+				SetStatus(status).
+				SetShippingFee(in.ShippingFee).
+				ClearFailureReason().
+				Save(ctx)
+		} else {
+			_, err = tx.DeliveryItem.Create().
+				SetRunID(run.ID).
+				SetOrderID(ord.ID).
+				SetOrderItemID(oi.ID).
+				SetNillableOrderItemCode(oi.Code).
+				SetNillableClinicID(ord.ClinicID).
+				SetNillableClinicName(ord.ClinicName).
+				SetStatus(status).
+				SetShippingFee(in.ShippingFee).
+				Save(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(orderIDs, ord.ID) {
+			orderIDs = append(orderIDs, ord.ID)
+		}
+	}
+
+	return orderIDs, nil
+}
+
+func (r *deliveryRepository) RemoveItem(ctx context.Context, deptID int, id int64) (*model.DeliveryItemDTO, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	item, err := r.loadItem(ctx, tx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Status == ItemStatusDelivered {
+		err = ErrItemDelivered
+		return nil, err
+	}
+	if err = r.ensureOrdersNotInvoiced(ctx, tx, []int64{item.OrderID}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.DeliveryItem.DeleteOneID(item.ID).Exec(ctx); err != nil {
+		return nil, err
+	}
+	if err = r.syncRunStatus(ctx, tx, item.RunID); err != nil {
+		return nil, err
+	}
+
+	return mapper.MapAs[*generated.DeliveryItem, *model.DeliveryItemDTO](item), nil
+}
+
+func (r *deliveryRepository) UpdateItemStatus(ctx context.Context, deptID int, id int64, input *model.DeliveryItemStatusDTO) (*model.DeliveryItemDTO, error) {
+	if !isItemStatus(input.Status) {
+		return nil, ErrInvalidItemStatus
+	}
+	if input.ShippingFee != nil && *input.ShippingFee < 0 {
+		return nil, ErrInvalidShippingFee
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	item, err := r.loadItem(ctx, tx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.ShippingFee != nil && *input.ShippingFee != item.ShippingFee {
+		if err = r.ensureOrdersNotInvoiced(ctx, tx, []int64{item.OrderID}); err != nil {
+			return nil, err
+		}
+	}
+
+	up := tx.DeliveryItem.UpdateOneID(item.ID).
+		SetStatus(input.Status).
+		SetNillableRecipientName(input.RecipientName).
+		SetNillableShippingFee(input.ShippingFee)
+
+	switch input.Status {
+	case ItemStatusDelivered:
+		up = up.SetDeliveredAt(time.Now()).ClearFailureReason()
+	case ItemStatusFailed:
+		up = up.ClearDeliveredAt().SetNillableFailureReason(input.FailureReason)
+	default:
+		up = up.ClearDeliveredAt().ClearFailureReason()
+	}
+
+	updated, err := up.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.syncRunStatus(ctx, tx, item.RunID); err != nil {
+		return nil, err
+	}
+
+	return mapper.MapAs[*generated.DeliveryItem, *model.DeliveryItemDTO](updated), nil
+}
+
+func (r *deliveryRepository) AttachProof(ctx context.Context, deptID int, id int64, photoIDs []int) (*model.DeliveryItemDTO, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	item, err := r.loadItem(ctx, tx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := slices.Clone(item.ProofPhotoIds)
+	for _, pid := range photoIDs {
+		if pid > 0 && !slices.Contains(ids, pid) {
+			ids = append(ids, pid)
+		}
+	}
+
+	updated, err := tx.DeliveryItem.UpdateOneID(item.ID).
+		SetProofPhotoIds(ids).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.MapAs[*generated.DeliveryItem, *model.DeliveryItemDTO](updated), nil
+}
+
+func (r *deliveryRepository) GetItemsByOrderID(ctx context.Context, orderID int64) ([]*model.DeliveryItemDTO, error) {
+	items, err := r.db.DeliveryItem.Query().
+		Where(deliveryitem.OrderIDEQ(orderID)).
+		Order(generated.Asc(deliveryitem.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.DeliveryItem, *model.DeliveryItemDTO](items), nil
+}
+
+func (r *deliveryRepository) OrderShippingFee(ctx context.Context, orderID int64) (float64, error) {
+	items, err := r.db.DeliveryItem.Query().
+		Where(deliveryitem.OrderIDEQ(orderID)).
+		All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, it := range items {
+		total += it.ShippingFee
+	}
+	return total, nil
+}
+
+// -- helpers
+
+func (r *deliveryRepository) loadRun(ctx context.Context, db *generated.Client, deptID int, id int64) (*generated.DeliveryRun, error) {
+	run, err := db.DeliveryRun.Query().
+		Where(
+			deliveryrun.ID(id),
+			deliveryrun.DepartmentIDEQ(deptID),
+			deliveryrun.DeletedAtIsNil(),
+		).
+		Only(ctx)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return nil, ErrRunNotFound
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+func (r *deliveryRepository) loadItem(ctx context.Context, tx *generated.Tx, deptID int, id int64) (*generated.DeliveryItem, error) {
+	item, err := tx.DeliveryItem.Query().
+		Where(
+			deliveryitem.ID(id),
+			deliveryitem.HasRunWith(
+				deliveryrun.DepartmentIDEQ(deptID),
+				deliveryrun.DeletedAtIsNil(),
+			),
+		).
+		Only(ctx)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *deliveryRepository) ensureOrdersNotInvoiced(ctx context.Context, tx *generated.Tx, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	invoiced, err := tx.Order.Query().
+		Where(
+			order.IDIn(orderIDs...),
+			order.InvoiceIDNotNil(),
+		).
+		Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if invoiced {
+		return ErrOrderInvoiced
+	}
+	return nil
+}
+
+// syncRunStatus completes a dispatched run once every item is delivered or failed.
+func (r *deliveryRepository) syncRunStatus(ctx context.Context, tx *generated.Tx, runID int64) error {
+	run, err := tx.DeliveryRun.Get(ctx, runID)
+	if err != nil {
+		return err
+	}
+	if run.Status == RunStatusPlanned {
+		return nil
+	}
+
+	open, err := tx.DeliveryItem.Query().
+		Where(
+			deliveryitem.RunIDEQ(runID),
+			deliveryitem.StatusIn(ItemStatusPacked, ItemStatusOutForDelivery),
+		).
+		Exist(ctx)
+	if err != nil {
+		return err
+	}
+
+	status := RunStatusCompleted
+	if open {
+		status = RunStatusDispatched
+	}
+	if status == run.Status {
+		return nil
+	}
+	_, err = tx.DeliveryRun.UpdateOneID(runID).SetStatus(status).Save(ctx)
+	return err
+}
+
+func (r *deliveryRepository) mapRun(entity *generated.DeliveryRun) *model.DeliveryRunDTO {
+	dto := mapper.MapAs[*generated.DeliveryRun, *model.DeliveryRunDTO](entity)
+	dto.Items = mapper.MapListAs[*generated.DeliveryItem, *model.DeliveryItemDTO](entity.Edges.Items)
+	return dto
+}
+
+func isItemStatus(status string) bool {
+	switch status {
+	case ItemStatusPacked, ItemStatusOutForDelivery, ItemStatusDelivered, ItemStatusFailed:
+		return true
+	}
+	return false
+}
+
+// isOrderItemCompleted reads the process-derived status kept in custom fields,
+// falling back to the status column.
+func isOrderItemCompleted(oi *generated.OrderItem) bool {
+	if status := utils.SafeGetString(oi.CustomFields, "status"); status != "" {
+		return status == "completed"
+	}
+	return oi.Status == "completed"
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/delivery/repository"
+	orderservice "github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type DeliveryService interface {
+	CreateRun(ctx context.Context, deptID int, userID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error)
+	UpdateRun(ctx context.Context, deptID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error)
+	GetRun(ctx context.Context, id int64) (*model.DeliveryRunDTO, error)
+	ListRuns(ctx context.Context, deptID int, runDate *time.Time, query table.TableQuery) (table.TableListResult[model.DeliveryRunDTO], error)
+	DeleteRun(ctx context.Context, deptID int, id int64) error
+	Dispatch(ctx context.Context, deptID int, id int64) (*model.DeliveryRunDTO, error)
+	AssignItems(ctx context.Context, deptID int, runID int64, input *model.DeliveryAssignDTO) (*model.DeliveryRunDTO, error)
+	RemoveItem(ctx context.Context, deptID int, id int64) error
+	UpdateItemStatus(ctx context.Context, deptID int, id int64, input *model.DeliveryItemStatusDTO) (*model.DeliveryItemDTO, error)
+	AttachProof(ctx context.Context, deptID int, id int64, photoIDs []int) (*model.DeliveryItemDTO, error)
+	GetItemsByOrderID(ctx context.Context, orderID int64) ([]*model.DeliveryItemDTO, error)
+}
+
+type deliveryService struct {
+	repo     repository.DeliveryRepository
+	orderSvc orderservice.OrderService
+	deps     *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewDeliveryService(repo repository.DeliveryRepository, orderSvc orderservice.OrderService, deps *module.ModuleDeps[config.ModuleConfig]) DeliveryService {
+	return &deliveryService{repo: repo, orderSvc: orderSvc, deps: deps}
+}
+
+func kRunByID(id int64) string {
+	return fmt.Sprintf("delivery:run:id:%d", id)
+}
+
+func kRunListAll() string {
+	return "delivery:run:list:*"
+}
+
+func kItemsByOrderAll() string {
+	return "delivery:order:*"
+}
+
+func kItemsByOrder(orderID int64) string {
+	return fmt.Sprintf("delivery:order:%d", orderID)
+}
+
+func kRunList(deptID int, runDate *time.Time, q table.TableQuery) string {
+	orderBy := ""
+	if q.OrderBy != nil {
+		orderBy = *q.OrderBy
+	}
+	date := ""
+	if runDate != nil {
+		date = runDate.Format("2006-01-02")
+	}
+	return fmt.Sprintf("delivery:run:list:dpt%d:r%s:l%d:p%d:o%s:d%s", deptID, date, q.Limit, q.Page, orderBy, q.Direction)
+}
+
+func (s *deliveryService) CreateRun(ctx context.Context, deptID int, userID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error) {
+	dto, err := s.repo.CreateRun(ctx, deptID, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kRunListAll())
+	realtime.BroadcastToDept(deptID, "delivery:changed", nil)
+
+	return dto, nil
+}
+
+func (s *deliveryService) UpdateRun(ctx context.Context, deptID int, input *model.DeliveryRunUpsertDTO) (*model.DeliveryRunDTO, error) {
+	dto, err := s.repo.UpdateRun(ctx, deptID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(deptID, dto.ID)
+
+	return dto, nil
+}
+
+func (s *deliveryService) GetRun(ctx context.Context, id int64) (*model.DeliveryRunDTO, error) {
+	return cache.Get(kRunByID(id), cache.TTLShort, func() (*model.DeliveryRunDTO, error) {
+		return s.repo.GetRun(ctx, id)
+	})
+}
+
+func (s *deliveryService) ListRuns(ctx context.Context, deptID int, runDate *time.Time, q table.TableQuery) (table.TableListResult[model.DeliveryRunDTO], error) {
+	type boxed = table.TableListResult[model.DeliveryRunDTO]
+	key := kRunList(deptID, runDate, q)
+
+	ptr, err := cache.Get(key, cache.TTLShort, func() (*boxed, error) {
+		res, e := s.repo.ListRuns(ctx, deptID, runDate, q)
+		if e != nil {
+			return nil, e
+		}
+		return &res, nil
+	})
+	if err != nil {
+		var zero boxed
+		return zero, err
+	}
+	return *ptr, nil
+}
+
+func (s *deliveryService) DeleteRun(ctx context.Context, deptID int, id int64) error {
+	orderIDs, err := s.repo.DeleteRun(ctx, deptID, id)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(deptID, id)
+
+	return s.syncShippingFees(ctx, orderIDs)
+}
+
+func (s *deliveryService) Dispatch(ctx context.Context, deptID int, id int64) (*model.DeliveryRunDTO, error) {
+	dto, err := s.repo.Dispatch(ctx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(deptID, id)
+
+	return dto, nil
+}
+
+func (s *deliveryService) AssignItems(ctx context.Context, deptID int, runID int64, input *model.DeliveryAssignDTO) (*model.DeliveryRunDTO, error) {
+	orderIDs, err := s.repo.AssignItems(ctx, deptID, runID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// a failed item may have been moved off another run
+	cache.InvalidateKeys("delivery:run:id:*")
+	s.invalidate(deptID, runID)
+
+	if err := s.syncShippingFees(ctx, orderIDs); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetRun(ctx, runID)
+}
+
+func (s *deliveryService) RemoveItem(ctx context.Context, deptID int, id int64) error {
+	item, err := s.repo.RemoveItem(ctx, deptID, id)
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(deptID, item.RunID)
+
+	return s.syncShippingFees(ctx, []int64{item.OrderID})
+}
+
+func (s *deliveryService) UpdateItemStatus(ctx context.Context, deptID int, id int64, input *model.DeliveryItemStatusDTO) (*model.DeliveryItemDTO, error) {
+	dto, err := s.repo.UpdateItemStatus(ctx, deptID, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(deptID, dto.RunID)
+
+	if input.ShippingFee != nil {
+		if err := s.syncShippingFees(ctx, []int64{dto.OrderID}); err != nil {
+			return nil, err
+		}
+	}
+
+	return dto, nil
+}
+
+func (s *deliveryService) AttachProof(ctx context.Context, deptID int, id int64, photoIDs []int) (*model.DeliveryItemDTO, error) {
+	dto, err := s.repo.AttachProof(ctx, deptID, id, photoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(deptID, dto.RunID)
+
+	return dto, nil
+}
+
+func (s *deliveryService) GetItemsByOrderID(ctx context.Context, orderID int64) ([]*model.DeliveryItemDTO, error) {
+	return cache.GetList(kItemsByOrder(orderID), cache.TTLShort, func() ([]*model.DeliveryItemDTO, error) {
+		return s.repo.GetItemsByOrderID(ctx, orderID)
+	})
+}
+
+func (s *deliveryService) invalidate(deptID int, runID int64) {
+	cache.InvalidateKeys(kRunByID(runID), kRunListAll(), kItemsByOrderAll())
+	realtime.BroadcastToDept(deptID, "delivery:changed", nil)
+}
+
+// syncShippingFees writes the summed delivery fees back to each order,
+// which also recomputes the order total.
+func (s *deliveryService) syncShippingFees(ctx context.Context, orderIDs []int64) error {
+	for _, orderID := range orderIDs {
+		fee, err := s.repo.OrderShippingFee(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if _, err := s.orderSvc.UpdateShippingFee(ctx, orderID, fee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
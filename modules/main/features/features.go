@@ -5,16 +5,23 @@ import (
 	_ "github.com/khiemnd777/andy_api/modules/main/features/brand"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/category"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/clinic"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/commission"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/customer"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/dashboard"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/delivery"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/dentist"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/invoice"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/material"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/order"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/patient"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/payroll"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/price_list"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/printing"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/process"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/product"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/promotion"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/raw_material"
+	_ "github.com/khiemnd777/andy_api/modules/main/features/receivable"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/restoration_type"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/section"
 	_ "github.com/khiemnd777/andy_api/modules/main/features/staff"
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type InvoiceHandler struct {
+	svc  service.InvoiceService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewInvoiceHandler(svc service.InvoiceService, deps *module.ModuleDeps[config.ModuleConfig]) *InvoiceHandler {
+	return &InvoiceHandler{svc: svc, deps: deps}
+}
+
+func (h *InvoiceHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/invoice/list", h.List)
+	app.RouterGet(router, "/:dept_id<int>/invoice/search", h.Search)
+	app.RouterGet(router, "/:dept_id<int>/invoice/:id<int>", h.GetByID)
+	app.RouterPost(router, "/:dept_id<int>/invoice/issue", h.Issue)
+	app.RouterPost(router, "/:dept_id<int>/invoice/:id<int>/void", h.Void)
+}
+
+func (h *InvoiceHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "invoice.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := table.ParseTableQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+	clinicID := utils.GetQueryAsInt(c, "clinic_id")
+	var clinicPtr *int
+	if clinicID > 0 {
+		clinicPtr = &clinicID
+	}
+	res, err := h.svc.List(c.UserContext(), deptID, clinicPtr, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *InvoiceHandler) Search(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "invoice.search"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := dbutils.ParseSearchQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+	res, err := h.svc.Search(c.UserContext(), deptID, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *InvoiceHandler) GetByID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "invoice.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "invoice not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *InvoiceHandler) Issue(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "invoice.issue"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	payload, err := app.ParseBody[model.InvoiceIssueDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Issue(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoInvoiceableOrders) || errors.Is(err, repository.ErrOrdersAlreadyInvoiced) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *InvoiceHandler) Void(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "invoice.void"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload := &model.InvoiceVoidDTO{}
+	if len(c.Body()) > 0 {
+		parsed, err := app.ParseBody[model.InvoiceVoidDTO](c)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+		}
+		payload = parsed
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Void(c.UserContext(), deptID, int64(id), userID, payload.Reason)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "invoice not found")
+		}
+		if errors.Is(err, repository.ErrInvoiceAlreadyVoided) || errors.Is(err, repository.ErrInvoiceHasPayments) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
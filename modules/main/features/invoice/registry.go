@@ -0,0 +1,29 @@
+package invoice
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "invoice" }
+func (feature) Priority() int { return 75 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	repo := repository.NewInvoiceRepository(deps.Ent.(*generated.Client), deps)
+	svc := service.NewInvoiceService(repo, deps)
+	h := handler.NewInvoiceHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
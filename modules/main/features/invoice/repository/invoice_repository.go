@@ -0,0 +1,607 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicpayment"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicpaymentallocation"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/invoice"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/invoiceitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/ordercancellation"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocessinprogress"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemproduct"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/promotionusage"
+	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+const (
+	InvoiceStatusIssued = "issued"
+	InvoiceStatusVoid   = "void"
+)
+
+var (
+	ErrNoInvoiceableOrders   = errors.New("no completed orders to invoice in this period")
+	ErrInvoiceAlreadyVoided  = errors.New("invoice is already voided")
+	ErrOrdersAlreadyInvoiced = errors.New("some orders were invoiced concurrently")
+	ErrInvoiceHasPayments    = errors.New("invoice has payments allocated to it")
+)
+
+type InvoiceRepository interface {
+	Issue(ctx context.Context, deptID int, userID int, input *model.InvoiceIssueDTO) (*model.InvoiceDTO, error)
+	Void(ctx context.Context, deptID int, id int64, userID int, reason *string) (*model.InvoiceDTO, []int64, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.InvoiceDTO, error)
+	List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.InvoiceDTO], error)
+	Search(ctx context.Context, deptID int, query dbutils.SearchQuery) (dbutils.SearchResult[model.InvoiceDTO], error)
+}
+
+type invoiceRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewInvoiceRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) InvoiceRepository {
+	return &invoiceRepository{db: db, deps: deps}
+}
+
+func (r *invoiceRepository) Issue(ctx context.Context, deptID int, userID int, input *model.InvoiceIssueDTO) (*model.InvoiceDTO, error) {
+	if input == nil || input.ClinicID <= 0 {
+		return nil, fmt.Errorf("clinic_id is required")
+	}
+	if !input.PeriodEnd.After(input.PeriodStart) {
+		return nil, fmt.Errorf("period_end must be after period_start")
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	orders, err := r.invoiceableOrders(ctx, tx, deptID, input.ClinicID, input.PeriodStart, input.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		err = ErrNoInvoiceableOrders
+		return nil, err
+	}
+
+	lines, err := r.buildLines(ctx, tx, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	var subtotal, discountTotal, total float64
+	for _, l := range lines {
+		subtotal += l.UnitPrice * float64(l.Quantity)
+		discountTotal += l.DiscountAmount
+		total += l.LineTotal
+	}
+
+	code, err := r.nextInvoiceCode(ctx, tx, deptID)
+	if err != nil {
+		return nil, err
+	}
+
+	var clinicName *string
+	for _, o := range orders {
+		if o.ClinicName != nil {
+			clinicName = o.ClinicName
+			break
+		}
+	}
+
+	var issuedBy *int
+	if userID > 0 {
+		issuedBy = &userID
+	}
+
+	entity, err := tx.Invoice.Create().
+		SetDepartmentID(deptID).
+		SetCode(code).
+		SetClinicID(input.ClinicID).
+		SetNillableClinicName(clinicName).
+		SetPeriodStart(input.PeriodStart).
+		SetPeriodEnd(input.PeriodEnd).
+		SetStatus(InvoiceStatusIssued).
+		SetSubtotal(subtotal).
+		SetDiscountTotal(discountTotal).
+		SetTotal(total).
+		SetNillableNote(input.Note).
+		SetIssuedAt(time.Now()).
+		SetNillableIssuedBy(issuedBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bulk := make([]*generated.InvoiceItemCreate, 0, len(lines))
+	for _, l := range lines {
+		bulk = append(bulk, tx.InvoiceItem.Create().
+			SetInvoiceID(entity.ID).
+			SetOrderID(l.OrderID).
+			SetNillableOrderCode(l.OrderCode).
+			SetOrderItemID(l.OrderItemID).
+			SetNillableOrderItemCode(l.OrderItemCode).
+			SetProductID(l.ProductID).
+			SetNillableProductCode(l.ProductCode).
+			SetNillableProductName(l.ProductName).
+			SetNillableTeethPosition(l.TeethPosition).
+			SetQuantity(l.Quantity).
+			SetUnitPrice(l.UnitPrice).
+			SetDiscountAmount(l.DiscountAmount).
+			SetLineTotal(l.LineTotal))
+	}
+	items, err := tx.InvoiceItem.CreateBulk(bulk...).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// lock invoiced orders against price changes
+	orderIDs := make([]int64, 0, len(orders))
+	for _, o := range orders {
+		orderIDs = append(orderIDs, o.ID)
+	}
+	locked, err := tx.Order.Update().
+		Where(
+			order.IDIn(orderIDs...),
+			order.InvoiceIDIsNil(),
+		).
+		SetInvoiceID(entity.ID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if locked != len(orderIDs) {
+		err = ErrOrdersAlreadyInvoiced
+		return nil, err
+	}
+
+	dto := mapper.MapAs[*generated.Invoice, *model.InvoiceDTO](entity)
+	dto.Items = mapper.MapListAs[*generated.InvoiceItem, *model.InvoiceItemDTO](items)
+	return dto, nil
+}
+
+func (r *invoiceRepository) Void(ctx context.Context, deptID int, id int64, userID int, reason *string) (*model.InvoiceDTO, []int64, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	// payments lock the open invoices of the clinic while they allocate
+	if _, err = tx.ExecContext(ctx, `SELECT id FROM invoices WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, nil, err
+	}
+
+	current, err := tx.Invoice.Query().
+		Where(
+			invoice.ID(id),
+			invoice.DepartmentIDEQ(deptID),
+			invoice.DeletedAtIsNil(),
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current.Status == InvoiceStatusVoid {
+		err = ErrInvoiceAlreadyVoided
+		return nil, nil, err
+	}
+
+	// money allocated to the invoice itself has no document to go back to
+	paid, err := tx.ClinicPaymentAllocation.Query().
+		Where(
+			clinicpaymentallocation.InvoiceIDEQ(id),
+			clinicpaymentallocation.HasPaymentWith(clinicpayment.DeletedAtIsNil()),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if paid {
+		err = ErrInvoiceHasPayments
+		return nil, nil, err
+	}
+
+	var voidedBy *int
+	if userID > 0 {
+		voidedBy = &userID
+	}
+
+	entity, err := tx.Invoice.UpdateOneID(id).
+		SetStatus(InvoiceStatusVoid).
+		SetVoidedAt(time.Now()).
+		SetNillableVoidedBy(voidedBy).
+		SetNillableVoidReason(reason).
+		Save(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// release the price lock
+	orderIDs, err := tx.Order.Query().
+		Where(order.InvoiceIDEQ(id)).
+		IDs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(orderIDs) > 0 {
+		if _, err = tx.Order.Update().
+			Where(order.IDIn(orderIDs...)).
+			ClearInvoiceID().
+			Save(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dto := mapper.MapAs[*generated.Invoice, *model.InvoiceDTO](entity)
+	return dto, orderIDs, nil
+}
+
+func (r *invoiceRepository) GetByID(ctx context.Context, deptID int, id int64) (*model.InvoiceDTO, error) {
+	entity, err := r.db.Invoice.Query().
+		Where(
+			invoice.ID(id),
+			invoice.DepartmentIDEQ(deptID),
+			invoice.DeletedAtIsNil(),
+		).
+		WithItems(func(q *generated.InvoiceItemQuery) {
+			q.Order(invoiceitem.ByOrderID(), invoiceitem.ByID())
+		}).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dto := mapper.MapAs[*generated.Invoice, *model.InvoiceDTO](entity)
+	dto.Items = mapper.MapListAs[*generated.InvoiceItem, *model.InvoiceItemDTO](entity.Edges.Items)
+	return dto, nil
+}
+
+func (r *invoiceRepository) List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.InvoiceDTO], error) {
+	q := r.db.Invoice.Query().
+		Where(
+			invoice.DepartmentIDEQ(deptID),
+			invoice.DeletedAtIsNil(),
+		)
+	if clinicID != nil {
+		q = q.Where(invoice.ClinicIDEQ(*clinicID))
+	}
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		invoice.Table,
+		invoice.FieldID,
+		invoice.FieldIssuedAt,
+		func(src []*generated.Invoice) []*model.InvoiceDTO {
+			return mapper.MapListAs[*generated.Invoice, *model.InvoiceDTO](src)
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.InvoiceDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+func (r *invoiceRepository) Search(ctx context.Context, deptID int, query dbutils.SearchQuery) (dbutils.SearchResult[model.InvoiceDTO], error) {
+	q := r.db.Invoice.Query().
+		Where(
+			invoice.DepartmentIDEQ(deptID),
+			invoice.DeletedAtIsNil(),
+		)
+
+	return dbutils.Search(
+		ctx,
+		q,
+		[]string{
+			dbutils.GetNormField(invoice.FieldCode),
+			dbutils.GetNormField(invoice.FieldClinicName),
+		},
+		query,
+		invoice.Table,
+		invoice.FieldID,
+		invoice.FieldID,
+		invoice.Or,
+		func(src []*generated.Invoice) []*model.InvoiceDTO {
+			return mapper.MapListAs[*generated.Invoice, *model.InvoiceDTO](src)
+		},
+	)
+}
+
+// -- helpers
+
+// invoiceableOrders returns the completed, not yet invoiced orders of a clinic
+// whose last process check-out falls into [from, to). An order counts as
+// completed only once every live, non-cancelled item of it is, so a split-off
+// item still in production holds the whole order back.
+func (r *invoiceRepository) invoiceableOrders(
+	ctx context.Context,
+	tx *generated.Tx,
+	deptID int,
+	clinicID int,
+	from time.Time,
+	to time.Time,
+) ([]*generated.Order, error) {
+	candidates, err := tx.Order.Query().
+		Where(
+			order.DepartmentIDEQ(deptID),
+			order.ClinicIDEQ(clinicID),
+			order.Or(
+				order.StatusLatestEQ("completed"),
+				order.StatusLatestEQ("cancelled"),
+			),
+			order.InvoiceIDIsNil(),
+			order.DeletedAtIsNil(),
+			order.Not(order.HasItemsWith(
+				orderitem.DeletedAtIsNil(),
+				orderitem.StatusNotIn("completed", "cancelled"),
+			)),
+		).
+		Order(order.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(candidates))
+	for _, o := range candidates {
+		ids = append(ids, o.ID)
+	}
+
+	// a cancelled order is billed only for its cancellation fee
+	fees, err := tx.OrderCancellation.Query().
+		Where(
+			ordercancellation.OrderIDIn(ids...),
+			ordercancellation.FeeGT(0),
+		).
+		Select(ordercancellation.FieldOrderID).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hasFee := make(map[int64]bool, len(fees))
+	for _, f := range fees {
+		hasFee[f.OrderID] = true
+	}
+
+	checkouts, err := tx.OrderItemProcessInProgress.Query().
+		Where(
+			orderitemprocessinprogress.OrderIDIn(ids...),
+			orderitemprocessinprogress.CompletedAtNotNil(),
+		).
+		Select(
+			orderitemprocessinprogress.FieldOrderID,
+			orderitemprocessinprogress.FieldCompletedAt,
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	completedAt := make(map[int64]time.Time, len(candidates))
+	for _, c := range checkouts {
+		if c.OrderID == nil || c.CompletedAt == nil {
+			continue
+		}
+		if cur, ok := completedAt[*c.OrderID]; !ok || c.CompletedAt.After(cur) {
+			completedAt[*c.OrderID] = *c.CompletedAt
+		}
+	}
+
+	out := make([]*generated.Order, 0, len(candidates))
+	for _, o := range candidates {
+		if o.StatusLatest != nil && *o.StatusLatest == "cancelled" && !hasFee[o.ID] {
+			continue
+		}
+		at, ok := completedAt[o.ID]
+		if !ok {
+			at = o.UpdatedAt
+		}
+		if at.Before(from) || !at.Before(to) {
+			continue
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func (r *invoiceRepository) buildLines(ctx context.Context, tx *generated.Tx, orders []*generated.Order) ([]*model.InvoiceItemDTO, error) {
+	ids := make([]int64, 0, len(orders))
+	codes := make(map[int64]*string, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+		codes[o.ID] = o.Code
+	}
+
+	products, err := tx.OrderItemProduct.Query().
+		Where(
+			orderitemproduct.OrderIDIn(ids...),
+			orderitemproduct.HasOrderItemWith(
+				orderitem.DeletedAtIsNil(),
+				orderitem.StatusNEQ("cancelled"),
+			),
+		).
+		WithOrderItem(func(q *generated.OrderItemQuery) {
+			q.Select(orderitem.FieldID, orderitem.FieldCode)
+		}).
+		WithProduct(func(q *generated.ProductQuery) {
+			q.Select(product.FieldID, product.FieldCode, product.FieldName)
+		}).
+		Order(orderitemproduct.ByOrderID(), orderitemproduct.ByID(sql.OrderAsc())).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := tx.PromotionUsage.Query().
+		Where(promotionusage.OrderIDIn(ids...)).
+		Select(promotionusage.FieldOrderID, promotionusage.FieldDiscountAmount).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	discounts := make(map[int64]float64, len(usages))
+	for _, u := range usages {
+		discounts[u.OrderID] += float64(u.DiscountAmount)
+	}
+
+	lines := make([]*model.InvoiceItemDTO, 0, len(products))
+	byOrder := make(map[int64][]*model.InvoiceItemDTO, len(orders))
+	for _, p := range products {
+		qty := p.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		var unitPrice float64
+		if p.RetailPrice != nil {
+			unitPrice = *p.RetailPrice
+		}
+
+		line := &model.InvoiceItemDTO{
+			OrderID:       p.OrderID,
+			OrderCode:     codes[p.OrderID],
+			OrderItemID:   p.OrderItemID,
+			ProductID:     p.ProductID,
+			ProductCode:   p.ProductCode,
+			TeethPosition: p.TeethPosition,
+			Quantity:      qty,
+			UnitPrice:     unitPrice,
+		}
+		if oi := p.Edges.OrderItem; oi != nil {
+			line.OrderItemCode = oi.Code
+		}
+		if prd := p.Edges.Product; prd != nil {
+			line.ProductName = prd.Name
+			if line.ProductCode == nil {
+				line.ProductCode = prd.Code
+			}
+		}
+
+		lines = append(lines, line)
+		byOrder[p.OrderID] = append(byOrder[p.OrderID], line)
+	}
+
+	for orderID, orderLines := range byOrder {
+		allocateDiscount(orderLines, discounts[orderID])
+	}
+
+	// cancellation fees, one line each, never discounted
+	cancellations, err := tx.OrderCancellation.Query().
+		Where(
+			ordercancellation.OrderIDIn(ids...),
+			ordercancellation.FeeGT(0),
+		).
+		Order(ordercancellation.ByOrderID(), ordercancellation.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cancellations {
+		name := "Phí hủy đơn"
+		if c.ReasonName != nil {
+			name = fmt.Sprintf("%s: %s", name, *c.ReasonName)
+		}
+		lines = append(lines, &model.InvoiceItemDTO{
+			OrderID:     c.OrderID,
+			OrderCode:   codes[c.OrderID],
+			ProductName: &name,
+			Quantity:    1,
+			UnitPrice:   c.Fee,
+			LineTotal:   c.Fee,
+		})
+	}
+
+	return lines, nil
+}
+
+// allocateDiscount spreads an order level discount over its lines proportionally
+// to their gross amount; the last line absorbs the rounding remainder.
+func allocateDiscount(lines []*model.InvoiceItemDTO, discount float64) {
+	var gross float64
+	for _, l := range lines {
+		gross += l.UnitPrice * float64(l.Quantity)
+	}
+	discount = math.Min(math.Max(0, discount), gross)
+
+	remaining := discount
+	for i, l := range lines {
+		lineGross := l.UnitPrice * float64(l.Quantity)
+		share := 0.0
+		if gross > 0 {
+			if i == len(lines)-1 {
+				share = remaining
+			} else {
+				share = math.Round(discount * lineGross / gross)
+			}
+		}
+		share = math.Min(share, lineGross)
+		remaining -= share
+
+		l.DiscountAmount = share
+		l.LineTotal = lineGross - share
+	}
+}
+
+func (r *invoiceRepository) nextInvoiceCode(ctx context.Context, tx *generated.Tx, deptID int) (string, error) {
+	const nextSeqSQL = `
+INSERT INTO invoice_code_counters(department_id, last_seq)
+VALUES ($1, 1)
+ON CONFLICT (department_id)
+DO UPDATE SET last_seq = invoice_code_counters.last_seq + 1
+RETURNING last_seq
+`
+
+	rows, err := tx.QueryContext(ctx, nextSeqSQL, deptID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var seq int
+	if !rows.Next() {
+		return "", errors.New("failed to generate invoice sequence")
+	}
+	if err := rows.Scan(&seq); err != nil {
+		return "", err
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("INV%06d", seq), nil
+}
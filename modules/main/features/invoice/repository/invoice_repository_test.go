@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+)
+
+func TestAllocateDiscount(t *testing.T) {
+	type line struct {
+		price float64
+		qty   int
+	}
+	tests := []struct {
+		name      string
+		lines     []line
+		discount  float64
+		discounts []float64
+		totals    []float64
+	}{
+		{
+			name:      "proportional to gross",
+			lines:     []line{{100, 1}, {100, 3}},
+			discount:  100,
+			discounts: []float64{25, 75},
+			totals:    []float64{75, 225},
+		},
+		{
+			name:      "last line absorbs rounding",
+			lines:     []line{{100, 1}, {100, 1}, {100, 1}},
+			discount:  100,
+			discounts: []float64{33, 33, 34},
+			totals:    []float64{67, 67, 66},
+		},
+		{
+			name:      "discount capped at gross",
+			lines:     []line{{50, 2}},
+			discount:  500,
+			discounts: []float64{100},
+			totals:    []float64{0},
+		},
+		{
+			name:      "negative discount ignored",
+			lines:     []line{{40, 1}, {60, 1}},
+			discount:  -10,
+			discounts: []float64{0, 0},
+			totals:    []float64{40, 60},
+		},
+		{
+			name:      "no discount",
+			lines:     []line{{40, 1}, {60, 2}},
+			discount:  0,
+			discounts: []float64{0, 0},
+			totals:    []float64{40, 120},
+		},
+		{
+			name:      "free lines only",
+			lines:     []line{{0, 1}, {0, 2}},
+			discount:  50,
+			discounts: []float64{0, 0},
+			totals:    []float64{0, 0},
+		},
+		{
+			name:      "free line takes no share",
+			lines:     []line{{0, 1}, {200, 1}},
+			discount:  50,
+			discounts: []float64{0, 50},
+			totals:    []float64{0, 150},
+		},
+	}
+
+	for _, tt := range tests {
+		lines := make([]*model.InvoiceItemDTO, 0, len(tt.lines))
+		for _, l := range tt.lines {
+			lines = append(lines, &model.InvoiceItemDTO{UnitPrice: l.price, Quantity: l.qty})
+		}
+
+		allocateDiscount(lines, tt.discount)
+
+		discounts := make([]float64, 0, len(lines))
+		totals := make([]float64, 0, len(lines))
+		for _, l := range lines {
+			discounts = append(discounts, l.DiscountAmount)
+			totals = append(totals, l.LineTotal)
+		}
+		if !slices.Equal(discounts, tt.discounts) {
+			t.Errorf("%s: discounts = %v; want %v", tt.name, discounts, tt.discounts)
+		}
+		if !slices.Equal(totals, tt.totals) {
+			t.Errorf("%s: totals = %v; want %v", tt.name, totals, tt.totals)
+		}
+	}
+}
+
+func TestAllocateDiscountEmpty(t *testing.T) {
+	// nothing to spread over, nothing to panic on
+	allocateDiscount(nil, 100)
+}
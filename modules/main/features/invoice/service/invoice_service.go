@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/invoice/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
+	"github.com/khiemnd777/andy_api/shared/module"
+	searchmodel "github.com/khiemnd777/andy_api/shared/modules/search/model"
+	"github.com/khiemnd777/andy_api/shared/pubsub"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type InvoiceService interface {
+	Issue(ctx context.Context, deptID int, userID int, input *model.InvoiceIssueDTO) (*model.InvoiceDTO, error)
+	Void(ctx context.Context, deptID int, id int64, userID int, reason *string) (*model.InvoiceDTO, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.InvoiceDTO, error)
+	List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.InvoiceDTO], error)
+	Search(ctx context.Context, deptID int, query dbutils.SearchQuery) (dbutils.SearchResult[model.InvoiceDTO], error)
+}
+
+type invoiceService struct {
+	repo repository.InvoiceRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewInvoiceService(repo repository.InvoiceRepository, deps *module.ModuleDeps[config.ModuleConfig]) InvoiceService {
+	return &invoiceService{repo: repo, deps: deps}
+}
+
+func kInvoiceByID(deptID int, id int64) string {
+	return fmt.Sprintf("invoice:dpt:%d:id:%d", deptID, id)
+}
+
+func kInvoiceAll() []string {
+	return []string{
+		kInvoiceListAll(),
+		kInvoiceSearchAll(),
+	}
+}
+
+func kInvoiceListAll() string {
+	return "invoice:list:*"
+}
+
+func kInvoiceSearchAll() string {
+	return "invoice:search:*"
+}
+
+func kInvoiceList(deptID int, clinicID *int, q table.TableQuery) string {
+	orderBy := ""
+	if q.OrderBy != nil {
+		orderBy = *q.OrderBy
+	}
+	cid := 0
+	if clinicID != nil {
+		cid = *clinicID
+	}
+	return fmt.Sprintf("invoice:list:dpt%d:c%d:l%d:p%d:o%s:d%s", deptID, cid, q.Limit, q.Page, orderBy, q.Direction)
+}
+
+func kInvoiceSearch(deptID int, q dbutils.SearchQuery) string {
+	orderBy := ""
+	if q.OrderBy != nil {
+		orderBy = *q.OrderBy
+	}
+	return fmt.Sprintf("invoice:search:dpt%d:k%s:l%d:p%d:o%s:d%s", deptID, q.Keyword, q.Limit, q.Page, orderBy, q.Direction)
+}
+
+// order cache keys owned by the order feature
+func kOrderKeys(orderIDs []int64) []string {
+	keys := make([]string, 0, len(orderIDs)*2+1)
+	for _, id := range orderIDs {
+		keys = append(keys, fmt.Sprintf("order:id:%d", id), fmt.Sprintf("order:id:%d:*", id))
+	}
+	return append(keys, "order:list:*")
+}
+
+// receivable cache keys owned by the receivable and dashboard features
+func kReceivableKeys(deptID, clinicID int) []string {
+	return []string{
+		fmt.Sprintf("receivable:dpt%d:c%d:*", deptID, clinicID),
+		fmt.Sprintf("dashboard:receivable_aging:dpt%d:*", deptID),
+	}
+}
+
+func (s *invoiceService) Issue(ctx context.Context, deptID int, userID int, input *model.InvoiceIssueDTO) (*model.InvoiceDTO, error) {
+	dto, err := s.repo.Issue(ctx, deptID, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]int64, 0, len(dto.Items))
+	seen := make(map[int64]struct{}, len(dto.Items))
+	for _, it := range dto.Items {
+		if _, ok := seen[it.OrderID]; ok {
+			continue
+		}
+		seen[it.OrderID] = struct{}{}
+		orderIDs = append(orderIDs, it.OrderID)
+	}
+
+	cache.InvalidateKeys(kInvoiceAll()...)
+	cache.InvalidateKeys(kOrderKeys(orderIDs)...)
+	cache.InvalidateKeys(kReceivableKeys(deptID, dto.ClinicID)...)
+
+	s.upsertSearch(deptID, dto)
+
+	return dto, nil
+}
+
+func (s *invoiceService) Void(ctx context.Context, deptID int, id int64, userID int, reason *string) (*model.InvoiceDTO, error) {
+	dto, orderIDs, err := s.repo.Void(ctx, deptID, id, userID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kInvoiceByID(deptID, id))
+	cache.InvalidateKeys(kInvoiceAll()...)
+	cache.InvalidateKeys(kOrderKeys(orderIDs)...)
+	cache.InvalidateKeys(kReceivableKeys(deptID, dto.ClinicID)...)
+
+	s.upsertSearch(deptID, dto)
+
+	return dto, nil
+}
+
+func (s *invoiceService) upsertSearch(deptID int, dto *model.InvoiceDTO) {
+	if dto == nil {
+		return
+	}
+	kw := dto.Code
+	if dto.ClinicName != nil {
+		kw = fmt.Sprintf("%s %s", kw, *dto.ClinicName)
+	}
+	pubsub.PublishAsync("search:upsert", &searchmodel.Doc{
+		EntityType: "invoice",
+		EntityID:   dto.ID,
+		Title:      dto.Code,
+		Subtitle:   dto.ClinicName,
+		Keywords:   &kw,
+		Content:    nil,
+		Attributes: map[string]any{
+			"status": dto.Status,
+			"total":  dto.Total,
+		},
+		OrgID:   utils.Ptr(int64(deptID)),
+		OwnerID: nil,
+	})
+}
+
+func (s *invoiceService) GetByID(ctx context.Context, deptID int, id int64) (*model.InvoiceDTO, error) {
+	return cache.Get(kInvoiceByID(deptID, id), cache.TTLMedium, func() (*model.InvoiceDTO, error) {
+		return s.repo.GetByID(ctx, deptID, id)
+	})
+}
+
+func (s *invoiceService) List(ctx context.Context, deptID int, clinicID *int, q table.TableQuery) (table.TableListResult[model.InvoiceDTO], error) {
+	type boxed = table.TableListResult[model.InvoiceDTO]
+	key := kInvoiceList(deptID, clinicID, q)
+
+	ptr, err := cache.Get(key, cache.TTLMedium, func() (*boxed, error) {
+		res, e := s.repo.List(ctx, deptID, clinicID, q)
+		if e != nil {
+			return nil, e
+		}
+		return &res, nil
+	})
+	if err != nil {
+		var zero boxed
+		return zero, err
+	}
+	return *ptr, nil
+}
+
+func (s *invoiceService) Search(ctx context.Context, deptID int, q dbutils.SearchQuery) (dbutils.SearchResult[model.InvoiceDTO], error) {
+	type boxed = dbutils.SearchResult[model.InvoiceDTO]
+	key := kInvoiceSearch(deptID, q)
+
+	ptr, err := cache.Get(key, cache.TTLMedium, func() (*boxed, error) {
+		res, e := s.repo.Search(ctx, deptID, q)
+		if e != nil {
+			return nil, e
+		}
+		return &res, nil
+	})
+	if err != nil {
+		var zero boxed
+		return zero, err
+	}
+	return *ptr, nil
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type OrderCancelReasonHandler struct {
+	svc  service.OrderCancelReasonService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderCancelReasonHandler(svc service.OrderCancelReasonService, deps *module.ModuleDeps[config.ModuleConfig]) *OrderCancelReasonHandler {
+	return &OrderCancelReasonHandler{svc: svc, deps: deps}
+}
+
+func (h *OrderCancelReasonHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/order/cancel-reasons", h.List)
+	app.RouterPost(router, "/:dept_id<int>/order/cancel-reasons", h.Create)
+	app.RouterPut(router, "/:dept_id<int>/order/cancel-reasons/:id<int>", h.Update)
+	app.RouterDelete(router, "/:dept_id<int>/order/cancel-reasons/:id<int>", h.Delete)
+}
+
+func (h *OrderCancelReasonHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	res, err := h.svc.List(c.UserContext(), deptID, c.QueryBool("active"))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderCancelReasonHandler) Create(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.cancel"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.OrderCancelReasonDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Create(c.UserContext(), deptID, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCancelReasonInput) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		if generated.IsConstraintError(err) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, "cancel reason code already exists")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *OrderCancelReasonHandler) Update(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.cancel"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderCancelReasonDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Update(c.UserContext(), deptID, id, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCancelReasonInput):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case generated.IsNotFound(err):
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "cancel reason not found")
+		case generated.IsConstraintError(err):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, "cancel reason code already exists")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderCancelReasonHandler) Delete(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.cancel"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.Delete(c.UserContext(), deptID, id); err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "cancel reason not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
 	"github.com/khiemnd777/andy_api/shared/app"
 	"github.com/khiemnd777/andy_api/shared/app/client_error"
@@ -14,6 +17,7 @@ import (
 	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
 	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
 	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/toothchart"
 	"github.com/khiemnd777/andy_api/shared/utils"
 	"github.com/khiemnd777/andy_api/shared/utils/table"
 )
@@ -40,9 +44,19 @@ func (h *OrderHandler) RegisterRoutes(router fiber.Router) {
 	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/products", h.GetAllOrderProducts)
 	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/materials", h.GetAllOrderMaterials)
 	app.RouterGet(router, "/:dept_id<int>/order/:id<int>/sync-price", h.SyncPrice)
+	app.RouterGet(router, "/:dept_id<int>/order/status-machine", h.StatusMachine)
+	app.RouterGet(router, "/:dept_id<int>/order/:id<int>/status-history", h.StatusHistory)
+	app.RouterGet(router, "/:dept_id<int>/order/:id<int>/timeline", h.Timeline)
+	app.RouterGet(router, "/:dept_id<int>/order/:id<int>/cancellations", h.Cancellations)
+	app.RouterGet(router, "/:dept_id<int>/order/tooth-chart/shades", h.ToothChartShades)
+	app.RouterPost(router, "/:dept_id<int>/order/tooth-chart/preview", h.PreviewToothChart)
 	app.RouterPost(router, "/:dept_id<int>/order", h.Create)
 	app.RouterPut(router, "/:dept_id<int>/order/:id<int>", h.Update)
 	app.RouterPut(router, "/:dept_id<int>/order/:id<int>/process/:order_item_process_id<int>/change-status/:status", h.UpdateStatus)
+	app.RouterPost(router, "/:dept_id<int>/order/:id<int>/cancel", h.Cancel)
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/lineage", h.Lineage)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/split", h.Split)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/merge", h.Merge)
 	app.RouterDelete(router, "/:dept_id<int>/order/:id<int>", h.Delete)
 }
 
@@ -219,7 +233,8 @@ func (h *OrderHandler) SyncPrice(c *fiber.Ctx) error {
 		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid order id")
 	}
 
-	total, err := h.svc.SyncPrice(c.UserContext(), int64(id))
+	userID, _ := utils.GetUserIDInt(c)
+	total, err := h.svc.SyncPrice(c.UserContext(), userID, int64(id))
 	if err != nil {
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
@@ -244,6 +259,12 @@ func (h *OrderHandler) Create(c *fiber.Ctx) error {
 
 	dto, err := h.svc.Create(c.UserContext(), deptID, userID, payload)
 	if err != nil {
+		if errors.Is(err, repository.ErrOrderInvoiced) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		if errors.Is(err, toothchart.ErrInvalidChart) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
 	return c.Status(fiber.StatusCreated).JSON(dto)
@@ -273,16 +294,18 @@ func (h *OrderHandler) Update(c *fiber.Ctx) error {
 
 	dto, err := h.svc.Update(c.UserContext(), deptID, userID, payload)
 	if err != nil {
+		if errors.Is(err, repository.ErrOrderInvoiced) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		if errors.Is(err, toothchart.ErrInvalidChart) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
 	return c.Status(fiber.StatusOK).JSON(dto)
 }
 
 func (h *OrderHandler) UpdateStatus(c *fiber.Ctx) error {
-	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
-		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
-	}
-
 	oipID, _ := utils.GetParamAsInt(c, "order_item_process_id")
 	if oipID <= 0 {
 		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid oip id")
@@ -293,15 +316,167 @@ func (h *OrderHandler) UpdateStatus(c *fiber.Ctx) error {
 		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid status")
 	}
 
+	// each declared transition carries its own permission
+	perms, err := h.svc.StatusTransitionPermission(c.UserContext(), int64(oipID), status)
+	if err != nil {
+		return h.responseStatusError(c, err)
+	}
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), perms...); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	userID, _ := utils.GetUserIDInt(c)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.UpdateStatus(c.UserContext(), deptID, userID, int64(oipID), status)
+	if err != nil {
+		return h.responseStatusError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderHandler) StatusMachine(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(h.svc.StatusMachine())
+}
+
+func (h *OrderHandler) ToothChartShades(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(h.svc.ToothChartShades())
+}
+
+// PreviewToothChart normalizes a chart and returns the quantity and the FDI
+// and Universal texts it produces, without saving anything.
+func (h *OrderHandler) PreviewToothChart(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[toothchart.Chart](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	dto, err := h.svc.PreviewToothChart(payload)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderHandler) StatusHistory(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	res, err := h.svc.StatusHistory(c.UserContext(), int64(id))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderHandler) Timeline(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	var types []string
+	for _, t := range strings.Split(utils.GetQueryAsString(c, "types"), ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(repository.OrderTimelineEventTypes, t) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid event type: "+t)
+		}
+		types = append(types, t)
+	}
+
+	q := table.ParseTableQuery(c, 50)
+	res, err := h.svc.Timeline(c.UserContext(), int64(id), model.OrderTimelineQuery{
+		Types:  types,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+	})
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderHandler) responseStatusError(c *fiber.Ctx, err error) error {
+	switch {
+	case generated.IsNotFound(err):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, "order item process not found")
+	case errors.Is(err, repository.ErrInvalidStatusTransition),
+		errors.Is(err, repository.ErrStatusTransitionBlocked):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
+
+func (h *OrderHandler) Cancel(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.cancel"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderCancelDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
 	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
 
-	dto, err := h.svc.UpdateStatus(c.UserContext(), deptID, int64(oipID), status)
+	dto, err := h.svc.Cancel(c.UserContext(), deptID, userID, int64(id), payload)
 	if err != nil {
+		switch {
+		case generated.IsNotFound(err):
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "order not found")
+		case errors.Is(err, repository.ErrInvalidCancelReason),
+			errors.Is(err, repository.ErrInvalidCancelFee),
+			errors.Is(err, repository.ErrItemNotInOrder):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case errors.Is(err, repository.ErrNothingToCancel),
+			errors.Is(err, repository.ErrOrderInvoiced),
+			errors.Is(err, repository.ErrInvalidStatusTransition):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
 	return c.Status(fiber.StatusOK).JSON(dto)
 }
 
+func (h *OrderHandler) Cancellations(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	res, err := h.svc.Cancellations(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
 func (h *OrderHandler) Delete(c *fiber.Ctx) error {
 	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.delete"); err != nil {
 		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
@@ -315,3 +490,79 @@ func (h *OrderHandler) Delete(c *fiber.Ctx) error {
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+func (h *OrderHandler) Lineage(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	if orderItemID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	res, err := h.svc.Lineage(c.UserContext(), int64(orderItemID))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderHandler) Split(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	if orderID <= 0 || orderItemID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderItemSplitDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Split(c.UserContext(), deptID, userID, int64(orderID), int64(orderItemID), payload)
+	if err != nil {
+		return h.responseLineageError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *OrderHandler) Merge(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	if orderID <= 0 || orderItemID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderItemMergeDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Merge(c.UserContext(), deptID, userID, int64(orderID), int64(orderItemID), payload)
+	if err != nil {
+		return h.responseLineageError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderHandler) responseLineageError(c *fiber.Ctx, err error) error {
+	switch {
+	case generated.IsNotFound(err):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, "order item not found")
+	case errors.Is(err, repository.ErrInvalidSplit),
+		errors.Is(err, repository.ErrInvalidMerge),
+		errors.Is(err, repository.ErrRowNotInItem):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, repository.ErrItemNotMovable),
+		errors.Is(err, repository.ErrOrderInvoiced):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	metadataservice "github.com/khiemnd777/andy_api/modules/metadata/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type OrderImportHandler struct {
+	svc    service.OrderImportService
+	engine *metadataservice.ImportEngine
+	deps   *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderImportHandler(svc service.OrderImportService, engine *metadataservice.ImportEngine, deps *module.ModuleDeps[config.ModuleConfig]) *OrderImportHandler {
+	return &OrderImportHandler{svc: svc, engine: engine, deps: deps}
+}
+
+func (h *OrderImportHandler) RegisterRoutes(router fiber.Router) {
+	// POST /:dept_id/order/import?code=... (multipart "file")
+	app.RouterPost(router, "/:dept_id<int>/order/import", h.Import)
+}
+
+func (h *OrderImportHandler) Import(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.import"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "file is required")
+	}
+	f, err := fileHeader.Open()
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "cannot open file")
+	}
+	defer f.Close()
+
+	x, err := excelize.OpenReader(f)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid excel file")
+	}
+	defer x.Close()
+
+	ctx := c.UserContext()
+
+	profile, mappings, err := h.engine.Mapper.ResolveProfileAndMappings(c, ctx, service.OrderImportScope, c.Query("code"))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+
+	rows, errs, err := h.engine.MapSheet(ctx, profile, x)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+	groups, groupErrs := h.engine.GroupMappedRows(profile, mappings, rows)
+	errs = append(errs, groupErrs...)
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	res := h.svc.Import(ctx, deptID, userID, groups)
+	res.Errors = append(res.Errors, errs...)
+
+	logger.Info("order.import.done", "dept_id", deptID, "created", res.Created, "failed", res.Failed)
+
+	return c.Status(fiber.StatusOK).JSON(res)
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type OrderItemFileHandler struct {
+	svc  service.OrderItemFileService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderItemFileHandler(svc service.OrderItemFileService, deps *module.ModuleDeps[config.ModuleConfig]) *OrderItemFileHandler {
+	return &OrderItemFileHandler{svc: svc, deps: deps}
+}
+
+func (h *OrderItemFileHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/files", h.List)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/files", h.Upload)
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/files/:file_id<int>/download", h.Download)
+}
+
+func (h *OrderItemFileHandler) Upload(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	orderID, orderItemID, ok := parseOrderItemParams(c)
+	if !ok {
+		return nil
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "missing file")
+	}
+
+	var input service.OrderItemFileUpload
+	if v := c.FormValue("description"); v != "" {
+		input.Description = &v
+	}
+	if v := c.FormValue("replaces_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid replaces_id")
+		}
+		input.ReplacesID = &id
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	res, err := h.svc.Upload(c.UserContext(), deptID, userID, orderID, orderItemID, file, input)
+	if err != nil {
+		return h.responseFileError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(res)
+}
+
+func (h *OrderItemFileHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	orderID, orderItemID, ok := parseOrderItemParams(c)
+	if !ok {
+		return nil
+	}
+
+	res, err := h.svc.List(c.UserContext(), orderID, orderItemID, c.QueryBool("all"))
+	if err != nil {
+		return h.responseFileError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderItemFileHandler) Download(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	orderID, orderItemID, ok := parseOrderItemParams(c)
+	if !ok {
+		return nil
+	}
+	fileID, _ := utils.GetParamAsInt(c, "file_id")
+	if fileID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid file id")
+	}
+
+	dto, path, err := h.svc.FilePath(c.UserContext(), orderID, orderItemID, int64(fileID))
+	if err != nil {
+		return h.responseFileError(c, err)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, "file not found")
+	}
+
+	name := dto.FileURL
+	if dto.FileName != nil && *dto.FileName != "" {
+		name = *dto.FileName
+	}
+	return c.Download(path, name)
+}
+
+func parseOrderItemParams(c *fiber.Ctx) (int64, int64, bool) {
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	if orderID <= 0 {
+		_ = client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid order id")
+		return 0, 0, false
+	}
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	if orderItemID <= 0 {
+		_ = client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid order item id")
+		return 0, 0, false
+	}
+	return int64(orderID), int64(orderItemID), true
+}
+
+func (h *OrderItemFileHandler) responseFileError(c *fiber.Ctx, err error) error {
+	switch {
+	case generated.IsNotFound(err),
+		errors.Is(err, service.ErrOrderItemNotInOrder):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+	case errors.Is(err, service.ErrUnsupportedFileType),
+		errors.Is(err, service.ErrInvalidMeshFile):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, service.ErrFileTooLarge):
+		return client_error.ResponseError(c, fiber.StatusRequestEntityTooLarge, err, err.Error())
+	case errors.Is(err, service.ErrDuplicateFile),
+		errors.Is(err, repository.ErrFileAlreadyReplaced):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type OrderItemHoldHandler struct {
+	svc  service.OrderItemHoldService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderItemHoldHandler(svc service.OrderItemHoldService, deps *module.ModuleDeps[config.ModuleConfig]) *OrderItemHoldHandler {
+	return &OrderItemHoldHandler{svc: svc, deps: deps}
+}
+
+func (h *OrderItemHoldHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/order/holds", h.ListActive)
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/holds", h.List)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/hold", h.Hold)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/resume", h.Resume)
+}
+
+func (h *OrderItemHoldHandler) parseOrderParams(c *fiber.Ctx) (int64, int64, bool) {
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	return int64(orderID), int64(orderItemID), orderID > 0 && orderItemID > 0
+}
+
+func (h *OrderItemHoldHandler) responseHoldError(c *fiber.Ctx, err error) error {
+	switch {
+	case generated.IsNotFound(err):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, "order item not found")
+	case errors.Is(err, repository.ErrInvalidHoldReason):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, repository.ErrOrderItemOnHold),
+		errors.Is(err, repository.ErrOrderItemNotOnHold),
+		errors.Is(err, repository.ErrInvalidStatusTransition),
+		errors.Is(err, repository.ErrStatusTransitionBlocked):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
+
+func (h *OrderItemHoldHandler) ListActive(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	res, err := h.svc.ListActive(c.UserContext(), deptID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderItemHoldHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	_, orderItemID, ok := h.parseOrderParams(c)
+	if !ok {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	res, err := h.svc.ListByOrderItemID(c.UserContext(), orderItemID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderItemHoldHandler) Hold(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.hold"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, orderItemID, ok := h.parseOrderParams(c)
+	if !ok {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderItemHoldInputDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Hold(c.UserContext(), deptID, userID, orderID, orderItemID, payload)
+	if err != nil {
+		return h.responseHoldError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *OrderItemHoldHandler) Resume(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.hold"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, orderItemID, ok := h.parseOrderParams(c)
+	if !ok {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload := &model.OrderItemResumeDTO{}
+	if len(c.Body()) > 0 {
+		var err error
+		if payload, err = app.ParseBody[model.OrderItemResumeDTO](c); err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+		}
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Resume(c.UserContext(), deptID, userID, orderID, orderItemID, payload)
+	if err != nil {
+		return h.responseHoldError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
 	"github.com/khiemnd777/andy_api/shared/app"
 	"github.com/khiemnd777/andy_api/shared/app/client_error"
@@ -34,7 +37,10 @@ func (h *OrderItemProcessHandler) RegisterRoutes(router fiber.Router) {
 	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/check-out/latest", h.GetCheckoutLatest)
 	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/check-in-out/prepare", h.PrepareCheckInOrOut)
 	app.RouterGet(router, "/:dept_id<int>/order/processes/check-in-out/prepare-by-code", h.PrepareCheckInOrOutByCode)
+	app.RouterGet(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/check-in-out/options", h.CheckInOrOutOptions)
 	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/check-in-out", h.CheckInOrOut)
+	app.RouterPost(router, "/:dept_id<int>/order/processes/check-in-out/batch", h.BatchCheckInOrOut)
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/undo", h.UndoLastTransition)
 	app.RouterPost(router, "/:dept_id<int>/order/processes/in-progress/:in_progress_id<int>/assign", h.Assign)
 	app.RouterPut(router, "/:dept_id<int>/order/:order_id<int>/historical/:order_item_id<int>/processes/:order_item_process_id<int>", h.Update)
 }
@@ -135,6 +141,23 @@ func (h *OrderItemProcessHandler) PrepareCheckInOrOut(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(dto)
 }
 
+func (h *OrderItemProcessHandler) CheckInOrOutOptions(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.development"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	orderID, orderItemID, err := h.parseOrderParams(c)
+	if err != nil {
+		return err
+	}
+
+	dto, svcErr := h.svc.CheckInOrOutOptions(c.UserContext(), int64(orderID), int64(orderItemID))
+	if svcErr != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, svcErr, svcErr.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
 func (h *OrderItemProcessHandler) PrepareCheckInOrOutByCode(c *fiber.Ctx) error {
 	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.development"); err != nil {
 		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
@@ -193,11 +216,44 @@ func (h *OrderItemProcessHandler) CheckInOrOut(c *fiber.Ctx) error {
 
 	dto, err := h.svc.CheckInOrOut(c.UserContext(), deptID, userID, checkInOrOutData)
 	if err != nil {
+		if errors.Is(err, repository.ErrProcessBlocked) ||
+			errors.Is(err, repository.ErrOrderItemOnHold) ||
+			errors.Is(err, repository.ErrOrderItemCancelled) ||
+			errors.Is(err, repository.ErrInvalidStatusTransition) ||
+			errors.Is(err, repository.ErrStatusTransitionBlocked) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
 	return c.Status(fiber.StatusOK).JSON(dto)
 }
 
+func (h *OrderItemProcessHandler) BatchCheckInOrOut(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.development"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	payload, err := app.ParseBody[model.OrderItemProcessBatchScanDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	userID, _ := utils.GetUserIDInt(c)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	res, err := h.svc.BatchCheckInOrOut(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidScanAction),
+			errors.Is(err, service.ErrNoScanCodes),
+			errors.Is(err, service.ErrScanBatchTooLarge):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
 func (h *OrderItemProcessHandler) Assign(c *fiber.Ctx) error {
 	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.development"); err != nil {
 		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
@@ -272,6 +328,32 @@ func (h *OrderItemProcessHandler) Update(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(dto)
 }
 
+func (h *OrderItemProcessHandler) UndoLastTransition(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.undo"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	orderID, orderItemID, err := h.parseOrderParams(c)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := utils.GetUserIDInt(c)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	res, err := h.svc.UndoLastTransition(c.UserContext(), deptID, userID, int64(orderID), int64(orderItemID))
+	if err != nil {
+		switch {
+		case generated.IsNotFound(err), errors.Is(err, repository.ErrNothingToUndo):
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		case errors.Is(err, repository.ErrUndoWindowExpired):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
 func (h *OrderItemProcessHandler) parseOrderParams(c *fiber.Ctx) (int, int, error) {
 	orderID, _ := utils.GetParamAsInt(c, "order_id")
 	if orderID <= 0 {
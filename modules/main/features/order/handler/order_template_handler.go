@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/toothchart"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type OrderTemplateHandler struct {
+	svc  service.OrderTemplateService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderTemplateHandler(svc service.OrderTemplateService, deps *module.ModuleDeps[config.ModuleConfig]) *OrderTemplateHandler {
+	return &OrderTemplateHandler{svc: svc, deps: deps}
+}
+
+func (h *OrderTemplateHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterPost(router, "/:dept_id<int>/order/:order_id<int>/item/:order_item_id<int>/reorder", h.Reorder)
+
+	app.RouterGet(router, "/:dept_id<int>/order/templates", h.List)
+	app.RouterPost(router, "/:dept_id<int>/order/templates", h.Create)
+	app.RouterGet(router, "/:dept_id<int>/order/templates/:id<int>", h.GetByID)
+	app.RouterPut(router, "/:dept_id<int>/order/templates/:id<int>", h.Update)
+	app.RouterDelete(router, "/:dept_id<int>/order/templates/:id<int>", h.Delete)
+	app.RouterPost(router, "/:dept_id<int>/order/templates/:id<int>/instantiate", h.Instantiate)
+}
+
+// responseCreateError maps the errors of creating an order from a previous
+// case or a template.
+func (h *OrderTemplateHandler) responseCreateError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrSourceNotFound), generated.IsNotFound(err):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+	case errors.Is(err, toothchart.ErrInvalidChart):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, repository.ErrInvalidOrExpiredOrderCode):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
+
+func (h *OrderTemplateHandler) Reorder(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	orderID, _ := utils.GetParamAsInt(c, "order_id")
+	orderItemID, _ := utils.GetParamAsInt(c, "order_item_id")
+	if orderID <= 0 || orderItemID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload := &model.OrderReorderDTO{}
+	if len(c.Body()) > 0 {
+		var err error
+		if payload, err = app.ParseBody[model.OrderReorderDTO](c); err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+		}
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Reorder(c.UserContext(), deptID, userID, int64(orderID), int64(orderItemID), payload)
+	if err != nil {
+		return h.responseCreateError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *OrderTemplateHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	clinicID, err := utils.GetQueryAsNillableInt(c, "clinic_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid clinic_id")
+	}
+	dentistID, err := utils.GetQueryAsNillableInt(c, "dentist_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid dentist_id")
+	}
+
+	res, err := h.svc.List(c.UserContext(), deptID, clinicID, dentistID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *OrderTemplateHandler) GetByID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, id)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "order template not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderTemplateHandler) Create(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.OrderTemplateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Create(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTemplate) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		if errors.Is(err, service.ErrSourceNotFound) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *OrderTemplateHandler) Update(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.OrderTemplateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Update(c.UserContext(), deptID, id, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTemplate) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "order template not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *OrderTemplateHandler) Delete(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.Delete(c.UserContext(), deptID, id); err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "order template not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OrderTemplateHandler) Instantiate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload := &model.OrderReorderDTO{}
+	if len(c.Body()) > 0 {
+		var err error
+		if payload, err = app.ParseBody[model.OrderReorderDTO](c); err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+		}
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Instantiate(c.UserContext(), deptID, userID, id, payload)
+	if err != nil {
+		return h.responseCreateError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
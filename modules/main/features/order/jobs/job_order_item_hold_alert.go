@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/logger"
+)
+
+type OrderItemHoldAlertJob struct {
+	svc service.OrderItemHoldService
+}
+
+func NewOrderItemHoldAlertJob(svc service.OrderItemHoldService) *OrderItemHoldAlertJob {
+	return &OrderItemHoldAlertJob{svc: svc}
+}
+
+func (j OrderItemHoldAlertJob) Name() string            { return "OrderItemHoldAlert" }
+func (j OrderItemHoldAlertJob) DefaultSchedule() string { return "@every 1h" }
+func (j OrderItemHoldAlertJob) ConfigKey() string       { return "cron.order_item_hold_alert" }
+
+func (j OrderItemHoldAlertJob) Run() error {
+	logger.Debug("[OrderItemHoldAlertJob] Long hold alert starting...")
+
+	n, err := j.svc.AlertLongHolds(context.Background(), time.Now())
+	if err != nil {
+		logger.Error(fmt.Sprintf("[OrderItemHoldAlertJob] Long hold alert failed: %v", err))
+		return err
+	}
+
+	logger.Debug(fmt.Sprintf("[OrderItemHoldAlertJob] Done. %d hold(s) announced.", n))
+	return nil
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	"github.com/khiemnd777/andy_api/shared/logger"
+)
+
+type RenderOrderItemFilePreviewsJob struct {
+	svc service.OrderItemFileService
+}
+
+func NewRenderOrderItemFilePreviewsJob(svc service.OrderItemFileService) *RenderOrderItemFilePreviewsJob {
+	return &RenderOrderItemFilePreviewsJob{svc: svc}
+}
+
+func (j RenderOrderItemFilePreviewsJob) Name() string            { return "RenderOrderItemFilePreviews" }
+func (j RenderOrderItemFilePreviewsJob) DefaultSchedule() string { return "@every 10m" }
+func (j RenderOrderItemFilePreviewsJob) ConfigKey() string {
+	return "cron.render_order_item_file_previews"
+}
+
+func (j RenderOrderItemFilePreviewsJob) Run() error {
+	logger.Debug("[RenderOrderItemFilePreviewsJob] Render pending previews starting...")
+
+	if err := j.svc.RenderPendingPreviews(context.Background()); err != nil {
+		logger.Error("[RenderOrderItemFilePreviewsJob] Render pending previews failed", err)
+		return err
+	}
+
+	logger.Debug("[RenderOrderItemFilePreviewsJob] Done.")
+	return nil
+}
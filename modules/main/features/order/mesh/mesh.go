@@ -0,0 +1,167 @@
+// Package mesh reads the geometry of intraoral scans and CAD exports (STL and
+// PLY) without loading them into a full mesh library: it streams the file once,
+// counting triangles and tracking the bounding box, and can hand every
+// triangle to a callback for callers that need the surface itself.
+package mesh
+
+import (
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+)
+
+const (
+	FormatSTL = "stl"
+	FormatPLY = "ply"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported mesh format")
+	ErrMalformed         = errors.New("malformed mesh file")
+)
+
+type BBox struct {
+	Min [3]float64 `json:"min"`
+	Max [3]float64 `json:"max"`
+}
+
+func (b BBox) Size() [3]float64 {
+	return [3]float64{b.Max[0] - b.Min[0], b.Max[1] - b.Min[1], b.Max[2] - b.Min[2]}
+}
+
+type Info struct {
+	Format        string `json:"format"`
+	Encoding      string `json:"encoding"` // ascii | binary
+	TriangleCount int64  `json:"triangle_count"`
+	VertexCount   int64  `json:"vertex_count,omitempty"`
+	BBox          *BBox  `json:"bbox,omitempty"`
+	// neither format stores units reliably; dental CAD works in millimetres so
+	// that is assumed unless the header says otherwise
+	Units        string `json:"units"`
+	UnitsAssumed bool   `json:"units_assumed"`
+}
+
+// Meta flattens the info for storing next to the file.
+func (i *Info) Meta() map[string]any {
+	m := map[string]any{
+		"format":         i.Format,
+		"encoding":       i.Encoding,
+		"triangle_count": i.TriangleCount,
+		"units":          i.Units,
+		"units_assumed":  i.UnitsAssumed,
+	}
+	if i.VertexCount > 0 {
+		m["vertex_count"] = i.VertexCount
+	}
+	if i.BBox != nil {
+		size := i.BBox.Size()
+		m["bbox"] = map[string]any{
+			"min":  i.BBox.Min[:],
+			"max":  i.BBox.Max[:],
+			"size": size[:],
+		}
+	}
+	return m
+}
+
+// Triangle is one face of the surface, three vertices of x, y, z.
+type Triangle [3][3]float32
+
+// FormatOf maps a file extension (with or without the dot) to a mesh format.
+func FormatOf(ext string) (string, bool) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case FormatSTL:
+		return FormatSTL, true
+	case FormatPLY:
+		return FormatPLY, true
+	}
+	return "", false
+}
+
+// Inspect reads the file once and reports its mesh info.
+func Inspect(r io.ReadSeeker, format string) (*Info, error) {
+	return Walk(r, format, nil)
+}
+
+// Walk reads the file once, reporting its mesh info and passing every
+// triangle to fn when it is not nil. Polygons of PLY files are fanned into
+// triangles.
+func Walk(r io.ReadSeeker, format string, fn func(Triangle)) (*Info, error) {
+	var (
+		info *Info
+		err  error
+	)
+	switch format {
+	case FormatSTL:
+		info, err = walkSTL(r, fn)
+	case FormatPLY:
+		info, err = walkPLY(r, fn)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Units == "" {
+		info.Units = "mm"
+		info.UnitsAssumed = true
+	}
+	return info, nil
+}
+
+type bboxBuilder struct {
+	box BBox
+	any bool
+}
+
+func (b *bboxBuilder) add(x, y, z float64) {
+	// NaN and infinite coordinates would make the stored meta unencodable
+	for _, v := range [3]float64{x, y, z} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+	}
+	if !b.any {
+		b.box = BBox{Min: [3]float64{x, y, z}, Max: [3]float64{x, y, z}}
+		b.any = true
+		return
+	}
+	p := [3]float64{x, y, z}
+	for i := range p {
+		b.box.Min[i] = math.Min(b.box.Min[i], p[i])
+		b.box.Max[i] = math.Max(b.box.Max[i], p[i])
+	}
+}
+
+func (b *bboxBuilder) result() *BBox {
+	if !b.any {
+		return nil
+	}
+	box := b.box
+	return &box
+}
+
+var unitsRe = regexp.MustCompile(`(?i)\bunits?\s*[=:]?\s*(mm|millimet(?:er|re)s?|cm|centimet(?:er|re)s?|m|met(?:er|re)s?|in|inch(?:es)?|um|microns?)\b`)
+
+// unitsIn looks for a units declaration in header or comment text.
+func unitsIn(text string) string {
+	m := unitsRe.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	u := strings.ToLower(m[1])
+	switch {
+	case u == "mm" || strings.HasPrefix(u, "milli"):
+		return "mm"
+	case u == "cm" || strings.HasPrefix(u, "centi"):
+		return "cm"
+	case u == "in" || strings.HasPrefix(u, "inch"):
+		return "inch"
+	case u == "um" || strings.HasPrefix(u, "micro"):
+		return "um"
+	default:
+		return "m"
+	}
+}
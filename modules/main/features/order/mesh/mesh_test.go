@@ -0,0 +1,189 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func binarySTL(count uint32, tris ...[9]float32) []byte {
+	var b bytes.Buffer
+	b.Write(make([]byte, stlHeaderSize))
+	_ = binary.Write(&b, binary.LittleEndian, count)
+	for _, t := range tris {
+		_ = binary.Write(&b, binary.LittleEndian, [3]float32{}) // normal
+		_ = binary.Write(&b, binary.LittleEndian, t)
+		_ = binary.Write(&b, binary.LittleEndian, uint16(0))
+	}
+	return b.Bytes()
+}
+
+func binaryPLY(header string, body ...any) []byte {
+	var b bytes.Buffer
+	b.WriteString(header)
+	for _, v := range body {
+		_ = binary.Write(&b, binary.LittleEndian, v)
+	}
+	return b.Bytes()
+}
+
+const plyBinaryHeader = "ply\nformat binary_little_endian 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n"
+
+func TestWalkValid(t *testing.T) {
+	tri := [9]float32{0, 0, 0, 1, 0, 0, 0, 2, 0}
+	tests := []struct {
+		name      string
+		format    string
+		data      []byte
+		triangles int64
+		walked    int
+		size      [3]float64
+	}{
+		{
+			name:      "binary stl",
+			format:    FormatSTL,
+			data:      binarySTL(1, tri),
+			triangles: 1,
+			walked:    1,
+			size:      [3]float64{1, 2, 0},
+		},
+		{
+			name:      "ascii stl",
+			format:    FormatSTL,
+			data:      []byte("solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nvertex 0 2 0\nendloop\nendfacet\nendsolid t\n"),
+			triangles: 1,
+			walked:    1,
+			size:      [3]float64{1, 2, 0},
+		},
+		{
+			name:      "ascii ply quad",
+			format:    FormatPLY,
+			data:      []byte("ply\nformat ascii 1.0\nelement vertex 4\nproperty float x\nproperty float y\nproperty float z\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n0 0 0\n1 0 0\n1 1 0\n0 1 0\n4 0 1 2 3\n"),
+			triangles: 2,
+			walked:    2,
+			size:      [3]float64{1, 1, 0},
+		},
+		{
+			name:      "binary ply",
+			format:    FormatPLY,
+			data:      binaryPLY(plyBinaryHeader, tri, uint8(3), [3]int32{0, 1, 2}),
+			triangles: 1,
+			walked:    1,
+			size:      [3]float64{1, 2, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		walked := 0
+		info, err := Walk(bytes.NewReader(tt.data), tt.format, func(Triangle) { walked++ })
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if info.TriangleCount != tt.triangles || walked != tt.walked {
+			t.Errorf("%s: triangles = %d, walked = %d; want %d, %d", tt.name, info.TriangleCount, walked, tt.triangles, tt.walked)
+		}
+		if info.BBox == nil || info.BBox.Size() != tt.size {
+			t.Errorf("%s: bbox = %+v; want size %v", tt.name, info.BBox, tt.size)
+		}
+	}
+}
+
+func TestWalkMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+	}{
+		{"empty stl", FormatSTL, nil},
+		{"stl without solid", FormatSTL, []byte("hello world")},
+		{"binary stl count beyond file", FormatSTL, append(binarySTL(2, [9]float32{}), make([]byte, 10)...)},
+		{"ascii stl bad vertex", FormatSTL, []byte("solid t\nfacet normal 0 0 1\nvertex 0 x 0\nendfacet\n")},
+		{"ascii stl short vertex", FormatSTL, []byte("solid t\nfacet normal 0 0 1\nvertex 0 0\nendfacet\n")},
+		{"not a ply", FormatPLY, []byte("plx\nformat ascii 1.0\nend_header\n")},
+		{"ply missing format", FormatPLY, []byte("ply\nelement vertex 0\nend_header\n")},
+		{"ply unknown format", FormatPLY, []byte("ply\nformat utf16 1.0\nend_header\n")},
+		{"ply truncated header", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex 1\n")},
+		{"ply negative count", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex -1\nproperty float x\nend_header\n")},
+		{"ply unknown property type", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex 1\nproperty float128 x\nend_header\n0\n")},
+		{"ply property outside element", FormatPLY, []byte("ply\nformat ascii 1.0\nproperty float x\nend_header\n")},
+		{"ply list coordinate", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex 1\nproperty list uchar float x\nproperty float y\nproperty float z\nend_header\n0 1 2\n")},
+		{"ply element without properties", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex 1000\nend_header\n")},
+		{"ply count beyond file", FormatPLY, []byte("ply\nformat binary_little_endian 1.0\nelement vertex 9223372036854775807\nproperty float x\nend_header\n")},
+		{"ascii ply huge list", FormatPLY, []byte("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n1000000000 0 1 2\n")},
+		{"ascii ply nan list", FormatPLY, []byte("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\nNaN 0 1 2\n")},
+		{"ascii ply fractional list", FormatPLY, []byte("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n2.5 0 1 2\n")},
+		{"ascii ply negative list", FormatPLY, []byte("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n-3 0 1 2\n")},
+		{"ascii ply short row", FormatPLY, []byte("ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0\n")},
+		{"binary ply huge list", FormatPLY, binaryPLY("ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint int vertex_indices\nend_header\n", uint32(1<<31), [3]int32{0, 1, 2})},
+		{"binary ply nan list", FormatPLY, binaryPLY("ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list float int vertex_indices\nend_header\n", float32(math.NaN()), [3]int32{0, 1, 2})},
+		{"binary ply truncated body", FormatPLY, binaryPLY(plyBinaryHeader, [9]float32{}, uint8(3), int32(0))},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: panic %v", tt.name, r)
+				}
+			}()
+			_, err := Walk(bytes.NewReader(tt.data), tt.format, func(Triangle) {})
+			if !errors.Is(err, ErrMalformed) {
+				t.Errorf("%s: err = %v; want ErrMalformed", tt.name, err)
+			}
+		}()
+	}
+}
+
+func TestWalkOutOfRangeFaceIndex(t *testing.T) {
+	data := []byte("ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\nelement face 2\nproperty list uchar float vertex_indices\nend_header\n0 0 0\n1 0 0\n0 1 0\n3 0 1 3\n3 0 NaN 1e300\n")
+	walked := 0
+	info, err := Walk(bytes.NewReader(data), FormatPLY, func(Triangle) { walked++ })
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if info.TriangleCount != 2 || walked != 0 {
+		t.Errorf("triangles = %d, walked = %d; want 2, 0", info.TriangleCount, walked)
+	}
+}
+
+func TestBBoxSkipsNonFinite(t *testing.T) {
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+	data := binarySTL(2,
+		[9]float32{0, 0, 0, 1, 1, 1, inf, 0, 0},
+		[9]float32{nan, 0, 0, 0, float32(math.Inf(-1)), 0, 2, 2, 2},
+	)
+	info, err := Inspect(bytes.NewReader(data), FormatSTL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if info.BBox == nil || info.BBox.Max != [3]float64{2, 2, 2} || info.BBox.Min != [3]float64{0, 0, 0} {
+		t.Errorf("bbox = %+v; want [0 0 0]..[2 2 2]", info.BBox)
+	}
+	if _, err := json.Marshal(info.Meta()); err != nil {
+		t.Errorf("meta does not marshal: %v", err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{".stl", FormatSTL, true},
+		{"PLY", FormatPLY, true},
+		{".obj", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FormatOf(tt.input)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("FormatOf(%q) = %q, %v; want %q, %v", tt.input, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type plyProperty struct {
+	name      string
+	typ       string
+	list      bool
+	countType string // list length type
+}
+
+type plyElement struct {
+	name  string
+	count int64
+	props []plyProperty
+}
+
+// maxPLYListLen bounds list properties: faces of scans and CAD exports are
+// triangles or quads, so anything longer is a broken or hostile file.
+const maxPLYListLen = 32
+
+var plyTypeSize = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4,
+	"double": 8, "float64": 8,
+}
+
+func walkPLY(r io.ReadSeeker, fn func(Triangle)) (*Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(r, 1<<16)
+
+	info := &Info{Format: FormatPLY}
+	var (
+		elements []*plyElement
+		order    binary.ByteOrder
+	)
+
+	first, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(first) != "ply" {
+		return nil, fmt.Errorf("%w: not a PLY file", ErrMalformed)
+	}
+	headerSize := int64(len(first))
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+		}
+		headerSize += int64(len(line))
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "format":
+			if len(f) < 2 {
+				return nil, fmt.Errorf("%w: bad format line", ErrMalformed)
+			}
+			switch f[1] {
+			case "ascii":
+				info.Encoding = "ascii"
+			case "binary_little_endian":
+				info.Encoding, order = "binary", binary.LittleEndian
+			case "binary_big_endian":
+				info.Encoding, order = "binary", binary.BigEndian
+			default:
+				return nil, fmt.Errorf("%w: format %q", ErrMalformed, f[1])
+			}
+		case "comment", "obj_info":
+			if info.Units == "" {
+				info.Units = unitsIn(line)
+			}
+		case "element":
+			if len(f) != 3 {
+				return nil, fmt.Errorf("%w: bad element line", ErrMalformed)
+			}
+			n, err := strconv.ParseInt(f[2], 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: bad element count", ErrMalformed)
+			}
+			elements = append(elements, &plyElement{name: f[1], count: n})
+		case "property":
+			if len(elements) == 0 {
+				return nil, fmt.Errorf("%w: property outside element", ErrMalformed)
+			}
+			el := elements[len(elements)-1]
+			switch {
+			case len(f) == 5 && f[1] == "list":
+				if plyTypeSize[f[2]] == 0 || plyTypeSize[f[3]] == 0 {
+					return nil, fmt.Errorf("%w: property type", ErrMalformed)
+				}
+				if f[4] == "x" || f[4] == "y" || f[4] == "z" {
+					return nil, fmt.Errorf("%w: list property %q", ErrMalformed, f[4])
+				}
+				el.props = append(el.props, plyProperty{name: f[4], typ: f[3], list: true, countType: f[2]})
+			case len(f) == 3:
+				if plyTypeSize[f[1]] == 0 {
+					return nil, fmt.Errorf("%w: property type %q", ErrMalformed, f[1])
+				}
+				el.props = append(el.props, plyProperty{name: f[2], typ: f[1]})
+			default:
+				return nil, fmt.Errorf("%w: bad property line", ErrMalformed)
+			}
+		}
+		if f[0] == "end_header" {
+			break
+		}
+	}
+	if info.Encoding == "" {
+		return nil, fmt.Errorf("%w: missing format", ErrMalformed)
+	}
+	if err := checkPLYBody(elements, info.Encoding, size-headerSize); err != nil {
+		return nil, err
+	}
+
+	var (
+		bb    bboxBuilder
+		verts []float32 // kept only when triangles are walked
+	)
+
+	var next func(el *plyElement) ([][]float64, error)
+	if info.Encoding == "ascii" {
+		next = func(el *plyElement) ([][]float64, error) {
+			return readASCIIRow(br, el)
+		}
+	} else {
+		next = func(el *plyElement) ([][]float64, error) {
+			return readBinaryRow(br, order, el)
+		}
+	}
+
+	for _, el := range elements {
+		xi, yi, zi := propIndex(el, "x"), propIndex(el, "y"), propIndex(el, "z")
+		fi := propIndex(el, "vertex_indices")
+		if fi < 0 {
+			fi = propIndex(el, "vertex_index")
+		}
+
+		for i := int64(0); i < el.count; i++ {
+			row, err := next(el)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %d: %v", ErrMalformed, el.name, i, err)
+			}
+
+			switch {
+			case el.name == "vertex" && xi >= 0 && yi >= 0 && zi >= 0:
+				x, y, z := row[xi][0], row[yi][0], row[zi][0]
+				bb.add(x, y, z)
+				info.VertexCount++
+				if fn != nil {
+					verts = append(verts, float32(x), float32(y), float32(z))
+				}
+			case el.name == "face" && fi >= 0:
+				idx := row[fi]
+				if len(idx) < 3 {
+					continue
+				}
+				info.TriangleCount += int64(len(idx) - 2)
+				if fn == nil {
+					continue
+				}
+				for k := 1; k+1 < len(idx); k++ {
+					tri, ok := plyTriangle(verts, idx[0], idx[k], idx[k+1])
+					if ok {
+						fn(tri)
+					}
+				}
+			}
+		}
+	}
+
+	info.BBox = bb.result()
+	return info, nil
+}
+
+// checkPLYBody rejects element counts the rest of the file cannot hold, before
+// anything is read or allocated for them. A binary row takes at least its
+// scalars and list lengths; an ASCII row at least one digit and one separator
+// per property.
+func checkPLYBody(elements []*plyElement, encoding string, remaining int64) error {
+	var need float64
+	for _, el := range elements {
+		if el.count == 0 {
+			continue
+		}
+		if len(el.props) == 0 {
+			return fmt.Errorf("%w: element %s has no properties", ErrMalformed, el.name)
+		}
+		row := 0
+		for _, p := range el.props {
+			switch {
+			case encoding == "ascii":
+				row += 2
+			case p.list:
+				row += plyTypeSize[p.countType]
+			default:
+				row += plyTypeSize[p.typ]
+			}
+		}
+		need += float64(el.count) * float64(row)
+	}
+	if need > float64(remaining) {
+		return fmt.Errorf("%w: element counts exceed the file size", ErrMalformed)
+	}
+	return nil
+}
+
+// plyListLen validates a list length read from the file.
+func plyListLen(n float64) (int, error) {
+	if math.IsNaN(n) || n < 0 || n > maxPLYListLen || n != math.Trunc(n) {
+		return 0, fmt.Errorf("bad list length")
+	}
+	return int(n), nil
+}
+
+func propIndex(el *plyElement, name string) int {
+	for i, p := range el.props {
+		if p.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func plyTriangle(verts []float32, a, b, c float64) (Triangle, bool) {
+	var tri Triangle
+	for v, idx := range [3]float64{a, b, c} {
+		if math.IsNaN(idx) || idx < 0 || idx >= float64(len(verts)/3) {
+			return tri, false
+		}
+		i := int(idx) * 3
+		tri[v] = [3]float32{verts[i], verts[i+1], verts[i+2]}
+	}
+	return tri, true
+}
+
+// readASCIIRow reads one element instance; every property becomes a slice,
+// scalars of length one.
+func readASCIIRow(br *bufio.Reader, el *plyElement) ([][]float64, error) {
+	var fields []string
+	for len(fields) == 0 {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		fields = strings.Fields(line)
+	}
+
+	row := make([][]float64, len(el.props))
+	pos := 0
+	take := func() (float64, error) {
+		if pos >= len(fields) {
+			return 0, fmt.Errorf("short row")
+		}
+		v, err := strconv.ParseFloat(fields[pos], 64)
+		pos++
+		return v, err
+	}
+	for i, p := range el.props {
+		if !p.list {
+			v, err := take()
+			if err != nil {
+				return nil, err
+			}
+			row[i] = []float64{v}
+			continue
+		}
+		v, err := take()
+		if err != nil {
+			return nil, fmt.Errorf("bad list length")
+		}
+		n, err := plyListLen(v)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]float64, n)
+		for k := range vals {
+			if vals[k], err = take(); err != nil {
+				return nil, err
+			}
+		}
+		row[i] = vals
+	}
+	return row, nil
+}
+
+func readBinaryRow(br *bufio.Reader, order binary.ByteOrder, el *plyElement) ([][]float64, error) {
+	row := make([][]float64, len(el.props))
+	var buf [8]byte
+	read := func(typ string) (float64, error) {
+		size := plyTypeSize[typ]
+		if _, err := io.ReadFull(br, buf[:size]); err != nil {
+			return 0, err
+		}
+		b := buf[:size]
+		switch typ {
+		case "char", "int8":
+			return float64(int8(b[0])), nil
+		case "uchar", "uint8":
+			return float64(b[0]), nil
+		case "short", "int16":
+			return float64(int16(order.Uint16(b))), nil
+		case "ushort", "uint16":
+			return float64(order.Uint16(b)), nil
+		case "int", "int32":
+			return float64(int32(order.Uint32(b))), nil
+		case "uint", "uint32":
+			return float64(order.Uint32(b)), nil
+		case "float", "float32":
+			return float64(math.Float32frombits(order.Uint32(b))), nil
+		default:
+			return math.Float64frombits(order.Uint64(b)), nil
+		}
+	}
+	for i, p := range el.props {
+		if !p.list {
+			v, err := read(p.typ)
+			if err != nil {
+				return nil, err
+			}
+			row[i] = []float64{v}
+			continue
+		}
+		v, err := read(p.countType)
+		if err != nil {
+			return nil, err
+		}
+		n, err := plyListLen(v)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]float64, n)
+		for k := range vals {
+			if vals[k], err = read(p.typ); err != nil {
+				return nil, err
+			}
+		}
+		row[i] = vals
+	}
+	return row, nil
+}
@@ -0,0 +1,184 @@
+package mesh
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+var ErrEmptyMesh = errors.New("mesh has no triangles")
+
+// View is an orthographic camera looking along Dir with Up pointing up on
+// screen.
+type View struct {
+	Name string
+	Dir  [3]float64
+	Up   [3]float64
+}
+
+// StandardViews are the previews rendered for every scan. Scans are usually
+// exported with the occlusal plane on XY, so "top" is the occlusal view.
+var StandardViews = []View{
+	{Name: "top", Dir: [3]float64{0, 0, -1}, Up: [3]float64{0, 1, 0}},
+	{Name: "front", Dir: [3]float64{0, 1, 0}, Up: [3]float64{0, 0, 1}},
+	{Name: "side", Dir: [3]float64{-1, 0, 0}, Up: [3]float64{0, 0, 1}},
+	{Name: "iso", Dir: [3]float64{-1, 1, -1}, Up: [3]float64{0, 0, 1}},
+}
+
+// stone-like model color, shaded by a headlight
+var baseColor = [3]float64{222, 214, 196}
+
+const (
+	ambient = 0.22
+	margin  = 0.06
+)
+
+// Load reads every triangle of the file into memory.
+func Load(r io.ReadSeeker, format string) ([]Triangle, *Info, error) {
+	var tris []Triangle
+	info, err := Walk(r, format, func(t Triangle) {
+		tris = append(tris, t)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tris, info, nil
+}
+
+// Render rasterizes the triangles into a size x size image with a
+// transparent background, using flat shading and a depth buffer.
+func Render(tris []Triangle, view View, size int) (*image.NRGBA, error) {
+	if len(tris) == 0 {
+		return nil, ErrEmptyMesh
+	}
+
+	dir := normalize(view.Dir)
+	right := normalize(cross(dir, view.Up))
+	up := cross(right, dir)
+
+	// project and fit the silhouette into the frame
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, t := range tris {
+		for _, v := range t {
+			p := vec(v)
+			x, y := dot(p, right), dot(p, up)
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	extent := math.Max(maxX-minX, maxY-minY)
+	if extent <= 0 || math.IsInf(extent, 0) || math.IsNaN(extent) {
+		return nil, ErrEmptyMesh
+	}
+	scale := float64(size) * (1 - 2*margin) / extent
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	half := float64(size) / 2
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	zbuf := make([]float64, size*size)
+	for i := range zbuf {
+		zbuf[i] = math.Inf(1)
+	}
+
+	var sx, sy, sz [3]float64
+	for _, t := range tris {
+		a, b, c := vec(t[0]), vec(t[1]), vec(t[2])
+		n := cross(sub(b, a), sub(c, a))
+		if dot(n, n) == 0 {
+			continue
+		}
+		shade := ambient + (1-ambient)*math.Abs(dot(normalize(n), dir))
+		col := color.NRGBA{
+			R: uint8(baseColor[0] * shade),
+			G: uint8(baseColor[1] * shade),
+			B: uint8(baseColor[2] * shade),
+			A: 255,
+		}
+
+		for i, p := range [3][3]float64{a, b, c} {
+			sx[i] = half + (dot(p, right)-cx)*scale
+			sy[i] = half - (dot(p, up)-cy)*scale
+			sz[i] = dot(p, dir)
+		}
+
+		area := edge(sx[0], sy[0], sx[1], sy[1], sx[2], sy[2])
+		if area == 0 {
+			continue
+		}
+
+		x0 := clamp(int(math.Floor(min3(sx))), 0, size-1)
+		x1 := clamp(int(math.Ceil(max3(sx))), 0, size-1)
+		y0 := clamp(int(math.Floor(min3(sy))), 0, size-1)
+		y1 := clamp(int(math.Ceil(max3(sy))), 0, size-1)
+
+		for y := y0; y <= y1; y++ {
+			py := float64(y) + 0.5
+			for x := x0; x <= x1; x++ {
+				px := float64(x) + 0.5
+				w0 := edge(sx[1], sy[1], sx[2], sy[2], px, py) / area
+				w1 := edge(sx[2], sy[2], sx[0], sy[0], px, py) / area
+				w2 := 1 - w0 - w1
+				if w0 < 0 || w1 < 0 || w2 < 0 {
+					continue
+				}
+				z := w0*sz[0] + w1*sz[1] + w2*sz[2]
+				idx := y*size + x
+				if z >= zbuf[idx] {
+					continue
+				}
+				zbuf[idx] = z
+				img.SetNRGBA(x, y, col)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func vec(v [3]float32) [3]float64 {
+	return [3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func sub(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func normalize(a [3]float64) [3]float64 {
+	l := math.Sqrt(dot(a, a))
+	if l == 0 {
+		return a
+	}
+	return [3]float64{a[0] / l, a[1] / l, a[2] / l}
+}
+
+func edge(ax, ay, bx, by, px, py float64) float64 {
+	return (bx-ax)*(py-ay) - (by-ay)*(px-ax)
+}
+
+func min3(v [3]float64) float64 { return math.Min(v[0], math.Min(v[1], v[2])) }
+func max3(v [3]float64) float64 { return math.Max(v[0], math.Max(v[1], v[2])) }
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,138 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Binary STL: 80 byte header, uint32 triangle count, then 50 bytes per
+// triangle (normal, three vertices, attribute). ASCII files start with
+// "solid" too, so the size has to match the count to be taken as binary.
+const (
+	stlHeaderSize   = 80
+	stlTriangleSize = 50
+)
+
+func walkSTL(r io.ReadSeeker, fn func(Triangle)) (*Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, stlHeaderSize+4)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if n == stlHeaderSize+4 {
+		count := binary.LittleEndian.Uint32(head[stlHeaderSize:])
+		if int64(stlHeaderSize+4)+int64(count)*stlTriangleSize == size {
+			return walkBinarySTL(r, head[:stlHeaderSize], count, fn)
+		}
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(head), []byte("solid")) {
+		return nil, fmt.Errorf("%w: not an STL file", ErrMalformed)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return walkASCIISTL(r, fn)
+}
+
+func walkBinarySTL(r io.Reader, header []byte, count uint32, fn func(Triangle)) (*Info, error) {
+	info := &Info{
+		Format:        FormatSTL,
+		Encoding:      "binary",
+		TriangleCount: int64(count),
+		Units:         unitsIn(string(bytes.Trim(header, "\x00"))),
+	}
+
+	var (
+		bb  bboxBuilder
+		buf = make([]byte, stlTriangleSize)
+		br  = bufio.NewReaderSize(r, 1<<16)
+	)
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("%w: triangle %d: %v", ErrMalformed, i, err)
+		}
+		var tri Triangle
+		for v := 0; v < 3; v++ {
+			for c := 0; c < 3; c++ {
+				off := 12 + v*12 + c*4
+				tri[v][c] = math.Float32frombits(binary.LittleEndian.Uint32(buf[off:]))
+			}
+			bb.add(float64(tri[v][0]), float64(tri[v][1]), float64(tri[v][2]))
+		}
+		if fn != nil {
+			fn(tri)
+		}
+	}
+
+	info.BBox = bb.result()
+	return info, nil
+}
+
+func walkASCIISTL(r io.Reader, fn func(Triangle)) (*Info, error) {
+	info := &Info{Format: FormatSTL, Encoding: "ascii"}
+
+	var (
+		bb   bboxBuilder
+		tri  Triangle
+		vert int
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 1<<16), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "solid"):
+			if info.Units == "" {
+				info.Units = unitsIn(line)
+			}
+		case strings.HasPrefix(line, "facet"):
+			vert = 0
+		case strings.HasPrefix(line, "vertex"):
+			f := strings.Fields(line)
+			if len(f) != 4 {
+				return nil, fmt.Errorf("%w: bad vertex %q", ErrMalformed, line)
+			}
+			var p [3]float64
+			for c := 0; c < 3; c++ {
+				v, err := strconv.ParseFloat(f[c+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: bad vertex %q", ErrMalformed, line)
+				}
+				p[c] = v
+			}
+			bb.add(p[0], p[1], p[2])
+			if vert < 3 {
+				tri[vert] = [3]float32{float32(p[0]), float32(p[1]), float32(p[2])}
+			}
+			vert++
+		case strings.HasPrefix(line, "endfacet"):
+			info.TriangleCount++
+			if fn != nil && vert == 3 {
+				fn(tri)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	info.BBox = bb.result()
+	return info, nil
+}
@@ -8,7 +8,11 @@ import (
 	"github.com/khiemnd777/andy_api/modules/main/features/order/jobs"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/service"
+	productrepo "github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	productservice "github.com/khiemnd777/andy_api/modules/main/features/product/service"
 	"github.com/khiemnd777/andy_api/modules/main/registry"
+	metadatarepo "github.com/khiemnd777/andy_api/modules/metadata/repository"
+	metadataservice "github.com/khiemnd777/andy_api/modules/metadata/service"
 	"github.com/khiemnd777/andy_api/shared/cron"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
@@ -33,10 +37,18 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	orderCodeHandler.RegisterRoutes(router)
 
 	ordItemRepo := repository.NewOrderItemRepository(deps.Ent.(*generated.Client), deps, cfMgr)
-	ordItemSvc := service.NewOrderItemService(ordItemRepo, deps, cfMgr)
+	priceRepo := productrepo.NewProductPriceRepository(deps.Ent.(*generated.Client), deps)
+	priceSvc := productservice.NewProductPriceService(priceRepo, deps)
+	ordItemSvc := service.NewOrderItemService(ordItemRepo, priceSvc, deps, cfMgr)
 	ordItemHandler := handler.NewOrderItemHandler(ordItemSvc, deps)
 	ordItemHandler.RegisterRoutes(router)
 
+	ordItemFileRepo := repository.NewOrderItemFileRepository(deps.Ent.(*generated.Client))
+	ordItemFileSvc := service.NewOrderItemFileService(ordItemFileRepo, deps)
+	cron.RegisterJob(jobs.NewRenderOrderItemFilePreviewsJob(ordItemFileSvc))
+	ordItemFileHandler := handler.NewOrderItemFileHandler(ordItemFileSvc, deps)
+	ordItemFileHandler.RegisterRoutes(router)
+
 	ordItemMaterialRepo := repository.NewOrderItemMaterialRepository(deps.Ent.(*generated.Client))
 	ordItemMaterialSvc := service.NewOrderItemMaterialService(ordItemMaterialRepo, deps)
 	ordItemMaterialHandler := handler.NewOrderItemMaterialHandler(ordItemMaterialSvc, deps)
@@ -48,6 +60,30 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	ordItemProcessHandler := handler.NewOrderItemProcessHandler(ordItemProcessSvc, deps)
 	ordItemProcessHandler.RegisterRoutes(router)
 
+	ordTemplateRepo := repository.NewOrderTemplateRepository(deps.Ent.(*generated.Client), deps)
+	ordTemplateSvc := service.NewOrderTemplateService(ordTemplateRepo, ordRepo, ordItemProcessRepo, orderCodeSvc, ordSvc, deps)
+	ordTemplateHandler := handler.NewOrderTemplateHandler(ordTemplateSvc, deps)
+	ordTemplateHandler.RegisterRoutes(router)
+
+	ordCancelReasonRepo := repository.NewOrderCancelReasonRepository(deps.Ent.(*generated.Client))
+	ordCancelReasonSvc := service.NewOrderCancelReasonService(ordCancelReasonRepo, deps)
+	ordCancelReasonHandler := handler.NewOrderCancelReasonHandler(ordCancelReasonSvc, deps)
+	ordCancelReasonHandler.RegisterRoutes(router)
+
+	ordItemHoldRepo := repository.NewOrderItemHoldRepository(deps.Ent.(*generated.Client))
+	ordItemHoldSvc := service.NewOrderItemHoldService(ordItemHoldRepo, deps)
+	cron.RegisterJob(jobs.NewOrderItemHoldAlertJob(ordItemHoldSvc))
+	ordItemHoldHandler := handler.NewOrderItemHoldHandler(ordItemHoldSvc, deps)
+	ordItemHoldHandler.RegisterRoutes(router)
+
+	importProfileRepo := metadatarepo.NewImportFieldProfileRepository(deps.DB)
+	importMappingRepo := metadatarepo.NewImportFieldMappingRepository(deps.DB)
+	importEngine := metadataservice.NewImportEngine(deps.DB, metadataservice.NewImportFieldMappingService(importMappingRepo, importProfileRepo))
+	ordImportRepo := repository.NewOrderImportRepository(deps.Ent.(*generated.Client), deps)
+	ordImportSvc := service.NewOrderImportService(ordImportRepo, orderCodeSvc, ordSvc, priceSvc, deps)
+	ordImportHandler := handler.NewOrderImportHandler(ordImportSvc, importEngine, deps)
+	ordImportHandler.RegisterRoutes(router)
+
 	return nil
 }
 
@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/ordercancellation"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/ordercancelreason"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemhold"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemmaterial"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocessinprogress"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+// Cancellation moves the chosen items (every item not cancelled yet when
+// none are chosen) to "cancelled", closes their open steps, open in-progress
+// rows, open holds and open loaners, and cancels the order once its latest item is
+// cancelled. Only then are the promotion usages of the order released. The
+// fee is billed on the next invoice of the clinic.
+
+const (
+	CancelScopeOrder = "order"
+	CancelScopeItems = "items"
+
+	statusCancelled = "cancelled"
+)
+
+var (
+	ErrInvalidCancelReason = errors.New("cancel reason is unknown or inactive")
+	ErrInvalidCancelFee    = errors.New("cancellation fee must not be negative")
+	ErrNothingToCancel     = errors.New("order has no item left to cancel")
+	ErrItemNotInOrder      = errors.New("order item does not belong to the order")
+	ErrOrderItemCancelled  = errors.New("order item is cancelled")
+)
+
+func (r *orderRepository) cancelReason(ctx context.Context, tx *generated.Tx, deptID int, input *model.OrderCancelDTO) (*generated.OrderCancelReason, error) {
+	q := tx.OrderCancelReason.Query().
+		Where(
+			ordercancelreason.DepartmentIDEQ(deptID),
+			ordercancelreason.ActiveEQ(true),
+		)
+	switch {
+	case input.ReasonID != nil:
+		q = q.Where(ordercancelreason.IDEQ(*input.ReasonID))
+	case input.ReasonCode != nil && *input.ReasonCode != "":
+		q = q.Where(ordercancelreason.CodeEQ(*input.ReasonCode))
+	default:
+		return nil, ErrInvalidCancelReason
+	}
+
+	reason, err := q.Only(ctx)
+	if generated.IsNotFound(err) {
+		return nil, ErrInvalidCancelReason
+	}
+	return reason, err
+}
+
+func itemStatus(item *generated.OrderItem) string {
+	if s, ok := item.CustomFields["status"].(string); ok && s != "" {
+		return s
+	}
+	return item.Status
+}
+
+func (r *orderRepository) Cancel(ctx context.Context, deptID, userID int, orderID int64, input *model.OrderCancelDTO) (*model.OrderCancellationDTO, error) {
+	var err error
+
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	ctx = WithStatusActor(ctx, userID)
+
+	orderEnt, err := tx.Order.Query().
+		Where(
+			order.IDEQ(orderID),
+			order.DepartmentIDEQ(deptID),
+			order.DeletedAtIsNil(),
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.ensureNotInvoiced(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+
+	reason, err := r.cancelReason(ctx, tx, deptID, input)
+	if err != nil {
+		return nil, err
+	}
+	fee := reason.DefaultFee
+	if input.Fee != nil {
+		fee = *input.Fee
+	}
+	if fee < 0 {
+		err = ErrInvalidCancelFee
+		return nil, err
+	}
+
+	items, err := tx.OrderItem.Query().
+		Where(
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Order(generated.Asc(orderitem.FieldCreatedAt), generated.Asc(orderitem.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		err = ErrNothingToCancel
+		return nil, err
+	}
+	latest, err := tx.OrderItem.Query().
+		Where(
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt), generated.Desc(orderitem.FieldID)).
+		First(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := CancelScopeOrder
+	targets := make([]*generated.OrderItem, 0, len(items))
+	if len(input.OrderItemIDs) > 0 {
+		scope = CancelScopeItems
+		for _, id := range input.OrderItemIDs {
+			idx := slices.IndexFunc(items, func(it *generated.OrderItem) bool { return it.ID == id })
+			if idx < 0 {
+				err = ErrItemNotInOrder
+				return nil, err
+			}
+			// already cancelled items are skipped, so a repeated request
+			// neither logs a second cancellation nor bills the fee again
+			if itemStatus(items[idx]) == statusCancelled || slices.Contains(targets, items[idx]) {
+				continue
+			}
+			targets = append(targets, items[idx])
+		}
+	} else {
+		for _, it := range items {
+			if itemStatus(it) != statusCancelled {
+				targets = append(targets, it)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		err = ErrNothingToCancel
+		return nil, err
+	}
+
+	now := time.Now()
+	closeNote := fmt.Sprintf("cancelled: %s", reason.Name)
+	var (
+		itemIDs       []int64
+		closedIP      int
+		closedLoaners int
+	)
+
+	for _, it := range targets {
+		from := itemStatus(it)
+		if err = checkStatusTransition(StatusEntityOrderItem, from, statusCancelled, nil, nil); err != nil {
+			return nil, err
+		}
+
+		// open steps
+		processes, e := tx.OrderItemProcess.Query().
+			Where(orderitemprocess.OrderItemIDEQ(it.ID)).
+			All(ctx)
+		if e != nil {
+			err = e
+			return nil, err
+		}
+		for _, p := range processes {
+			ps := processStatus(p)
+			if isStepDone(ps) {
+				continue
+			}
+			cf := maps.Clone(p.CustomFields)
+			if cf == nil {
+				cf = make(map[string]any)
+			}
+			cf["status"] = statusCancelled
+			if err = tx.OrderItemProcess.UpdateOneID(p.ID).
+				SetCustomFields(cf).
+				Exec(ctx); err != nil {
+				return nil, err
+			}
+			if err = recordStatusChange(ctx, tx, StatusEntityProcess, p.ID, &orderID, &it.ID, &ps, statusCancelled); err != nil {
+				return nil, err
+			}
+		}
+
+		// open in-progress rows
+		n, e := tx.OrderItemProcessInProgress.Update().
+			Where(
+				orderitemprocessinprogress.OrderItemIDEQ(it.ID),
+				orderitemprocessinprogress.CompletedAtIsNil(),
+			).
+			SetCompletedAt(now).
+			SetCheckOutNote(closeNote).
+			Save(ctx)
+		if e != nil {
+			err = e
+			return nil, err
+		}
+		closedIP += n
+
+		// open loaners
+		n, e = tx.OrderItemMaterial.Update().
+			Where(
+				orderitemmaterial.OrderItemIDEQ(it.ID),
+				orderitemmaterial.TypeEQ("loaner"),
+				orderitemmaterial.StatusIn("on_loan", "partial_returned"),
+			).
+			SetStatus("returned").
+			SetReturnedAt(now).
+			Save(ctx)
+		if e != nil {
+			err = e
+			return nil, err
+		}
+		closedLoaners += n
+
+		// open hold
+		if _, err = tx.OrderItemHold.Update().
+			Where(
+				orderitemhold.OrderItemIDEQ(it.ID),
+				orderitemhold.ResumedAtIsNil(),
+			).
+			SetResumedAt(now).
+			SetResumeNote(closeNote).
+			Save(ctx); err != nil {
+			return nil, err
+		}
+
+		cf := maps.Clone(it.CustomFields)
+		if cf == nil {
+			cf = make(map[string]any)
+		}
+		cf["status"] = statusCancelled
+		if err = tx.OrderItem.UpdateOneID(it.ID).
+			SetCustomFields(cf).
+			SetStatus(statusCancelled).
+			Exec(ctx); err != nil {
+			return nil, err
+		}
+		if err = recordStatusChange(ctx, tx, StatusEntityOrderItem, it.ID, &orderID, &it.ID, &from, statusCancelled); err != nil {
+			return nil, err
+		}
+		itemIDs = append(itemIDs, it.ID)
+	}
+
+	// the order follows its latest item
+	statusLatest := orderEnt.StatusLatest
+	releasedDiscount := 0
+	if slices.Contains(itemIDs, latest.ID) {
+		if err = tx.Order.UpdateOneID(orderID).
+			SetStatusLatest(statusCancelled).
+			Exec(ctx); err != nil {
+			return nil, err
+		}
+		if err = recordStatusChange(ctx, tx, StatusEntityOrder, orderID, &orderID, &latest.ID, orderEnt.StatusLatest, statusCancelled); err != nil {
+			return nil, err
+		}
+		statusLatest = utils.Ptr(statusCancelled)
+
+		releasedDiscount, err = r.promotionRepo.ReleaseUsagesByOrderID(ctx, tx, orderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var cancelledBy *int
+	if userID > 0 {
+		cancelledBy = &userID
+	}
+
+	entity, err := tx.OrderCancellation.Create().
+		SetDepartmentID(deptID).
+		SetOrderID(orderID).
+		SetNillableOrderCode(orderEnt.Code).
+		SetScope(scope).
+		SetOrderItemIds(itemIDs).
+		SetReasonID(reason.ID).
+		SetReasonCode(reason.Code).
+		SetReasonName(reason.Name).
+		SetNillableNote(input.Note).
+		SetFee(fee).
+		SetReleasedDiscount(releasedDiscount).
+		SetClosedInProgresses(closedIP).
+		SetClosedLoaners(closedLoaners).
+		SetNillableCancelledBy(cancelledBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := mapOrderCancellation(entity)
+	out.StatusLatest = statusLatest
+	return out, nil
+}
+
+func (r *orderRepository) Cancellations(ctx context.Context, deptID int, orderID int64) ([]*model.OrderCancellationDTO, error) {
+	list, err := r.db.OrderCancellation.Query().
+		Where(
+			ordercancellation.DepartmentIDEQ(deptID),
+			ordercancellation.OrderIDEQ(orderID),
+		).
+		Order(generated.Asc(ordercancellation.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*model.OrderCancellationDTO, 0, len(list))
+	for _, e := range list {
+		out = append(out, mapOrderCancellation(e))
+	}
+	return out, nil
+}
+
+func mapOrderCancellation(e *generated.OrderCancellation) *model.OrderCancellationDTO {
+	return &model.OrderCancellationDTO{
+		ID:                 e.ID,
+		DepartmentID:       e.DepartmentID,
+		OrderID:            e.OrderID,
+		OrderCode:          e.OrderCode,
+		Scope:              e.Scope,
+		OrderItemIDs:       e.OrderItemIds,
+		ReasonID:           e.ReasonID,
+		ReasonCode:         e.ReasonCode,
+		ReasonName:         e.ReasonName,
+		Note:               e.Note,
+		Fee:                e.Fee,
+		ReleasedDiscount:   e.ReleasedDiscount,
+		ClosedInProgresses: e.ClosedInProgresses,
+		ClosedLoaners:      e.ClosedLoaners,
+		CancelledBy:        e.CancelledBy,
+		CreatedAt:          e.CreatedAt,
+	}
+}
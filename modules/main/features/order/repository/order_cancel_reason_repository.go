@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/ordercancelreason"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+)
+
+type OrderCancelReasonRepository interface {
+	List(ctx context.Context, deptID int, activeOnly bool) ([]*model.OrderCancelReasonDTO, error)
+	Create(ctx context.Context, deptID int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+}
+
+type orderCancelReasonRepository struct {
+	db *generated.Client
+}
+
+func NewOrderCancelReasonRepository(db *generated.Client) OrderCancelReasonRepository {
+	return &orderCancelReasonRepository{db: db}
+}
+
+func (r *orderCancelReasonRepository) List(ctx context.Context, deptID int, activeOnly bool) ([]*model.OrderCancelReasonDTO, error) {
+	q := r.db.OrderCancelReason.Query().
+		Where(ordercancelreason.DepartmentIDEQ(deptID))
+	if activeOnly {
+		q = q.Where(ordercancelreason.ActiveEQ(true))
+	}
+	list, err := q.
+		Order(generated.Asc(ordercancelreason.FieldCode)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.OrderCancelReason, *model.OrderCancelReasonDTO](list), nil
+}
+
+func (r *orderCancelReasonRepository) Create(ctx context.Context, deptID int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error) {
+	entity, err := r.db.OrderCancelReason.Create().
+		SetDepartmentID(deptID).
+		SetCode(input.Code).
+		SetName(input.Name).
+		SetDefaultFee(input.DefaultFee).
+		SetActive(input.Active).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.OrderCancelReason, *model.OrderCancelReasonDTO](entity), nil
+}
+
+func (r *orderCancelReasonRepository) Update(ctx context.Context, deptID int, id int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error) {
+	entity, err := r.db.OrderCancelReason.UpdateOneID(id).
+		Where(ordercancelreason.DepartmentIDEQ(deptID)).
+		SetCode(input.Code).
+		SetName(input.Name).
+		SetDefaultFee(input.DefaultFee).
+		SetActive(input.Active).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.OrderCancelReason, *model.OrderCancelReasonDTO](entity), nil
+}
+
+// Delete only deactivates the reason; past cancellations keep a snapshot of
+// it anyway.
+func (r *orderCancelReasonRepository) Delete(ctx context.Context, deptID int, id int) error {
+	return r.db.OrderCancelReason.UpdateOneID(id).
+		Where(ordercancelreason.DepartmentIDEQ(deptID)).
+		SetActive(false).
+		Exec(ctx)
+}
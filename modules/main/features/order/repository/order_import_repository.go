@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinic"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicdentist"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicpatient"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/dentist"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/material"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/patient"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+// Order imports name clinics, dentists, patients, products and materials the
+// way people type them. Names match case-insensitively among live records;
+// products and materials match on code first. Dentists and patients linked to
+// the clinic of the order win over namesakes elsewhere.
+
+var (
+	ErrImportRefNotFound  = errors.New("not found")
+	ErrImportRefAmbiguous = errors.New("matches more than one record")
+)
+
+type OrderImportRef struct {
+	ID   int
+	Name string
+	// material only: consumable | asset | loaner
+	Type *string
+}
+
+type OrderImportRepository interface {
+	Clinic(ctx context.Context, name string) (*OrderImportRef, error)
+	Dentist(ctx context.Context, clinicID int, name string) (*OrderImportRef, error)
+	Patient(ctx context.Context, clinicID int, name string) (*OrderImportRef, error)
+	Product(ctx context.Context, code, name string) (*OrderImportRef, error)
+	Material(ctx context.Context, code, name string) (*OrderImportRef, error)
+}
+
+type orderImportRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderImportRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) OrderImportRepository {
+	return &orderImportRepository{db: db, deps: deps}
+}
+
+func importRefError(kind, value string, err error) error {
+	return fmt.Errorf("%s %q %w", kind, value, err)
+}
+
+func (r *orderImportRepository) Clinic(ctx context.Context, name string) (*OrderImportRef, error) {
+	name = strings.TrimSpace(name)
+	list, err := r.db.Clinic.Query().
+		Where(
+			clinic.NameEqualFold(name),
+			clinic.DeletedAtIsNil(),
+		).
+		Limit(2).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, importRefError("clinic", name, ErrImportRefNotFound)
+	case 1:
+		return &OrderImportRef{ID: list[0].ID, Name: list[0].Name}, nil
+	}
+	return nil, importRefError("clinic", name, ErrImportRefAmbiguous)
+}
+
+func (r *orderImportRepository) Dentist(ctx context.Context, clinicID int, name string) (*OrderImportRef, error) {
+	name = strings.TrimSpace(name)
+
+	if clinicID > 0 {
+		list, err := r.db.Dentist.Query().
+			Where(
+				dentist.NameEqualFold(name),
+				dentist.DeletedAtIsNil(),
+				dentist.HasClinicsWith(clinicdentist.ClinicIDEQ(clinicID)),
+			).
+			Limit(2).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 1 {
+			return &OrderImportRef{ID: list[0].ID, Name: list[0].Name}, nil
+		}
+		if len(list) > 1 {
+			return nil, importRefError("dentist", name, ErrImportRefAmbiguous)
+		}
+	}
+
+	list, err := r.db.Dentist.Query().
+		Where(
+			dentist.NameEqualFold(name),
+			dentist.DeletedAtIsNil(),
+		).
+		Limit(2).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, importRefError("dentist", name, ErrImportRefNotFound)
+	case 1:
+		return &OrderImportRef{ID: list[0].ID, Name: list[0].Name}, nil
+	}
+	return nil, importRefError("dentist", name, ErrImportRefAmbiguous)
+}
+
+func (r *orderImportRepository) Patient(ctx context.Context, clinicID int, name string) (*OrderImportRef, error) {
+	name = strings.TrimSpace(name)
+
+	if clinicID > 0 {
+		list, err := r.db.Patient.Query().
+			Where(
+				patient.NameEqualFold(name),
+				patient.DeletedAtIsNil(),
+				patient.HasClinicsWith(clinicpatient.ClinicIDEQ(clinicID)),
+			).
+			Limit(2).
+			All(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 1 {
+			return &OrderImportRef{ID: list[0].ID, Name: list[0].Name}, nil
+		}
+		if len(list) > 1 {
+			return nil, importRefError("patient", name, ErrImportRefAmbiguous)
+		}
+	}
+
+	list, err := r.db.Patient.Query().
+		Where(
+			patient.NameEqualFold(name),
+			patient.DeletedAtIsNil(),
+		).
+		Limit(2).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, importRefError("patient", name, ErrImportRefNotFound)
+	case 1:
+		return &OrderImportRef{ID: list[0].ID, Name: list[0].Name}, nil
+	}
+	return nil, importRefError("patient", name, ErrImportRefAmbiguous)
+}
+
+func (r *orderImportRepository) Product(ctx context.Context, code, name string) (*OrderImportRef, error) {
+	code, name = strings.TrimSpace(code), strings.TrimSpace(name)
+
+	q := r.db.Product.Query().Where(product.DeletedAtIsNil())
+	value := code
+	if code != "" {
+		q = q.Where(product.CodeEqualFold(code))
+	} else {
+		q = q.Where(product.NameEqualFold(name))
+		value = name
+	}
+	list, err := q.Limit(2).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, importRefError("product", value, ErrImportRefNotFound)
+	case 1:
+		ref := &OrderImportRef{ID: list[0].ID}
+		if list[0].Name != nil {
+			ref.Name = *list[0].Name
+		}
+		return ref, nil
+	}
+	return nil, importRefError("product", value, ErrImportRefAmbiguous)
+}
+
+func (r *orderImportRepository) Material(ctx context.Context, code, name string) (*OrderImportRef, error) {
+	code, name = strings.TrimSpace(code), strings.TrimSpace(name)
+
+	q := r.db.Material.Query().Where(material.DeletedAtIsNil())
+	value := code
+	if code != "" {
+		q = q.Where(material.CodeEqualFold(code))
+	} else {
+		q = q.Where(material.NameEqualFold(name))
+		value = name
+	}
+	list, err := q.Limit(2).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, importRefError("material", value, ErrImportRefNotFound)
+	case 1:
+		ref := &OrderImportRef{ID: list[0].ID, Type: list[0].Type}
+		if list[0].Name != nil {
+			ref.Name = *list[0].Name
+		}
+		return ref, nil
+	}
+	return nil, importRefError("material", value, ErrImportRefAmbiguous)
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemfile"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+)
+
+var ErrFileAlreadyReplaced = errors.New("file has already been replaced by a newer version")
+
+type OrderItemFileRepository interface {
+	ItemBelongsToOrder(ctx context.Context, orderID, orderItemID int64) (bool, error)
+	FindCurrentByChecksum(ctx context.Context, orderItemID int64, checksum string) (*model.OrderItemFileDTO, error)
+	// Create stores a new file; with replacesID it becomes the next version of
+	// that file, which is marked replaced in the same transaction.
+	Create(ctx context.Context, input *model.OrderItemFileDTO, replacesID *int64) (*model.OrderItemFileDTO, error)
+	GetByID(ctx context.Context, orderItemID, id int64) (*model.OrderItemFileDTO, error)
+	// List returns the current files of an item, or every version with all.
+	List(ctx context.Context, orderItemID int64, all bool) ([]*model.OrderItemFileDTO, error)
+	// LoadFiles attaches the current files to the items.
+	LoadFiles(ctx context.Context, items ...*model.OrderItemDTO) error
+	OrderIDOfItem(ctx context.Context, orderItemID int64) (int64, error)
+
+	// previews
+	Get(ctx context.Context, id int64) (*model.OrderItemFileDTO, error)
+	ListPendingPreviews(ctx context.Context, createdBefore time.Time, limit int) ([]*model.OrderItemFileDTO, error)
+	SetPreviews(ctx context.Context, id int64, status string, previews []map[string]string) error
+}
+
+type orderItemFileRepository struct {
+	db *generated.Client
+}
+
+func NewOrderItemFileRepository(db *generated.Client) OrderItemFileRepository {
+	return &orderItemFileRepository{db: db}
+}
+
+func (r *orderItemFileRepository) ItemBelongsToOrder(ctx context.Context, orderID, orderItemID int64) (bool, error) {
+	return r.db.OrderItem.
+		Query().
+		Where(
+			orderitem.ID(orderItemID),
+			orderitem.OrderID(orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Exist(ctx)
+}
+
+func (r *orderItemFileRepository) FindCurrentByChecksum(ctx context.Context, orderItemID int64, checksum string) (*model.OrderItemFileDTO, error) {
+	entity, err := r.db.OrderItemFile.
+		Query().
+		Where(
+			orderitemfile.OrderItemID(orderItemID),
+			orderitemfile.Checksum(checksum),
+			orderitemfile.ReplacedAtIsNil(),
+		).
+		First(ctx)
+	if generated.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.OrderItemFile, *model.OrderItemFileDTO](entity), nil
+}
+
+func (r *orderItemFileRepository) Create(ctx context.Context, input *model.OrderItemFileDTO, replacesID *int64) (*model.OrderItemFileDTO, error) {
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	version := 1
+	if replacesID != nil {
+		var prev *generated.OrderItemFile
+		prev, err = tx.OrderItemFile.
+			Query().
+			Where(
+				orderitemfile.ID(*replacesID),
+				orderitemfile.OrderItemID(input.OrderItemID),
+			).
+			Only(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// only the current version can be replaced, also under concurrent uploads
+		var n int
+		n, err = tx.OrderItemFile.
+			Update().
+			Where(
+				orderitemfile.ID(prev.ID),
+				orderitemfile.ReplacedAtIsNil(),
+			).
+			SetReplacedAt(time.Now()).
+			Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			err = ErrFileAlreadyReplaced
+			return nil, err
+		}
+		version = prev.Version + 1
+	}
+
+	var entity *generated.OrderItemFile
+	entity, err = tx.OrderItemFile.
+		Create().
+		SetOrderItemID(input.OrderItemID).
+		SetFileURL(input.FileURL).
+		SetFileType(input.FileType).
+		SetDescription(input.Description).
+		SetNillableFileName(input.FileName).
+		SetNillableMimeType(input.MimeType).
+		SetSize(input.Size).
+		SetNillableChecksum(input.Checksum).
+		SetVersion(version).
+		SetNillablePreviousID(replacesID).
+		SetMeta(input.Meta).
+		SetNillablePreviewStatus(input.PreviewStatus).
+		SetNillableUploadedBy(input.UploadedBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.MapAs[*generated.OrderItemFile, *model.OrderItemFileDTO](entity), nil
+}
+
+func (r *orderItemFileRepository) GetByID(ctx context.Context, orderItemID, id int64) (*model.OrderItemFileDTO, error) {
+	entity, err := r.db.OrderItemFile.
+		Query().
+		Where(
+			orderitemfile.ID(id),
+			orderitemfile.OrderItemID(orderItemID),
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.OrderItemFile, *model.OrderItemFileDTO](entity), nil
+}
+
+func (r *orderItemFileRepository) List(ctx context.Context, orderItemID int64, all bool) ([]*model.OrderItemFileDTO, error) {
+	q := r.db.OrderItemFile.
+		Query().
+		Where(orderitemfile.OrderItemID(orderItemID))
+	if !all {
+		q = q.Where(orderitemfile.ReplacedAtIsNil())
+	}
+
+	rows, err := q.
+		Order(
+			orderitemfile.ByCreatedAt(sql.OrderDesc()),
+			orderitemfile.ByID(sql.OrderDesc()),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.OrderItemFile, *model.OrderItemFileDTO](rows), nil
+}
+
+func (r *orderItemFileRepository) LoadFiles(ctx context.Context, items ...*model.OrderItemDTO) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	itemIndex := make(map[int64]*model.OrderItemDTO, len(items))
+	itemIDs := make([]int64, 0, len(items))
+	for _, it := range items {
+		if it == nil {
+			continue
+		}
+		itemIDs = append(itemIDs, it.ID)
+		itemIndex[it.ID] = it
+	}
+
+	if len(itemIDs) == 0 {
+		return nil
+	}
+
+	rows, err := r.db.OrderItemFile.Query().
+		Where(
+			orderitemfile.OrderItemIDIn(itemIDs...),
+			orderitemfile.ReplacedAtIsNil(),
+		).
+		Order(orderitemfile.ByCreatedAt(sql.OrderDesc())).
+		All(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if dto, ok := itemIndex[row.OrderItemID]; ok {
+			dto.Files = append(dto.Files, mapper.MapAs[*generated.OrderItemFile, *model.OrderItemFileDTO](row))
+		}
+	}
+
+	return nil
+}
+
+func (r *orderItemFileRepository) OrderIDOfItem(ctx context.Context, orderItemID int64) (int64, error) {
+	entity, err := r.db.OrderItem.
+		Query().
+		Where(orderitem.ID(orderItemID)).
+		Select(orderitem.FieldOrderID).
+		Only(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return entity.OrderID, nil
+}
+
+func (r *orderItemFileRepository) Get(ctx context.Context, id int64) (*model.OrderItemFileDTO, error) {
+	entity, err := r.db.OrderItemFile.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.OrderItemFile, *model.OrderItemFileDTO](entity), nil
+}
+
+func (r *orderItemFileRepository) ListPendingPreviews(ctx context.Context, createdBefore time.Time, limit int) ([]*model.OrderItemFileDTO, error) {
+	rows, err := r.db.OrderItemFile.
+		Query().
+		Where(
+			orderitemfile.PreviewStatus("pending"),
+			orderitemfile.CreatedAtLT(createdBefore),
+		).
+		Order(orderitemfile.ByCreatedAt()).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.OrderItemFile, *model.OrderItemFileDTO](rows), nil
+}
+
+func (r *orderItemFileRepository) SetPreviews(ctx context.Context, id int64, status string, previews []map[string]string) error {
+	q := r.db.OrderItemFile.
+		UpdateOneID(id).
+		SetPreviewStatus(status)
+	if previews != nil {
+		q = q.SetPreviews(previews)
+	}
+	return q.Exec(ctx)
+}
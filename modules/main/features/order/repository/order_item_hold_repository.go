@@ -0,0 +1,399 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"time"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinic"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemhold"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
+	"github.com/khiemnd777/andy_api/shared/logger"
+)
+
+// A hold parks an order item while the lab waits on the clinic. The item
+// moves to "on_hold", takes no check-in, and the held period is left out of
+// turnaround, due-date risk and step SLAs. Resuming derives the status again
+// from the processes, so steps checked out meanwhile are not lost.
+
+const statusOnHold = "on_hold"
+
+var (
+	ErrOrderItemOnHold    = errors.New("order item is on hold")
+	ErrOrderItemNotOnHold = errors.New("order item is not on hold")
+	ErrInvalidHoldReason  = errors.New("hold reason is required")
+)
+
+// HoldAlert is an open hold past the alert threshold together with whom to
+// tell about it.
+type HoldAlert struct {
+	Hold             *generated.OrderItemHold
+	OrderCode        *string
+	ClinicID         *int
+	ClinicName       *string
+	AccountOwnerID   int
+	AccountOwnerName *string
+}
+
+type OrderItemHoldRepository interface {
+	Hold(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemHoldInputDTO) (*model.OrderItemHoldDTO, error)
+	Resume(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemResumeDTO) (*model.OrderItemHoldDTO, *generated.OrderItem, error)
+	ListByOrderItemID(ctx context.Context, orderItemID int64) ([]*model.OrderItemHoldDTO, error)
+	ListActive(ctx context.Context, deptID int) ([]*model.OrderItemHoldDTO, error)
+	DueAlerts(ctx context.Context, heldBefore time.Time) ([]*HoldAlert, error)
+	MarkAlerted(ctx context.Context, holdID int64, at time.Time) (bool, error)
+}
+
+type orderItemHoldRepository struct {
+	db *generated.Client
+}
+
+func NewOrderItemHoldRepository(db *generated.Client) OrderItemHoldRepository {
+	return &orderItemHoldRepository{db: db}
+}
+
+func (r *orderItemHoldRepository) Hold(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemHoldInputDTO) (*model.OrderItemHoldDTO, error) {
+	if input.Reason == "" {
+		return nil, ErrInvalidHoldReason
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	ctx = WithStatusActor(ctx, userID)
+
+	item, err := r.orderItem(ctx, tx, deptID, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	from := itemStatus(item)
+	if from == statusOnHold {
+		err = ErrOrderItemOnHold
+		return nil, err
+	}
+	if err = checkStatusTransition(StatusEntityOrderItem, from, statusOnHold, nil, nil); err != nil {
+		return nil, err
+	}
+	if err = moveItemStatus(ctx, tx, item, from, statusOnHold); err != nil {
+		return nil, err
+	}
+
+	var heldBy *int
+	if userID > 0 {
+		heldBy = &userID
+	}
+	entity, err := tx.OrderItemHold.Create().
+		SetDepartmentID(deptID).
+		SetOrderID(orderID).
+		SetOrderItemID(orderItemID).
+		SetNillableOrderItemCode(item.Code).
+		SetFromStatus(from).
+		SetReason(input.Reason).
+		SetNillableNote(input.Note).
+		SetNillableExpectedResumeAt(input.ExpectedResumeAt).
+		SetNillableHeldBy(heldBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := mapOrderItemHold(entity, time.Now())
+	status := statusOnHold
+	out.Status = &status
+	return out, nil
+}
+
+func (r *orderItemHoldRepository) Resume(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemResumeDTO) (*model.OrderItemHoldDTO, *generated.OrderItem, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	ctx = WithStatusActor(ctx, userID)
+
+	item, err := r.orderItem(ctx, tx, deptID, orderID, orderItemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	hold, err := tx.OrderItemHold.Query().
+		Where(
+			orderitemhold.OrderItemIDEQ(orderItemID),
+			orderitemhold.ResumedAtIsNil(),
+		).
+		Order(generated.Desc(orderitemhold.FieldHeldAt)).
+		First(ctx)
+	if generated.IsNotFound(err) || (err == nil && itemStatus(item) != statusOnHold) {
+		err = ErrOrderItemNotOnHold
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processes, err := tx.OrderItemProcess.Query().
+		Where(orderitemprocess.OrderItemIDEQ(orderItemID)).
+		All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	to := hold.FromStatus
+	if len(processes) > 0 {
+		to = deriveItemStatus(processes)
+	}
+	if err = checkStatusTransition(StatusEntityOrderItem, statusOnHold, to, processes, nil); err != nil {
+		return nil, nil, err
+	}
+	if err = moveItemStatus(ctx, tx, item, statusOnHold, to); err != nil {
+		return nil, nil, err
+	}
+
+	var resumedBy *int
+	if userID > 0 {
+		resumedBy = &userID
+	}
+	var note *string
+	if input != nil {
+		note = input.Note
+	}
+	now := time.Now()
+	entity, err := tx.OrderItemHold.UpdateOneID(hold.ID).
+		SetResumedAt(now).
+		SetNillableResumedBy(resumedBy).
+		SetNillableResumeNote(note).
+		Save(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := mapOrderItemHold(entity, now)
+	out.Status = &to
+	return out, item, nil
+}
+
+func (r *orderItemHoldRepository) ListByOrderItemID(ctx context.Context, orderItemID int64) ([]*model.OrderItemHoldDTO, error) {
+	list, err := r.db.OrderItemHold.Query().
+		Where(orderitemhold.OrderItemIDEQ(orderItemID)).
+		Order(generated.Asc(orderitemhold.FieldHeldAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderItemHolds(list), nil
+}
+
+func (r *orderItemHoldRepository) ListActive(ctx context.Context, deptID int) ([]*model.OrderItemHoldDTO, error) {
+	list, err := r.db.OrderItemHold.Query().
+		Where(
+			orderitemhold.DepartmentIDEQ(deptID),
+			orderitemhold.ResumedAtIsNil(),
+		).
+		Order(generated.Asc(orderitemhold.FieldHeldAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderItemHolds(list), nil
+}
+
+// DueAlerts lists open, not yet announced holds started before heldBefore
+// whose clinic has an account owner.
+func (r *orderItemHoldRepository) DueAlerts(ctx context.Context, heldBefore time.Time) ([]*HoldAlert, error) {
+	holds, err := r.db.OrderItemHold.Query().
+		Where(
+			orderitemhold.ResumedAtIsNil(),
+			orderitemhold.AlertedAtIsNil(),
+			orderitemhold.HeldAtLT(heldBefore),
+		).
+		Order(generated.Asc(orderitemhold.FieldHeldAt)).
+		All(ctx)
+	if err != nil || len(holds) == 0 {
+		return nil, err
+	}
+
+	orderIDs := make([]int64, 0, len(holds))
+	for _, h := range holds {
+		orderIDs = append(orderIDs, h.OrderID)
+	}
+	orders, err := r.db.Order.Query().
+		Where(order.IDIn(orderIDs...)).
+		Select(order.FieldID, order.FieldCode, order.FieldClinicID, order.FieldClinicName).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byOrder := make(map[int64]*generated.Order, len(orders))
+	clinicIDs := make([]int, 0, len(orders))
+	for _, o := range orders {
+		byOrder[o.ID] = o
+		if o.ClinicID != nil {
+			clinicIDs = append(clinicIDs, *o.ClinicID)
+		}
+	}
+	clinics, err := r.db.Clinic.Query().
+		Where(
+			clinic.IDIn(clinicIDs...),
+			clinic.AccountOwnerIDNotNil(),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byClinic := make(map[int]*generated.Clinic, len(clinics))
+	for _, c := range clinics {
+		byClinic[c.ID] = c
+	}
+
+	out := make([]*HoldAlert, 0, len(holds))
+	for _, h := range holds {
+		o := byOrder[h.OrderID]
+		if o == nil || o.ClinicID == nil {
+			continue
+		}
+		c := byClinic[*o.ClinicID]
+		if c == nil {
+			continue
+		}
+		out = append(out, &HoldAlert{
+			Hold:             h,
+			OrderCode:        o.Code,
+			ClinicID:         o.ClinicID,
+			ClinicName:       o.ClinicName,
+			AccountOwnerID:   *c.AccountOwnerID,
+			AccountOwnerName: c.AccountOwnerName,
+		})
+	}
+	return out, nil
+}
+
+// MarkAlerted claims the alert of a hold; false when another run got it first.
+func (r *orderItemHoldRepository) MarkAlerted(ctx context.Context, holdID int64, at time.Time) (bool, error) {
+	n, err := r.db.OrderItemHold.Update().
+		Where(
+			orderitemhold.IDEQ(holdID),
+			orderitemhold.AlertedAtIsNil(),
+		).
+		SetAlertedAt(at).
+		Save(ctx)
+	return n > 0, err
+}
+
+func (r *orderItemHoldRepository) orderItem(ctx context.Context, tx *generated.Tx, deptID int, orderID, orderItemID int64) (*generated.OrderItem, error) {
+	return tx.OrderItem.Query().
+		Where(
+			orderitem.IDEQ(orderItemID),
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+			orderitem.HasOrderWith(
+				order.DepartmentIDEQ(deptID),
+				order.DeletedAtIsNil(),
+			),
+		).
+		Only(ctx)
+}
+
+// moveItemStatus stores a hand-made item move and, when the item is the
+// latest of its order, carries it to the order as well.
+func moveItemStatus(ctx context.Context, tx *generated.Tx, item *generated.OrderItem, from, to string) error {
+	orderID := item.OrderID
+
+	cf := maps.Clone(item.CustomFields)
+	if cf == nil {
+		cf = make(map[string]any)
+	}
+	cf["status"] = to
+	if err := tx.OrderItem.UpdateOneID(item.ID).
+		SetCustomFields(cf).
+		SetStatus(to).
+		Exec(ctx); err != nil {
+		return err
+	}
+	if err := recordStatusChange(ctx, tx, StatusEntityOrderItem, item.ID, &orderID, &item.ID, &from, to); err != nil {
+		return err
+	}
+
+	latest, err := tx.OrderItem.Query().
+		Where(
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt), generated.Desc(orderitem.FieldID)).
+		First(ctx)
+	if err != nil {
+		return err
+	}
+	if latest.ID != item.ID {
+		return nil
+	}
+
+	ord, err := tx.Order.Get(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Order.UpdateOneID(orderID).
+		SetStatusLatest(to).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return recordStatusChange(ctx, tx, StatusEntityOrder, orderID, &orderID, &item.ID, ord.StatusLatest, to)
+}
+
+func mapOrderItemHolds(list []*generated.OrderItemHold) []*model.OrderItemHoldDTO {
+	now := time.Now()
+	out := make([]*model.OrderItemHoldDTO, 0, len(list))
+	for _, e := range list {
+		out = append(out, mapOrderItemHold(e, now))
+	}
+	return out
+}
+
+func mapOrderItemHold(e *generated.OrderItemHold, now time.Time) *model.OrderItemHoldDTO {
+	end := now
+	if e.ResumedAt != nil {
+		end = *e.ResumedAt
+	}
+	return &model.OrderItemHoldDTO{
+		ID:               e.ID,
+		DepartmentID:     e.DepartmentID,
+		OrderID:          e.OrderID,
+		OrderItemID:      e.OrderItemID,
+		OrderItemCode:    e.OrderItemCode,
+		FromStatus:       e.FromStatus,
+		Reason:           e.Reason,
+		Note:             e.Note,
+		ExpectedResumeAt: e.ExpectedResumeAt,
+		HeldAt:           e.HeldAt,
+		HeldBy:           e.HeldBy,
+		ResumedAt:        e.ResumedAt,
+		ResumedBy:        e.ResumedBy,
+		ResumeNote:       e.ResumeNote,
+		AlertedAt:        e.AlertedAt,
+		HeldHours:        math.Round(end.Sub(e.HeldAt).Hours()*10) / 10,
+	}
+}
@@ -0,0 +1,660 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemlineage"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemmaterial"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocessinprogress"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemproduct"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+// Split and merge move product and material rows between order items.
+//
+// A split creates a child item of the same order, coded like a remake, that
+// takes the chosen rows; it starts as "received" with processes built from
+// its products. The order keeps following its original item and remakes, so
+// split-off items sort after them wherever the latest item is looked up. A merge moves every row of one item into another item of the
+// same clinic and patient and removes the emptied item, together with its
+// order when nothing is left in it. The process rows of an item that lost or
+// gained rows are rebuilt from its products unless its production already
+// started, in which case the moved rows follow the existing routing. Prices
+// are synced within the move and each move is kept in order_item_lineages.
+
+const (
+	LineageSplit = "split"
+	LineageMerge = "merge"
+)
+
+var (
+	ErrInvalidSplit   = errors.New("choose some, but not all, product rows of the order item to split")
+	ErrRowNotInItem   = errors.New("product or material row does not belong to the order item")
+	ErrInvalidMerge   = errors.New("items to merge must be two different items of the same clinic and patient")
+	ErrItemNotMovable = errors.New("order item is cancelled, on hold, has a running step or has remakes")
+)
+
+// splitOffLast orders split-off items after the items of the remake chain;
+// followed by the creation time it picks the item an order follows.
+func splitOffLast(s *sql.Selector) {
+	s.OrderExprFunc(func(b *sql.Builder) {
+		b.WriteString("EXISTS (SELECT 1 FROM ").
+			Ident(orderitemlineage.Table).
+			WriteString(" WHERE ").
+			Ident(orderitemlineage.FieldTargetOrderItemID).
+			WriteString(" = ").
+			WriteString(s.C(orderitem.FieldID)).
+			WriteString(" AND ").
+			Ident(orderitemlineage.FieldKind).
+			WriteString(" = '" + LineageSplit + "')")
+	})
+}
+
+func (r *orderRepository) Split(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemSplitDTO) (*model.OrderItemLineageDTO, error) {
+	if len(input.OrderItemProductIDs) == 0 {
+		return nil, ErrInvalidSplit
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	ctx = WithStatusActor(ctx, userID)
+
+	source, err := r.movableItem(ctx, tx, deptID, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := tx.OrderItemProduct.Query().
+		Where(orderitemproduct.OrderItemIDEQ(source.ID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	productIDs := dedupInt(input.OrderItemProductIDs)
+	for _, id := range productIDs {
+		if !slices.ContainsFunc(products, func(p *generated.OrderItemProduct) bool { return p.ID == id }) {
+			err = ErrRowNotInItem
+			return nil, err
+		}
+	}
+	if len(productIDs) >= len(products) {
+		err = ErrInvalidSplit
+		return nil, err
+	}
+	materialIDs := dedupInt(input.OrderItemMaterialIDs)
+	if len(materialIDs) > 0 {
+		n, e := tx.OrderItemMaterial.Query().
+			Where(
+				orderitemmaterial.IDIn(materialIDs...),
+				orderitemmaterial.OrderItemIDEQ(source.ID),
+			).
+			Count(ctx)
+		if e != nil {
+			err = e
+			return nil, err
+		}
+		if n != len(materialIDs) {
+			err = ErrRowNotInItem
+			return nil, err
+		}
+	}
+
+	// child item, coded like a remake of the order
+	code, err := r.nextItemCode(ctx, tx, source)
+	if err != nil {
+		return nil, err
+	}
+	cf := maps.Clone(source.CustomFields)
+	if cf == nil {
+		cf = make(map[string]any)
+	}
+	cf["status"] = "received"
+	child, err := tx.OrderItem.Create().
+		SetOrderID(source.OrderID).
+		SetParentItemID(source.ID).
+		SetCode(code).
+		SetNillableQrCode(utils.GenerateQRCodeString(&code)).
+		SetNillableCodeOriginal(source.CodeOriginal).
+		SetNillableCodeParent(source.Code).
+		SetRemakeCount(source.RemakeCount).
+		SetCustomFields(cf).
+		SetStatus("received").
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordStatusChange(ctx, tx, StatusEntityOrderItem, child.ID, &child.OrderID, &child.ID, nil, "received"); err != nil {
+		return nil, err
+	}
+
+	if err = r.moveRows(ctx, tx, source, child, productIDs, materialIDs); err != nil {
+		return nil, err
+	}
+	if err = r.refreshPrimaryProduct(ctx, tx, source); err != nil {
+		return nil, err
+	}
+	if err = r.refreshPrimaryProduct(ctx, tx, child); err != nil {
+		return nil, err
+	}
+
+	sourceRebuilt, err := r.rebuildProcesses(ctx, tx, source)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.rebuildProcesses(ctx, tx, child); err != nil {
+		return nil, err
+	}
+
+	// the order keeps following the source's chain, so its latest fields stay
+	out, err := r.createLineage(ctx, tx, deptID, userID, LineageSplit, source, child, productIDs, materialIDs, input.Note)
+	if err != nil {
+		return nil, err
+	}
+	out.SourceProcessesRebuilt = sourceRebuilt
+	out.TargetProcessesRebuilt = true
+
+	if err = r.syncLineagePrices(ctx, tx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *orderRepository) Merge(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemMergeDTO) (*model.OrderItemLineageDTO, error) {
+	if input.SourceOrderItemID <= 0 || input.SourceOrderItemID == orderItemID {
+		return nil, ErrInvalidMerge
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	ctx = WithStatusActor(ctx, userID)
+
+	target, err := r.movableItem(ctx, tx, deptID, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	srcItem, err := tx.OrderItem.Get(ctx, input.SourceOrderItemID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := r.movableItem(ctx, tx, deptID, srcItem.OrderID, srcItem.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// same clinic and patient
+	targetOrder, err := tx.Order.Get(ctx, target.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	sourceOrder, err := tx.Order.Get(ctx, source.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if targetOrder.ClinicID == nil || sourceOrder.ClinicID == nil || *targetOrder.ClinicID != *sourceOrder.ClinicID ||
+		targetOrder.PatientID == nil || sourceOrder.PatientID == nil || *targetOrder.PatientID != *sourceOrder.PatientID {
+		err = ErrInvalidMerge
+		return nil, err
+	}
+
+	// a removed item must not leave children behind
+	hasChildren, err := tx.OrderItem.Query().
+		Where(
+			orderitem.ParentItemIDEQ(source.ID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hasChildren {
+		err = ErrItemNotMovable
+		return nil, err
+	}
+
+	productIDs, err := tx.OrderItemProduct.Query().
+		Where(orderitemproduct.OrderItemIDEQ(source.ID)).
+		IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	materialIDs, err := tx.OrderItemMaterial.Query().
+		Where(orderitemmaterial.OrderItemIDEQ(source.ID)).
+		IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.moveRows(ctx, tx, source, target, productIDs, materialIDs); err != nil {
+		return nil, err
+	}
+	if err = r.refreshPrimaryProduct(ctx, tx, target); err != nil {
+		return nil, err
+	}
+	targetRebuilt, err := r.rebuildProcesses(ctx, tx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// the emptied source item goes away like a deleted one
+	if err = tx.OrderItem.UpdateOneID(source.ID).
+		SetDeletedAt(time.Now()).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+	sourceOrderRemoved, err := r.refreshOrderLatest(ctx, tx, source.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if source.OrderID != target.OrderID {
+		if _, err = r.refreshOrderLatest(ctx, tx, target.OrderID); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := r.createLineage(ctx, tx, deptID, userID, LineageMerge, source, target, productIDs, materialIDs, input.Note)
+	if err != nil {
+		return nil, err
+	}
+	out.TargetProcessesRebuilt = targetRebuilt
+	out.SourceOrderRemoved = sourceOrderRemoved
+
+	if err = r.syncLineagePrices(ctx, tx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// syncLineagePrices recomputes, within the move, the totals of the items and
+// orders a split or merge touched, as SyncPrice does. Orders keep their
+// shipping fee and promotion.
+func (r *orderRepository) syncLineagePrices(ctx context.Context, tx *generated.Tx, lineage *model.OrderItemLineageDTO) error {
+	itemIDs := []int64{lineage.SourceOrderItemID, lineage.TargetOrderItemID}
+	if lineage.Kind == LineageMerge {
+		itemIDs = itemIDs[1:]
+	}
+	for _, id := range itemIDs {
+		total, err := r.orderItemRepo.GetTotalPriceByOrderItemID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.OrderItem.UpdateOneID(id).
+			SetTotalPrice(total).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	orderIDs := []int64{lineage.TargetOrderID}
+	if lineage.SourceOrderID != lineage.TargetOrderID {
+		orderIDs = append(orderIDs, lineage.SourceOrderID)
+	}
+	for _, id := range orderIDs {
+		if id == lineage.SourceOrderID && lineage.SourceOrderRemoved {
+			continue
+		}
+		dto, err := r.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := r.syncOrderTotal(ctx, tx, dto, orderShippingFee(dto)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *orderRepository) Lineage(ctx context.Context, orderItemID int64) ([]*model.OrderItemLineageDTO, error) {
+	list, err := r.db.OrderItemLineage.Query().
+		Where(
+			orderitemlineage.Or(
+				orderitemlineage.SourceOrderItemIDEQ(orderItemID),
+				orderitemlineage.TargetOrderItemIDEQ(orderItemID),
+			),
+		).
+		Order(generated.Asc(orderitemlineage.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*model.OrderItemLineageDTO, 0, len(list))
+	for _, e := range list {
+		out = append(out, mapOrderItemLineage(e))
+	}
+	return out, nil
+}
+
+// movableItem loads an order item of the department that may give or take
+// rows: not cancelled or on hold, no step running and not invoiced.
+func (r *orderRepository) movableItem(ctx context.Context, tx *generated.Tx, deptID int, orderID, orderItemID int64) (*generated.OrderItem, error) {
+	item, err := tx.OrderItem.Query().
+		Where(
+			orderitem.IDEQ(orderItemID),
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+			orderitem.HasOrderWith(
+				order.DepartmentIDEQ(deptID),
+				order.DeletedAtIsNil(),
+			),
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if st := itemStatus(item); st == statusCancelled || st == statusOnHold {
+		return nil, ErrItemNotMovable
+	}
+
+	running, err := tx.OrderItemProcessInProgress.Query().
+		Where(
+			orderitemprocessinprogress.OrderItemIDEQ(item.ID),
+			orderitemprocessinprogress.StartedAtNotNil(),
+			orderitemprocessinprogress.CompletedAtIsNil(),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		return nil, ErrItemNotMovable
+	}
+
+	if err := r.ensureNotInvoiced(ctx, tx, item.OrderID); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// nextItemCode gives the next free remake style code of the order.
+func (r *orderRepository) nextItemCode(ctx context.Context, tx *generated.Tx, item *generated.OrderItem) (string, error) {
+	base := ""
+	if item.CodeOriginal != nil {
+		base = *item.CodeOriginal
+	} else if item.Code != nil {
+		base = *item.Code
+	}
+
+	seq, err := tx.OrderItem.Query().
+		Where(orderitem.OrderIDEQ(item.OrderID)).
+		Count(ctx)
+	if err != nil {
+		return "", err
+	}
+	for seq++; ; seq++ {
+		code := fmt.Sprintf("%s%s", utils.AlphabetSeq(seq), base)
+		taken, err := tx.OrderItem.Query().
+			Where(
+				orderitem.CodeEQ(code),
+				orderitem.DeletedAtIsNil(),
+			).
+			Exist(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+}
+
+// moveRows hands product and material rows over to another item, keeping the
+// item they first belonged to.
+func (r *orderRepository) moveRows(ctx context.Context, tx *generated.Tx, from, to *generated.OrderItem, productIDs, materialIDs []int) error {
+	if len(productIDs) > 0 {
+		if err := tx.OrderItemProduct.Update().
+			Where(
+				orderitemproduct.IDIn(productIDs...),
+				orderitemproduct.OriginalOrderItemIDIsNil(),
+			).
+			SetOriginalOrderItemID(from.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		if err := tx.OrderItemProduct.Update().
+			Where(orderitemproduct.IDIn(productIDs...)).
+			SetOrderItemID(to.ID).
+			SetOrderID(to.OrderID).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	if len(materialIDs) > 0 {
+		if err := tx.OrderItemMaterial.Update().
+			Where(
+				orderitemmaterial.IDIn(materialIDs...),
+				orderitemmaterial.OriginalOrderItemIDIsNil(),
+			).
+			SetOriginalOrderItemID(from.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		if err := tx.OrderItemMaterial.Update().
+			Where(orderitemmaterial.IDIn(materialIDs...)).
+			SetOrderItemID(to.ID).
+			SetOrderID(to.OrderID).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshPrimaryProduct points the item at the first of its products.
+func (r *orderRepository) refreshPrimaryProduct(ctx context.Context, tx *generated.Tx, item *generated.OrderItem) error {
+	first, err := tx.OrderItemProduct.Query().
+		Where(orderitemproduct.OrderItemIDEQ(item.ID)).
+		Order(generated.Asc(orderitemproduct.FieldID)).
+		First(ctx)
+	if generated.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if first.ProductID == item.ProductID && item.ProductName != nil {
+		return nil
+	}
+
+	prd, err := tx.Product.Query().
+		Where(product.IDEQ(first.ProductID)).
+		Only(ctx)
+	if err != nil && !generated.IsNotFound(err) {
+		return err
+	}
+	q := tx.OrderItem.UpdateOneID(item.ID).
+		SetProductID(first.ProductID)
+	if prd != nil {
+		q.SetNillableProductName(prd.Name)
+	}
+	return q.Exec(ctx)
+}
+
+// rebuildProcesses recreates the process rows of an item from its products
+// while none of its steps has started. Reports whether it did.
+func (r *orderRepository) rebuildProcesses(ctx context.Context, tx *generated.Tx, item *generated.OrderItem) (bool, error) {
+	started, err := tx.OrderItemProcessInProgress.Query().
+		Where(orderitemprocessinprogress.OrderItemIDEQ(item.ID)).
+		Exist(ctx)
+	if err != nil || started {
+		return false, err
+	}
+	processes, err := tx.OrderItemProcess.Query().
+		Where(orderitemprocess.OrderItemIDEQ(item.ID)).
+		All(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range processes {
+		if s := processStatus(p); s != "" && s != "waiting" && s != "skipped" {
+			return false, nil
+		}
+	}
+
+	if _, err := tx.OrderItemProcess.Delete().
+		Where(orderitemprocess.OrderItemIDEQ(item.ID)).
+		Exec(ctx); err != nil {
+		return false, err
+	}
+
+	rows, err := tx.OrderItemProduct.Query().
+		Where(orderitemproduct.OrderItemIDEQ(item.ID)).
+		Order(generated.Asc(orderitemproduct.FieldID)).
+		All(ctx)
+	if err != nil {
+		return false, err
+	}
+	productIDs := make([]int, 0, len(rows))
+	for _, p := range rows {
+		if p.ProductID != 0 && !slices.Contains(productIDs, p.ProductID) {
+			productIDs = append(productIDs, p.ProductID)
+		}
+	}
+
+	priority := utils.SafeGetString(item.CustomFields, "priority")
+	if _, err := r.orderItemProcessRepo.CreateManyByProductIDs(ctx, tx, item.ID, item.OrderID, item.Code, &priority, productIDs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refreshOrderLatest re-points the cached latest fields of an order at its
+// latest live item, or removes the order once it has none left and reports so.
+func (r *orderRepository) refreshOrderLatest(ctx context.Context, tx *generated.Tx, orderID int64) (bool, error) {
+	latest, err := tx.OrderItem.Query().
+		Where(
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt), generated.Desc(orderitem.FieldID)).
+		First(ctx)
+	if generated.IsNotFound(err) {
+		return true, tx.Order.UpdateOneID(orderID).
+			SetDeletedAt(time.Now()).
+			Exec(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ord, err := tx.Order.Get(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	lstStatus := itemStatus(latest)
+	if ord.StatusLatest != nil && *ord.StatusLatest != "" {
+		if err := checkStatusTransition(StatusEntityOrder, *ord.StatusLatest, lstStatus, nil, nil); err != nil {
+			return false, err
+		}
+	}
+	rmkCount := latest.RemakeCount
+	if err := tx.Order.UpdateOneID(orderID).
+		SetNillableCodeLatest(latest.Code).
+		SetStatusLatest(lstStatus).
+		SetNillablePriorityLatest(utils.SafeGetStringPtr(latest.CustomFields, "priority")).
+		SetNillableDeliveryDate(utils.SafeGetDateTimePtr(latest.CustomFields, "delivery_date")).
+		SetNillableRemakeType(utils.SafeGetStringPtr(latest.CustomFields, "remake_type")).
+		SetNillableRemakeCount(&rmkCount).
+		Exec(ctx); err != nil {
+		return false, err
+	}
+	return false, recordStatusChange(ctx, tx, StatusEntityOrder, orderID, &orderID, &latest.ID, ord.StatusLatest, lstStatus)
+}
+
+func (r *orderRepository) createLineage(
+	ctx context.Context,
+	tx *generated.Tx,
+	deptID, userID int,
+	kind string,
+	source, target *generated.OrderItem,
+	productIDs, materialIDs []int,
+	note *string,
+) (*model.OrderItemLineageDTO, error) {
+	var createdBy *int
+	if userID > 0 {
+		createdBy = &userID
+	}
+	entity, err := tx.OrderItemLineage.Create().
+		SetDepartmentID(deptID).
+		SetKind(kind).
+		SetSourceOrderID(source.OrderID).
+		SetSourceOrderItemID(source.ID).
+		SetNillableSourceCode(source.Code).
+		SetTargetOrderID(target.OrderID).
+		SetTargetOrderItemID(target.ID).
+		SetNillableTargetCode(target.Code).
+		SetOrderItemProductIds(productIDs).
+		SetOrderItemMaterialIds(materialIDs).
+		SetNillableNote(note).
+		SetNillableCreatedBy(createdBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderItemLineage(entity), nil
+}
+
+func mapOrderItemLineage(e *generated.OrderItemLineage) *model.OrderItemLineageDTO {
+	return &model.OrderItemLineageDTO{
+		ID:                   e.ID,
+		DepartmentID:         e.DepartmentID,
+		Kind:                 e.Kind,
+		SourceOrderID:        e.SourceOrderID,
+		SourceOrderItemID:    e.SourceOrderItemID,
+		SourceCode:           e.SourceCode,
+		TargetOrderID:        e.TargetOrderID,
+		TargetOrderItemID:    e.TargetOrderItemID,
+		TargetCode:           e.TargetCode,
+		OrderItemProductIDs:  e.OrderItemProductIds,
+		OrderItemMaterialIDs: e.OrderItemMaterialIds,
+		Note:                 e.Note,
+		CreatedBy:            e.CreatedBy,
+		CreatedAt:            e.CreatedAt,
+	}
+}
+
+func dedupInt(ids []int) []int {
+	out := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -75,8 +75,14 @@ func (r *orderItemMaterialRepository) CalculateConsumableTotalPrice(consumableMa
 	return &total
 }
 
-func (r *orderItemMaterialRepository) GetConsumableTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error) {
-	materials, err := r.db.OrderItemMaterial.
+func (r *orderItemMaterialRepository) GetConsumableTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error) {
+	var c *generated.OrderItemMaterialClient
+	if tx != nil {
+		c = tx.OrderItemMaterial
+	} else {
+		c = r.db.OrderItemMaterial
+	}
+	materials, err := c.
 		Query().
 		Where(
 			orderitemmaterial.OrderItemIDEQ(orderItemID),
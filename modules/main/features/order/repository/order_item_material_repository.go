@@ -13,7 +13,7 @@ type OrderItemMaterialRepository interface {
 	// Consumable
 	PrepareConsumableMaterials(dto *model.OrderItemDTO) []*model.OrderItemMaterialDTO
 	CalculateConsumableTotalPrice(materials []*model.OrderItemMaterialDTO) *float64
-	GetConsumableTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error)
+	GetConsumableTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error)
 	GetConsumableTotalPriceByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (float64, error)
 	PrepareConsumableForRemake(
 		ctx context.Context,
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"entgo.io/ent/dialect/sql"
@@ -23,6 +25,7 @@ import (
 type OrderItemProcessInProgressRepository interface {
 	PrepareCheckInOrOut(ctx context.Context, tx *generated.Tx, orderItemID int64, orderID *int64) (*model.OrderItemProcessInProgressDTO, error)
 	PrepareCheckInOrOutByCode(ctx context.Context, code string) (*model.OrderItemProcessInProgressDTO, error)
+	CheckInOrOutOptions(ctx context.Context, orderItemID int64, orderID *int64) (*model.OrderItemProcessOptionsDTO, error)
 	CheckInOrOut(ctx context.Context, checkInOrOutData *model.OrderItemProcessInProgressDTO) (*model.OrderItemProcessInProgressDTO, *string, *string, *generated.OrderItem, error)
 	Assign(ctx context.Context, inprogressID int64, assignedID *int64, assignedName *string, note *string) (*model.OrderItemProcessInProgressDTO, *string, *string, *generated.OrderItem, error)
 	CheckIn(ctx context.Context, tx *generated.Tx, orderItemID int64, orderID *int64, note *string) (*model.OrderItemProcessInProgressDTO, error)
@@ -34,8 +37,18 @@ type OrderItemProcessInProgressRepository interface {
 	GetInProgressByID(ctx context.Context, tx *generated.Tx, inProgressID int64) (*model.OrderItemProcessInProgressAndProcessDTO, error)
 	GetInProgressesByAssignedID(ctx context.Context, tx *generated.Tx, assignedID int64, query table.TableQuery) (table.TableListResult[model.OrderItemProcessInProgressAndProcessDTO], error)
 	ProcessInfoByProcessID(ctx context.Context, tx *generated.Tx, processID *int64) (*int, *string, *string, *string, error)
+	UndoLastTransition(ctx context.Context, deptID int, orderID, orderItemID int64, window time.Duration) (*model.OrderItemProcessUndoDTO, *generated.OrderItem, error)
 }
 
+var (
+	ErrNothingToUndo     = errors.New("order item has no transition to undo")
+	ErrUndoWindowExpired = errors.New("last transition is too old to undo")
+)
+
+// handOverNotePrefix starts the check-out note Assign leaves on the row it
+// hands over; the row stays open next to the new assignee's row.
+const handOverNotePrefix = "➡"
+
 type orderItemProcessInProgressRepository struct {
 	db                   *generated.Client
 	orderItemProcessRepo OrderItemProcessRepository
@@ -86,6 +99,7 @@ func (r *orderItemProcessInProgressRepository) GetInProgressesByProcessID(ctx co
 			AssignedName: item.AssignedName,
 			StartedAt:    item.StartedAt,
 			CompletedAt:  item.CompletedAt,
+			SLAStatus:    item.SLAStatus,
 			ProcessName:  proc.ProcessName,
 			SectionName:  proc.SectionName,
 			SectionID:    proc.SectionID,
@@ -143,6 +157,7 @@ func (r *orderItemProcessInProgressRepository) GetInProgressesByOrderItemID(ctx
 			AssignedName:  item.AssignedName,
 			StartedAt:     item.StartedAt,
 			CompletedAt:   item.CompletedAt,
+			SLAStatus:     item.SLAStatus,
 			ProcessName:   proc.ProcessName,
 			SectionName:   proc.SectionName,
 			SectionID:     proc.SectionID,
@@ -183,6 +198,7 @@ func (r *orderItemProcessInProgressRepository) GetInProgressByID(ctx context.Con
 		AssignedName: entity.AssignedName,
 		StartedAt:    entity.StartedAt,
 		CompletedAt:  entity.CompletedAt,
+		SLAStatus:    entity.SLAStatus,
 		ProcessName:  proc.ProcessName,
 		SectionName:  proc.SectionName,
 		SectionID:    proc.SectionID,
@@ -259,48 +275,39 @@ func (r *orderItemProcessInProgressRepository) PrepareCheckInOrOut(ctx context.C
 	if err != nil && !generated.IsNotFound(err) {
 		return nil, err
 	}
+	err = nil
+
+	orderItemEntity, err := tx.OrderItem.
+		Query().
+		Where(orderitem.IDEQ(orderItemID)).
+		Select(orderitem.FieldCode).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orderItemCode := orderItemEntity.Code
+
+	graph := isRoutedByGraph(processes)
 
 	// Checkout
-	if latest != nil && latest.CompletedAt == nil {
-		currentProcessID := processes[0].ID
-		if latest.ProcessID != nil {
-			currentProcessID = *latest.ProcessID
-		}
-		nextProcessID := r.nextProcessID(processes, currentProcessID)
-		targetProcess := r.findProcess(processes, currentProcessID)
-		if targetProcess == nil {
-			err = fmt.Errorf("process %d not found for order item %d", currentProcessID, latest.OrderItemID)
+	running := latest
+	if graph {
+		// parallel branches may leave an older step running
+		open, err := r.openEntities(ctx, tx, orderItemID, processes)
+		if err != nil {
 			return nil, err
 		}
-
-		orderItemEntity, err := tx.OrderItem.
-			Query().
-			Where(orderitem.IDEQ(orderItemID)).
-			Select(orderitem.FieldCode).
-			Only(ctx)
+		running = nil
+		if len(open) > 0 {
+			running = open[0]
+		}
+	}
+	if running != nil && running.CompletedAt == nil {
+		dto, err := r.checkoutOption(processes, running, orderItemCode)
 		if err != nil {
 			return nil, err
 		}
-		orderItemCode := orderItemEntity.Code
-
-		return &model.OrderItemProcessInProgressDTO{
-			ID:            latest.ID,
-			ProcessID:     &currentProcessID,
-			PrevProcessID: latest.PrevProcessID,
-			NextProcessID: nextProcessID,
-			OrderItemID:   latest.OrderItemID,
-			OrderID:       r.pickOrderID(latest.OrderID, targetProcess),
-			OrderItemCode: orderItemCode,
-			AssignedID:    targetProcess.AssignedID,
-			AssignedName:  targetProcess.AssignedName,
-			SectionName:   targetProcess.SectionName,
-			SectionID:     targetProcess.SectionID,
-			CheckInNote:   latest.CheckInNote,
-			CheckOutNote:  latest.CheckOutNote,
-			StartedAt:     latest.StartedAt,
-			CompletedAt:   latest.CompletedAt,
-			UpdatedAt:     latest.UpdatedAt,
-		}, nil
+		return dto, nil
 	}
 
 	// Checkin
@@ -318,13 +325,57 @@ func (r *orderItemProcessInProgressRepository) PrepareCheckInOrOut(ctx context.C
 		}
 	}
 
-	targetProcess := r.findProcess(processes, targetProcessID)
-	if targetProcess == nil {
-		err = fmt.Errorf("process %d not found for order item %d", targetProcessID, orderItemID)
+	if graph {
+		startable := startableProcesses(processes)
+		if len(startable) > 0 {
+			target := startable[0]
+			if latest != nil && latest.NextProcessID != nil {
+				if p := r.findProcess(startable, *latest.NextProcessID); p != nil {
+					target = p
+				}
+			}
+			targetProcessID = target.ID
+			prevProcessID = r.graphPrevProcessID(processes, target, latest)
+		}
+	}
+
+	dto, err := r.checkinOption(processes, targetProcessID, prevProcessID, orderItemID, orderID, orderItemCode)
+	if err != nil {
 		return nil, err
 	}
+	return dto, nil
+}
 
-	orderItemEntity, err := tx.OrderItem.
+// CheckInOrOutOptions lists every transition possible for the order item.
+// Linearly routed items only ever have the one PrepareCheckInOrOut returns.
+func (r *orderItemProcessInProgressRepository) CheckInOrOutOptions(ctx context.Context, orderItemID int64, orderID *int64) (*model.OrderItemProcessOptionsDTO, error) {
+	out := &model.OrderItemProcessOptionsDTO{
+		CheckOut: []*model.OrderItemProcessInProgressDTO{},
+		CheckIn:  []*model.OrderItemProcessInProgressDTO{},
+	}
+
+	processes, err := r.getProcesses(ctx, nil, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("no processes found for order item %d", orderItemID)
+	}
+
+	if !isRoutedByGraph(processes) {
+		dto, err := r.PrepareCheckInOrOut(ctx, nil, orderItemID, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if dto.ID > 0 {
+			out.CheckOut = append(out.CheckOut, dto)
+		} else {
+			out.CheckIn = append(out.CheckIn, dto)
+		}
+		return out, nil
+	}
+
+	orderItemEntity, err := r.db.OrderItem.
 		Query().
 		Where(orderitem.IDEQ(orderItemID)).
 		Select(orderitem.FieldCode).
@@ -334,6 +385,81 @@ func (r *orderItemProcessInProgressRepository) PrepareCheckInOrOut(ctx context.C
 	}
 	orderItemCode := orderItemEntity.Code
 
+	open, err := r.openEntities(ctx, nil, orderItemID, processes)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range open {
+		dto, err := r.checkoutOption(processes, row, orderItemCode)
+		if err != nil {
+			return nil, err
+		}
+		out.CheckOut = append(out.CheckOut, dto)
+	}
+
+	latest, err := r.latestEntity(ctx, nil, orderItemID)
+	if err != nil && !generated.IsNotFound(err) {
+		return nil, err
+	}
+	for _, p := range startableProcesses(processes) {
+		dto, err := r.checkinOption(processes, p.ID, r.graphPrevProcessID(processes, p, latest), orderItemID, orderID, orderItemCode)
+		if err != nil {
+			return nil, err
+		}
+		out.CheckIn = append(out.CheckIn, dto)
+	}
+
+	return out, nil
+}
+
+func (r *orderItemProcessInProgressRepository) checkoutOption(
+	processes []*generated.OrderItemProcess,
+	running *generated.OrderItemProcessInProgress,
+	orderItemCode *string,
+) (*model.OrderItemProcessInProgressDTO, error) {
+	currentProcessID := processes[0].ID
+	if running.ProcessID != nil {
+		currentProcessID = *running.ProcessID
+	}
+	nextProcessID := r.nextProcessID(processes, currentProcessID)
+	targetProcess := r.findProcess(processes, currentProcessID)
+	if targetProcess == nil {
+		return nil, fmt.Errorf("process %d not found for order item %d", currentProcessID, running.OrderItemID)
+	}
+
+	return &model.OrderItemProcessInProgressDTO{
+		ID:            running.ID,
+		ProcessID:     &currentProcessID,
+		PrevProcessID: running.PrevProcessID,
+		NextProcessID: nextProcessID,
+		OrderItemID:   running.OrderItemID,
+		OrderID:       r.pickOrderID(running.OrderID, targetProcess),
+		OrderItemCode: orderItemCode,
+		AssignedID:    targetProcess.AssignedID,
+		AssignedName:  targetProcess.AssignedName,
+		SectionName:   targetProcess.SectionName,
+		SectionID:     targetProcess.SectionID,
+		CheckInNote:   running.CheckInNote,
+		CheckOutNote:  running.CheckOutNote,
+		StartedAt:     running.StartedAt,
+		CompletedAt:   running.CompletedAt,
+		UpdatedAt:     running.UpdatedAt,
+	}, nil
+}
+
+func (r *orderItemProcessInProgressRepository) checkinOption(
+	processes []*generated.OrderItemProcess,
+	targetProcessID int64,
+	prevProcessID *int64,
+	orderItemID int64,
+	orderID *int64,
+	orderItemCode *string,
+) (*model.OrderItemProcessInProgressDTO, error) {
+	targetProcess := r.findProcess(processes, targetProcessID)
+	if targetProcess == nil {
+		return nil, fmt.Errorf("process %d not found for order item %d", targetProcessID, orderItemID)
+	}
+
 	return &model.OrderItemProcessInProgressDTO{
 		ProcessID:     &targetProcessID,
 		PrevProcessID: prevProcessID,
@@ -347,6 +473,59 @@ func (r *orderItemProcessInProgressRepository) PrepareCheckInOrOut(ctx context.C
 	}, nil
 }
 
+// graphPrevProcessID links a check-in to the last checked-out step when that
+// step is one of its predecessors.
+func (r *orderItemProcessInProgressRepository) graphPrevProcessID(
+	processes []*generated.OrderItemProcess,
+	target *generated.OrderItemProcess,
+	latest *generated.OrderItemProcessInProgress,
+) *int64 {
+	if latest == nil || latest.ProcessID == nil || latest.CompletedAt == nil {
+		return nil
+	}
+	prev := r.findProcess(processes, *latest.ProcessID)
+	if prev == nil || prev.ProcessID == nil || !slices.Contains(target.DependsOn, *prev.ProcessID) {
+		return nil
+	}
+	return latest.ProcessID
+}
+
+// openEntities returns the running in-progress row of every active step,
+// newest first. Rows left open by a reassignment are ignored.
+func (r *orderItemProcessInProgressRepository) openEntities(
+	ctx context.Context,
+	tx *generated.Tx,
+	orderItemID int64,
+	processes []*generated.OrderItemProcess,
+) ([]*generated.OrderItemProcessInProgress, error) {
+	rows, err := r.inprogressClient(tx).
+		Query().
+		Where(
+			orderitemprocessinprogress.OrderItemID(orderItemID),
+			orderitemprocessinprogress.CompletedAtIsNil(),
+		).
+		Order(orderitemprocessinprogress.ByCreatedAt(sql.OrderDesc())).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	out := make([]*generated.OrderItemProcessInProgress, 0, len(rows))
+	for _, row := range rows {
+		if row.ProcessID == nil || seen[*row.ProcessID] {
+			continue
+		}
+		seen[*row.ProcessID] = true
+		p := r.findProcess(processes, *row.ProcessID)
+		if p == nil || !isStepActive(processStatus(p)) {
+			continue
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
 func (r *orderItemProcessInProgressRepository) Assign(
 	ctx context.Context,
 	inprogressID int64,
@@ -423,7 +602,7 @@ func (r *orderItemProcessInProgressRepository) Assign(
 
 	// Close current
 	// Then, assign it to the other one
-	checkoutNote := fmt.Sprintf("➡ Đã giao cho kỹ thuật viên %s", utils.SafeString(assignedName))
+	checkoutNote := fmt.Sprintf("%s Đã giao cho kỹ thuật viên %s", handOverNotePrefix, utils.SafeString(assignedName))
 
 	if _, err := r.inprogressClient(tx).
 		UpdateOneID(current.ID).
@@ -507,6 +686,21 @@ func (r *orderItemProcessInProgressRepository) CheckInOrOut(
 			return nil, nil, nil, nil, err
 		}
 
+		// a check-out completes the step, which must be a declared move
+		var processes []*generated.OrderItemProcess
+		processes, err = r.getProcesses(ctx, tx, checkInOrOutData.OrderItemID)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		target := r.findProcess(processes, *checkInOrOutData.ProcessID)
+		if target == nil {
+			err = fmt.Errorf("process %d not found for order item %d", *checkInOrOutData.ProcessID, checkInOrOutData.OrderItemID)
+			return nil, nil, nil, nil, err
+		}
+		if err = checkStatusTransition(StatusEntityProcess, processStatus(target), "completed", processes, target); err != nil {
+			return nil, nil, nil, nil, err
+		}
+
 		leaderID, leaderName, sectionName, processName, err := r.ProcessInfoByProcessID(ctx, tx, checkInOrOutData.NextProcessID)
 		if err != nil {
 			return nil, nil, nil, nil, err
@@ -553,6 +747,55 @@ func (r *orderItemProcessInProgressRepository) CheckInOrOut(
 		return nil, nil, nil, nil, err
 	}
 
+	// a held case takes no new work until it is resumed
+	held, err := tx.OrderItem.
+		Query().
+		Where(
+			orderitem.IDEQ(checkInOrOutData.OrderItemID),
+			orderitem.StatusEQ(statusOnHold),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if held {
+		err = ErrOrderItemOnHold
+		return nil, nil, nil, nil, err
+	}
+
+	// a cancelled case takes no work at all
+	cancelled, err := tx.OrderItem.
+		Query().
+		Where(
+			orderitem.IDEQ(checkInOrOutData.OrderItemID),
+			orderitem.StatusEQ(statusCancelled),
+		).
+		Exist(ctx)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if cancelled {
+		err = ErrOrderItemCancelled
+		return nil, nil, nil, nil, err
+	}
+
+	// graph routing: only unblocked steps can start
+	processes, err := r.getProcesses(ctx, tx, checkInOrOutData.OrderItemID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if isRoutedByGraph(processes) {
+		target := r.findProcess(processes, *checkInOrOutData.ProcessID)
+		if target == nil {
+			err = fmt.Errorf("process %d not found for order item %d", *checkInOrOutData.ProcessID, checkInOrOutData.OrderItemID)
+			return nil, nil, nil, nil, err
+		}
+		if processStatus(target) == "skipped" || !isUnblocked(processes, target) {
+			err = ErrProcessBlocked
+			return nil, nil, nil, nil, err
+		}
+	}
+
 	proc, err := r.processClient(tx).
 		Query().
 		Where(orderitemprocess.IDEQ(*checkInOrOutData.ProcessID)).
@@ -619,6 +862,278 @@ func (r *orderItemProcessInProgressRepository) CheckInOrOut(
 	return dto, &status, orderstatus, orderitem, nil
 }
 
+// UndoLastTransition reverts the most recent check-in, check-out or
+// reassignment of the order item, as long as it happened within window. A
+// check-out reopens the row, a check-in removes it and a reassignment hands
+// the step back to the previous assignee; process statuses and the cached
+// order fields are then re-synced as after a regular transition. When the
+// undone check-out had completed the item, the item as it was before is
+// returned as well.
+func (r *orderItemProcessInProgressRepository) UndoLastTransition(
+	ctx context.Context,
+	deptID int,
+	orderID,
+	orderItemID int64,
+	window time.Duration,
+) (*model.OrderItemProcessUndoDTO, *generated.OrderItem, error) {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	// the item must be the one of the route, in the caller's department
+	var item *generated.OrderItem
+	if item, err = tx.OrderItem.Query().
+		Where(
+			orderitem.IDEQ(orderItemID),
+			orderitem.OrderIDEQ(orderID),
+			orderitem.DeletedAtIsNil(),
+			orderitem.HasOrderWith(
+				order.DepartmentIDEQ(deptID),
+				order.DeletedAtIsNil(),
+			),
+		).
+		Only(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	latest, err := r.latestEntity(ctx, tx, orderItemID)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			err = ErrNothingToUndo
+		}
+		return nil, nil, err
+	}
+	if latest.ProcessID == nil {
+		err = ErrNothingToUndo
+		return nil, nil, err
+	}
+	processID := *latest.ProcessID
+
+	out := &model.OrderItemProcessUndoDTO{
+		InProgressID:  latest.ID,
+		OrderItemID:   latest.OrderItemID,
+		OrderID:       latest.OrderID,
+		OrderItemCode: latest.OrderItemCode,
+		ProcessID:     latest.ProcessID,
+	}
+
+	var (
+		status          string
+		latestProcessID *int64
+	)
+
+	if latest.CompletedAt != nil {
+		// --- undo checkout
+		out.Action = "check_out"
+		out.TransitionAt = *latest.CompletedAt
+		if time.Since(*latest.CompletedAt) > window {
+			err = ErrUndoWindowExpired
+			return nil, nil, err
+		}
+
+		var entity *generated.OrderItemProcessInProgress
+		entity, err = r.inprogressClient(tx).
+			UpdateOneID(latest.ID).
+			ClearCompletedAt().
+			ClearNextProcessID().
+			ClearNextProcessName().
+			ClearNextSectionID().
+			ClearNextSectionName().
+			ClearNextLeaderID().
+			ClearNextLeaderName().
+			ClearCheckOutNote().
+			Save(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Restored = mapper.MapAs[*generated.OrderItemProcessInProgress, *model.OrderItemProcessInProgressDTO](entity)
+
+		var rework bool
+		rework, err = r.hasCompletedProcess(ctx, tx, processID)
+		if err != nil {
+			return nil, nil, err
+		}
+		status = "in_progress"
+		if rework {
+			status = "rework"
+		}
+		latestProcessID = &processID
+	} else {
+		out.TransitionAt = latest.CreatedAt
+		if latest.StartedAt != nil {
+			out.TransitionAt = *latest.StartedAt
+		}
+		if time.Since(out.TransitionAt) > window {
+			err = ErrUndoWindowExpired
+			return nil, nil, err
+		}
+
+		var handedOver *generated.OrderItemProcessInProgress
+		handedOver, err = r.handedOverRow(ctx, tx, latest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if handedOver != nil {
+			// --- undo reassignment: the previous assignee takes the step back
+			out.Action = "assign"
+			if err = r.inprogressClient(tx).DeleteOneID(latest.ID).Exec(ctx); err != nil {
+				return nil, nil, err
+			}
+			var entity *generated.OrderItemProcessInProgress
+			entity, err = r.inprogressClient(tx).
+				UpdateOneID(handedOver.ID).
+				ClearCheckOutNote().
+				Save(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			out.Restored = mapper.MapAs[*generated.OrderItemProcessInProgress, *model.OrderItemProcessInProgressDTO](entity)
+
+			var proc *generated.OrderItemProcess
+			proc, err = r.processClient(tx).Get(ctx, processID)
+			if err != nil {
+				return nil, nil, err
+			}
+			status = processStatus(proc)
+			if status == "" {
+				status = proc.Status
+			}
+			if status == "" {
+				status = "in_progress"
+			}
+			if err = r.updateProcessStatusAndAssign(ctx, tx, processID, status, handedOver.AssignedID, handedOver.AssignedName); err != nil {
+				return nil, nil, err
+			}
+			out.ProcessName = proc.ProcessName
+			return out, nil, nil
+		}
+
+		// --- undo checkin
+		out.Action = "check_in"
+
+		if err = r.inprogressClient(tx).DeleteOneID(latest.ID).Exec(ctx); err != nil {
+			return nil, nil, err
+		}
+
+		var done bool
+		done, err = r.hasCompletedProcess(ctx, tx, processID)
+		if err != nil {
+			return nil, nil, err
+		}
+		status = "waiting"
+		if done {
+			status = "completed"
+		}
+
+		var prev *generated.OrderItemProcessInProgress
+		prev, err = r.latestEntity(ctx, tx, orderItemID)
+		if err != nil && !generated.IsNotFound(err) {
+			return nil, nil, err
+		}
+		err = nil
+		if prev != nil {
+			latestProcessID = prev.ProcessID
+		}
+	}
+
+	if err = r.updateProcessStatus(ctx, tx, processID, status); err != nil {
+		return nil, nil, err
+	}
+
+	var synced *string
+	if synced, _, err = r.syncOrderAndItemStatus(ctx, tx, orderItemID, latest.OrderID); err != nil {
+		return nil, nil, err
+	}
+	var reopened *generated.OrderItem
+	if out.Action == "check_out" && itemStatus(item) == "completed" && (synced == nil || *synced != "completed") {
+		reopened = item
+	}
+
+	if latestProcessID != nil {
+		err = r.syncOrderProcessLatest(ctx, tx, *latestProcessID, orderItemID, latest.OrderID)
+	} else {
+		err = r.clearOrderProcessLatest(ctx, tx, orderItemID, latest.OrderID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var proc *generated.OrderItemProcess
+	proc, err = r.processClient(tx).
+		Query().
+		Where(orderitemprocess.IDEQ(processID)).
+		Select(orderitemprocess.FieldProcessName).
+		Only(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	out.ProcessName = proc.ProcessName
+
+	return out, reopened, nil
+}
+
+// handedOverRow returns the row a reassignment left open for the same process
+// right before row, or nil when row is a plain check-in.
+func (r *orderItemProcessInProgressRepository) handedOverRow(
+	ctx context.Context,
+	tx *generated.Tx,
+	row *generated.OrderItemProcessInProgress,
+) (*generated.OrderItemProcessInProgress, error) {
+	prev, err := r.inprogressClient(tx).
+		Query().
+		Where(
+			orderitemprocessinprogress.ProcessIDEQ(*row.ProcessID),
+			orderitemprocessinprogress.IDLT(row.ID),
+		).
+		Order(orderitemprocessinprogress.ByID(sql.OrderDesc())).
+		First(ctx)
+	if generated.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prev.CompletedAt != nil || prev.CheckOutNote == nil || !strings.HasPrefix(*prev.CheckOutNote, handOverNotePrefix) {
+		return nil, nil
+	}
+	return prev, nil
+}
+
+// clearOrderProcessLatest resets the order's latest process once the item has
+// no transition left.
+func (r *orderItemProcessInProgressRepository) clearOrderProcessLatest(
+	ctx context.Context,
+	tx *generated.Tx,
+	orderItemID int64,
+	orderID *int64,
+) error {
+	if orderID == nil {
+		orderItem, err := tx.OrderItem.
+			Query().
+			Where(orderitem.IDEQ(orderItemID)).
+			Select(orderitem.FieldOrderID).
+			Only(ctx)
+		if err != nil {
+			return err
+		}
+		orderID = &orderItem.OrderID
+	}
+
+	return tx.Order.UpdateOneID(*orderID).
+		ClearProcessIDLatest().
+		ClearProcessNameLatest().
+		Exec(ctx)
+}
+
 func (r *orderItemProcessInProgressRepository) CheckIn(ctx context.Context, tx *generated.Tx, orderItemID int64, orderID *int64, note *string) (*model.OrderItemProcessInProgressDTO, error) {
 	processes, err := r.getProcesses(ctx, tx, orderItemID)
 	if err != nil {
@@ -714,6 +1229,7 @@ func (r *orderItemProcessInProgressRepository) GetCheckoutLatest(ctx context.Con
 		AssignedName:  entity.AssignedName,
 		StartedAt:     entity.StartedAt,
 		CompletedAt:   entity.CompletedAt,
+		SLAStatus:     entity.SLAStatus,
 		ProcessName:   proc.ProcessName,
 		SectionName:   proc.SectionName,
 		SectionID:     proc.SectionID,
@@ -743,6 +1259,9 @@ func (r *orderItemProcessInProgressRepository) getProcesses(ctx context.Context,
 }
 
 func (r *orderItemProcessInProgressRepository) nextProcessID(processes []*generated.OrderItemProcess, currentID int64) *int64 {
+	if isRoutedByGraph(processes) {
+		return graphNextProcessID(processes, currentID)
+	}
 	for i, p := range processes {
 		if p.ID == currentID && i+1 < len(processes) {
 			nextID := processes[i+1].ID
@@ -826,88 +1345,7 @@ func (r *orderItemProcessInProgressRepository) syncOrderAndItemStatus(
 	orderItemID int64,
 	orderID *int64,
 ) (*string, *generated.OrderItem, error) {
-	processes, err := r.processClient(tx).
-		Query().
-		Where(orderitemprocess.OrderItemID(orderItemID)).
-		Select(orderitemprocess.FieldCustomFields).
-		All(ctx)
-	if err != nil {
-		return nil, nil, err
-	}
-	if len(processes) == 0 {
-		return nil, nil, fmt.Errorf("no processes found for order item %d", orderItemID)
-	}
-
-	allWaiting := true
-	allCompleted := true
-	anyInProgress := false
-
-	for _, p := range processes {
-		status := utils.SafeGetString(p.CustomFields, "status")
-		if status != "waiting" {
-			allWaiting = false
-		}
-		if status != "completed" {
-			allCompleted = false
-		}
-		switch status {
-		case "in_progress", "qc", "rework":
-			anyInProgress = true
-		}
-	}
-
-	var orderStatus string
-	switch {
-	case allWaiting:
-		orderStatus = "received"
-	case anyInProgress:
-		orderStatus = "in_progress"
-	case allCompleted:
-		orderStatus = "completed"
-	default:
-		orderStatus = "in_progress"
-	}
-
-	orderItem, err := tx.OrderItem.
-		Query().
-		Where(orderitem.IDEQ(orderItemID)).
-		Select(
-			orderitem.FieldCustomFields,
-			orderitem.FieldOrderID,
-		).
-		Only(ctx)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	cf := maps.Clone(orderItem.CustomFields)
-	if cf == nil {
-		cf = make(map[string]any)
-	}
-	cf["status"] = orderStatus
-
-	if _, err := tx.OrderItem.
-		UpdateOneID(orderItemID).
-		SetCustomFields(cf).
-		SetStatus(orderStatus).
-		Save(ctx); err != nil {
-		return nil, nil, err
-	}
-
-	if orderID == nil {
-		oid := orderItem.OrderID
-		orderID = &oid
-	}
-
-	if orderID != nil {
-		if _, err := tx.Order.UpdateOneID(*orderID).
-			SetNillableStatusLatest(&orderStatus).
-			Save(ctx); err != nil {
-			return nil, nil, err
-		}
-	}
-
-	return &orderStatus, orderItem, nil
+	return syncItemStatus(ctx, tx, orderItemID, orderID)
 }
 
 func (r *orderItemProcessInProgressRepository) syncOrderProcessLatest(
@@ -1216,6 +1654,7 @@ func (r *orderItemProcessInProgressRepository) GetInProgressesByAssignedID(
 			AssignedName:  item.AssignedName,
 			StartedAt:     item.StartedAt,
 			CompletedAt:   item.CompletedAt,
+			SLAStatus:     item.SLAStatus,
 			ProcessName:   proc.ProcessName,
 			SectionName:   proc.SectionName,
 			SectionID:     proc.SectionID,
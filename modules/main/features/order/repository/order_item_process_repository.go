@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"maps"
 	"sort"
@@ -11,6 +12,7 @@ import (
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/categoryprocess"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/process"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
@@ -18,6 +20,7 @@ import (
 	"github.com/khiemnd777/andy_api/shared/mapper"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
 	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
 	"github.com/lib/pq"
 )
 
@@ -42,6 +45,16 @@ type OrderItemProcessRepository interface {
 		productIDs []int,
 	) ([]*model.OrderItemProcessDTO, error)
 
+	CreateManyFromRouting(
+		ctx context.Context,
+		tx *generated.Tx,
+		orderItemID int64,
+		orderID int64,
+		orderCode *string,
+		priority *string,
+		routing []*model.OrderItemProcessDTO,
+	) ([]*model.OrderItemProcessDTO, error)
+
 	CreateMany(
 		ctx context.Context,
 		tx *generated.Tx,
@@ -157,42 +170,44 @@ func (r *orderItemProcessRepository) CreateManyByProductIDs(
 		return nil, err
 	}
 
-	seenProcessIDs := make(map[int]struct{})
-	uniqueProcesses := make([]*model.ProcessDTO, 0)
-	processCounts := make(map[int]int, len(processMap))
-	totalProcesses := 0
-
-	for _, pid := range uniqueProductIDs {
-		processes := processMap[pid]
-		processCounts[pid] = len(processes)
-		totalProcesses += len(processes)
+	graphMap, err := r.getProcessGraphsByProductIDs(ctx, uniqueProductIDs)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ERROR] %v", err))
+		return nil, err
+	}
 
-		for _, p := range processes {
-			if p == nil {
-				continue
-			}
-			if _, ok := seenProcessIDs[p.ID]; ok {
-				continue
-			}
-			seenProcessIDs[p.ID] = struct{}{}
-			uniqueProcesses = append(uniqueProcesses, p)
+	// conditional steps are resolved against the item as it is created
+	var itemFields map[string]any
+	if len(graphMap) > 0 {
+		item, err := tx.OrderItem.
+			Query().
+			Where(orderitem.IDEQ(orderItemID)).
+			Select(orderitem.FieldCustomFields).
+			Only(ctx)
+		if err != nil {
+			return nil, err
 		}
+		itemFields = item.CustomFields
 	}
 
-	if len(uniqueProcesses) == 0 {
+	steps := mergeRouteSteps(uniqueProductIDs, processMap, graphMap, itemFields)
+	if len(steps) == 0 {
 		return []*model.OrderItemProcessDTO{}, nil
 	}
 
-	inputs := make([]*model.OrderItemProcessUpsertDTO, 0, len(uniqueProcesses))
+	inputs := make([]*model.OrderItemProcessUpsertDTO, 0, len(steps))
 	col := []string{"order-item-process"}
 
-	for i, p := range uniqueProcesses {
+	for i, st := range steps {
+		p := st.process
 		cf := maps.Clone(p.CustomFields)
 		if cf == nil {
 			cf = make(map[string]any)
 		}
 
-		if _, ok := cf["status"]; !ok {
+		if st.skipped {
+			cf["status"] = "skipped"
+		} else if _, ok := cf["status"]; !ok {
 			cf["status"] = "waiting"
 		}
 		if _, ok := cf["priority"]; !ok && priority != nil {
@@ -214,8 +229,67 @@ func (r *orderItemProcessRepository) CreateManyByProductIDs(
 				SectionID:    p.SectionID,
 				LeaderID:     p.LeaderID,
 				LeaderName:   p.LeaderName,
+				ProcessID:    &p.ID,
 				ProcessName:  pname,
 				StepNumber:   i + 1,
+				DependsOn:    st.dependsOn,
+				CustomFields: cf,
+			},
+			Collections: &col,
+		})
+	}
+
+	out, err := r.CreateMany(ctx, tx, inputs)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ERROR] %v", err))
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// CreateManyFromRouting copies the routing of a previous item: the same
+// processes, steps, sections, leaders and dependencies. Skipped steps stay
+// skipped, every other step starts over as waiting and unassigned.
+func (r *orderItemProcessRepository) CreateManyFromRouting(
+	ctx context.Context,
+	tx *generated.Tx,
+	orderItemID int64,
+	orderID int64,
+	orderCode *string,
+	priority *string,
+	routing []*model.OrderItemProcessDTO,
+) ([]*model.OrderItemProcessDTO, error) {
+	inputs := make([]*model.OrderItemProcessUpsertDTO, 0, len(routing))
+	col := []string{"order-item-process"}
+
+	for _, p := range routing {
+		if p == nil || p.ProcessID == nil {
+			continue
+		}
+
+		cf := map[string]any{"status": "waiting"}
+		if utils.SafeGetString(p.CustomFields, "status") == "skipped" {
+			cf["status"] = "skipped"
+		}
+		if priority != nil {
+			cf["priority"] = *priority
+		}
+
+		inputs = append(inputs, &model.OrderItemProcessUpsertDTO{
+			DTO: model.OrderItemProcessDTO{
+				OrderID:      &orderID,
+				OrderItemID:  orderItemID,
+				OrderCode:    orderCode,
+				Color:        p.Color,
+				SectionName:  p.SectionName,
+				SectionID:    p.SectionID,
+				LeaderID:     p.LeaderID,
+				LeaderName:   p.LeaderName,
+				ProcessID:    p.ProcessID,
+				ProcessName:  p.ProcessName,
+				StepNumber:   p.StepNumber,
+				DependsOn:    p.DependsOn,
 				CustomFields: cf,
 			},
 			Collections: &col,
@@ -286,6 +360,7 @@ func (r *orderItemProcessRepository) Create(ctx context.Context, tx *generated.T
 		Create().
 		SetOrderItemID(dto.OrderItemID).
 		SetNillableOrderID(dto.OrderID).
+		SetNillableProcessID(dto.ProcessID).
 		SetNillableProcessName(dto.ProcessName).
 		SetStepNumber(dto.StepNumber).
 		SetNillableAssignedID(dto.AssignedID).
@@ -296,6 +371,10 @@ func (r *orderItemProcessRepository) Create(ctx context.Context, tx *generated.T
 		SetNillableLeaderID(dto.LeaderID).
 		SetNillableLeaderName(dto.LeaderName)
 
+	if dto.DependsOn != nil {
+		q.SetDependsOn(dto.DependsOn)
+	}
+
 	if input.Collections != nil && len(*input.Collections) > 0 {
 		_, err := customfields.PrepareCustomFields(ctx,
 			r.cfMgr,
@@ -458,6 +537,12 @@ func (r *orderItemProcessRepository) UpdateStatus(
 	if err != nil {
 		return nil, err
 	}
+
+	from := processStatus(oip)
+	if err := recordStatusChange(ctx, tx, StatusEntityProcess, id, oip.OrderID, &oip.OrderItemID, &from, status); err != nil {
+		return nil, err
+	}
+
 	out := mapper.MapAs[*generated.OrderItemProcess, *model.OrderItemProcessDTO](entity)
 
 	return out, nil
@@ -493,6 +578,12 @@ func (r *orderItemProcessRepository) UpdateStatusAndAssign(
 	if err != nil {
 		return nil, err
 	}
+
+	from := processStatus(oip)
+	if err := recordStatusChange(ctx, tx, StatusEntityProcess, id, oip.OrderID, &oip.OrderItemID, &from, status); err != nil {
+		return nil, err
+	}
+
 	out := mapper.MapAs[*generated.OrderItemProcess, *model.OrderItemProcessDTO](entity)
 
 	return out, nil
@@ -677,8 +768,8 @@ func (r *orderItemProcessRepository) getRawProcessesByProductIDs(
 ),
 ranked_sections AS (
     SELECT
-				sp.process_id,
-				s.id AS section_id
+        sp.process_id,
+        s.id AS section_id,
         s.leader_id,
         s.leader_name,
         ROW_NUMBER() OVER (
@@ -691,13 +782,13 @@ ranked_sections AS (
        AND s.deleted_at IS NULL
 )
 SELECT
-		pc.product_id,
+    pc.product_id,
     p.id,
     p.code,
     p.name,
     p.color,
     p.section_name,
-		rs.section_id
+    rs.section_id,
     rs.leader_id,
     rs.leader_name
 FROM product_categories pc
@@ -753,6 +844,114 @@ ORDER BY pc.product_id, cp.display_order ASC;
 	return result, nil
 }
 
+// getProcessGraphsByProductIDs loads the process graph of each product that
+// has one. Products without ProductProcess rows are omitted.
+func (r *orderItemProcessRepository) getProcessGraphsByProductIDs(
+	ctx context.Context,
+	productIDs []int,
+) (map[int][]*graphStep, error) {
+	if len(productIDs) == 0 {
+		return map[int][]*graphStep{}, nil
+	}
+
+	const q = `WITH ranked_sections AS (
+    SELECT
+        sp.process_id,
+        s.id AS section_id,
+        s.leader_id,
+        s.leader_name,
+        ROW_NUMBER() OVER (
+            PARTITION BY sp.process_id
+            ORDER BY sp.id ASC
+        ) AS rn
+    FROM section_processes sp
+    JOIN sections s
+        ON s.id = sp.section_id
+       AND s.deleted_at IS NULL
+)
+SELECT
+    pp.product_id,
+    p.id,
+    p.code,
+    p.name,
+    p.color,
+    p.section_name,
+    rs.section_id,
+    rs.leader_id,
+    rs.leader_name,
+    pp.depends_on,
+    pp."condition"
+FROM product_processes pp
+JOIN products pr
+    ON pr.id = pp.product_id
+   AND pr.deleted_at IS NULL
+JOIN processes p
+    ON p.id = pp.process_id
+   AND p.deleted_at IS NULL
+LEFT JOIN ranked_sections rs
+    ON rs.process_id = p.id
+   AND rs.rn = 1
+WHERE pp.product_id = ANY($1)
+ORDER BY pp.product_id, pp.display_order ASC NULLS LAST, pp.id ASC;
+`
+
+	rows, err := r.db.QueryContext(ctx, q, pq.Array(productIDs))
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ERROR] %v", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int][]*graphStep)
+
+	for rows.Next() {
+		var (
+			productID int
+			dependsOn []byte
+			condition []byte
+			dto       = &model.ProcessDTO{}
+		)
+
+		if err := rows.Scan(
+			&productID,
+			&dto.ID,
+			&dto.Code,
+			&dto.Name,
+			&dto.Color,
+			&dto.SectionName,
+			&dto.SectionID,
+			&dto.LeaderID,
+			&dto.LeaderName,
+			&dependsOn,
+			&condition,
+		); err != nil {
+			logger.Error(fmt.Sprintf("[ERROR] %v", err))
+			return nil, err
+		}
+
+		step := &graphStep{process: dto}
+		if len(dependsOn) > 0 {
+			if err := json.Unmarshal(dependsOn, &step.dependsOn); err != nil {
+				return nil, err
+			}
+		}
+		if len(condition) > 0 {
+			if err := json.Unmarshal(condition, &step.condition); err != nil {
+				return nil, err
+			}
+		}
+
+		result[productID] = append(result[productID], step)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error(fmt.Sprintf("[ERROR] %v", err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func (r *orderItemProcessRepository) GetRawProcessesByProductID1(
 	ctx context.Context,
 	productID int,
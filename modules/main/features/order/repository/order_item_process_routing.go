@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+// Order items created from a product process graph carry the predecessors of
+// every step in depends_on. Items without it keep the historical linear
+// routing by step_number, so the helpers below fall back to that.
+
+var ErrProcessBlocked = errors.New("process is waiting for a previous step")
+
+func processStatus(p *generated.OrderItemProcess) string {
+	return utils.SafeGetString(p.CustomFields, "status")
+}
+
+func isStepDone(status string) bool {
+	return status == "completed" || status == "skipped" || status == statusCancelled
+}
+
+func isStepActive(status string) bool {
+	switch status {
+	case "in_progress", "qc", "rework":
+		return true
+	}
+	return false
+}
+
+func isRoutedByGraph(processes []*generated.OrderItemProcess) bool {
+	for _, p := range processes {
+		if p.DependsOn != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnblocked reports whether every predecessor of p is completed, skipped or
+// cancelled.
+// Predecessors that are not part of the item are ignored.
+func isUnblocked(processes []*generated.OrderItemProcess, p *generated.OrderItemProcess) bool {
+	if len(p.DependsOn) == 0 {
+		return true
+	}
+	done := make(map[int]bool, len(processes))
+	for _, q := range processes {
+		if q.ProcessID != nil {
+			done[*q.ProcessID] = isStepDone(processStatus(q))
+		}
+	}
+	for _, d := range p.DependsOn {
+		if ok, exists := done[d]; exists && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// startableProcesses returns the steps that can be checked in right now, in
+// step order: not started, not skipped or cancelled and with every
+// predecessor done.
+func startableProcesses(processes []*generated.OrderItemProcess) []*generated.OrderItemProcess {
+	out := make([]*generated.OrderItemProcess, 0)
+	for _, p := range processes {
+		status := processStatus(p)
+		if isStepDone(status) || isStepActive(status) {
+			continue
+		}
+		if isUnblocked(processes, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// graphNextProcessID picks the step to suggest after current is checked out:
+// the first successor that becomes unblocked once current is done.
+func graphNextProcessID(processes []*generated.OrderItemProcess, currentID int64) *int64 {
+	var current *generated.OrderItemProcess
+	for _, p := range processes {
+		if p.ID == currentID {
+			current = p
+			break
+		}
+	}
+	if current == nil || current.ProcessID == nil {
+		return nil
+	}
+
+	done := make(map[int]bool, len(processes))
+	for _, p := range processes {
+		if p.ProcessID != nil {
+			done[*p.ProcessID] = p.ID == currentID || isStepDone(processStatus(p))
+		}
+	}
+
+	for _, p := range processes {
+		status := processStatus(p)
+		if p.ID == currentID || isStepDone(status) || isStepActive(status) {
+			continue
+		}
+		successor := false
+		ready := true
+		for _, d := range p.DependsOn {
+			if d == *current.ProcessID {
+				successor = true
+			}
+			if ok, exists := done[d]; exists && !ok {
+				ready = false
+			}
+		}
+		if successor && ready {
+			id := p.ID
+			return &id
+		}
+	}
+	return nil
+}
+
+// conditionMatches reports whether the order item custom fields satisfy every
+// key of cond. An expected value may be a scalar or a list of accepted
+// values; a list-valued field matches when any of its values is accepted.
+func conditionMatches(cond map[string]any, fields map[string]any) bool {
+	for key, expected := range cond {
+		accepted := make(map[string]bool)
+		for _, v := range asList(expected) {
+			accepted[fmt.Sprint(v)] = true
+		}
+
+		matched := false
+		for _, v := range asList(fields[key]) {
+			if v != nil && accepted[fmt.Sprint(v)] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func asList(v any) []any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return t
+	case []string:
+		out := make([]any, len(t))
+		for i, s := range t {
+			out[i] = s
+		}
+		return out
+	}
+	return []any{v}
+}
+
+// graphStep is a ProductProcess row joined with its master process.
+type graphStep struct {
+	process   *model.ProcessDTO
+	dependsOn []int
+	condition map[string]any
+}
+
+// routeStep is a step to create for an order item.
+type routeStep struct {
+	process   *model.ProcessDTO
+	dependsOn []int
+	skipped   bool
+}
+
+// mergeRouteSteps combines the steps of every product of an order item. A
+// product with a process graph contributes its graph, any other product its
+// category processes chained one after another. A process shared by several
+// products becomes one step that waits for the predecessors of all of them
+// and is skipped only when every product skips it. Without any graph the
+// result keeps the plain linear routing (dependsOn left nil).
+func mergeRouteSteps(
+	productIDs []int,
+	linear map[int][]*model.ProcessDTO,
+	graphs map[int][]*graphStep,
+	fields map[string]any,
+) []*routeStep {
+	index := make(map[int]*routeStep)
+	order := make([]*routeStep, 0)
+
+	for _, pid := range productIDs {
+		nodes, ok := graphs[pid]
+		if !ok {
+			prev := 0
+			for _, p := range linear[pid] {
+				if p == nil {
+					continue
+				}
+				n := &graphStep{process: p}
+				if prev != 0 {
+					n.dependsOn = []int{prev}
+				}
+				prev = p.ID
+				nodes = append(nodes, n)
+			}
+		}
+
+		for _, n := range nodes {
+			skip := len(n.condition) > 0 && !conditionMatches(n.condition, fields)
+
+			st, ok := index[n.process.ID]
+			if !ok {
+				st = &routeStep{process: n.process, dependsOn: []int{}, skipped: skip}
+				index[n.process.ID] = st
+				order = append(order, st)
+			} else {
+				st.skipped = st.skipped && skip
+			}
+
+			for _, d := range n.dependsOn {
+				if d != n.process.ID && !slices.Contains(st.dependsOn, d) {
+					st.dependsOn = append(st.dependsOn, d)
+				}
+			}
+		}
+	}
+
+	if len(graphs) == 0 {
+		for _, st := range order {
+			st.dependsOn = nil
+		}
+		return order
+	}
+
+	return sortRouteSteps(order, index)
+}
+
+// sortRouteSteps orders steps so each one comes after its predecessors,
+// keeping the original order otherwise. Merging graphs of different products
+// can create a cycle; it is broken by dropping the dependencies of the first
+// remaining step on the steps not placed yet.
+func sortRouteSteps(steps []*routeStep, index map[int]*routeStep) []*routeStep {
+	placed := make(map[int]bool, len(steps))
+	out := make([]*routeStep, 0, len(steps))
+
+	ready := func(st *routeStep) bool {
+		for _, d := range st.dependsOn {
+			if _, known := index[d]; known && !placed[d] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(out) < len(steps) {
+		var next *routeStep
+		for _, st := range steps {
+			if !placed[st.process.ID] && ready(st) {
+				next = st
+				break
+			}
+		}
+		if next == nil {
+			for _, st := range steps {
+				if !placed[st.process.ID] {
+					next = st
+					break
+				}
+			}
+			kept := make([]int, 0, len(next.dependsOn))
+			for _, d := range next.dependsOn {
+				if _, known := index[d]; !known || placed[d] {
+					kept = append(kept, d)
+				}
+			}
+			next.dependsOn = kept
+		}
+		placed[next.process.ID] = true
+		out = append(out, next)
+	}
+
+	return out
+}
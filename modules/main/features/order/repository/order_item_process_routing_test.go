@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+)
+
+func TestIsUnblocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		processes []*generated.OrderItemProcess
+		expected  bool
+	}{
+		{
+			name:      "linear step",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "waiting"), testProcess(2, 20, "waiting")},
+			expected:  true,
+		},
+		{
+			name:      "predecessor completed",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "completed"), testProcess(2, 20, "waiting", 10)},
+			expected:  true,
+		},
+		{
+			name:      "predecessor skipped",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "skipped"), testProcess(2, 20, "waiting", 10)},
+			expected:  true,
+		},
+		{
+			name:      "predecessor cancelled",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "cancelled"), testProcess(2, 20, "waiting", 10)},
+			expected:  true,
+		},
+		{
+			name:      "predecessor in progress",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "in_progress"), testProcess(2, 20, "waiting", 10)},
+			expected:  false,
+		},
+		{
+			name: "one of two predecessors pending",
+			processes: []*generated.OrderItemProcess{
+				testProcess(1, 10, "completed"),
+				testProcess(2, 30, "qc"),
+				testProcess(3, 20, "waiting", 10, 30),
+			},
+			expected: false,
+		},
+		{
+			name:      "predecessor not on the item",
+			processes: []*generated.OrderItemProcess{testProcess(1, 10, "completed"), testProcess(2, 20, "waiting", 99)},
+			expected:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		target := tt.processes[len(tt.processes)-1]
+		if got := isUnblocked(tt.processes, target); got != tt.expected {
+			t.Errorf("%s: isUnblocked = %v; want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestStartableProcesses(t *testing.T) {
+	processes := []*generated.OrderItemProcess{
+		testProcess(1, 10, "cancelled"),
+		testProcess(2, 20, "waiting", 10),
+		testProcess(3, 30, "completed"),
+		testProcess(4, 40, "in_progress"),
+	}
+
+	got := startableProcesses(processes)
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("startableProcesses = %d steps; want only step 2", len(got))
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		cond     map[string]any
+		fields   map[string]any
+		expected bool
+	}{
+		{"no condition", nil, map[string]any{"material": "zirconia"}, true},
+		{"scalar match", map[string]any{"material": "zirconia"}, map[string]any{"material": "zirconia"}, true},
+		{"scalar mismatch", map[string]any{"material": "zirconia"}, map[string]any{"material": "emax"}, false},
+		{"missing field", map[string]any{"material": "zirconia"}, map[string]any{}, false},
+		{"nil field", map[string]any{"material": "zirconia"}, map[string]any{"material": nil}, false},
+		{"accepted list", map[string]any{"material": []any{"zirconia", "emax"}}, map[string]any{"material": "emax"}, true},
+		{"accepted string list", map[string]any{"material": []string{"zirconia", "emax"}}, map[string]any{"material": "pfm"}, false},
+		{"list field", map[string]any{"shade": "A2"}, map[string]any{"shade": []any{"A1", "A2"}}, true},
+		{"numbers compare as text", map[string]any{"units": 3}, map[string]any{"units": "3"}, true},
+		{"every key must match", map[string]any{"material": "zirconia", "layered": true}, map[string]any{"material": "zirconia", "layered": false}, false},
+	}
+
+	for _, tt := range tests {
+		if got := conditionMatches(tt.cond, tt.fields); got != tt.expected {
+			t.Errorf("%s: conditionMatches = %v; want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func testRouteProcess(id int) *model.ProcessDTO {
+	return &model.ProcessDTO{ID: id}
+}
+
+func routeIDs(steps []*routeStep) []int {
+	out := make([]int, 0, len(steps))
+	for _, st := range steps {
+		out = append(out, st.process.ID)
+	}
+	return out
+}
+
+func TestMergeRouteSteps(t *testing.T) {
+	scan, design, mill, sinter, glaze, stain := testRouteProcess(1), testRouteProcess(2), testRouteProcess(3), testRouteProcess(4), testRouteProcess(5), testRouteProcess(6)
+
+	tests := []struct {
+		name       string
+		productIDs []int
+		linear     map[int][]*model.ProcessDTO
+		graphs     map[int][]*graphStep
+		fields     map[string]any
+		order      []int
+		dependsOn  map[int][]int
+		skipped    []int
+	}{
+		{
+			name:       "linear only",
+			productIDs: []int{100},
+			linear:     map[int][]*model.ProcessDTO{100: {scan, design, nil, mill}},
+			order:      []int{1, 2, 3},
+			dependsOn:  map[int][]int{1: nil, 2: nil, 3: nil},
+		},
+		{
+			name:       "parallel graph",
+			productIDs: []int{100},
+			graphs: map[int][]*graphStep{100: {
+				{process: design},
+				{process: mill, dependsOn: []int{2}},
+				{process: stain, dependsOn: []int{2}},
+				{process: glaze, dependsOn: []int{3, 6}},
+			}},
+			order:     []int{2, 3, 6, 5},
+			dependsOn: map[int][]int{2: {}, 3: {2}, 6: {2}, 5: {3, 6}},
+		},
+		{
+			name:       "graph listed out of order",
+			productIDs: []int{100},
+			graphs: map[int][]*graphStep{100: {
+				{process: glaze, dependsOn: []int{3}},
+				{process: mill, dependsOn: []int{2}},
+				{process: design},
+			}},
+			order:     []int{2, 3, 5},
+			dependsOn: map[int][]int{2: {}, 3: {2}, 5: {3}},
+		},
+		{
+			name:       "linear product chained next to a graph",
+			productIDs: []int{100, 200},
+			linear:     map[int][]*model.ProcessDTO{200: {scan, mill}},
+			graphs: map[int][]*graphStep{100: {
+				{process: design},
+				{process: mill, dependsOn: []int{2}},
+			}},
+			order:     []int{2, 1, 3},
+			dependsOn: map[int][]int{2: {}, 3: {2, 1}, 1: {}},
+		},
+		{
+			name:       "condition not met skips the step",
+			productIDs: []int{100},
+			graphs: map[int][]*graphStep{100: {
+				{process: mill},
+				{process: stain, dependsOn: []int{3}, condition: map[string]any{"layered": "yes"}},
+				{process: glaze, dependsOn: []int{6}},
+			}},
+			fields:    map[string]any{"layered": "no"},
+			order:     []int{3, 6, 5},
+			dependsOn: map[int][]int{3: {}, 6: {3}, 5: {6}},
+			skipped:   []int{6},
+		},
+		{
+			name:       "condition met keeps the step",
+			productIDs: []int{100},
+			graphs: map[int][]*graphStep{100: {
+				{process: mill},
+				{process: stain, dependsOn: []int{3}, condition: map[string]any{"layered": "yes"}},
+			}},
+			fields:    map[string]any{"layered": "yes"},
+			order:     []int{3, 6},
+			dependsOn: map[int][]int{3: {}, 6: {3}},
+		},
+		{
+			name:       "shared step skipped only when every product skips it",
+			productIDs: []int{100, 200},
+			graphs: map[int][]*graphStep{
+				100: {{process: sinter, condition: map[string]any{"material": "zirconia"}}},
+				200: {{process: sinter}},
+			},
+			fields:    map[string]any{"material": "emax"},
+			order:     []int{4},
+			dependsOn: map[int][]int{4: {}},
+		},
+		{
+			name:       "shared step skipped by every product",
+			productIDs: []int{100, 200},
+			graphs: map[int][]*graphStep{
+				100: {{process: sinter, condition: map[string]any{"material": "zirconia"}}},
+				200: {{process: sinter, condition: map[string]any{"material": "zirconia"}}},
+			},
+			fields:    map[string]any{"material": "emax"},
+			order:     []int{4},
+			dependsOn: map[int][]int{4: {}},
+			skipped:   []int{4},
+		},
+		{
+			name:       "cycle across products is broken",
+			productIDs: []int{100, 200},
+			graphs: map[int][]*graphStep{
+				100: {{process: mill}, {process: glaze, dependsOn: []int{3}}},
+				200: {{process: glaze}, {process: mill, dependsOn: []int{5}}},
+			},
+			order:     []int{3, 5},
+			dependsOn: map[int][]int{3: {}, 5: {3}},
+		},
+		{
+			name:       "self dependency ignored",
+			productIDs: []int{100},
+			graphs:     map[int][]*graphStep{100: {{process: mill, dependsOn: []int{3}}}},
+			order:      []int{3},
+			dependsOn:  map[int][]int{3: {}},
+		},
+		{
+			name:       "dependency outside the item kept",
+			productIDs: []int{100},
+			graphs:     map[int][]*graphStep{100: {{process: mill, dependsOn: []int{99}}}},
+			order:      []int{3},
+			dependsOn:  map[int][]int{3: {99}},
+		},
+	}
+
+	for _, tt := range tests {
+		steps := mergeRouteSteps(tt.productIDs, tt.linear, tt.graphs, tt.fields)
+
+		if got := routeIDs(steps); !slices.Equal(got, tt.order) {
+			t.Errorf("%s: order = %v; want %v", tt.name, got, tt.order)
+			continue
+		}
+		for _, st := range steps {
+			if want := tt.dependsOn[st.process.ID]; !slices.Equal(st.dependsOn, want) || (want == nil) != (st.dependsOn == nil) {
+				t.Errorf("%s: step %d depends on %v; want %v", tt.name, st.process.ID, st.dependsOn, want)
+			}
+			if want := slices.Contains(tt.skipped, st.process.ID); st.skipped != want {
+				t.Errorf("%s: step %d skipped = %v; want %v", tt.name, st.process.ID, st.skipped, want)
+			}
+		}
+	}
+}
+
+func TestSortRouteStepsCycle(t *testing.T) {
+	a := &routeStep{process: testRouteProcess(1), dependsOn: []int{3}}
+	b := &routeStep{process: testRouteProcess(2), dependsOn: []int{1}}
+	c := &routeStep{process: testRouteProcess(3), dependsOn: []int{2}}
+	index := map[int]*routeStep{1: a, 2: b, 3: c}
+
+	out := sortRouteSteps([]*routeStep{a, b, c}, index)
+
+	if got := routeIDs(out); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("order = %v; want [1 2 3]", got)
+	}
+	if len(a.dependsOn) != 0 {
+		t.Errorf("first step still depends on %v; want the cycle broken there", a.dependsOn)
+	}
+	if !slices.Equal(b.dependsOn, []int{1}) || !slices.Equal(c.dependsOn, []int{2}) {
+		t.Errorf("dependencies = %v, %v; want [1], [2]", b.dependsOn, c.dependsOn)
+	}
+}
@@ -2,13 +2,20 @@ package repository
 
 import (
 	"context"
+	"time"
 
+	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	pricelistrepo "github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	productrepo "github.com/khiemnd777/andy_api/modules/main/features/product/repository"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemproduct"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
 	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
 )
 
 type OrderItemProductRepository interface {
@@ -29,16 +36,23 @@ type OrderItemProductRepository interface {
 		ctx context.Context,
 		items ...*model.OrderItemDTO,
 	) error
-	GetTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error)
+	GetTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error)
 	GetTotalPriceByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (float64, error)
+	ApplyContractPrices(ctx context.Context, orderEnt *generated.Order, products []*model.OrderItemProductDTO) error
 }
 
 type orderItemProductRepository struct {
-	db *generated.Client
+	db            *generated.Client
+	priceListRepo pricelistrepo.PriceListRepository
+	basePriceRepo productrepo.ProductPriceRepository
 }
 
-func NewOrderItemProductRepository(db *generated.Client) OrderItemProductRepository {
-	return &orderItemProductRepository{db: db}
+func NewOrderItemProductRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) OrderItemProductRepository {
+	return &orderItemProductRepository{
+		db:            db,
+		priceListRepo: pricelistrepo.NewPriceListRepository(db, deps),
+		basePriceRepo: productrepo.NewProductPriceRepository(db, deps),
+	}
 }
 
 func (r *orderItemProductRepository) PrepareProducts(
@@ -61,6 +75,11 @@ func (r *orderItemProductRepository) PrepareProducts(
 		seen[product.ProductID] = struct{}{}
 
 		qty := r.normalizeQuantity(product.Quantity)
+		teeth := product.TeethPosition
+		if product.ToothChart != nil && product.ToothChart.Count() > 0 {
+			qty = product.ToothChart.Count()
+			teeth = utils.Ptr(product.ToothChart.String())
+		}
 
 		out = append(out, &model.OrderItemProductDTO{
 			ID:                  product.ID,
@@ -70,7 +89,8 @@ func (r *orderItemProductRepository) PrepareProducts(
 			IsCloneable:         product.IsCloneable,
 			OrderID:             product.OrderID,
 			RetailPrice:         product.RetailPrice,
-			TeethPosition:       product.TeethPosition,
+			TeethPosition:       teeth,
+			ToothChart:          product.ToothChart,
 			Quantity:            qty,
 			Note:                product.Note,
 		})
@@ -245,6 +265,7 @@ func (r *orderItemProductRepository) replaceProductsForOrderItem(
 			SetNillableNote(p.Note).
 			SetNillableRetailPrice(p.RetailPrice).
 			SetNillableTeethPosition(p.TeethPosition).
+			SetToothChart(p.ToothChart).
 			SetNillableIsCloneable(p.IsCloneable)
 
 		// Optional fields if your schema supports them (uncomment if applicable):
@@ -306,7 +327,8 @@ func (r *orderItemProductRepository) syncFromDerived(
 				SetNillableNote(p.Note).
 				SetNillableProductCode(p.ProductCode).
 				SetNillableRetailPrice(p.RetailPrice).
-				SetNillableTeethPosition(p.TeethPosition),
+				SetNillableTeethPosition(p.TeethPosition).
+				SetToothChart(p.ToothChart),
 		)
 	}
 
@@ -377,6 +399,7 @@ func (r *orderItemProductRepository) syncFromSource(
 					SetQuantity(qty).
 					SetNillableRetailPrice(p.RetailPrice).
 					SetNillableTeethPosition(p.TeethPosition).
+					SetToothChart(p.ToothChart).
 					SetNillableNote(p.Note).
 					Save(ctx); err != nil {
 					return err
@@ -391,6 +414,7 @@ func (r *orderItemProductRepository) syncFromSource(
 					SetQuantity(qty).
 					SetNillableRetailPrice(p.RetailPrice).
 					SetNillableTeethPosition(p.TeethPosition).
+					SetToothChart(p.ToothChart).
 					SetIsCloneable(true).
 					SetNillableNote(p.Note).
 					Save(ctx); err != nil {
@@ -430,6 +454,8 @@ func (r *orderItemProductRepository) GetProductsByOrderID(ctx context.Context, o
 			orderitemproduct.FieldQuantity,
 			orderitemproduct.FieldRetailPrice,
 			orderitemproduct.FieldNote,
+			orderitemproduct.FieldTeethPosition,
+			orderitemproduct.FieldToothChart,
 		).
 		WithOrderItem(func(q *generated.OrderItemQuery) {
 			q.Select(orderitem.FieldID, orderitem.FieldCode)
@@ -458,6 +484,7 @@ func (r *orderItemProductRepository) GetProductsByOrderID(ctx context.Context, o
 			Note:                it.Note,
 			RetailPrice:         it.RetailPrice,
 			TeethPosition:       it.TeethPosition,
+			ToothChart:          it.ToothChart,
 		}
 		if it.Edges.OrderItem != nil {
 			dto.OrderItemCode = it.Edges.OrderItem.Code
@@ -560,26 +587,36 @@ func (r *orderItemProductRepository) PrepareForRemake(
 	return nil
 }
 
-func (r *orderItemProductRepository) GetTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error) {
-	products, err := r.db.OrderItemProduct.
+func (r *orderItemProductRepository) GetTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error) {
+	oipC, oc := r.db.OrderItemProduct, r.db.Order
+	if tx != nil {
+		oipC, oc = tx.OrderItemProduct, tx.Order
+	}
+	products, err := oipC.
 		Query().
 		Where(orderitemproduct.OrderItemIDEQ(orderItemID)).
-		Select(orderitemproduct.FieldQuantity, orderitemproduct.FieldRetailPrice).
+		Select(
+			orderitemproduct.FieldID,
+			orderitemproduct.FieldOrderID,
+			orderitemproduct.FieldOrderItemID,
+			orderitemproduct.FieldProductID,
+			orderitemproduct.FieldQuantity,
+			orderitemproduct.FieldRetailPrice,
+		).
 		All(ctx)
 	if err != nil {
 		return 0, err
 	}
+	if len(products) == 0 {
+		return 0, nil
+	}
 
-	var total float64
-	for _, product := range products {
-		if product == nil || product.RetailPrice == nil {
-			continue
-		}
-		qty := r.normalizeQuantity(product.Quantity)
-		total += *product.RetailPrice * float64(qty)
+	contract, err := r.contractPrices(ctx, oc, products[0].OrderID, products)
+	if err != nil {
+		return 0, err
 	}
 
-	return total, nil
+	return r.sumProducts(products, contract), nil
 }
 
 func (r *orderItemProductRepository) GetTotalPriceByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (float64, error) {
@@ -595,22 +632,173 @@ func (r *orderItemProductRepository) GetTotalPriceByOrderID(ctx context.Context,
 			orderitemproduct.OrderIDEQ(orderID),
 			orderitemproduct.HasOrderItemWith(orderitem.DeletedAtIsNil()),
 		).
-		Select(orderitemproduct.FieldQuantity, orderitemproduct.FieldRetailPrice).
+		Select(
+			orderitemproduct.FieldID,
+			orderitemproduct.FieldOrderItemID,
+			orderitemproduct.FieldProductID,
+			orderitemproduct.FieldQuantity,
+			orderitemproduct.FieldRetailPrice,
+		).
 		All(ctx)
 	if err != nil {
 		return 0, err
 	}
 
+	oc := r.db.Order
+	if tx != nil {
+		oc = tx.Order
+	}
+	contract, err := r.contractPrices(ctx, oc, orderID, products)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.sumProducts(products, contract), nil
+}
+
+// ApplyContractPrices overwrites retail_price with the price list price that
+// was active when the order was created; unmatched products keep their price,
+// or get the product base price when they have none.
+func (r *orderItemProductRepository) ApplyContractPrices(
+	ctx context.Context,
+	orderEnt *generated.Order,
+	products []*model.OrderItemProductDTO,
+) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	lines := make([]pricelistrepo.PriceLine, 0, len(products))
+	var unpriced []int
+	for _, p := range products {
+		if p == nil || p.ProductID == 0 {
+			continue
+		}
+		lines = append(lines, pricelistrepo.PriceLine{ProductID: p.ProductID, Quantity: r.normalizeQuantity(p.Quantity)})
+		if p.RetailPrice == nil {
+			unpriced = append(unpriced, p.ProductID)
+		}
+	}
+
+	resolved := map[int]*pricelistrepo.ResolvedPrice{}
+	if pc, ok := orderPriceContext(orderEnt); ok {
+		var err error
+		if resolved, err = r.priceListRepo.Resolve(ctx, pc, lines); err != nil {
+			return err
+		}
+	}
+
+	base, err := r.basePriceRepo.GetBasePrices(ctx, unpriced)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if p == nil {
+			continue
+		}
+		if rp, ok := resolved[p.ProductID]; ok {
+			price := rp.Price
+			p.RetailPrice = &price
+		} else if price, ok := base[p.ProductID]; ok && p.RetailPrice == nil {
+			p.RetailPrice = &price
+		}
+	}
+	return nil
+}
+
+// contractPrices maps order item product id -> price list price at order
+// creation. Invoiced orders are locked and keep their stored prices.
+func (r *orderItemProductRepository) contractPrices(
+	ctx context.Context,
+	oc *generated.OrderClient,
+	orderID int64,
+	products []*generated.OrderItemProduct,
+) (map[int]float64, error) {
+	out := make(map[int]float64, len(products))
+	if len(products) == 0 {
+		return out, nil
+	}
+
+	orderEnt, err := oc.Query().
+		Where(order.ID(orderID)).
+		Select(
+			order.FieldDepartmentID,
+			order.FieldClinicID,
+			order.FieldDentistID,
+			order.FieldInvoiceID,
+			order.FieldCreatedAt,
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if orderEnt.InvoiceID != nil {
+		return out, nil
+	}
+	pc, ok := orderPriceContext(orderEnt)
+	if !ok {
+		return out, nil
+	}
+
+	// volume tiers apply per order item
+	byItem := make(map[int64][]*generated.OrderItemProduct)
+	for _, p := range products {
+		byItem[p.OrderItemID] = append(byItem[p.OrderItemID], p)
+	}
+
+	for _, rows := range byItem {
+		lines := make([]pricelistrepo.PriceLine, 0, len(rows))
+		for _, p := range rows {
+			lines = append(lines, pricelistrepo.PriceLine{ProductID: p.ProductID, Quantity: r.normalizeQuantity(p.Quantity)})
+		}
+		resolved, err := r.priceListRepo.Resolve(ctx, pc, lines)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range rows {
+			if rp, ok := resolved[p.ProductID]; ok {
+				out[p.ID] = rp.Price
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (r *orderItemProductRepository) sumProducts(products []*generated.OrderItemProduct, contract map[int]float64) float64 {
 	var total float64
 	for _, product := range products {
-		if product == nil || product.RetailPrice == nil {
+		if product == nil {
 			continue
 		}
+		price, ok := contract[product.ID]
+		if !ok {
+			if product.RetailPrice == nil {
+				continue
+			}
+			price = *product.RetailPrice
+		}
 		qty := r.normalizeQuantity(product.Quantity)
-		total += *product.RetailPrice * float64(qty)
+		total += price * float64(qty)
 	}
+	return total
+}
 
-	return total, nil
+func orderPriceContext(orderEnt *generated.Order) (pricelistrepo.PriceContext, bool) {
+	if orderEnt == nil || orderEnt.DepartmentID == nil {
+		return pricelistrepo.PriceContext{}, false
+	}
+	at := orderEnt.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return pricelistrepo.PriceContext{
+		DepartmentID: *orderEnt.DepartmentID,
+		ClinicID:     orderEnt.ClinicID,
+		DentistID:    orderEnt.DentistID,
+		At:           at,
+	}, true
 }
 
 func (r *orderItemProductRepository) normalizeQuantity(quantity int) int {
@@ -27,7 +27,7 @@ type OrderItemRepository interface {
 	GetLatestByOrderID(ctx context.Context, orderID int64) (*model.OrderItemDTO, error)
 	GetLatestOrderItemIDByOrderID(ctx context.Context, orderID int64) (int64, error)
 	GetHistoricalByOrderIDAndOrderItemID(ctx context.Context, orderID, orderItemID int64) ([]*model.OrderItemHistoricalDTO, error)
-	GetTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error)
+	GetTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error)
 	GetTotalPriceByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (float64, error)
 	GetAllProductsAndMaterialsByOrderID(ctx context.Context, orderID int64) (model.OrderProductsAndMaterialsDTO, error)
 	// -- general functions
@@ -51,12 +51,14 @@ type orderItemRepository struct {
 	orderItemProcessRepo  OrderItemProcessRepository
 	orderItemProductRepo  OrderItemProductRepository
 	orderItemMaterialRepo OrderItemMaterialRepository
+	orderItemFileRepo     OrderItemFileRepository
 }
 
 func NewOrderItemRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) OrderItemRepository {
 	orderItemProcessRepo := NewOrderItemProcessRepository(db, deps, cfMgr)
-	orderItemProductRepo := NewOrderItemProductRepository(db)
+	orderItemProductRepo := NewOrderItemProductRepository(db, deps)
 	orderItemMaterialRepo := NewOrderItemMaterialRepository(db)
+	orderItemFileRepo := NewOrderItemFileRepository(db)
 
 	return &orderItemRepository{
 		db:                    db,
@@ -65,6 +67,7 @@ func NewOrderItemRepository(db *generated.Client, deps *module.ModuleDeps[config
 		orderItemProcessRepo:  orderItemProcessRepo,
 		orderItemProductRepo:  orderItemProductRepo,
 		orderItemMaterialRepo: orderItemMaterialRepo,
+		orderItemFileRepo:     orderItemFileRepo,
 	}
 }
 
@@ -88,7 +91,7 @@ func (r *orderItemRepository) IsLatest(ctx context.Context, orderItemID int64) (
 			orderitem.OrderID(orderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		First(ctx)
 	if err != nil {
 		return false, err
@@ -103,7 +106,7 @@ func (r *orderItemRepository) IsLatestIfOrderID(ctx context.Context, orderID, or
 			orderitem.OrderID(orderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		First(ctx)
 	if err != nil {
 		return false, err
@@ -118,7 +121,7 @@ func (r *orderItemRepository) GetLatestByOrderID(ctx context.Context, orderID in
 			orderitem.OrderID(orderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		First(ctx)
 	if err != nil {
 		return nil, err
@@ -138,6 +141,11 @@ func (r *orderItemRepository) GetLatestByOrderID(ctx context.Context, orderID in
 	if err := r.orderItemMaterialRepo.LoadLoaner(ctx, dto); err != nil {
 		return nil, err
 	}
+
+	// Files
+	if err := r.orderItemFileRepo.LoadFiles(ctx, dto); err != nil {
+		return nil, err
+	}
 	return dto, nil
 }
 
@@ -152,7 +160,7 @@ func (r *orderItemRepository) PrepareLatestForRemakeByOrderID(
 			orderitem.OrderID(orderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		First(ctx)
 	if err != nil {
 		return nil, err
@@ -229,13 +237,13 @@ func (r *orderItemRepository) GetHistoricalByOrderIDAndOrderItemID(
 	return out, nil
 }
 
-func (r *orderItemRepository) GetTotalPriceByOrderItemID(ctx context.Context, orderItemID int64) (float64, error) {
-	productTotal, err := r.orderItemProductRepo.GetTotalPriceByOrderItemID(ctx, orderItemID)
+func (r *orderItemRepository) GetTotalPriceByOrderItemID(ctx context.Context, tx *generated.Tx, orderItemID int64) (float64, error) {
+	productTotal, err := r.orderItemProductRepo.GetTotalPriceByOrderItemID(ctx, tx, orderItemID)
 	if err != nil {
 		return 0, nil
 	}
 
-	consumableMaterialTotal, err := r.orderItemMaterialRepo.GetConsumableTotalPriceByOrderItemID(ctx, orderItemID)
+	consumableMaterialTotal, err := r.orderItemMaterialRepo.GetConsumableTotalPriceByOrderItemID(ctx, tx, orderItemID)
 	if err != nil {
 		return 0, err
 	}
@@ -487,7 +495,12 @@ func (r *orderItemRepository) Create(ctx context.Context, tx *generated.Tx, orde
 	out.LoanerMaterials = createdLoanerMaterials
 
 	// processes
-	if len(products) > 0 {
+	if len(input.Routing) > 0 {
+		priority := utils.SafeGetString(entity.CustomFields, "priority")
+		if _, err := r.orderItemProcessRepo.CreateManyFromRouting(ctx, tx, entity.ID, entity.OrderID, entity.Code, &priority, input.Routing); err != nil {
+			return nil, err
+		}
+	} else if len(products) > 0 {
 		priority := utils.SafeGetString(entity.CustomFields, "priority")
 		productIDs := make([]int, 0, len(products))
 		for _, product := range products {
@@ -613,7 +626,7 @@ func (r *orderItemRepository) GetLatestOrderItemIDByOrderID(ctx context.Context,
 			orderitem.OrderID(orderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		Select(orderitem.FieldID).
 		First(ctx)
 	if err != nil {
@@ -676,6 +689,11 @@ func (r *orderItemRepository) GetByID(ctx context.Context, id int64) (*model.Ord
 		return nil, err
 	}
 
+	// Files
+	if err := r.orderItemFileRepo.LoadFiles(ctx, dto); err != nil {
+		return nil, err
+	}
+
 	return dto, nil
 }
 
@@ -708,6 +726,11 @@ func (r *orderItemRepository) List(ctx context.Context, query table.TableQuery)
 	if err := r.orderItemMaterialRepo.LoadLoaner(ctx, list.Items...); err != nil {
 		return list, err
 	}
+
+	// Files
+	if err := r.orderItemFileRepo.LoadFiles(ctx, list.Items...); err != nil {
+		return list, err
+	}
 	return list, nil
 }
 
@@ -808,7 +831,7 @@ func (r *orderItemRepository) Delete(ctx context.Context, id int64) error {
 			orderitem.OrderID(item.OrderID),
 			orderitem.DeletedAtIsNil(),
 		).
-		Order(generated.Desc(orderitem.FieldCreatedAt)).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt)).
 		Select(
 			orderitem.FieldCode,
 			orderitem.FieldCustomFields,
@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"maps"
 	"math"
+	"strings"
 	"time"
 
+	"entgo.io/ent/dialect/sql"
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
 	relation "github.com/khiemnd777/andy_api/modules/main/features/__relation/policy"
@@ -20,6 +22,7 @@ import (
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemmaterial"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemproduct"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderstatushistory"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
 	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
 	"github.com/khiemnd777/andy_api/shared/logger"
@@ -30,13 +33,24 @@ import (
 	"github.com/khiemnd777/andy_api/shared/utils/table"
 )
 
+var ErrOrderInvoiced = errors.New("order is locked by an issued invoice")
+
 type OrderRepository interface {
 	ExistsByCode(ctx context.Context, code string) (bool, error)
 	GetByOrderIDAndOrderItemID(ctx context.Context, orderID, orderItemID int64) (*model.OrderDTO, error)
 	UpdateStatus(ctx context.Context, orderItemProcessID int64, status string) (*model.OrderItemDTO, error)
+	StatusTransitionPermission(ctx context.Context, orderItemProcessID int64, status string) ([]string, error)
+	StatusHistory(ctx context.Context, orderID int64) ([]*model.OrderStatusHistoryDTO, error)
+	Timeline(ctx context.Context, orderID int64, query model.OrderTimelineQuery) (table.TableListResult[model.OrderTimelineEventDTO], error)
 	SyncPrice(ctx context.Context, orderID int64) (float64, error)
+	UpdateShippingFee(ctx context.Context, orderID int64, shippingFee float64) (*model.OrderDTO, error)
 	GetAllOrderProducts(ctx context.Context, orderID int64) ([]*model.OrderItemProductDTO, error)
 	GetAllOrderMaterials(ctx context.Context, orderID int64) ([]*model.OrderItemMaterialDTO, error)
+	Cancel(ctx context.Context, deptID, userID int, orderID int64, input *model.OrderCancelDTO) (*model.OrderCancellationDTO, error)
+	Cancellations(ctx context.Context, deptID int, orderID int64) ([]*model.OrderCancellationDTO, error)
+	Split(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemSplitDTO) (*model.OrderItemLineageDTO, error)
+	Merge(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemMergeDTO) (*model.OrderItemLineageDTO, error)
+	Lineage(ctx context.Context, orderItemID int64) ([]*model.OrderItemLineageDTO, error)
 	// -- general functions
 	Create(ctx context.Context, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error)
 	Update(ctx context.Context, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error)
@@ -73,7 +87,7 @@ func NewOrderRepository(
 	deps *module.ModuleDeps[config.ModuleConfig],
 	cfMgr *customfields.Manager,
 ) OrderRepository {
-	orderItemProductRepo := NewOrderItemProductRepository(db)
+	orderItemProductRepo := NewOrderItemProductRepository(db, deps)
 	promotionRepo := promotionrepo.NewPromotionRepository(db, deps.DB)
 	promoengine := engine.NewEngine(deps)
 	promoctxbuilder := contextbuilder.NewBuilder(orderItemProductRepo)
@@ -127,12 +141,118 @@ func (r *orderRepository) GetByOrderIDAndOrderItemID(ctx context.Context, orderI
 	return dto, nil
 }
 
+// SyncPrice recomputes the order total from its items. An invoiced order is
+// priced by its invoice, so its stored total is returned as is.
 func (r *orderRepository) SyncPrice(ctx context.Context, orderID int64) (float64, error) {
+	o, err := r.db.Order.Query().
+		Where(order.IDEQ(orderID)).
+		Select(order.FieldInvoiceID, order.FieldTotalPrice).
+		Only(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if o.InvoiceID != nil {
+		if o.TotalPrice == nil {
+			return 0, nil
+		}
+		return *o.TotalPrice, nil
+	}
 	return r.orderItemRepo.GetTotalPriceByOrderID(ctx, nil, orderID)
 }
 
+// UpdateShippingFee stores the order shipping fee and recomputes the order total
+// (products + shipping - promotion discount).
+func (r *orderRepository) UpdateShippingFee(ctx context.Context, orderID int64, shippingFee float64) (*model.OrderDTO, error) {
+	output, err := r.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	if err = r.ensureNotInvoiced(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+
+	if err = r.syncOrderTotal(ctx, tx, output, shippingFee); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// syncOrderTotal stores the shipping fee and the order total (products +
+// shipping - promotion discount) of output within tx.
+func (r *orderRepository) syncOrderTotal(ctx context.Context, tx *generated.Tx, output *model.OrderDTO, shippingFee float64) error {
+	output.ShippingFee = &shippingFee
+
+	totalPrice, err := r.orderItemRepo.GetTotalPriceByOrderID(ctx, tx, output.ID)
+	if err != nil {
+		return err
+	}
+	totalPrice += shippingFee
+
+	discountAmount, promoSnapshot := r.buildPromotionSnapshot(ctx, output)
+	if discountAmount > 0 {
+		totalPrice = math.Max(0, totalPrice-discountAmount)
+	}
+
+	if err := tx.Order.UpdateOneID(output.ID).
+		SetShippingFee(shippingFee).
+		SetTotalPrice(totalPrice).
+		Exec(ctx); err != nil {
+		return err
+	}
+	output.TotalPrice = &totalPrice
+
+	if promoSnapshot != nil {
+		return r.promotionRepo.UpsertPromotionUsageFromSnapshot(
+			ctx,
+			tx,
+			*output.PromotionCodeID,
+			output.ID,
+			output.RefUserID,
+			promoSnapshot,
+		)
+	}
+	return nil
+}
+
 // -- helpers
 
+func orderShippingFee(dto *model.OrderDTO) float64 {
+	if dto == nil || dto.ShippingFee == nil {
+		return 0
+	}
+	return *dto.ShippingFee
+}
+
+func (r *orderRepository) ensureNotInvoiced(ctx context.Context, tx *generated.Tx, orderID int64) error {
+	invoiced, err := tx.Order.
+		Query().
+		Where(
+			order.ID(orderID),
+			order.InvoiceIDNotNil(),
+		).
+		Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if invoiced {
+		return ErrOrderInvoiced
+	}
+	return nil
+}
+
 func (r *orderRepository) createNewOrder(
 	ctx context.Context,
 	tx *generated.Tx,
@@ -190,6 +310,11 @@ func (r *orderRepository) createNewOrder(
 	loi.DTO.OrderID = out.ID
 	loi.DTO.CodeOriginal = out.Code
 
+	// contract prices active at order creation
+	if err := r.orderItemProductRepo.ApplyContractPrices(ctx, orderEnt, loi.DTO.Products); err != nil {
+		return nil, err
+	}
+
 	latest, err := r.orderItemRepo.Create(ctx, tx, out, loi)
 	if err != nil {
 		return nil, err
@@ -210,7 +335,7 @@ func (r *orderRepository) createNewOrder(
 	if err != nil {
 		return nil, err
 	}
-	prdTotalPrice := totalPrice
+	prdTotalPrice := totalPrice + orderShippingFee(out)
 
 	discountAmount, promoSnapshot := r.buildPromotionSnapshot(ctx, out)
 	if discountAmount > 0 {
@@ -301,6 +426,38 @@ func (r *orderRepository) createNewOrder(
 	return out, nil
 }
 
+// createRemakeLog records a new item on an existing order as an adjustment or
+// a remake of the previous item, with the reason taken from the custom fields.
+func (r *orderRepository) createRemakeLog(
+	ctx context.Context,
+	tx *generated.Tx,
+	userID int,
+	orderEnt *generated.Order,
+	item *model.OrderItemDTO,
+) error {
+	if item == nil || item.RemakeCount == 0 {
+		return nil
+	}
+
+	action := "remake"
+	if rmkType := utils.SafeGetStringPtr(item.CustomFields, "remake_type"); rmkType != nil && strings.EqualFold(*rmkType, "adjust") {
+		action = "adjust"
+	}
+
+	reason := utils.SafeGetStringPtr(item.CustomFields, "remake_reason")
+	if reason == nil {
+		reason = utils.SafeGetStringPtr(orderEnt.CustomFields, "remake_reason")
+	}
+
+	byUser := int64(userID)
+	return tx.OrderItemRemakeLog.Create().
+		SetItemID(item.ID).
+		SetAction(action).
+		SetNillableReason(reason).
+		SetByUser(byUser).
+		Exec(ctx)
+}
+
 func (r *orderRepository) upsertExistingOrder(
 	ctx context.Context,
 	tx *generated.Tx,
@@ -318,6 +475,9 @@ func (r *orderRepository) upsertExistingOrder(
 	if err != nil {
 		return nil, err
 	}
+	if orderEnt.InvoiceID != nil {
+		return nil, ErrOrderInvoiced
+	}
 
 	// UPDATE ORDER (custom fields + m2m + 1)
 	up := tx.Order.UpdateOneID(orderEnt.ID).
@@ -342,11 +502,20 @@ func (r *orderRepository) upsertExistingOrder(
 	loi.DTO.OrderID = out.ID
 	loi.DTO.CodeOriginal = out.Code
 
+	// contract prices active at order creation
+	if err := r.orderItemProductRepo.ApplyContractPrices(ctx, orderEnt, loi.DTO.Products); err != nil {
+		return nil, err
+	}
+
 	latest, err := r.orderItemRepo.Create(ctx, tx, out, loi)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := r.createRemakeLog(ctx, tx, userID, orderEnt, latest); err != nil {
+		return nil, err
+	}
+
 	out.LatestOrderItem = latest
 
 	// reassign latest order item -> order as cache to appear them on the table
@@ -360,7 +529,7 @@ func (r *orderRepository) upsertExistingOrder(
 	if err != nil {
 		return nil, err
 	}
-	prdTotalPrice := totalPrice
+	prdTotalPrice := totalPrice + orderShippingFee(out)
 
 	discountAmount, promoSnapshot := r.buildPromotionSnapshot(ctx, out)
 	if discountAmount > 0 {
@@ -484,6 +653,10 @@ func (r *orderRepository) Update(
 
 	output := &input.DTO
 
+	if err = r.ensureNotInvoiced(ctx, tx, output.ID); err != nil {
+		return nil, err
+	}
+
 	q := tx.Order.UpdateOneID(output.ID).
 		SetNillableClinicID(output.ClinicID).
 		SetNillableClinicName(output.ClinicName).
@@ -517,6 +690,13 @@ func (r *orderRepository) Update(
 
 	output = mapper.MapAs[*generated.Order, *model.OrderDTO](entity)
 
+	// contract prices active at order creation
+	if loi := input.DTO.LatestOrderItemUpsert; loi != nil {
+		if err = r.orderItemProductRepo.ApplyContractPrices(ctx, entity, loi.DTO.Products); err != nil {
+			return nil, err
+		}
+	}
+
 	// ===== Update latest order item
 	latest, err := r.orderItemRepo.Update(
 		ctx,
@@ -555,6 +735,7 @@ func (r *orderRepository) Update(
 		if err != nil {
 			return nil, err
 		}
+		totalPrice += orderShippingFee(output)
 
 		discountAmount, promoSnapshot := r.buildPromotionSnapshot(ctx, output)
 
@@ -677,31 +858,37 @@ func (r *orderRepository) UpdateStatus(ctx context.Context, orderItemProcessID i
 		}
 	}()
 
-	_, err = r.orderItemProcessRepo.UpdateStatus(ctx, tx, orderItemProcessID, status)
+	oip, err := tx.OrderItemProcess.
+		Query().
+		Where(orderitemprocess.IDEQ(orderItemProcessID)).
+		Only(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get oip from memory to ensure CF == latest status, because not yet Committed to db
-	updatedOIP, err := tx.OrderItemProcess.
+	if oip.OrderID == nil {
+		err = fmt.Errorf("OrderID is nil for process %d", orderItemProcessID)
+		return nil, err
+	}
+
+	processes, err := tx.OrderItemProcess.
 		Query().
-		Where(orderitemprocess.IDEQ(orderItemProcessID)).
-		First(ctx)
+		Where(orderitemprocess.OrderItemID(oip.OrderItemID)).
+		All(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if updatedOIP.OrderID == nil {
-		err = fmt.Errorf("OrderID is nil after updating process")
+	if err = checkStatusTransition(StatusEntityProcess, processStatus(oip), status, processes, oip); err != nil {
 		return nil, err
 	}
 
-	processes, err := r.orderItemProcessRepo.GetProcessesByOrderItemID(ctx, tx, updatedOIP.OrderItemID)
+	_, err = r.orderItemProcessRepo.UpdateStatus(ctx, tx, orderItemProcessID, status)
 	if err != nil {
 		return nil, err
 	}
 
-	orderDTO, err := r.recalculateOrderStatusByProcesses(ctx, tx, *updatedOIP.OrderID, updatedOIP.OrderItemID, processes)
+	orderDTO, err := r.recalculateOrderStatus(ctx, tx, *oip.OrderID, oip.OrderItemID)
 	if err != nil {
 		return nil, err
 	}
@@ -709,76 +896,40 @@ func (r *orderRepository) UpdateStatus(ctx context.Context, orderItemProcessID i
 	return orderDTO, nil
 }
 
-func (r *orderRepository) recalculateOrderStatusByProcesses(
-	ctx context.Context,
-	tx *generated.Tx,
-	orderID,
-	orderItemID int64,
-	processes []*model.OrderItemProcessDTO,
-) (*model.OrderItemDTO, error) {
-
-	if len(processes) == 0 {
-		return nil, fmt.Errorf("no processes found for order %d", orderItemID)
-	}
-
-	allWaiting := true
-	allCompleted := true
-	anyInProgress := false
-
-	for _, p := range processes {
-		status := utils.SafeGetString(p.CustomFields, "status")
-
-		switch status {
-		case "waiting":
-		case "completed":
-			allWaiting = false
-		case "in_progress", "qc", "rework":
-			allWaiting = false
-			allCompleted = false
-			anyInProgress = true
-		default:
-			allWaiting = false
-			allCompleted = false
-		}
-
-		if status != "waiting" {
-			allWaiting = false
-		}
-		if status != "completed" {
-			allCompleted = false
-		}
-	}
-
-	var orderStatus string
-
-	switch {
-	case allWaiting:
-		orderStatus = "received"
-
-	case anyInProgress:
-		orderStatus = "in_progress"
-
-	case allCompleted:
-		orderStatus = "completed"
-
-	default:
-		orderStatus = "in_progress"
+func (r *orderRepository) StatusTransitionPermission(ctx context.Context, orderItemProcessID int64, status string) ([]string, error) {
+	oip, err := r.db.OrderItemProcess.
+		Query().
+		Where(orderitemprocess.IDEQ(orderItemProcessID)).
+		Select(orderitemprocess.FieldCustomFields).
+		Only(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return ProcessTransitionPermission(processStatus(oip), status)
+}
 
-	oi, err := tx.OrderItem.Query().
-		Where(orderitem.IDEQ(orderItemID)).
-		First(ctx)
+func (r *orderRepository) StatusHistory(ctx context.Context, orderID int64) ([]*model.OrderStatusHistoryDTO, error) {
+	rows, err := r.db.OrderStatusHistory.
+		Query().
+		Where(orderstatushistory.OrderIDEQ(orderID)).
+		Order(
+			orderstatushistory.ByCreatedAt(sql.OrderDesc()),
+			orderstatushistory.ByID(sql.OrderDesc()),
+		).
+		All(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return mapper.MapListAs[*generated.OrderStatusHistory, *model.OrderStatusHistoryDTO](rows), nil
+}
 
-	cf := maps.Clone(oi.CustomFields)
-	cf["status"] = orderStatus
-
-	updated, err := tx.OrderItem.
-		UpdateOne(oi).
-		SetCustomFields(cf).
-		Save(ctx)
+func (r *orderRepository) recalculateOrderStatus(
+	ctx context.Context,
+	tx *generated.Tx,
+	orderID,
+	orderItemID int64,
+) (*model.OrderItemDTO, error) {
+	_, updated, err := syncItemStatus(ctx, tx, orderItemID, &orderID)
 	if err != nil {
 		return nil, err
 	}
@@ -786,10 +937,6 @@ func (r *orderRepository) recalculateOrderStatusByProcesses(
 	dto := mapper.MapAs[*generated.OrderItem, *model.OrderItemDTO](updated)
 	dto.TotalPrice = updated.TotalPrice
 
-	tx.Order.UpdateOneID(orderID).
-		SetNillableStatusLatest(&orderStatus).
-		Save(ctx)
-
 	return dto, nil
 }
 
@@ -1064,6 +1211,8 @@ func (r *orderRepository) GetAllOrderProducts(ctx context.Context, orderID int64
 			orderitemproduct.FieldProductCode,
 			orderitemproduct.FieldQuantity,
 			orderitemproduct.FieldRetailPrice,
+			orderitemproduct.FieldTeethPosition,
+			orderitemproduct.FieldToothChart,
 		).
 		WithOrderItem(func(q *generated.OrderItemQuery) {
 			q.Select(orderitem.FieldID, orderitem.FieldCode)
@@ -1092,6 +1241,7 @@ func (r *orderRepository) GetAllOrderProducts(ctx context.Context, orderID int64
 			Note:                it.Note,
 			RetailPrice:         it.RetailPrice,
 			TeethPosition:       it.TeethPosition,
+			ToothChart:          it.ToothChart,
 		}
 		if it.Edges.OrderItem != nil {
 			dto.OrderItemCode = it.Edges.OrderItem.Code
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
+)
+
+// Status state machine of orders, order items and their processes.
+//
+// Process statuses are moved by check-in/out and by hand through the
+// change-status endpoint. Hand moves and the completion made by a check-out
+// are checked against the table below; a check-in starts or reworks the step
+// the workflow has already routed to. Order item and order statuses are
+// always derived from the processes, and the derived move must be declared.
+// Cancellation and hold are the moves made directly on items. Cancellation is
+// terminal, so a cancelled item no longer derives a status and its open steps
+// are closed as cancelled. A held item keeps "on_hold" until it is resumed,
+// when its status is derived again. Every effective change is written to
+// order_status_histories.
+
+const (
+	StatusEntityOrder     = "order"
+	StatusEntityOrderItem = "order_item"
+	StatusEntityProcess   = "order_item_process"
+)
+
+var (
+	ErrInvalidStatusTransition = errors.New("status transition is not allowed")
+	ErrStatusTransitionBlocked = errors.New("status transition is blocked")
+)
+
+// statusGuard vetoes a transition; target is nil for order item transitions.
+type statusGuard func(processes []*generated.OrderItemProcess, target *generated.OrderItemProcess) error
+
+type StatusTransition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// permission needed to make the move by hand; empty for derived moves
+	Permission string `json:"permission,omitempty"`
+	guard      statusGuard
+}
+
+var processTransitions = []StatusTransition{
+	{From: "waiting", To: "in_progress", Permission: "order.development", guard: guardPredecessorsDone},
+	{From: "waiting", To: "skipped", Permission: "order.update"},
+	{From: "skipped", To: "waiting", Permission: "order.update"},
+	{From: "in_progress", To: "paused", Permission: "order.development"},
+	{From: "in_progress", To: "qc", Permission: "order.development"},
+	{From: "in_progress", To: "issue", Permission: "order.development"},
+	{From: "in_progress", To: "completed", Permission: "order.development", guard: guardNoFailedQC},
+	{From: "paused", To: "in_progress", Permission: "order.development"},
+	{From: "qc", To: "completed", Permission: "order.qc", guard: guardNoFailedQC},
+	{From: "qc", To: "issue", Permission: "order.qc"},
+	{From: "qc", To: "rework", Permission: "order.qc"},
+	{From: "issue", To: "rework", Permission: "order.development"},
+	{From: "issue", To: "in_progress", Permission: "order.development"},
+	{From: "rework", To: "paused", Permission: "order.development"},
+	{From: "rework", To: "qc", Permission: "order.development"},
+	{From: "rework", To: "issue", Permission: "order.development"},
+	{From: "rework", To: "completed", Permission: "order.development", guard: guardNoFailedQC},
+	{From: "completed", To: "rework", Permission: "order.qc"},
+}
+
+// order items and orders share the same derived lifecycle
+var itemTransitions = []StatusTransition{
+	{From: "received", To: "in_progress"},
+	{From: "received", To: "completed"},
+	{From: "in_progress", To: "received"},
+	{From: "in_progress", To: "completed"},
+	{From: "completed", To: "in_progress"},
+	{From: "completed", To: "received"},
+	{From: "received", To: "cancelled", Permission: "order.cancel"},
+	{From: "in_progress", To: "cancelled", Permission: "order.cancel"},
+	{From: "completed", To: "cancelled", Permission: "order.cancel"},
+	{From: "received", To: "on_hold", Permission: "order.hold"},
+	{From: "in_progress", To: "on_hold", Permission: "order.hold"},
+	{From: "on_hold", To: "received", Permission: "order.hold"},
+	{From: "on_hold", To: "in_progress", Permission: "order.hold"},
+	{From: "on_hold", To: "completed", Permission: "order.hold"},
+	{From: "on_hold", To: "cancelled", Permission: "order.cancel"},
+}
+
+func StatusTransitions(entity string) []StatusTransition {
+	switch entity {
+	case StatusEntityProcess:
+		return processTransitions
+	case StatusEntityOrderItem, StatusEntityOrder:
+		return itemTransitions
+	}
+	return nil
+}
+
+func findStatusTransition(entity, from, to string) *StatusTransition {
+	for i, t := range StatusTransitions(entity) {
+		if t.From == from && t.To == to {
+			return &StatusTransitions(entity)[i]
+		}
+	}
+	return nil
+}
+
+// ProcessTransitionPermission returns the permissions that allow moving a
+// process from one status to another by hand. Staying in the same status is
+// allowed, as in checkStatusTransition, to anyone who may move the process
+// out of it.
+func ProcessTransitionPermission(from, to string) ([]string, error) {
+	if from == to {
+		var perms []string
+		for _, t := range processTransitions {
+			if t.From == from && !slices.Contains(perms, t.Permission) {
+				perms = append(perms, t.Permission)
+			}
+		}
+		if len(perms) == 0 {
+			perms = append(perms, "order.update")
+		}
+		return perms, nil
+	}
+	t := findStatusTransition(StatusEntityProcess, from, to)
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, from, to)
+	}
+	return []string{t.Permission}, nil
+}
+
+// checkStatusTransition validates a move and runs its guard. Staying in the
+// same status is always allowed.
+func checkStatusTransition(
+	entity, from, to string,
+	processes []*generated.OrderItemProcess,
+	target *generated.OrderItemProcess,
+) error {
+	if from == to {
+		return nil
+	}
+	t := findStatusTransition(entity, from, to)
+	if t == nil {
+		return fmt.Errorf("%w: %s %s -> %s", ErrInvalidStatusTransition, entity, from, to)
+	}
+	if t.guard != nil {
+		return t.guard(processes, target)
+	}
+	return nil
+}
+
+func guardPredecessorsDone(processes []*generated.OrderItemProcess, target *generated.OrderItemProcess) error {
+	if target != nil && !isUnblocked(processes, target) {
+		return fmt.Errorf("%w: previous steps are not done", ErrStatusTransitionBlocked)
+	}
+	return nil
+}
+
+// a step in "issue" is a failed QC check that still has to be reworked before
+// another step of the item may be completed
+func guardNoFailedQC(processes []*generated.OrderItemProcess, _ *generated.OrderItemProcess) error {
+	for _, p := range processes {
+		if processStatus(p) == "issue" {
+			return fmt.Errorf("%w: a QC check has failed", ErrStatusTransitionBlocked)
+		}
+	}
+	return nil
+}
+
+// deriveItemStatus computes the order item status from its processes.
+func deriveItemStatus(processes []*generated.OrderItemProcess) string {
+	allWaiting := true
+	allCompleted := true
+	anyInProgress := false
+
+	for _, p := range processes {
+		status := processStatus(p)
+		// optional steps that do not apply to the item
+		if status == "skipped" {
+			continue
+		}
+		if status != "waiting" {
+			allWaiting = false
+		}
+		if status != "completed" {
+			allCompleted = false
+		}
+		if isStepActive(status) {
+			anyInProgress = true
+		}
+	}
+
+	switch {
+	case allWaiting:
+		return "received"
+	case anyInProgress:
+		return "in_progress"
+	case allCompleted:
+		return "completed"
+	}
+	return "in_progress"
+}
+
+// syncItemStatus derives the order item status from its processes, checks
+// the move against the state machine and stores it on the item and, when it
+// is the latest item, on the order, recording both transitions.
+func syncItemStatus(
+	ctx context.Context,
+	tx *generated.Tx,
+	orderItemID int64,
+	orderID *int64,
+) (*string, *generated.OrderItem, error) {
+	processes, err := tx.OrderItemProcess.
+		Query().
+		Where(orderitemprocess.OrderItemID(orderItemID)).
+		All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(processes) == 0 {
+		return nil, nil, fmt.Errorf("no processes found for order item %d", orderItemID)
+	}
+
+	status := deriveItemStatus(processes)
+
+	item, err := tx.OrderItem.
+		Query().
+		Where(orderitem.IDEQ(orderItemID)).
+		Only(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if orderID == nil {
+		oid := item.OrderID
+		orderID = &oid
+	}
+
+	from := item.Status
+	if s, ok := item.CustomFields["status"].(string); ok && s != "" {
+		from = s
+	}
+	// steps checked out while on hold are picked up on resume
+	if from == statusOnHold {
+		return &from, item, nil
+	}
+	if err := checkStatusTransition(StatusEntityOrderItem, from, status, processes, nil); err != nil {
+		return nil, nil, err
+	}
+
+	cf := maps.Clone(item.CustomFields)
+	if cf == nil {
+		cf = make(map[string]any)
+	}
+	cf["status"] = status
+
+	updated, err := tx.OrderItem.
+		UpdateOneID(orderItemID).
+		SetCustomFields(cf).
+		SetStatus(status).
+		Save(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := recordStatusChange(ctx, tx, StatusEntityOrderItem, orderItemID, orderID, &orderItemID, &from, status); err != nil {
+		return nil, nil, err
+	}
+
+	// the order follows its latest item
+	latest, err := tx.OrderItem.Query().
+		Where(
+			orderitem.OrderIDEQ(*orderID),
+			orderitem.DeletedAtIsNil(),
+		).
+		Order(splitOffLast, generated.Desc(orderitem.FieldCreatedAt), generated.Desc(orderitem.FieldID)).
+		First(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latest.ID != orderItemID {
+		return &status, updated, nil
+	}
+
+	ord, err := tx.Order.Get(ctx, *orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ord.StatusLatest != nil && *ord.StatusLatest != "" {
+		if err := checkStatusTransition(StatusEntityOrder, *ord.StatusLatest, status, processes, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := tx.Order.UpdateOneID(*orderID).
+		SetStatusLatest(status).
+		Save(ctx); err != nil {
+		return nil, nil, err
+	}
+	if err := recordStatusChange(ctx, tx, StatusEntityOrder, *orderID, orderID, &orderItemID, ord.StatusLatest, status); err != nil {
+		return nil, nil, err
+	}
+
+	return &status, updated, nil
+}
+
+type statusActorKey struct{}
+
+// WithStatusActor tags ctx with the user making status changes so the
+// history can tell who moved a case.
+func WithStatusActor(ctx context.Context, userID int) context.Context {
+	if userID <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, statusActorKey{}, userID)
+}
+
+func statusActor(ctx context.Context) *int {
+	if v, ok := ctx.Value(statusActorKey{}).(int); ok {
+		return &v
+	}
+	return nil
+}
+
+// recordStatusChange appends a history row unless the status did not change.
+func recordStatusChange(
+	ctx context.Context,
+	tx *generated.Tx,
+	entity string,
+	entityID int64,
+	orderID *int64,
+	orderItemID *int64,
+	from *string,
+	to string,
+) error {
+	if to == "" || (from != nil && *from == to) {
+		return nil
+	}
+	if from != nil && *from == "" {
+		from = nil
+	}
+
+	return tx.OrderStatusHistory.
+		Create().
+		SetEntity(entity).
+		SetEntityID(entityID).
+		SetNillableOrderID(orderID).
+		SetNillableOrderItemID(orderItemID).
+		SetNillableFromStatus(from).
+		SetToStatus(to).
+		SetNillableChangedBy(statusActor(ctx)).
+		Exec(ctx)
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+)
+
+// testProcess builds an order item step of master process processID; without
+// dependsOn the step is routed linearly.
+func testProcess(id int64, processID int, status string, dependsOn ...int) *generated.OrderItemProcess {
+	return &generated.OrderItemProcess{
+		ID:           id,
+		ProcessID:    &processID,
+		DependsOn:    dependsOn,
+		CustomFields: map[string]any{"status": status},
+	}
+}
+
+func TestCheckStatusTransition(t *testing.T) {
+	design := testProcess(1, 10, "completed")
+	mill := testProcess(2, 20, "waiting", 10)
+	blocked := testProcess(3, 30, "waiting", 20)
+	failed := testProcess(4, 40, "issue")
+	glaze := testProcess(5, 50, "in_progress")
+	steps := []*generated.OrderItemProcess{design, mill, blocked}
+
+	tests := []struct {
+		name      string
+		entity    string
+		from, to  string
+		processes []*generated.OrderItemProcess
+		target    *generated.OrderItemProcess
+		expected  error
+	}{
+		{"same status", StatusEntityProcess, "qc", "qc", nil, nil, nil},
+		{"declared move", StatusEntityProcess, "in_progress", "qc", nil, nil, nil},
+		{"undeclared move", StatusEntityProcess, "waiting", "completed", nil, nil, ErrInvalidStatusTransition},
+		{"unknown entity", "invoice", "draft", "issued", nil, nil, ErrInvalidStatusTransition},
+		{"predecessors done", StatusEntityProcess, "waiting", "in_progress", steps, mill, nil},
+		{"predecessor pending", StatusEntityProcess, "waiting", "in_progress", steps, blocked, ErrStatusTransitionBlocked},
+		{"complete with failed qc", StatusEntityProcess, "in_progress", "completed", []*generated.OrderItemProcess{failed, glaze}, glaze, ErrStatusTransitionBlocked},
+		{"rework with failed qc", StatusEntityProcess, "rework", "completed", []*generated.OrderItemProcess{failed, glaze}, glaze, ErrStatusTransitionBlocked},
+		{"complete without failed qc", StatusEntityProcess, "in_progress", "completed", []*generated.OrderItemProcess{design, glaze}, glaze, nil},
+		{"qc passes", StatusEntityProcess, "qc", "completed", nil, nil, nil},
+		{"qc passes with another failed qc", StatusEntityProcess, "qc", "completed", []*generated.OrderItemProcess{failed, glaze}, glaze, ErrStatusTransitionBlocked},
+		{"check-out of a failed step", StatusEntityProcess, "issue", "completed", []*generated.OrderItemProcess{failed}, failed, ErrInvalidStatusTransition},
+		{"item starts", StatusEntityOrderItem, "received", "in_progress", nil, nil, nil},
+		{"item cancelled", StatusEntityOrderItem, "completed", "cancelled", nil, nil, nil},
+		{"cancelled is terminal", StatusEntityOrderItem, "cancelled", "in_progress", nil, nil, ErrInvalidStatusTransition},
+		{"order follows item table", StatusEntityOrder, "in_progress", "completed", nil, nil, nil},
+		{"order cannot leave cancelled", StatusEntityOrder, "cancelled", "received", nil, nil, ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		err := checkStatusTransition(tt.entity, tt.from, tt.to, tt.processes, tt.target)
+		if tt.expected == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.expected != nil && !errors.Is(err, tt.expected) {
+			t.Errorf("%s: err = %v; want %v", tt.name, err, tt.expected)
+		}
+	}
+}
+
+func TestProcessTransitionPermission(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected []string
+		ok       bool
+	}{
+		{"waiting", "in_progress", []string{"order.development"}, true},
+		{"qc", "completed", []string{"order.qc"}, true},
+		{"completed", "rework", []string{"order.qc"}, true},
+		{"qc", "qc", []string{"order.qc"}, true},
+		{"waiting", "waiting", []string{"order.development", "order.update"}, true},
+		{"unknown", "unknown", []string{"order.update"}, true},
+		{"waiting", "completed", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ProcessTransitionPermission(tt.from, tt.to)
+		if (err == nil) != tt.ok {
+			t.Errorf("ProcessTransitionPermission(%q, %q) err = %v; want ok %v", tt.from, tt.to, err, tt.ok)
+			continue
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("ProcessTransitionPermission(%q, %q) = %v; want %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+// every same-status request accepted by the permission check must pass the
+// transition check too, and the other way around
+func TestSameStatusAgrees(t *testing.T) {
+	for _, tr := range processTransitions {
+		for _, status := range []string{tr.From, tr.To} {
+			if _, err := ProcessTransitionPermission(status, status); err != nil {
+				t.Errorf("ProcessTransitionPermission(%q, %q): %v", status, status, err)
+			}
+			if err := checkStatusTransition(StatusEntityProcess, status, status, nil, nil); err != nil {
+				t.Errorf("checkStatusTransition(%q, %q): %v", status, status, err)
+			}
+		}
+	}
+}
+
+func TestDeriveItemStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		expected string
+	}{
+		{"all waiting", []string{"waiting", "waiting"}, "received"},
+		{"waiting and skipped", []string{"waiting", "skipped"}, "received"},
+		{"one started", []string{"completed", "in_progress", "waiting"}, "in_progress"},
+		{"in qc", []string{"completed", "qc"}, "in_progress"},
+		{"in rework", []string{"rework", "completed"}, "in_progress"},
+		{"paused", []string{"completed", "paused"}, "in_progress"},
+		{"failed qc", []string{"completed", "issue"}, "in_progress"},
+		{"between steps", []string{"completed", "waiting"}, "in_progress"},
+		{"all completed", []string{"completed", "completed"}, "completed"},
+		{"completed and skipped", []string{"completed", "skipped"}, "completed"},
+	}
+
+	for _, tt := range tests {
+		processes := make([]*generated.OrderItemProcess, 0, len(tt.statuses))
+		for i, s := range tt.statuses {
+			processes = append(processes, testProcess(int64(i+1), i+1, s))
+		}
+		if got := deriveItemStatus(processes); got != tt.expected {
+			t.Errorf("%s: deriveItemStatus = %q; want %q", tt.name, got, tt.expected)
+		}
+	}
+}
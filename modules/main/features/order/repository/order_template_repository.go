@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/ordertemplate"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type OrderTemplateRepository interface {
+	List(ctx context.Context, deptID int, clinicID, dentistID *int) ([]*model.OrderTemplateDTO, error)
+	GetByID(ctx context.Context, deptID int, id int) (*model.OrderTemplateDTO, error)
+	Create(ctx context.Context, deptID, userID int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+}
+
+type orderTemplateRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderTemplateRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) OrderTemplateRepository {
+	return &orderTemplateRepository{db: db, deps: deps}
+}
+
+// content is stored as plain JSON so the template keeps whatever the order
+// DTOs carry at the time it is saved.
+func templateContentToMap(c *model.OrderTemplateContentDTO) (map[string]any, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]any{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func mapOrderTemplate(e *generated.OrderTemplate) (*model.OrderTemplateDTO, error) {
+	dto := &model.OrderTemplateDTO{
+		ID:           e.ID,
+		DepartmentID: e.DepartmentID,
+		Name:         e.Name,
+		ClinicID:     e.ClinicID,
+		ClinicName:   e.ClinicName,
+		DentistID:    e.DentistID,
+		DentistName:  e.DentistName,
+		CreatedBy:    e.CreatedBy,
+		CreatedAt:    e.CreatedAt,
+		UpdatedAt:    e.UpdatedAt,
+	}
+	if len(e.Content) > 0 {
+		b, err := json.Marshal(e.Content)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &dto.Content); err != nil {
+			return nil, err
+		}
+	}
+	return dto, nil
+}
+
+func (r *orderTemplateRepository) List(ctx context.Context, deptID int, clinicID, dentistID *int) ([]*model.OrderTemplateDTO, error) {
+	q := r.db.OrderTemplate.Query().
+		Where(
+			ordertemplate.DepartmentIDEQ(deptID),
+			ordertemplate.DeletedAtIsNil(),
+		)
+	if clinicID != nil {
+		q = q.Where(ordertemplate.ClinicIDEQ(*clinicID))
+	}
+	if dentistID != nil {
+		q = q.Where(ordertemplate.DentistIDEQ(*dentistID))
+	}
+	list, err := q.
+		Order(generated.Asc(ordertemplate.FieldName)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*model.OrderTemplateDTO, 0, len(list))
+	for _, e := range list {
+		dto, err := mapOrderTemplate(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, nil
+}
+
+func (r *orderTemplateRepository) GetByID(ctx context.Context, deptID int, id int) (*model.OrderTemplateDTO, error) {
+	entity, err := r.db.OrderTemplate.Query().
+		Where(
+			ordertemplate.IDEQ(id),
+			ordertemplate.DepartmentIDEQ(deptID),
+			ordertemplate.DeletedAtIsNil(),
+		).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderTemplate(entity)
+}
+
+func (r *orderTemplateRepository) Create(ctx context.Context, deptID, userID int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error) {
+	content, err := templateContentToMap(&input.Content)
+	if err != nil {
+		return nil, err
+	}
+	entity, err := r.db.OrderTemplate.Create().
+		SetDepartmentID(deptID).
+		SetName(input.Name).
+		SetNillableClinicID(input.ClinicID).
+		SetNillableClinicName(input.ClinicName).
+		SetNillableDentistID(input.DentistID).
+		SetNillableDentistName(input.DentistName).
+		SetContent(content).
+		SetCreatedBy(userID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderTemplate(entity)
+}
+
+func (r *orderTemplateRepository) Update(ctx context.Context, deptID int, id int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error) {
+	content, err := templateContentToMap(&input.Content)
+	if err != nil {
+		return nil, err
+	}
+	up := r.db.OrderTemplate.UpdateOneID(id).
+		Where(
+			ordertemplate.DepartmentIDEQ(deptID),
+			ordertemplate.DeletedAtIsNil(),
+		).
+		SetName(input.Name).
+		SetContent(content)
+
+	if input.ClinicID != nil {
+		up.SetClinicID(*input.ClinicID).SetNillableClinicName(input.ClinicName)
+	} else {
+		up.ClearClinicID().ClearClinicName()
+	}
+	if input.DentistID != nil {
+		up.SetDentistID(*input.DentistID).SetNillableDentistName(input.DentistName)
+	} else {
+		up.ClearDentistID().ClearDentistName()
+	}
+
+	entity, err := up.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapOrderTemplate(entity)
+}
+
+func (r *orderTemplateRepository) Delete(ctx context.Context, deptID int, id int) error {
+	return r.db.OrderTemplate.UpdateOneID(id).
+		Where(
+			ordertemplate.DepartmentIDEQ(deptID),
+			ordertemplate.DeletedAtIsNil(),
+		).
+		SetDeletedAt(time.Now()).
+		Exec(ctx)
+}
@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+	"github.com/lib/pq"
+)
+
+// Order timeline: one chronological feed of everything that happened to an
+// order, read straight from the tables that already record each kind of event.
+// Price syncs and custom field edits are only kept in audit_logs, as is undo.
+// Splits and merges show on both orders they touch. An in-progress row opened
+// while the previous row of the same process was left open with a hand-over
+// note is an assignment rather than a check-in.
+
+var OrderTimelineEventTypes = []string{
+	"check_in",
+	"check_out",
+	"assign",
+	"status_change",
+	"remake",
+	"adjust",
+	"price_sync",
+	"promotion",
+	"file_upload",
+	"custom_field_edit",
+	"undo",
+	"cancel",
+	"split",
+	"merge",
+}
+
+const orderTimelineSQL = `
+WITH ip AS (
+  SELECT
+    ip.*,
+    oip.process_name,
+    COALESCE(prev.completed_at IS NULL AND prev.check_out_note LIKE '➡%', false) AS is_assign
+  FROM order_item_process_in_progresses ip
+  LEFT JOIN order_item_processes oip ON oip.id = ip.process_id
+  LEFT JOIN LATERAL (
+    SELECT p.completed_at, p.check_out_note
+    FROM order_item_process_in_progresses p
+    WHERE p.process_id = ip.process_id AND p.id < ip.id
+    ORDER BY p.id DESC
+    LIMIT 1
+  ) prev ON true
+  WHERE ip.order_id = $1
+),
+events AS (
+  SELECT
+    CASE WHEN ip.is_assign THEN 'assign' ELSE 'check_in' END AS type,
+    ip.started_at AS at,
+    ip.assigned_id AS actor_id,
+    ip.assigned_name AS actor_name,
+    ip.order_item_id,
+    ip.order_item_code,
+    ip.process_id,
+    ip.process_name,
+    jsonb_build_object(
+      'in_progress_id', ip.id,
+      'section_name', ip.section_name,
+      'note', ip.check_in_note
+    ) AS data
+  FROM ip
+  WHERE ip.started_at IS NOT NULL
+
+  UNION ALL
+
+  SELECT
+    'check_out',
+    ip.completed_at,
+    ip.assigned_id,
+    ip.assigned_name,
+    ip.order_item_id,
+    ip.order_item_code,
+    ip.process_id,
+    ip.process_name,
+    jsonb_build_object(
+      'in_progress_id', ip.id,
+      'next_process_id', ip.next_process_id,
+      'next_process_name', ip.next_process_name,
+      'note', ip.check_out_note
+    )
+  FROM ip
+  WHERE ip.completed_at IS NOT NULL
+
+  UNION ALL
+
+  SELECT
+    'status_change',
+    h.created_at,
+    h.changed_by::bigint,
+    NULL,
+    h.order_item_id,
+    oi.code,
+    CASE WHEN h.entity = 'order_item_process' THEN h.entity_id END,
+    oip.process_name,
+    jsonb_build_object(
+      'entity', h.entity,
+      'entity_id', h.entity_id,
+      'from_status', h.from_status,
+      'to_status', h.to_status
+    )
+  FROM order_status_histories h
+  LEFT JOIN order_items oi ON oi.id = h.order_item_id
+  LEFT JOIN order_item_processes oip ON h.entity = 'order_item_process' AND oip.id = h.entity_id
+  WHERE h.order_id = $1
+
+  UNION ALL
+
+  SELECT
+    rl.action,
+    rl.created_at,
+    rl.by_user,
+    NULL,
+    rl.item_id,
+    oi.code,
+    NULL,
+    NULL,
+    jsonb_build_object('reason', rl.reason)
+  FROM order_item_remake_logs rl
+  JOIN order_items oi ON oi.id = rl.item_id
+  WHERE oi.order_id = $1
+
+  UNION ALL
+
+  SELECT
+    'promotion',
+    COALESCE(pu.used_at, pu.applied_at),
+    pu.user_id::bigint,
+    NULL,
+    NULL,
+    NULL,
+    NULL,
+    NULL,
+    jsonb_build_object(
+      'promo_code_id', pu.promo_code_id,
+      'promo_code', pu.promo_code,
+      'discount_type', pu.discount_type,
+      'discount_value', pu.discount_value,
+      'discount_amount', pu.discount_amount,
+      'is_remake', pu.is_remake
+    )
+  FROM promotion_usages pu
+  WHERE pu.order_id = $1
+
+  UNION ALL
+
+  SELECT
+    'cancel',
+    oc.created_at,
+    oc.cancelled_by::bigint,
+    NULL,
+    NULL,
+    NULL,
+    NULL,
+    NULL,
+    jsonb_build_object(
+      'cancellation_id', oc.id,
+      'scope', oc.scope,
+      'order_item_ids', oc.order_item_ids,
+      'reason_code', oc.reason_code,
+      'reason_name', oc.reason_name,
+      'note', oc.note,
+      'fee', oc.fee,
+      'released_discount', oc.released_discount
+    )
+  FROM order_cancellations oc
+  WHERE oc.order_id = $1
+
+  UNION ALL
+
+  SELECT
+    l.kind,
+    l.created_at,
+    l.created_by::bigint,
+    NULL,
+    CASE WHEN l.target_order_id = $1 THEN l.target_order_item_id ELSE l.source_order_item_id END,
+    CASE WHEN l.target_order_id = $1 THEN l.target_code ELSE l.source_code END,
+    NULL,
+    NULL,
+    jsonb_build_object(
+      'lineage_id', l.id,
+      'source_order_id', l.source_order_id,
+      'source_order_item_id', l.source_order_item_id,
+      'source_code', l.source_code,
+      'target_order_id', l.target_order_id,
+      'target_order_item_id', l.target_order_item_id,
+      'target_code', l.target_code,
+      'order_item_product_ids', l.order_item_product_ids,
+      'order_item_material_ids', l.order_item_material_ids,
+      'note', l.note
+    )
+  FROM order_item_lineages l
+  WHERE l.source_order_id = $1 OR l.target_order_id = $1
+
+  UNION ALL
+
+  SELECT
+    'file_upload',
+    f.created_at,
+    f.uploaded_by::bigint,
+    NULL,
+    f.order_item_id,
+    oi.code,
+    NULL,
+    NULL,
+    jsonb_build_object(
+      'file_id', f.id,
+      'file_url', f.file_url,
+      'file_type', f.file_type,
+      'file_name', f.file_name,
+      'description', f.description,
+      'version', f.version,
+      'previous_id', f.previous_id
+    )
+  FROM order_item_files f
+  JOIN order_items oi ON oi.id = f.order_item_id
+  WHERE oi.order_id = $1
+
+  UNION ALL
+
+  SELECT
+    CASE
+      WHEN al.module = 'order_item_process' THEN 'undo'
+      WHEN al.action = 'sync_price' THEN 'price_sync'
+      ELSE 'custom_field_edit'
+    END,
+    al.created_at,
+    al.user_id::bigint,
+    al.user_fullname,
+    NULLIF(al.data->>'order_item_id', '')::bigint,
+    al.data->>'order_item_code',
+    NULLIF(al.data->>'process_id', '')::bigint,
+    al.data->>'process_name',
+    al.data
+  FROM audit_logs al
+  WHERE
+    (al.module = 'order' AND al.target_id = $1 AND al.action IN ('sync_price', 'update_custom_fields'))
+    OR (al.module = 'order_item_process' AND al.action LIKE 'undo_%' AND al.data->>'order_id' = $1::text)
+)
+SELECT
+  e.type,
+  e.at,
+  e.actor_id,
+  COALESCE(e.actor_name, u.name),
+  e.order_item_id,
+  e.order_item_code,
+  e.process_id,
+  e.process_name,
+  e.data,
+  COUNT(*) OVER ()
+FROM events e
+LEFT JOIN users u ON u.id = e.actor_id
+WHERE e.at IS NOT NULL AND (cardinality($2::text[]) = 0 OR e.type = ANY($2::text[]))
+ORDER BY e.at DESC, e.type
+LIMIT $3 OFFSET $4
+`
+
+func (r *orderRepository) Timeline(
+	ctx context.Context,
+	orderID int64,
+	query model.OrderTimelineQuery,
+) (table.TableListResult[model.OrderTimelineEventDTO], error) {
+	var out table.TableListResult[model.OrderTimelineEventDTO]
+	out.Items = []*model.OrderTimelineEventDTO{}
+
+	types := query.Types
+	if types == nil {
+		types = []string{}
+	}
+
+	rows, err := r.db.QueryContext(ctx, orderTimelineSQL, orderID, pq.Array(types), query.Limit, query.Offset)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			ev   model.OrderTimelineEventDTO
+			data []byte
+		)
+		if err := rows.Scan(
+			&ev.Type,
+			&ev.At,
+			&ev.ActorID,
+			&ev.ActorName,
+			&ev.OrderItemID,
+			&ev.OrderItemCode,
+			&ev.ProcessID,
+			&ev.ProcessName,
+			&data,
+			&out.Total,
+		); err != nil {
+			return out, err
+		}
+		if ev.ActorName != nil && strings.TrimSpace(*ev.ActorName) == "" {
+			ev.ActorName = nil
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &ev.Data); err != nil {
+				return out, err
+			}
+		}
+		out.Items = append(out.Items, &ev)
+	}
+
+	return out, rows.Err()
+}
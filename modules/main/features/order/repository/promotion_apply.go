@@ -225,6 +225,7 @@ type orderPromotionContext struct {
 	RemakeReason   string
 	OriginalTime   time.Time
 	ProductIDs     []int
+	UnitCount      int
 	ShippingAmount float64
 	SellerID       int
 }
@@ -260,7 +261,13 @@ func (r *orderRepository) buildOrderPromotionContext(order *model.OrderDTO) orde
 
 	productIDs := collectOrderProductIDs(order)
 
-	shippingAmount := utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_fee"))
+	shippingAmount := 0.0
+	if order.ShippingFee != nil {
+		shippingAmount = *order.ShippingFee
+	}
+	if shippingAmount == 0 {
+		shippingAmount = utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_fee"))
+	}
 	if shippingAmount == 0 {
 		shippingAmount = utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_cost"))
 	}
@@ -277,11 +284,30 @@ func (r *orderRepository) buildOrderPromotionContext(order *model.OrderDTO) orde
 		RemakeReason:   remakeReason,
 		OriginalTime:   originalTime,
 		ProductIDs:     productIDs,
+		UnitCount:      countOrderUnits(order),
 		ShippingAmount: shippingAmount,
 		SellerID:       sellerID,
 	}
 }
 
+func countOrderUnits(order *model.OrderDTO) int {
+	if order.LatestOrderItem == nil {
+		return 0
+	}
+	n := 0
+	for _, p := range order.LatestOrderItem.Products {
+		if p == nil {
+			continue
+		}
+		if p.ToothChart != nil && p.ToothChart.Count() > 0 {
+			n += p.ToothChart.Count()
+			continue
+		}
+		n += p.Quantity
+	}
+	return n
+}
+
 func collectOrderProductIDs(order *model.OrderDTO) []int {
 	seen := map[int]struct{}{}
 	var out []int
@@ -442,6 +468,24 @@ func matchPromotionConditions(
 				return nil, fmt.Errorf("condition_remake_reason_not_met")
 			}
 			applied = append(applied, string(cond.ConditionType))
+		case promotionmodel.PromotionConditionUnitCountGTE:
+			value, err := parsePromotionIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount < value {
+				return nil, fmt.Errorf("condition_unit_count_gte_not_met")
+			}
+			applied = append(applied, string(cond.ConditionType))
+		case promotionmodel.PromotionConditionUnitCountLTE:
+			value, err := parsePromotionIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount > value {
+				return nil, fmt.Errorf("condition_unit_count_lte_not_met")
+			}
+			applied = append(applied, string(cond.ConditionType))
 		default:
 			return nil, fmt.Errorf("unsupported condition type: %s", cond.ConditionType)
 		}
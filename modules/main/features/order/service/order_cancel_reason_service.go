@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+var ErrInvalidCancelReasonInput = errors.New("code and name are required and default_fee must not be negative")
+
+type OrderCancelReasonService interface {
+	List(ctx context.Context, deptID int, activeOnly bool) ([]*model.OrderCancelReasonDTO, error)
+	Create(ctx context.Context, deptID int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+}
+
+type orderCancelReasonService struct {
+	repo repository.OrderCancelReasonRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderCancelReasonService(repo repository.OrderCancelReasonRepository, deps *module.ModuleDeps[config.ModuleConfig]) OrderCancelReasonService {
+	return &orderCancelReasonService{repo: repo, deps: deps}
+}
+
+func validateCancelReason(input *model.OrderCancelReasonDTO) error {
+	input.Code = strings.TrimSpace(input.Code)
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Code == "" || input.Name == "" || input.DefaultFee < 0 {
+		return ErrInvalidCancelReasonInput
+	}
+	return nil
+}
+
+func (s *orderCancelReasonService) List(ctx context.Context, deptID int, activeOnly bool) ([]*model.OrderCancelReasonDTO, error) {
+	return s.repo.List(ctx, deptID, activeOnly)
+}
+
+func (s *orderCancelReasonService) Create(ctx context.Context, deptID int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error) {
+	if err := validateCancelReason(input); err != nil {
+		return nil, err
+	}
+	return s.repo.Create(ctx, deptID, input)
+}
+
+func (s *orderCancelReasonService) Update(ctx context.Context, deptID int, id int, input *model.OrderCancelReasonDTO) (*model.OrderCancelReasonDTO, error) {
+	if err := validateCancelReason(input); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, deptID, id, input)
+}
+
+func (s *orderCancelReasonService) Delete(ctx context.Context, deptID int, id int) error {
+	return s.repo.Delete(ctx, deptID, id)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	pricelistrepo "github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	productservice "github.com/khiemnd777/andy_api/modules/main/features/product/service"
+	metadatamodel "github.com/khiemnd777/andy_api/modules/metadata/model"
+	"github.com/khiemnd777/andy_api/shared/module"
+	auditlog_model "github.com/khiemnd777/andy_api/shared/modules/auditlog/model"
+	"github.com/khiemnd777/andy_api/shared/pubsub"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+// Order sheet import. The metadata import profile of scope "orders" maps the
+// columns and groups rows by its pivot field, the order key. Per order key:
+//   - core clinic_name, dentist_name and patient_name are resolved to ids;
+//   - "products.*" lines (product_code or product_name, quantity,
+//     teeth_position, retail_price, note) and "materials.*" lines
+//     (material_code or material_name, quantity, retail_price, note) become
+//     the item rows, loaner materials going to the loaner list;
+//   - metadata of collection "order" and "order-item" become the order and
+//     item custom fields.
+//
+// Each order goes through orderService.Create under a newly reserved code, so
+// it is priced, indexed and announced like one entered by hand. Products
+// without a retail_price take the catalog price, as the order form does.
+// Orders are independent: a failing key does not stop the others.
+
+const (
+	OrderImportScope = "orders"
+	importCodeTTL    = 15 * time.Minute
+)
+
+type OrderImportService interface {
+	Import(ctx context.Context, deptID, userID int, groups []*metadatamodel.MappedGroup) *model.OrderImportResultDTO
+}
+
+type orderImportService struct {
+	repo     repository.OrderImportRepository
+	codeSvc  OrderCodeService
+	orderSvc OrderService
+	priceSvc productservice.ProductPriceService
+	deps     *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderImportService(
+	repo repository.OrderImportRepository,
+	codeSvc OrderCodeService,
+	orderSvc OrderService,
+	priceSvc productservice.ProductPriceService,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) OrderImportService {
+	return &orderImportService{
+		repo:     repo,
+		codeSvc:  codeSvc,
+		orderSvc: orderSvc,
+		priceSvc: priceSvc,
+		deps:     deps,
+	}
+}
+
+func (s *orderImportService) Import(ctx context.Context, deptID, userID int, groups []*metadatamodel.MappedGroup) *model.OrderImportResultDTO {
+	out := &model.OrderImportResultDTO{
+		Orders: make([]*model.OrderImportOrderDTO, 0, len(groups)),
+		Errors: []string{},
+	}
+
+	for _, g := range groups {
+		res := &model.OrderImportOrderDTO{Key: g.Key, Rows: g.Rows}
+		out.Orders = append(out.Orders, res)
+
+		if len(g.Errors) > 0 {
+			res.Errors = g.Errors
+			out.Failed++
+			continue
+		}
+
+		input, errs := s.buildOrder(ctx, deptID, g)
+		if len(errs) > 0 {
+			res.Errors = errs
+			out.Failed++
+			continue
+		}
+
+		code, _, err := s.codeSvc.ReserveOrderCode(ctx, time.Now(), importCodeTTL)
+		if err != nil {
+			res.Errors = []string{err.Error()}
+			out.Failed++
+			continue
+		}
+		input.DTO.Code = &code
+
+		dto, err := s.orderSvc.Create(ctx, deptID, userID, input)
+		if err != nil {
+			res.Errors = []string{err.Error()}
+			out.Failed++
+			continue
+		}
+		res.OrderID = dto.ID
+		res.Code = dto.Code
+		out.Created++
+	}
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID: userID,
+		Action: "import",
+		Module: "order",
+		Data: map[string]any{
+			"created": out.Created,
+			"failed":  out.Failed,
+		},
+	})
+
+	return out
+}
+
+// buildOrder turns one order key into the payload the order form would send.
+func (s *orderImportService) buildOrder(ctx context.Context, deptID int, g *metadatamodel.MappedGroup) (*model.OrderUpsertDTO, []string) {
+	var errs []string
+
+	dto := model.OrderDTO{
+		DepartmentID: &deptID,
+		CustomFields: maps.Clone(g.MetadataFields["order"]),
+	}
+	if dto.CustomFields == nil {
+		dto.CustomFields = map[string]any{}
+	}
+
+	if name := importString(g.CoreFields["clinic_name"]); name != "" {
+		ref, err := s.repo.Clinic(ctx, name)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			dto.ClinicID, dto.ClinicName = &ref.ID, &ref.Name
+		}
+	}
+	clinicID := 0
+	if dto.ClinicID != nil {
+		clinicID = *dto.ClinicID
+	}
+	if name := importString(g.CoreFields["dentist_name"]); name != "" {
+		ref, err := s.repo.Dentist(ctx, clinicID, name)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			dto.DentistID, dto.DentistName = &ref.ID, &ref.Name
+		}
+	}
+	if name := importString(g.CoreFields["patient_name"]); name != "" {
+		ref, err := s.repo.Patient(ctx, clinicID, name)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			dto.PatientID, dto.PatientName = &ref.ID, &ref.Name
+		}
+	}
+
+	itemFields := maps.Clone(g.MetadataFields["order-item"])
+	if itemFields == nil {
+		itemFields = map[string]any{}
+	}
+	if v, ok := itemFields["delivery_date"]; ok {
+		if t := utils.SafeParseDateTimePtr(v); t != nil {
+			itemFields["delivery_date"] = t.Format(time.RFC3339)
+		} else {
+			errs = append(errs, fmt.Sprintf("delivery_date %q is not a date", fmt.Sprint(v)))
+		}
+	}
+	item := model.OrderItemDTO{CustomFields: itemFields}
+
+	seenProducts := map[int]int{}
+	for _, line := range g.Lines["products"] {
+		ref, err := s.repo.Product(ctx, importString(line.Fields["product_code"]), importString(line.Fields["product_name"]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: %v", line.Row, err))
+			continue
+		}
+		if prev, ok := seenProducts[ref.ID]; ok {
+			errs = append(errs, fmt.Sprintf("row %d: product %q already on row %d", line.Row, ref.Name, prev))
+			continue
+		}
+		seenProducts[ref.ID] = line.Row
+
+		p := &model.OrderItemProductDTO{
+			ProductID:     ref.ID,
+			ProductName:   &ref.Name,
+			Quantity:      utils.SafeParseInt(line.Fields["quantity"]),
+			RetailPrice:   utils.SafeParseFloatPtr(line.Fields["retail_price"]),
+			TeethPosition: utils.SafeParseStringPtr(line.Fields["teeth_position"]),
+			Note:          utils.SafeParseStringPtr(line.Fields["note"]),
+		}
+		if p.RetailPrice == nil {
+			price, err := s.priceSvc.GetPrice(ctx, pricelistrepo.PriceContext{
+				DepartmentID: deptID,
+				ClinicID:     dto.ClinicID,
+				DentistID:    dto.DentistID,
+				At:           time.Now(),
+			}, ref.ID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("row %d: %v", line.Row, err))
+				continue
+			}
+			p.RetailPrice = &price.Price
+		}
+		item.Products = append(item.Products, p)
+	}
+	if len(item.Products) == 0 && len(errs) == 0 {
+		errs = append(errs, "order has no product")
+	}
+
+	seenMaterials := map[int]int{}
+	for _, line := range g.Lines["materials"] {
+		ref, err := s.repo.Material(ctx, importString(line.Fields["material_code"]), importString(line.Fields["material_name"]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: %v", line.Row, err))
+			continue
+		}
+		if prev, ok := seenMaterials[ref.ID]; ok {
+			errs = append(errs, fmt.Sprintf("row %d: material %q already on row %d", line.Row, ref.Name, prev))
+			continue
+		}
+		seenMaterials[ref.ID] = line.Row
+
+		m := &model.OrderItemMaterialDTO{
+			MaterialID:   ref.ID,
+			MaterialName: &ref.Name,
+			Quantity:     utils.SafeParseInt(line.Fields["quantity"]),
+			RetailPrice:  utils.SafeParseFloatPtr(line.Fields["retail_price"]),
+			Note:         utils.SafeParseStringPtr(line.Fields["note"]),
+		}
+		if ref.Type != nil && *ref.Type == "loaner" {
+			item.LoanerMaterials = append(item.LoanerMaterials, m)
+		} else {
+			item.ConsumableMaterials = append(item.ConsumableMaterials, m)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	orderCol := []string{"order"}
+	itemCol := []string{"order-item"}
+	dto.LatestOrderItemUpsert = &model.OrderItemUpsertDTO{
+		DTO:         item,
+		Collections: &itemCol,
+	}
+	return &model.OrderUpsertDTO{
+		DTO:         dto,
+		Collections: &orderCol,
+	}, nil
+}
+
+func importString(v any) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
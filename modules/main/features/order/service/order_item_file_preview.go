@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/mesh"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+/*
+	storage/photo/
+	├── medium/
+	│   └── 3f0c...e1-top.png     (1024px)
+	└── thumbnail/
+		└── 3f0c...e1-top.png     (256px)
+
+Scan previews share the photo module's sizes so its file route serves them.
+*/
+
+const (
+	previewStatusPending = "pending"
+	previewStatusDone    = "done"
+	previewStatusFailed  = "failed"
+
+	previewMediumSize    = 1024
+	previewThumbnailSize = 256
+
+	// uploads still pending after this are picked up by the preview job
+	previewRetryAfter = 5 * time.Minute
+	previewBatchSize  = 20
+)
+
+func orderItemFilePreviewPath(cfg *config.ModuleConfig) string {
+	path := cfg.Files.PhotoPath
+	if path == "" {
+		path = "./storage/photo"
+	}
+	return utils.ExpandHomeDir(path)
+}
+
+func (s *orderItemFileService) RenderPreviews(ctx context.Context, fileID int64) error {
+	file, err := s.repo.Get(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if file.PreviewStatus == nil || *file.PreviewStatus != previewStatusPending {
+		return nil
+	}
+
+	previews, err := s.renderPreviews(file)
+	if err != nil {
+		logger.Error("order item file: render previews", "file_id", fileID, "err", err)
+		return s.repo.SetPreviews(ctx, fileID, previewStatusFailed, nil)
+	}
+	if err := s.repo.SetPreviews(ctx, fileID, previewStatusDone, previews); err != nil {
+		return err
+	}
+
+	orderID, err := s.repo.OrderIDOfItem(ctx, file.OrderItemID)
+	if err != nil {
+		return err
+	}
+	cache.InvalidateKeys(kOrderByID(orderID), kOrderByIDAll(orderID))
+	realtime.BroadcastAll("order:files", map[string]any{
+		"order_id":      orderID,
+		"order_item_id": file.OrderItemID,
+		"file_id":       file.ID,
+	})
+	return nil
+}
+
+func (s *orderItemFileService) RenderPendingPreviews(ctx context.Context) error {
+	files, err := s.repo.ListPendingPreviews(ctx, time.Now().Add(-previewRetryAfter), previewBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := s.RenderPreviews(ctx, f.ID); err != nil {
+			logger.Error("order item file: pending preview", "file_id", f.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+func (s *orderItemFileService) renderPreviews(file *model.OrderItemFileDTO) ([]map[string]string, error) {
+	ext := filepath.Ext(file.FileURL)
+	format, ok := mesh.FormatOf(ext)
+	if !ok {
+		return nil, mesh.ErrUnsupportedFormat
+	}
+
+	src, err := os.Open(filepath.Join(orderItemFileStoragePath(s.deps.Config), filepath.Base(filepath.FromSlash(file.FileURL))))
+	if err != nil {
+		return nil, err
+	}
+	tris, _, err := mesh.Load(src, format)
+	src.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := orderItemFilePreviewPath(s.deps.Config)
+	for _, size := range []string{"medium", "thumbnail"} {
+		if err := utils.EnsureDirExists(filepath.Join(basePath, size)); err != nil {
+			return nil, fmt.Errorf("failed to create folder: %w", err)
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(file.FileURL), ext)
+	previews := make([]map[string]string, 0, len(mesh.StandardViews))
+	for _, view := range mesh.StandardViews {
+		img, err := mesh.Render(tris, view, previewMediumSize)
+		if err != nil {
+			return nil, err
+		}
+
+		name := base + "-" + view.Name + ".png"
+		if err := savePNG(img, filepath.Join(basePath, "medium", name)); err != nil {
+			return nil, err
+		}
+		thumb := imaging.Resize(img, previewThumbnailSize, 0, imaging.Lanczos)
+		if err := savePNG(thumb, filepath.Join(basePath, "thumbnail", name)); err != nil {
+			return nil, err
+		}
+
+		previews = append(previews, map[string]string{"view": view.Name, "file": name})
+	}
+	return previews, nil
+}
+
+func savePNG(img image.Image, path string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/mesh"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+	"github.com/khiemnd777/andy_api/shared/pubsub"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+var (
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	ErrFileTooLarge        = errors.New("file is too large")
+	ErrDuplicateFile       = errors.New("the same file is already attached to this order item")
+	ErrInvalidMeshFile     = errors.New("scan file could not be read")
+	ErrOrderItemNotInOrder = errors.New("order item does not belong to the order")
+)
+
+type OrderItemFileUpload struct {
+	Description *string
+	// id of the file this upload replaces as a newer version
+	ReplacesID *int64
+}
+
+type OrderItemFileService interface {
+	Upload(
+		ctx context.Context,
+		deptID, userID int,
+		orderID, orderItemID int64,
+		fileHeader *multipart.FileHeader,
+		input OrderItemFileUpload,
+	) (*model.OrderItemFileDTO, error)
+	List(ctx context.Context, orderID, orderItemID int64, all bool) ([]*model.OrderItemFileDTO, error)
+	// FilePath resolves a stored file on disk for download.
+	FilePath(ctx context.Context, orderID, orderItemID, id int64) (*model.OrderItemFileDTO, string, error)
+
+	// RenderPreviews renders the PNG previews of a scan still pending.
+	RenderPreviews(ctx context.Context, fileID int64) error
+	RenderPendingPreviews(ctx context.Context) error
+}
+
+type orderItemFileService struct {
+	repo repository.OrderItemFileRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderItemFileService(
+	repo repository.OrderItemFileRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) OrderItemFileService {
+	svc := &orderItemFileService{
+		repo: repo,
+		deps: deps,
+	}
+
+	pubsub.SubscribeAsync("order:file:preview", func(payload *model.OrderItemFilePreviewRequest) error {
+		return svc.RenderPreviews(context.Background(), payload.FileID)
+	})
+
+	return svc
+}
+
+func (s *orderItemFileService) Upload(
+	ctx context.Context,
+	deptID, userID int,
+	orderID, orderItemID int64,
+	fileHeader *multipart.FileHeader,
+	input OrderItemFileUpload,
+) (*model.OrderItemFileDTO, error) {
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	fileType, ok := orderItemFileTypes[ext]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, ext)
+	}
+
+	maxBytes := orderItemFileMaxBytes(s.deps.Config, orderItemFileKind(fileType))
+	if fileHeader.Size > maxBytes {
+		return nil, fmt.Errorf("%w: limit is %d MB", ErrFileTooLarge, maxBytes/1024/1024)
+	}
+
+	if err := s.ensureItemInOrder(ctx, orderID, orderItemID); err != nil {
+		return nil, err
+	}
+
+	stored, err := saveOrderItemFile(fileHeader, orderItemFileStoragePath(s.deps.Config), ext)
+	if err != nil {
+		return nil, err
+	}
+
+	dto, err := s.create(ctx, userID, orderItemID, fileHeader.Filename, ext, fileType, stored, input)
+	if err != nil {
+		if rmErr := os.Remove(stored.Path); rmErr != nil {
+			logger.Warn("order item file: remove orphan", "path", stored.Path, "err", rmErr)
+		}
+		return nil, err
+	}
+
+	if dto.PreviewStatus != nil {
+		pubsub.PublishAsync("order:file:preview", &model.OrderItemFilePreviewRequest{FileID: dto.ID})
+	}
+
+	cache.InvalidateKeys(kOrderByID(orderID), kOrderByIDAll(orderID))
+	realtime.BroadcastToDept(deptID, "order:files", map[string]any{
+		"order_id":      orderID,
+		"order_item_id": orderItemID,
+		"file_id":       dto.ID,
+	})
+
+	return dto, nil
+}
+
+func (s *orderItemFileService) create(
+	ctx context.Context,
+	userID int,
+	orderItemID int64,
+	fileName, ext, fileType string,
+	stored *storedFile,
+	input OrderItemFileUpload,
+) (*model.OrderItemFileDTO, error) {
+	if dup, err := s.repo.FindCurrentByChecksum(ctx, orderItemID, stored.Checksum); err != nil {
+		return nil, err
+	} else if dup != nil {
+		return nil, fmt.Errorf("%w (file #%d)", ErrDuplicateFile, dup.ID)
+	}
+
+	var (
+		meta          map[string]any
+		previewStatus *string
+	)
+	if format, ok := mesh.FormatOf(ext); ok {
+		f, err := os.Open(stored.Path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := mesh.Inspect(f, format)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMeshFile, err)
+		}
+		meta = info.Meta()
+		previewStatus = utils.Ptr(previewStatusPending)
+	}
+
+	return s.repo.Create(ctx, &model.OrderItemFileDTO{
+		OrderItemID:   orderItemID,
+		FileURL:       filepath.ToSlash(stored.Name),
+		FileType:      fileType,
+		Description:   utils.SafeString(input.Description),
+		FileName:      &fileName,
+		MimeType:      &stored.MimeType,
+		Size:          stored.Size,
+		Checksum:      &stored.Checksum,
+		Meta:          meta,
+		PreviewStatus: previewStatus,
+		UploadedBy:    &userID,
+	}, input.ReplacesID)
+}
+
+func (s *orderItemFileService) List(ctx context.Context, orderID, orderItemID int64, all bool) ([]*model.OrderItemFileDTO, error) {
+	if err := s.ensureItemInOrder(ctx, orderID, orderItemID); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, orderItemID, all)
+}
+
+func (s *orderItemFileService) FilePath(ctx context.Context, orderID, orderItemID, id int64) (*model.OrderItemFileDTO, string, error) {
+	if err := s.ensureItemInOrder(ctx, orderID, orderItemID); err != nil {
+		return nil, "", err
+	}
+	dto, err := s.repo.GetByID(ctx, orderItemID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(orderItemFileStoragePath(s.deps.Config), filepath.Base(filepath.FromSlash(dto.FileURL)))
+	return dto, path, nil
+}
+
+func (s *orderItemFileService) ensureItemInOrder(ctx context.Context, orderID, orderItemID int64) error {
+	ok, err := s.repo.ItemBelongsToOrder(ctx, orderID, orderItemID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOrderItemNotInOrder
+	}
+	return nil
+}
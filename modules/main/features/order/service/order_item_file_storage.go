@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+/*
+	storage/order_item_file/
+	└── 3f0c...e1.stl
+
+Files are kept flat under uuid names as the photo module does; the original
+name, checksum and mesh info live on the order_item_files row.
+*/
+
+const (
+	fileKindScan  = "scan"
+	fileKindCad   = "cad"
+	fileKindVideo = "video"
+)
+
+// file_type stored per extension
+var orderItemFileTypes = map[string]string{
+	".stl":  "scan_stl",
+	".ply":  "scan_ply",
+	".obj":  "scan_obj",
+	".zip":  "cad",
+	".dcm":  "cad",
+	".mp4":  "video",
+	".mov":  "video",
+	".m4v":  "video",
+	".webm": "video",
+	".avi":  "video",
+	".mkv":  "video",
+}
+
+var zipMagic = []byte("PK\x03\x04")
+
+func orderItemFileKind(fileType string) string {
+	switch {
+	case strings.HasPrefix(fileType, "scan_"):
+		return fileKindScan
+	case fileType == "cad":
+		return fileKindCad
+	default:
+		return fileKindVideo
+	}
+}
+
+func orderItemFileMaxBytes(cfg *config.ModuleConfig, kind string) int64 {
+	mb := 0
+	switch kind {
+	case fileKindScan:
+		mb = cfg.Files.MaxScanMB
+		if mb <= 0 {
+			mb = 100
+		}
+	case fileKindCad:
+		mb = cfg.Files.MaxCadMB
+		if mb <= 0 {
+			mb = 200
+		}
+	default:
+		mb = cfg.Files.MaxVideoMB
+		if mb <= 0 {
+			mb = 200
+		}
+	}
+	return int64(mb) * 1024 * 1024
+}
+
+func orderItemFileStoragePath(cfg *config.ModuleConfig) string {
+	path := cfg.Files.StoragePath
+	if path == "" {
+		path = "./storage/order_item_file"
+	}
+	return utils.ExpandHomeDir(path)
+}
+
+type storedFile struct {
+	Name     string // name on disk
+	Path     string
+	Size     int64
+	Checksum string
+	MimeType string
+}
+
+// saveOrderItemFile copies the upload to disk, hashing it on the way.
+func saveOrderItemFile(fileHeader *multipart.FileHeader, basePath, ext string) (*storedFile, error) {
+	if err := utils.EnsureDirExists(basePath); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(src, head)
+	head = head[:n]
+	if ext == ".zip" && !bytes.HasPrefix(head, zipMagic) {
+		return nil, fmt.Errorf("%w: not a zip archive", ErrUnsupportedFileType)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	mimeType := http.DetectContentType(head)
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			mimeType = byExt
+		}
+	}
+
+	name := uuid.New().String() + ext
+	path := filepath.Join(basePath, name)
+	dst, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return &storedFile{
+		Name:     name,
+		Path:     path,
+		Size:     size,
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+		MimeType: mimeType,
+	}, nil
+}
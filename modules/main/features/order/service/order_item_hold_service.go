@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+	auditlog_model "github.com/khiemnd777/andy_api/shared/modules/auditlog/model"
+	"github.com/khiemnd777/andy_api/shared/modules/notification"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+	"github.com/khiemnd777/andy_api/shared/pubsub"
+)
+
+const defaultHoldAlertDays = 3
+
+type OrderItemHoldService interface {
+	Hold(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemHoldInputDTO) (*model.OrderItemHoldDTO, error)
+	Resume(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemResumeDTO) (*model.OrderItemHoldDTO, error)
+	ListByOrderItemID(ctx context.Context, orderItemID int64) ([]*model.OrderItemHoldDTO, error)
+	ListActive(ctx context.Context, deptID int) ([]*model.OrderItemHoldDTO, error)
+	AlertLongHolds(ctx context.Context, now time.Time) (int, error)
+}
+
+type orderItemHoldService struct {
+	repo repository.OrderItemHoldRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderItemHoldService(repo repository.OrderItemHoldRepository, deps *module.ModuleDeps[config.ModuleConfig]) OrderItemHoldService {
+	return &orderItemHoldService{repo: repo, deps: deps}
+}
+
+func (s *orderItemHoldService) Hold(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemHoldInputDTO) (*model.OrderItemHoldDTO, error) {
+	input.Reason = strings.TrimSpace(input.Reason)
+	out, err := s.repo.Hold(ctx, deptID, userID, orderID, orderItemID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(orderID, orderItemID)
+	s.audit(userID, "hold", out)
+	s.broadcast(deptID, false)
+
+	return out, nil
+}
+
+// Resume takes the item off hold. When every step got done meanwhile the item
+// completes here, so the daily stats are fed like on a check-out.
+func (s *orderItemHoldService) Resume(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemResumeDTO) (*model.OrderItemHoldDTO, error) {
+	out, item, err := s.repo.Resume(ctx, deptID, userID, orderID, orderItemID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := out.Status != nil && *out.Status == "completed"
+	if completed {
+		publishCompletedStats(deptID, *out.ResumedAt, item)
+	}
+
+	s.invalidate(orderID, orderItemID)
+	s.audit(userID, "resume", out)
+	s.broadcast(deptID, completed)
+
+	return out, nil
+}
+
+func (s *orderItemHoldService) ListByOrderItemID(ctx context.Context, orderItemID int64) ([]*model.OrderItemHoldDTO, error) {
+	return s.repo.ListByOrderItemID(ctx, orderItemID)
+}
+
+func (s *orderItemHoldService) ListActive(ctx context.Context, deptID int) ([]*model.OrderItemHoldDTO, error) {
+	return s.repo.ListActive(ctx, deptID)
+}
+
+// AlertLongHolds tells the clinic's account owner about holds running longer
+// than order.hold_alert_days. Each hold is announced once. Returns the number
+// of alerts sent.
+func (s *orderItemHoldService) AlertLongHolds(ctx context.Context, now time.Time) (int, error) {
+	days := defaultHoldAlertDays
+	if s.deps.Config != nil && s.deps.Config.Order.HoldAlertDays > 0 {
+		days = s.deps.Config.Order.HoldAlertDays
+	}
+
+	alerts, err := s.repo.DueAlerts(ctx, now.AddDate(0, 0, -days))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, a := range alerts {
+		claimed, err := s.repo.MarkAlerted(ctx, a.Hold.ID, now)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		notification.Notify(a.AccountOwnerID, 0, "order:hold_overdue", map[string]any{
+			"hold_id":            a.Hold.ID,
+			"order_id":           a.Hold.OrderID,
+			"order_code":         a.OrderCode,
+			"order_item_id":      a.Hold.OrderItemID,
+			"order_item_code":    a.Hold.OrderItemCode,
+			"clinic_id":          a.ClinicID,
+			"clinic_name":        a.ClinicName,
+			"reason":             a.Hold.Reason,
+			"held_at":            a.Hold.HeldAt,
+			"expected_resume_at": a.Hold.ExpectedResumeAt,
+			"held_days":          math.Floor(now.Sub(a.Hold.HeldAt).Hours() / 24),
+		})
+		sent++
+	}
+	return sent, nil
+}
+
+func (s *orderItemHoldService) invalidate(orderID, orderItemID int64) {
+	cache.InvalidateKeys(
+		kOrderByID(orderID),
+		kOrderByIDAll(orderID),
+		fmt.Sprintf("order:id:%d:oid:%d:processes", orderID, orderItemID),
+	)
+	cache.InvalidateKeys(kOrderAll()...)
+}
+
+func (s *orderItemHoldService) audit(userID int, action string, out *model.OrderItemHoldDTO) {
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   action,
+		Module:   "order",
+		TargetID: int(out.OrderID),
+		Data: map[string]any{
+			"order_id":           out.OrderID,
+			"order_item_id":      out.OrderItemID,
+			"hold_id":            out.ID,
+			"reason":             out.Reason,
+			"expected_resume_at": out.ExpectedResumeAt,
+			"status":             out.Status,
+		},
+	})
+}
+
+func (s *orderItemHoldService) broadcast(deptID int, completed bool) {
+	if completed {
+		realtime.BroadcastToDept(deptID, "dashboard:daily:turnaround:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:remake:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:completed:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
+	}
+
+	realtime.BroadcastAll("order:inprogress", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
+	realtime.BroadcastToDept(deptID, "process:sla", nil)
+}
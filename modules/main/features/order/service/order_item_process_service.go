@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/khiemnd777/andy_api/modules/main/config"
@@ -12,6 +14,7 @@ import (
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
 	"github.com/khiemnd777/andy_api/shared/module"
+	auditlog_model "github.com/khiemnd777/andy_api/shared/modules/auditlog/model"
 	"github.com/khiemnd777/andy_api/shared/modules/notification"
 	"github.com/khiemnd777/andy_api/shared/modules/realtime"
 	"github.com/khiemnd777/andy_api/shared/pubsub"
@@ -69,12 +72,34 @@ type OrderItemProcessService interface {
 		code string,
 	) (*model.OrderItemProcessInProgressDTO, error)
 
+	CheckInOrOutOptions(
+		ctx context.Context,
+		orderID int64,
+		orderItemID int64,
+	) (*model.OrderItemProcessOptionsDTO, error)
+
 	CheckInOrOut(
 		ctx context.Context,
 		deptID int,
 		userID int,
 		checkInOrOutData *model.OrderItemProcessInProgressDTO,
 	) (*model.OrderItemProcessInProgressDTO, error)
+
+	BatchCheckInOrOut(
+		ctx context.Context,
+		deptID int,
+		userID int,
+		input *model.OrderItemProcessBatchScanDTO,
+	) (*model.OrderItemProcessBatchScanResultDTO, error)
+
+	UndoLastTransition(
+		ctx context.Context,
+		deptID int,
+		userID int,
+		orderID int64,
+		orderItemID int64,
+	) (*model.OrderItemProcessUndoDTO, error)
+
 	Assign(
 		ctx context.Context,
 		inprogressID int64,
@@ -90,6 +115,25 @@ type OrderItemProcessService interface {
 	) (*model.OrderItemProcessDTO, error)
 }
 
+const (
+	ScanActionCheckIn  = "check_in"
+	ScanActionCheckOut = "check_out"
+
+	// a tray rarely holds more than a few dozen cases
+	maxScanBatch = 200
+
+	defaultUndoWindow = 30 * time.Minute
+)
+
+var (
+	ErrInvalidScanAction = errors.New("action must be check_in or check_out")
+	ErrNoScanCodes       = errors.New("codes is required")
+	ErrScanBatchTooLarge = errors.New("too many codes in one batch")
+	ErrScanCodeNotFound  = errors.New("order item not found")
+	ErrNotCheckedIn      = errors.New("order item has no running process to check out")
+	ErrAlreadyCheckedIn  = errors.New("order item is already checked in")
+)
+
 type orderItemProcessService struct {
 	repo           repository.OrderItemProcessRepository
 	inprogressRepo repository.OrderItemProcessInProgressRepository
@@ -198,6 +242,10 @@ func (s *orderItemProcessService) PrepareCheckInOrOut(ctx context.Context, order
 	return s.inprogressRepo.PrepareCheckInOrOut(ctx, nil, orderItemID, &orderID)
 }
 
+func (s *orderItemProcessService) CheckInOrOutOptions(ctx context.Context, orderID int64, orderItemID int64) (*model.OrderItemProcessOptionsDTO, error) {
+	return s.inprogressRepo.CheckInOrOutOptions(ctx, orderItemID, &orderID)
+}
+
 func (s *orderItemProcessService) PrepareCheckInOrOutByCode(ctx context.Context, code string) (*model.OrderItemProcessInProgressDTO, error) {
 	return s.inprogressRepo.PrepareCheckInOrOutByCode(ctx, code)
 }
@@ -209,11 +257,146 @@ func (s *orderItemProcessService) CheckInOrOut(
 	userID int,
 	checkInOrOutData *model.OrderItemProcessInProgressDTO,
 ) (*model.OrderItemProcessInProgressDTO, error) {
+	dto, completed, err := s.applyCheckInOrOut(ctx, deptID, userID, checkInOrOutData)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcastCheckInOrOut(deptID, completed)
+
+	return dto, nil
+}
+
+// BatchCheckInOrOut applies the same transition to every scanned code. Each
+// item runs in its own transaction, so one bad code does not block the rest;
+// the realtime broadcasts go out once for the whole batch.
+func (s *orderItemProcessService) BatchCheckInOrOut(
+	ctx context.Context,
+	deptID,
+	userID int,
+	input *model.OrderItemProcessBatchScanDTO,
+) (*model.OrderItemProcessBatchScanResultDTO, error) {
+	if input.Action != ScanActionCheckIn && input.Action != ScanActionCheckOut {
+		return nil, ErrInvalidScanAction
+	}
+
+	codes := make([]string, 0, len(input.Codes))
+	seen := make(map[string]struct{}, len(input.Codes))
+	for _, code := range input.Codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, ErrNoScanCodes
+	}
+	if len(codes) > maxScanBatch {
+		return nil, ErrScanBatchTooLarge
+	}
+
+	result := &model.OrderItemProcessBatchScanResultDTO{
+		Action: input.Action,
+		Items:  make([]*model.OrderItemProcessBatchScanItemDTO, 0, len(codes)),
+	}
+	anyCompleted := false
+
+	for _, code := range codes {
+		item := &model.OrderItemProcessBatchScanItemDTO{Code: code}
+		result.Items = append(result.Items, item)
+
+		dto, completed, err := s.scanOne(ctx, deptID, userID, code, input)
+		if err != nil {
+			msg := err.Error()
+			item.Error = &msg
+			result.Failed++
+			continue
+		}
+
+		item.Success = true
+		item.InProgress = dto
+		result.Succeeded++
+		anyCompleted = anyCompleted || completed
+	}
+
+	if result.Succeeded > 0 {
+		s.broadcastCheckInOrOut(deptID, anyCompleted)
+	}
+
+	return result, nil
+}
+
+// scanOne validates the scanned item against its current in-progress state
+// before applying the requested transition.
+func (s *orderItemProcessService) scanOne(
+	ctx context.Context,
+	deptID,
+	userID int,
+	code string,
+	input *model.OrderItemProcessBatchScanDTO,
+) (*model.OrderItemProcessInProgressDTO, bool, error) {
+	data, err := s.inprogressRepo.PrepareCheckInOrOutByCode(ctx, code)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return nil, false, ErrScanCodeNotFound
+		}
+		return nil, false, err
+	}
+
+	// a prepared row with an id is the running step waiting for check-out
+	checkingOut := data.ID > 0
+	switch {
+	case input.Action == ScanActionCheckOut && !checkingOut:
+		return nil, false, ErrNotCheckedIn
+	case input.Action == ScanActionCheckIn && checkingOut:
+		// a parallel step may still be free to start
+		opts, err := s.inprogressRepo.CheckInOrOutOptions(ctx, data.OrderItemID, data.OrderID)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(opts.CheckIn) == 0 {
+			return nil, false, ErrAlreadyCheckedIn
+		}
+		data = opts.CheckIn[0]
+		checkingOut = false
+	}
+
+	if checkingOut {
+		if input.Note != nil {
+			data.CheckOutNote = input.Note
+		}
+	} else {
+		data.CheckInNote = input.Note
+		if input.AssignedID != nil {
+			data.AssignedID = input.AssignedID
+			data.AssignedName = input.AssignedName
+		}
+	}
+
+	return s.applyCheckInOrOut(ctx, deptID, userID, data)
+}
+
+// applyCheckInOrOut runs one transition, invalidates the affected caches and
+// sends the per-item notifications and stats. It reports whether the order
+// item got completed so the caller can broadcast accordingly.
+func (s *orderItemProcessService) applyCheckInOrOut(
+	ctx context.Context,
+	deptID,
+	userID int,
+	checkInOrOutData *model.OrderItemProcessInProgressDTO,
+) (*model.OrderItemProcessInProgressDTO, bool, error) {
 	var err error
+	ctx = repository.WithStatusActor(ctx, userID)
 	dto, _, orderstatus, orderitem, err := s.inprogressRepo.CheckInOrOut(ctx, checkInOrOutData)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	completed := false
 
 	orderItemID := dto.OrderItemID
 	if orderItemID == 0 && checkInOrOutData != nil {
@@ -256,54 +439,159 @@ func (s *orderItemProcessService) CheckInOrOut(
 
 	// notify to next process's leader
 	if dto.CompletedAt != nil && dto.NextProcessID != nil {
-		notification.Notify(*dto.NextLeaderID, userID, "order:checkout", map[string]any{
-			"leader_id":       dto.NextLeaderID,
-			"leader_name":     dto.NextLeaderName,
-			"order_item_id":   dto.OrderItemID,
-			"order_item_code": dto.OrderItemCode,
-			"section_name":    dto.NextSectionName,
-			"process_name":    dto.NextProcessName,
-		})
+		if dto.NextLeaderID != nil {
+			notification.Notify(*dto.NextLeaderID, userID, "order:checkout", map[string]any{
+				"leader_id":       dto.NextLeaderID,
+				"leader_name":     dto.NextLeaderName,
+				"order_item_id":   dto.OrderItemID,
+				"order_item_code": dto.OrderItemCode,
+				"section_name":    dto.NextSectionName,
+				"process_name":    dto.NextProcessName,
+			})
+		}
 
 		if orderstatus != nil && "completed" == *orderstatus {
-			pubsub.PublishAsync("dashboard:daily:turnaround:stats", &model.CaseDailyStatsUpsert{
-				DepartmentID: deptID,
-				CompletedAt:  *dto.CompletedAt,
-				ReceivedAt:   orderitem.CreatedAt,
-			})
+			completed = true
+			publishCompletedStats(deptID, *dto.CompletedAt, orderitem)
+			if orderID != nil {
+				invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), *orderID)
+			}
+		}
+	}
 
-			realtime.BroadcastToDept(deptID, "dashboard:daily:turnaround:stats", nil)
+	return dto, completed, nil
+}
 
-			pubsub.PublishAsync("dashboard:daily:remake:stats", &model.CaseDailyRemakeStatsUpsert{
-				DepartmentID: deptID,
-				CompletedAt:  *dto.CompletedAt,
-				IsRemake:     orderitem.RemakeCount > 0,
-			})
+// publishCompletedStats feeds the daily dashboards with an order item that
+// just got completed.
+func publishCompletedStats(deptID int, completedAt time.Time, orderitem *generated.OrderItem) {
+	pubsub.PublishAsync("dashboard:daily:turnaround:stats", &model.CaseDailyStatsUpsert{
+		DepartmentID: deptID,
+		OrderItemID:  orderitem.ID,
+		CompletedAt:  completedAt,
+		ReceivedAt:   orderitem.CreatedAt,
+	})
 
-			realtime.BroadcastToDept(deptID, "dashboard:daily:remake:stats", nil)
+	pubsub.PublishAsync("dashboard:daily:remake:stats", &model.CaseDailyRemakeStatsUpsert{
+		DepartmentID: deptID,
+		CompletedAt:  completedAt,
+		IsRemake:     orderitem.RemakeCount > 0,
+	})
 
-			pubsub.PublishAsync("dashboard:daily:completed:stats", &model.CaseDailyCompletedStatsUpsert{
-				DepartmentID: deptID,
-				CompletedAt:  *dto.CompletedAt,
-			})
+	pubsub.PublishAsync("dashboard:daily:completed:stats", &model.CaseDailyCompletedStatsUpsert{
+		DepartmentID: deptID,
+		CompletedAt:  completedAt,
+	})
 
-			realtime.BroadcastToDept(deptID, "dashboard:daily:completed:stats", nil)
+	pubsub.PublishAsync("dashboard:daily:active:stats", &model.CaseDailyActiveStatsUpsert{
+		DepartmentID: deptID,
+		StatAt:       time.Now(),
+	})
+}
 
-			pubsub.PublishAsync("dashboard:daily:active:stats", &model.CaseDailyActiveStatsUpsert{
-				DepartmentID: deptID,
-				StatAt:       time.Now(),
-			})
+// publishReopenedStats takes back from the daily dashboards an order item
+// whose completing check-out was undone.
+func publishReopenedStats(deptID int, completedAt time.Time, orderitem *generated.OrderItem) {
+	pubsub.PublishAsync("dashboard:daily:turnaround:stats", &model.CaseDailyStatsUpsert{
+		DepartmentID: deptID,
+		OrderItemID:  orderitem.ID,
+		CompletedAt:  completedAt,
+		ReceivedAt:   orderitem.CreatedAt,
+		Revert:       true,
+	})
 
-			realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
-		}
+	pubsub.PublishAsync("dashboard:daily:remake:stats", &model.CaseDailyRemakeStatsUpsert{
+		DepartmentID: deptID,
+		CompletedAt:  completedAt,
+		IsRemake:     orderitem.RemakeCount > 0,
+		Revert:       true,
+	})
+
+	pubsub.PublishAsync("dashboard:daily:active:stats", &model.CaseDailyActiveStatsUpsert{
+		DepartmentID: deptID,
+		StatAt:       time.Now(),
+	})
+}
+
+func (s *orderItemProcessService) broadcastCheckInOrOut(deptID int, completed bool) {
+	if completed {
+		realtime.BroadcastToDept(deptID, "dashboard:daily:turnaround:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:remake:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:completed:stats", nil)
+		realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
 	}
 
 	realtime.BroadcastAll("order:inprogress", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:active_today", nil)
+}
 
-	return dto, nil
+// UndoLastTransition reverts the latest check-in, check-out or reassignment of
+// an order item within the configured window and records who did it in the audit log.
+func (s *orderItemProcessService) UndoLastTransition(
+	ctx context.Context,
+	deptID,
+	userID int,
+	orderID,
+	orderItemID int64,
+) (*model.OrderItemProcessUndoDTO, error) {
+	window := defaultUndoWindow
+	if s.deps.Config != nil && s.deps.Config.Order.UndoWindowMinutes > 0 {
+		window = time.Duration(s.deps.Config.Order.UndoWindowMinutes) * time.Minute
+	}
+
+	out, reopened, err := s.inprogressRepo.UndoLastTransition(repository.WithStatusActor(ctx, userID), deptID, orderID, orderItemID, window)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if out.OrderID != nil {
+		keys = append(keys,
+			kOrderByID(*out.OrderID),
+			kOrderByIDAll(*out.OrderID),
+			fmt.Sprintf("order:id:%d:oid:%d:processes", *out.OrderID, orderItemID),
+			fmt.Sprintf("order:id:%d:oid:%d:inprogresses", *out.OrderID, orderItemID),
+		)
+	}
+	keys = append(keys,
+		fmt.Sprintf("order:process:checkout:latest:oid:%d", orderItemID),
+		fmt.Sprintf("order:process:inprogress:id%d", out.InProgressID),
+	)
+	if out.ProcessID != nil {
+		keys = append(keys, fmt.Sprintf("order:process:id%d:*", *out.ProcessID))
+	}
+	if out.Restored != nil && out.Restored.AssignedID != nil {
+		keys = append(keys, fmt.Sprintf("order:assigned:%d:*", *out.Restored.AssignedID))
+	}
+	cache.InvalidateKeys(keys...)
+	cache.InvalidateKeys(kOrderAll()...)
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   "undo_" + out.Action,
+		Module:   "order_item_process",
+		TargetID: int(out.InProgressID),
+		Data: map[string]any{
+			"order_id":        out.OrderID,
+			"order_item_id":   out.OrderItemID,
+			"order_item_code": out.OrderItemCode,
+			"process_id":      out.ProcessID,
+			"process_name":    out.ProcessName,
+			"transition_at":   out.TransitionAt,
+		},
+	})
+
+	// the daily stats counted the completion the check-out made; take it back
+	if reopened != nil {
+		publishReopenedStats(deptID, out.TransitionAt, reopened)
+		invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), reopened.OrderID)
+	}
+	s.broadcastCheckInOrOut(deptID, out.Action == ScanActionCheckOut)
+
+	return out, nil
 }
 
 func (s *orderItemProcessService) Assign(ctx context.Context, inprogressID int64, assignedID *int64, assignedName *string, note *string) (*model.OrderItemProcessInProgressDTO, error) {
@@ -7,6 +7,7 @@ import (
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	productservice "github.com/khiemnd777/andy_api/modules/main/features/product/service"
 	"github.com/khiemnd777/andy_api/shared/cache"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
 	"github.com/khiemnd777/andy_api/shared/module"
@@ -30,38 +31,32 @@ type OrderItemService interface {
 }
 
 type orderItemService struct {
-	repo  repository.OrderItemRepository
-	deps  *module.ModuleDeps[config.ModuleConfig]
-	cfMgr *customfields.Manager
+	repo     repository.OrderItemRepository
+	priceSvc productservice.ProductPriceService
+	deps     *module.ModuleDeps[config.ModuleConfig]
+	cfMgr    *customfields.Manager
 }
 
 func NewOrderItemService(
 	repo repository.OrderItemRepository,
+	priceSvc productservice.ProductPriceService,
 	deps *module.ModuleDeps[config.ModuleConfig],
 	cfMgr *customfields.Manager,
 ) OrderItemService {
 	return &orderItemService{
-		repo:  repo,
-		deps:  deps,
-		cfMgr: cfMgr,
+		repo:     repo,
+		priceSvc: priceSvc,
+		deps:     deps,
+		cfMgr:    cfMgr,
 	}
 }
 
 func (s *orderItemService) CalculateTotalPrice(prices []float64, quantities []int) float64 {
-	var total float64
-
-	for i, price := range prices {
-		qty := 1
-		if i < len(quantities) && quantities[i] > 0 {
-			qty = quantities[i]
-		}
-		total += price * float64(qty)
-	}
-	return total
+	return s.priceSvc.CalculateTotal(prices, quantities)
 }
 
 func (s *orderItemService) SyncPrice(ctx context.Context, orderItemID int64) (float64, error) {
-	return s.repo.GetTotalPriceByOrderItemID(ctx, orderItemID)
+	return s.repo.GetTotalPriceByOrderItemID(ctx, nil, orderItemID)
 }
 
 func (s *orderItemService) GetAllProductsAndMaterialsByOrderID(ctx context.Context, orderID int64) (model.OrderProductsAndMaterialsDTO, error) {
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,14 +10,19 @@ import (
 	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
 	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
 	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
 	dbutils "github.com/khiemnd777/andy_api/shared/db/utils"
+	"github.com/khiemnd777/andy_api/shared/logger"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
 	"github.com/khiemnd777/andy_api/shared/module"
+	auditlog_model "github.com/khiemnd777/andy_api/shared/modules/auditlog/model"
 	"github.com/khiemnd777/andy_api/shared/modules/notification"
 	"github.com/khiemnd777/andy_api/shared/modules/realtime"
 	searchmodel "github.com/khiemnd777/andy_api/shared/modules/search/model"
 	"github.com/khiemnd777/andy_api/shared/pubsub"
 	searchutils "github.com/khiemnd777/andy_api/shared/search"
+	"github.com/khiemnd777/andy_api/shared/toothchart"
 	"github.com/khiemnd777/andy_api/shared/utils"
 	"github.com/khiemnd777/andy_api/shared/utils/table"
 )
@@ -24,7 +30,13 @@ import (
 type OrderService interface {
 	Create(ctx context.Context, deptID, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error)
 	Update(ctx context.Context, deptID, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error)
-	UpdateStatus(ctx context.Context, deptID int, orderItemProcessID int64, status string) (*model.OrderItemDTO, error)
+	UpdateStatus(ctx context.Context, deptID, userID int, orderItemProcessID int64, status string) (*model.OrderItemDTO, error)
+	StatusTransitionPermission(ctx context.Context, orderItemProcessID int64, status string) ([]string, error)
+	StatusMachine() map[string][]repository.StatusTransition
+	StatusHistory(ctx context.Context, orderID int64) ([]*model.OrderStatusHistoryDTO, error)
+	Timeline(ctx context.Context, orderID int64, query model.OrderTimelineQuery) (table.TableListResult[model.OrderTimelineEventDTO], error)
+	ToothChartShades() map[string][]string
+	PreviewToothChart(chart *toothchart.Chart) (*model.ToothChartPreviewDTO, error)
 	GetByID(ctx context.Context, id int64) (*model.OrderDTO, error)
 	GetByOrderIDAndOrderItemID(ctx context.Context, orderID, orderItemID int64) (*model.OrderDTO, error)
 	PrepareForRemakeByOrderID(ctx context.Context, orderID int64) (*model.OrderDTO, error)
@@ -37,7 +49,13 @@ type OrderService interface {
 	CompletedList(ctx context.Context, deptID int, query table.TableQuery) (table.TableListResult[model.CompletedOrderDTO], error)
 	Search(ctx context.Context, deptID int, query dbutils.SearchQuery) (dbutils.SearchResult[model.OrderDTO], error)
 	Delete(ctx context.Context, id int64) error
-	SyncPrice(ctx context.Context, orderID int64) (float64, error)
+	Cancel(ctx context.Context, deptID, userID int, orderID int64, input *model.OrderCancelDTO) (*model.OrderCancellationDTO, error)
+	Cancellations(ctx context.Context, deptID int, orderID int64) ([]*model.OrderCancellationDTO, error)
+	Split(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemSplitDTO) (*model.OrderItemLineageDTO, error)
+	Merge(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemMergeDTO) (*model.OrderItemLineageDTO, error)
+	Lineage(ctx context.Context, orderItemID int64) ([]*model.OrderItemLineageDTO, error)
+	SyncPrice(ctx context.Context, userID int, orderID int64) (float64, error)
+	UpdateShippingFee(ctx context.Context, orderID int64, shippingFee float64) (*model.OrderDTO, error)
 }
 
 type orderService struct {
@@ -123,7 +141,39 @@ func kOrderSearch(q dbutils.SearchQuery) string {
 	return fmt.Sprintf("order:search:k%s:l%d:p%d:o%s:d%s", q.Keyword, q.Limit, q.Page, orderBy, q.Direction)
 }
 
+// receivable cache keys owned by the receivable and dashboard features
+func kReceivableKeys(deptID, clinicID int) []string {
+	return []string{
+		fmt.Sprintf("receivable:dpt%d:c%d:*", deptID, clinicID),
+		fmt.Sprintf("dashboard:receivable_aging:dpt%d:*", deptID),
+	}
+}
+
+// invalidateReceivable drops the receivable summary, ledger and aging caches
+// of the clinics of the orders, whose balance follows completed order totals.
+func invalidateReceivable(ctx context.Context, db *generated.Client, orderIDs ...int64) {
+	orders, err := db.Order.Query().
+		Where(order.IDIn(orderIDs...)).
+		Select(order.FieldDepartmentID, order.FieldClinicID).
+		All(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("[OrderService] load clinics of orders %v failed: %v", orderIDs, err))
+		return
+	}
+	for _, o := range orders {
+		if o.DepartmentID == nil || o.ClinicID == nil {
+			continue
+		}
+		cache.InvalidateKeys(kReceivableKeys(*o.DepartmentID, *o.ClinicID)...)
+		realtime.BroadcastToDept(*o.DepartmentID, "dashboard:receivable_aging", nil)
+	}
+}
+
 func (s *orderService) Create(ctx context.Context, deptID int, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error) {
+	if err := normalizeToothCharts(input); err != nil {
+		return nil, err
+	}
+
 	dto, err := s.repo.Create(ctx, userID, input)
 	if err != nil {
 		return nil, err
@@ -156,17 +206,26 @@ func (s *orderService) Create(ctx context.Context, deptID int, userID int, input
 	realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:active_today", nil)
 
 	return dto, nil
 }
 
 func (s *orderService) Update(ctx context.Context, deptID, userID int, input *model.OrderUpsertDTO) (*model.OrderDTO, error) {
+	if err := normalizeToothCharts(input); err != nil {
+		return nil, err
+	}
+
+	prev, _ := s.repo.GetByID(ctx, input.DTO.ID)
+
 	dto, err := s.repo.Update(ctx, userID, input)
 	if err != nil {
 		return nil, err
 	}
 
+	s.logCustomFieldEdits(userID, prev, dto)
+
 	if dto != nil {
 		cache.InvalidateKeys(kOrderByID(dto.ID), kOrderByIDAll(dto.ID))
 	}
@@ -180,6 +239,7 @@ func (s *orderService) Update(ctx context.Context, deptID, userID int, input *mo
 	realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
 	realtime.BroadcastToDept(deptID, "dashboard:active_today", nil)
 
 	s.upsertSearch(ctx, deptID, dto)
@@ -187,8 +247,8 @@ func (s *orderService) Update(ctx context.Context, deptID, userID int, input *mo
 	return dto, nil
 }
 
-func (s *orderService) UpdateStatus(ctx context.Context, deptID int, orderItemProcessID int64, status string) (*model.OrderItemDTO, error) {
-	out, err := s.repo.UpdateStatus(ctx, orderItemProcessID, status)
+func (s *orderService) UpdateStatus(ctx context.Context, deptID, userID int, orderItemProcessID int64, status string) (*model.OrderItemDTO, error) {
+	out, err := s.repo.UpdateStatus(repository.WithStatusActor(ctx, userID), orderItemProcessID, status)
 	if err != nil {
 		return nil, err
 	}
@@ -206,6 +266,89 @@ func (s *orderService) UpdateStatus(ctx context.Context, deptID int, orderItemPr
 	return out, nil
 }
 
+// StatusTransitionPermission returns the permissions allowing a user to move
+// the process to status by hand, or an error when the move is not declared.
+func (s *orderService) StatusTransitionPermission(ctx context.Context, orderItemProcessID int64, status string) ([]string, error) {
+	return s.repo.StatusTransitionPermission(ctx, orderItemProcessID, status)
+}
+
+func (s *orderService) StatusMachine() map[string][]repository.StatusTransition {
+	return map[string][]repository.StatusTransition{
+		repository.StatusEntityOrder:     repository.StatusTransitions(repository.StatusEntityOrder),
+		repository.StatusEntityOrderItem: repository.StatusTransitions(repository.StatusEntityOrderItem),
+		repository.StatusEntityProcess:   repository.StatusTransitions(repository.StatusEntityProcess),
+	}
+}
+
+func (s *orderService) StatusHistory(ctx context.Context, orderID int64) ([]*model.OrderStatusHistoryDTO, error) {
+	return s.repo.StatusHistory(ctx, orderID)
+}
+
+func (s *orderService) Timeline(ctx context.Context, orderID int64, query model.OrderTimelineQuery) (table.TableListResult[model.OrderTimelineEventDTO], error) {
+	return s.repo.Timeline(ctx, orderID, query)
+}
+
+// logCustomFieldEdits records changed custom fields of the order and of its
+// latest item so they show up on the order timeline.
+func (s *orderService) logCustomFieldEdits(userID int, prev, next *model.OrderDTO) {
+	if prev == nil || next == nil {
+		return
+	}
+
+	data := map[string]any{"order_id": next.ID}
+	changed := false
+
+	if diff := customFieldsDiff(prev.CustomFields, next.CustomFields); len(diff) > 0 {
+		data["order_changes"] = diff
+		changed = true
+	}
+
+	if prev.LatestOrderItem != nil && next.LatestOrderItem != nil &&
+		prev.LatestOrderItem.ID == next.LatestOrderItem.ID {
+		if diff := customFieldsDiff(prev.LatestOrderItem.CustomFields, next.LatestOrderItem.CustomFields); len(diff) > 0 {
+			data["order_item_id"] = next.LatestOrderItem.ID
+			data["order_item_code"] = next.LatestOrderItem.Code
+			data["order_item_changes"] = diff
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   "update_custom_fields",
+		Module:   "order",
+		TargetID: int(next.ID),
+		Data:     data,
+	})
+}
+
+func customFieldsDiff(prev, next map[string]any) map[string]any {
+	diff := map[string]any{}
+	for k, v := range next {
+		if old, ok := prev[k]; !ok || !sameJSON(old, v) {
+			diff[k] = map[string]any{"from": prev[k], "to": v}
+		}
+	}
+	for k, old := range prev {
+		if _, ok := next[k]; !ok {
+			diff[k] = map[string]any{"from": old, "to": nil}
+		}
+	}
+	return diff
+}
+
+// sameJSON compares values as stored, ignoring Go type differences such as
+// int vs float64 between a freshly saved map and one read back from the DB.
+func sameJSON(a, b any) bool {
+	ab, errA := json.Marshal(a)
+	bb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ab) == string(bb)
+}
+
 func (s *orderService) upsertSearch(ctx context.Context, deptID int, dto *model.OrderDTO) {
 	kwPtr, _ := searchutils.BuildKeywords(ctx, s.cfMgr, "order", []any{dto.Code}, dto.CustomFields)
 
@@ -255,8 +398,38 @@ func (s *orderService) GetAllOrderMaterials(ctx context.Context, orderID int64)
 	return s.repo.GetAllOrderMaterials(ctx, orderID)
 }
 
-func (s *orderService) SyncPrice(ctx context.Context, orderID int64) (float64, error) {
-	return s.repo.SyncPrice(ctx, orderID)
+func (s *orderService) SyncPrice(ctx context.Context, userID int, orderID int64) (float64, error) {
+	total, err := s.repo.SyncPrice(ctx, orderID)
+	if err != nil {
+		return 0, err
+	}
+	invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), orderID)
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   "sync_price",
+		Module:   "order",
+		TargetID: int(orderID),
+		Data: map[string]any{
+			"order_id":    orderID,
+			"total_price": total,
+		},
+	})
+
+	return total, nil
+}
+
+func (s *orderService) UpdateShippingFee(ctx context.Context, orderID int64, shippingFee float64) (*model.OrderDTO, error) {
+	dto, err := s.repo.UpdateShippingFee(ctx, orderID, shippingFee)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kOrderByID(orderID), kOrderByIDAll(orderID))
+	cache.InvalidateKeys(kOrderAll()...)
+	invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), orderID)
+
+	return dto, nil
 }
 
 func (s *orderService) NewestList(ctx context.Context, deptID int, q table.TableQuery) (table.TableListResult[model.NewestOrderDTO], error) {
@@ -385,6 +558,109 @@ func (s *orderService) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+// Cancel cancels the order or some of its items and refreshes the lists and
+// dashboards that count active cases.
+func (s *orderService) Cancel(ctx context.Context, deptID, userID int, orderID int64, input *model.OrderCancelDTO) (*model.OrderCancellationDTO, error) {
+	out, err := s.repo.Cancel(ctx, deptID, userID, orderID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kOrderByID(orderID), kOrderByIDAll(orderID))
+	cache.InvalidateKeys(kOrderAll()...)
+	invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), orderID)
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   "cancel",
+		Module:   "order",
+		TargetID: int(orderID),
+		Data: map[string]any{
+			"order_id":        orderID,
+			"cancellation_id": out.ID,
+			"scope":           out.Scope,
+			"order_item_ids":  out.OrderItemIDs,
+			"reason_code":     out.ReasonCode,
+			"fee":             out.Fee,
+		},
+	})
+
+	pubsub.PublishAsync("dashboard:daily:active:stats", &model.CaseDailyActiveStatsUpsert{
+		DepartmentID: deptID,
+		StatAt:       time.Now(),
+	})
+
+	realtime.BroadcastToDept(deptID, "dashboard:daily:active:stats", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:active_today", nil)
+
+	return out, nil
+}
+
+func (s *orderService) Cancellations(ctx context.Context, deptID int, orderID int64) ([]*model.OrderCancellationDTO, error) {
+	return s.repo.Cancellations(ctx, deptID, orderID)
+}
+
+// Split moves some rows of an order item to a new child item; the prices of
+// both are synced with the move.
+func (s *orderService) Split(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemSplitDTO) (*model.OrderItemLineageDTO, error) {
+	out, err := s.repo.Split(ctx, deptID, userID, orderID, orderItemID, input)
+	if err != nil {
+		return nil, err
+	}
+	s.afterLineage(ctx, deptID, userID, out)
+	return out, nil
+}
+
+// Merge moves every row of another item into the order item; the prices of
+// the orders involved are synced with the move.
+func (s *orderService) Merge(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderItemMergeDTO) (*model.OrderItemLineageDTO, error) {
+	out, err := s.repo.Merge(ctx, deptID, userID, orderID, orderItemID, input)
+	if err != nil {
+		return nil, err
+	}
+	s.afterLineage(ctx, deptID, userID, out)
+	return out, nil
+}
+
+func (s *orderService) Lineage(ctx context.Context, orderItemID int64) ([]*model.OrderItemLineageDTO, error) {
+	return s.repo.Lineage(ctx, orderItemID)
+}
+
+func (s *orderService) afterLineage(ctx context.Context, deptID, userID int, out *model.OrderItemLineageDTO) {
+	for _, id := range []int64{out.SourceOrderID, out.TargetOrderID} {
+		cache.InvalidateKeys(kOrderByID(id), kOrderByIDAll(id))
+	}
+	cache.InvalidateKeys(kOrderAll()...)
+	invalidateReceivable(ctx, s.deps.Ent.(*generated.Client), out.SourceOrderID, out.TargetOrderID)
+	if out.SourceOrderRemoved {
+		s.unlinkSearch(out.SourceOrderID)
+	}
+
+	pubsub.PublishAsync("log:create", &auditlog_model.AuditLogRequest{
+		UserID:   userID,
+		Action:   out.Kind,
+		Module:   "order",
+		TargetID: int(out.TargetOrderID),
+		Data: map[string]any{
+			"lineage_id":              out.ID,
+			"source_order_id":         out.SourceOrderID,
+			"source_order_item_id":    out.SourceOrderItemID,
+			"target_order_id":         out.TargetOrderID,
+			"target_order_item_id":    out.TargetOrderItemID,
+			"order_item_product_ids":  out.OrderItemProductIDs,
+			"order_item_material_ids": out.OrderItemMaterialIDs,
+		},
+	})
+
+	realtime.BroadcastToDept(deptID, "dashboard:statuses", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:due_today", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:at_risk", nil)
+	realtime.BroadcastToDept(deptID, "dashboard:active_today", nil)
+}
+
 func (s *orderService) Search(ctx context.Context, deptID int, q dbutils.SearchQuery) (dbutils.SearchResult[model.OrderDTO], error) {
 	type boxed = dbutils.SearchResult[model.OrderDTO]
 	key := kOrderSearch(q)
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/order/repository"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+const reorderCodeTTL = 15 * time.Minute
+
+var (
+	ErrInvalidTemplate = errors.New("template name is required and it needs at least one product")
+	ErrSourceNotFound  = errors.New("order item not found in the department")
+)
+
+// item custom fields describing the progress of the previous case; a new
+// order starts without them.
+var reorderDroppedItemFields = []string{"status", "remake_type", "remake_reason", "delivery_date"}
+
+type OrderTemplateService interface {
+	// Reorder creates a new order from an existing order item.
+	Reorder(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderReorderDTO) (*model.OrderDTO, error)
+
+	List(ctx context.Context, deptID int, clinicID, dentistID *int) ([]*model.OrderTemplateDTO, error)
+	GetByID(ctx context.Context, deptID int, id int) (*model.OrderTemplateDTO, error)
+	Create(ctx context.Context, deptID, userID int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+	// Instantiate creates a new order from a template.
+	Instantiate(ctx context.Context, deptID, userID int, id int, input *model.OrderReorderDTO) (*model.OrderDTO, error)
+}
+
+type orderTemplateService struct {
+	repo        repository.OrderTemplateRepository
+	orderRepo   repository.OrderRepository
+	processRepo repository.OrderItemProcessRepository
+	codeSvc     OrderCodeService
+	orderSvc    OrderService
+	deps        *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewOrderTemplateService(
+	repo repository.OrderTemplateRepository,
+	orderRepo repository.OrderRepository,
+	processRepo repository.OrderItemProcessRepository,
+	codeSvc OrderCodeService,
+	orderSvc OrderService,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) OrderTemplateService {
+	return &orderTemplateService{
+		repo:        repo,
+		orderRepo:   orderRepo,
+		processRepo: processRepo,
+		codeSvc:     codeSvc,
+		orderSvc:    orderSvc,
+		deps:        deps,
+	}
+}
+
+// sourceContent loads an order item of the department and what a new order
+// copies from it.
+func (s *orderTemplateService) sourceContent(ctx context.Context, deptID int, orderID, orderItemID int64) (*model.OrderDTO, *model.OrderTemplateContentDTO, error) {
+	src, err := s.orderRepo.GetByOrderIDAndOrderItemID(ctx, orderID, orderItemID)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return nil, nil, ErrSourceNotFound
+		}
+		return nil, nil, err
+	}
+	if src.DepartmentID == nil || *src.DepartmentID != deptID ||
+		src.LatestOrderItem == nil || src.LatestOrderItem.OrderID != src.ID {
+		return nil, nil, ErrSourceNotFound
+	}
+
+	processes, err := s.processRepo.GetProcessesByOrderItemID(ctx, nil, orderItemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, templateContentFromItem(src, src.LatestOrderItem, processes), nil
+}
+
+// templateContentFromItem keeps what describes the work of an item and
+// drops ids, statuses, loan tracking and progress.
+func templateContentFromItem(order *model.OrderDTO, item *model.OrderItemDTO, processes []*model.OrderItemProcessDTO) *model.OrderTemplateContentDTO {
+	out := &model.OrderTemplateContentDTO{
+		CustomFields:     maps.Clone(order.CustomFields),
+		ItemCustomFields: maps.Clone(item.CustomFields),
+	}
+	for _, k := range reorderDroppedItemFields {
+		delete(out.ItemCustomFields, k)
+	}
+
+	for _, p := range item.Products {
+		if p == nil || p.ProductID == 0 {
+			continue
+		}
+		out.Products = append(out.Products, &model.OrderItemProductDTO{
+			ProductID:     p.ProductID,
+			ProductCode:   p.ProductCode,
+			ProductName:   p.ProductName,
+			Quantity:      p.Quantity,
+			RetailPrice:   p.RetailPrice,
+			TeethPosition: p.TeethPosition,
+			ToothChart:    p.ToothChart,
+			Note:          p.Note,
+		})
+	}
+
+	copyMaterials := func(list []*model.OrderItemMaterialDTO) []*model.OrderItemMaterialDTO {
+		var res []*model.OrderItemMaterialDTO
+		for _, m := range list {
+			if m == nil || m.MaterialID == 0 {
+				continue
+			}
+			res = append(res, &model.OrderItemMaterialDTO{
+				MaterialID:   m.MaterialID,
+				MaterialCode: m.MaterialCode,
+				MaterialName: m.MaterialName,
+				Quantity:     m.Quantity,
+				Type:         m.Type,
+				RetailPrice:  m.RetailPrice,
+				Note:         m.Note,
+			})
+		}
+		return res
+	}
+	out.ConsumableMaterials = copyMaterials(item.ConsumableMaterials)
+	out.LoanerMaterials = copyMaterials(item.LoanerMaterials)
+
+	for _, p := range processes {
+		if p == nil || p.ProcessID == nil {
+			continue
+		}
+		step := &model.OrderItemProcessDTO{
+			ProcessID:   p.ProcessID,
+			ProcessName: p.ProcessName,
+			StepNumber:  p.StepNumber,
+			DependsOn:   p.DependsOn,
+			SectionID:   p.SectionID,
+			SectionName: p.SectionName,
+			LeaderID:    p.LeaderID,
+			LeaderName:  p.LeaderName,
+			Color:       p.Color,
+		}
+		if status, _ := p.CustomFields["status"].(string); status == "skipped" {
+			step.CustomFields = map[string]any{"status": "skipped"}
+		}
+		out.Processes = append(out.Processes, step)
+	}
+
+	return out
+}
+
+// instantiate creates an order from content under a newly reserved code.
+// Promotions are never carried over.
+func (s *orderTemplateService) instantiate(
+	ctx context.Context,
+	deptID, userID int,
+	base *model.OrderDTO,
+	content *model.OrderTemplateContentDTO,
+	input *model.OrderReorderDTO,
+) (*model.OrderDTO, error) {
+	if input == nil {
+		input = &model.OrderReorderDTO{}
+	}
+
+	code, _, err := s.codeSvc.ReserveOrderCode(ctx, time.Now(), reorderCodeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	dto := model.OrderDTO{
+		DepartmentID: &deptID,
+		Code:         &code,
+		ClinicID:     base.ClinicID,
+		ClinicName:   base.ClinicName,
+		DentistID:    base.DentistID,
+		DentistName:  base.DentistName,
+		PatientID:    base.PatientID,
+		PatientName:  base.PatientName,
+		RefUserID:    base.RefUserID,
+		RefUserName:  base.RefUserName,
+		CustomFields: maps.Clone(content.CustomFields),
+	}
+	if input.ClinicID != nil {
+		dto.ClinicID, dto.ClinicName = input.ClinicID, input.ClinicName
+	}
+	if input.DentistID != nil {
+		dto.DentistID, dto.DentistName = input.DentistID, input.DentistName
+	}
+	if input.PatientID != nil {
+		dto.PatientID, dto.PatientName = input.PatientID, input.PatientName
+	}
+	if input.RefUserID != nil {
+		dto.RefUserID, dto.RefUserName = input.RefUserID, input.RefUserName
+	}
+	if dto.CustomFields == nil {
+		dto.CustomFields = map[string]any{}
+	}
+	maps.Copy(dto.CustomFields, input.CustomFields)
+
+	itemFields := maps.Clone(content.ItemCustomFields)
+	if itemFields == nil {
+		itemFields = map[string]any{}
+	}
+	maps.Copy(itemFields, input.ItemCustomFields)
+	if input.DeliveryDate != nil {
+		itemFields["delivery_date"] = input.DeliveryDate.Format(time.RFC3339)
+	}
+
+	// fresh copies: the order repository fills ids and prices in place
+	item := model.OrderItemDTO{CustomFields: itemFields}
+	for _, p := range content.Products {
+		cp := *p
+		item.Products = append(item.Products, &cp)
+	}
+	for _, m := range content.ConsumableMaterials {
+		cp := *m
+		item.ConsumableMaterials = append(item.ConsumableMaterials, &cp)
+	}
+	for _, m := range content.LoanerMaterials {
+		cp := *m
+		item.LoanerMaterials = append(item.LoanerMaterials, &cp)
+	}
+
+	orderCol := []string{"order"}
+	itemCol := []string{"order-item"}
+	dto.LatestOrderItemUpsert = &model.OrderItemUpsertDTO{
+		DTO:         item,
+		Collections: &itemCol,
+		Routing:     content.Processes,
+	}
+
+	return s.orderSvc.Create(ctx, deptID, userID, &model.OrderUpsertDTO{
+		DTO:         dto,
+		Collections: &orderCol,
+	})
+}
+
+func (s *orderTemplateService) Reorder(ctx context.Context, deptID, userID int, orderID, orderItemID int64, input *model.OrderReorderDTO) (*model.OrderDTO, error) {
+	src, content, err := s.sourceContent(ctx, deptID, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	return s.instantiate(ctx, deptID, userID, src, content, input)
+}
+
+func validateTemplate(input *model.OrderTemplateDTO) error {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len(input.Content.Products) == 0 {
+		return ErrInvalidTemplate
+	}
+	return nil
+}
+
+func (s *orderTemplateService) List(ctx context.Context, deptID int, clinicID, dentistID *int) ([]*model.OrderTemplateDTO, error) {
+	return s.repo.List(ctx, deptID, clinicID, dentistID)
+}
+
+func (s *orderTemplateService) GetByID(ctx context.Context, deptID int, id int) (*model.OrderTemplateDTO, error) {
+	return s.repo.GetByID(ctx, deptID, id)
+}
+
+// Create saves a template. With source_order_item_id the content, and the
+// clinic or dentist when not given, come from that order item.
+func (s *orderTemplateService) Create(ctx context.Context, deptID, userID int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error) {
+	if input.SourceOrderItemID != nil {
+		orderID, err := s.orderIDOfItem(ctx, *input.SourceOrderItemID)
+		if err != nil {
+			return nil, err
+		}
+		src, content, err := s.sourceContent(ctx, deptID, orderID, *input.SourceOrderItemID)
+		if err != nil {
+			return nil, err
+		}
+		input.Content = *content
+		if input.ClinicID == nil && input.DentistID == nil {
+			input.ClinicID, input.ClinicName = src.ClinicID, src.ClinicName
+		}
+	}
+	if err := validateTemplate(input); err != nil {
+		return nil, err
+	}
+	return s.repo.Create(ctx, deptID, userID, input)
+}
+
+func (s *orderTemplateService) orderIDOfItem(ctx context.Context, orderItemID int64) (int64, error) {
+	item, err := s.deps.Ent.(*generated.Client).OrderItem.Get(ctx, orderItemID)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return 0, ErrSourceNotFound
+		}
+		return 0, err
+	}
+	return item.OrderID, nil
+}
+
+func (s *orderTemplateService) Update(ctx context.Context, deptID int, id int, input *model.OrderTemplateDTO) (*model.OrderTemplateDTO, error) {
+	if err := validateTemplate(input); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, deptID, id, input)
+}
+
+func (s *orderTemplateService) Delete(ctx context.Context, deptID int, id int) error {
+	return s.repo.Delete(ctx, deptID, id)
+}
+
+func (s *orderTemplateService) Instantiate(ctx context.Context, deptID, userID int, id int, input *model.OrderReorderDTO) (*model.OrderDTO, error) {
+	tpl, err := s.repo.GetByID(ctx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	base := &model.OrderDTO{
+		ClinicID:    tpl.ClinicID,
+		ClinicName:  tpl.ClinicName,
+		DentistID:   tpl.DentistID,
+		DentistName: tpl.DentistName,
+	}
+	return s.instantiate(ctx, deptID, userID, base, &tpl.Content, input)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/toothchart"
+)
+
+// normalizeToothCharts validates the tooth charts of the upserted products;
+// the repository derives quantity and teeth_position from them.
+func normalizeToothCharts(input *model.OrderUpsertDTO) error {
+	if input == nil || input.DTO.LatestOrderItemUpsert == nil {
+		return nil
+	}
+	for _, p := range input.DTO.LatestOrderItemUpsert.DTO.Products {
+		if p == nil || p.ToothChart == nil {
+			continue
+		}
+		if err := p.ToothChart.Normalize(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *orderService) ToothChartShades() map[string][]string {
+	return toothchart.ShadeGuides()
+}
+
+func (s *orderService) PreviewToothChart(chart *toothchart.Chart) (*model.ToothChartPreviewDTO, error) {
+	if chart == nil {
+		chart = &toothchart.Chart{}
+	}
+	if err := chart.Normalize(); err != nil {
+		return nil, err
+	}
+	return &model.ToothChartPreviewDTO{
+		Chart:     chart,
+		Quantity:  chart.Count(),
+		FDI:       chart.String(),
+		Universal: chart.UniversalString(),
+	}, nil
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/logger"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type PayrollHandler struct {
+	svc  service.PayrollService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPayrollHandler(svc service.PayrollService, deps *module.ModuleDeps[config.ModuleConfig]) *PayrollHandler {
+	return &PayrollHandler{svc: svc, deps: deps}
+}
+
+func (h *PayrollHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/payroll/piece-rates", h.ListPieceRates)
+	app.RouterPost(router, "/:dept_id<int>/payroll/piece-rates", h.CreatePieceRate)
+	app.RouterPut(router, "/:dept_id<int>/payroll/piece-rates/:id<int>", h.UpdatePieceRate)
+	app.RouterDelete(router, "/:dept_id<int>/payroll/piece-rates/:id<int>", h.DeletePieceRate)
+
+	app.RouterGet(router, "/:dept_id<int>/payroll/policy", h.GetPolicy)
+	app.RouterPut(router, "/:dept_id<int>/payroll/policy", h.SavePolicy)
+
+	app.RouterGet(router, "/:dept_id<int>/payroll/statements", h.List)
+	app.RouterPost(router, "/:dept_id<int>/payroll/statements/generate", h.Generate)
+	app.RouterGet(router, "/:dept_id<int>/payroll/statements/export", h.ExportPeriod)
+	app.RouterGet(router, "/:dept_id<int>/payroll/statements/:id<int>", h.GetByID)
+	app.RouterPost(router, "/:dept_id<int>/payroll/statements/:id<int>/approve", h.Approve)
+	app.RouterGet(router, "/:dept_id<int>/payroll/statements/:id<int>/export", h.Export)
+}
+
+func (h *PayrollHandler) ListPieceRates(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	processID, err := utils.GetQueryAsNillableInt(c, "process_id")
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid process_id")
+	}
+	res, err := h.svc.ListPieceRates(c.UserContext(), deptID, processID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *PayrollHandler) CreatePieceRate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PieceRateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.CreatePieceRate(c.UserContext(), deptID, payload)
+	if err != nil {
+		return pieceRateError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *PayrollHandler) UpdatePieceRate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	payload, err := app.ParseBody[model.PieceRateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.UpdatePieceRate(c.UserContext(), deptID, id, payload)
+	if err != nil {
+		return pieceRateError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func pieceRateError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidPieceRate):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	case errors.Is(err, repository.ErrPieceRateExists):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+	case generated.IsNotFound(err):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, "piece rate, process, product or restoration type not found")
+	case generated.IsConstraintError(err):
+		return client_error.ResponseError(c, fiber.StatusConflict, err, repository.ErrPieceRateExists.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
+
+func (h *PayrollHandler) DeletePieceRate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	if err := h.svc.DeletePieceRate(c.UserContext(), deptID, id); err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "piece rate not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *PayrollHandler) GetPolicy(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	dto, err := h.svc.GetPolicy(c.UserContext(), deptID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PayrollHandler) SavePolicy(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PayrollPolicyDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+	dto, err := h.svc.SavePolicy(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPolicy) {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PayrollHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := table.ParseTableQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	periodStart, err := utils.ParseNillableDate(utils.GetQueryAsString(c, "period_start"))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid period_start")
+	}
+	periodEnd, err := utils.ParseNillableDate(utils.GetQueryAsString(c, "period_end"))
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid period_end")
+	}
+
+	res, err := h.svc.List(c.UserContext(), deptID, periodStart, periodEnd, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *PayrollHandler) Generate(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PayrollGenerateDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	res, err := h.svc.Generate(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPeriod):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrNothingToGenerate), errors.Is(err, repository.ErrStatementLocked),
+			errors.Is(err, repository.ErrStatementOverlaps):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *PayrollHandler) GetByID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "payroll statement not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PayrollHandler) Approve(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.approve"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.Approve(c.UserContext(), deptID, int64(id), userID)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "payroll statement not found")
+		}
+		if errors.Is(err, repository.ErrStatementLocked) {
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PayrollHandler) Export(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.export"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "payroll statement not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	filename := fmt.Sprintf("payroll_%d_%s.xlsx", dto.StaffID, dto.PeriodStart.Format("20060102"))
+	return writeWorkbook(c, []*model.PayrollStatementDTO{dto}, filename)
+}
+
+func (h *PayrollHandler) ExportPeriod(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "payroll.export"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	var periodStart, periodEnd time.Time
+	for _, d := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"period_start", &periodStart},
+		{"period_end", &periodEnd},
+	} {
+		raw := utils.GetQueryAsString(c, d.name)
+		if raw == "" {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, nil, d.name+" is required")
+		}
+		var err error
+		*d.dst, err = utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid "+d.name)
+		}
+	}
+
+	list, err := h.svc.ListWithLines(c.UserContext(), deptID, periodStart, periodEnd)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	filename := fmt.Sprintf("payroll_%s_%s.xlsx", periodStart.Format("20060102"), periodEnd.Format("20060102"))
+	return writeWorkbook(c, list, filename)
+}
+
+func writeWorkbook(c *fiber.Ctx, statements []*model.PayrollStatementDTO, filename string) error {
+	x, err := service.BuildPayrollWorkbook(statements)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, "failed to build excel file")
+	}
+
+	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if err := x.Write(c.Response().BodyWriter()); err != nil {
+		logger.Error("payroll.export.write_failed", "err", err)
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, "failed to write excel file")
+	}
+	return nil
+}
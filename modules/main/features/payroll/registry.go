@@ -0,0 +1,31 @@
+package payroll
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "payroll" }
+func (feature) Priority() int { return 75 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	entClient := deps.Ent.(*generated.Client)
+	rateRepo := repository.NewPieceRateRepository(entClient, deps)
+	repo := repository.NewPayrollRepository(entClient, deps.DB, deps)
+	svc := service.NewPayrollService(rateRepo, repo, deps)
+	h := handler.NewPayrollHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
@@ -0,0 +1,507 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/payrollpolicy"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/payrollstatement"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/payrollstatementline"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+const (
+	StatementStatusDraft    = "draft"
+	StatementStatusApproved = "approved"
+)
+
+var (
+	ErrStatementLocked   = errors.New("payroll statement is approved and locked")
+	ErrStatementOverlaps = errors.New("period overlaps an approved payroll statement")
+)
+
+// WorkRow is one product line of a completed process step.
+type WorkRow struct {
+	InProgressID       int64
+	StaffID            int64
+	StaffName          *string
+	CompletedAt        time.Time
+	OrderID            int64
+	OrderCode          *string
+	OrderItemID        int64
+	OrderItemCode      *string
+	ProcessID          *int
+	ProcessName        *string
+	ProductID          *int
+	ProductName        *string
+	Quantity           int
+	RestorationTypeIDs []int64
+}
+
+// RemakeRow pairs a remake log with a completed step on the original item.
+type RemakeRow struct {
+	RemakeLogID int64
+	Action      string
+	Reason      *string
+	CreatedAt   time.Time
+	Work        WorkRow
+}
+
+type PayrollRepository interface {
+	GetPolicy(ctx context.Context, deptID int) (*model.PayrollPolicyDTO, error)
+	SavePolicy(ctx context.Context, deptID, userID int, input *model.PayrollPolicyDTO) (*model.PayrollPolicyDTO, error)
+
+	WorkRows(ctx context.Context, deptID int, from, to time.Time, staffID *int64) ([]*WorkRow, error)
+	RemakeRows(ctx context.Context, deptID int, from, to time.Time) ([]*RemakeRow, error)
+
+	// SaveStatements replaces the draft statements of the period in scope;
+	// approved statements are kept as they are.
+	SaveStatements(ctx context.Context, deptID, userID int, input *model.PayrollGenerateDTO, statements []*model.PayrollStatementDTO) error
+	Approve(ctx context.Context, deptID int, id int64, userID int) (*model.PayrollStatementDTO, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.PayrollStatementDTO, error)
+	List(ctx context.Context, deptID int, periodStart, periodEnd *time.Time, query table.TableQuery) (table.TableListResult[model.PayrollStatementDTO], error)
+	ListWithLines(ctx context.Context, deptID int, periodStart, periodEnd time.Time) ([]*model.PayrollStatementDTO, error)
+}
+
+type payrollRepository struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPayrollRepository(db *generated.Client, sqlDB *sql.DB, deps *module.ModuleDeps[config.ModuleConfig]) PayrollRepository {
+	return &payrollRepository{db: db, sqlDB: sqlDB, deps: deps}
+}
+
+func (r *payrollRepository) GetPolicy(ctx context.Context, deptID int) (*model.PayrollPolicyDTO, error) {
+	entity, err := r.db.PayrollPolicy.Query().
+		Where(payrollpolicy.DepartmentIDEQ(deptID)).
+		Only(ctx)
+	if generated.IsNotFound(err) {
+		return &model.PayrollPolicyDTO{
+			RemakeDeduction:   "none",
+			RemakeAttribution: "last",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.PayrollPolicy, *model.PayrollPolicyDTO](entity), nil
+}
+
+func (r *payrollRepository) SavePolicy(ctx context.Context, deptID, userID int, input *model.PayrollPolicyDTO) (*model.PayrollPolicyDTO, error) {
+	n, err := r.db.PayrollPolicy.Update().
+		Where(payrollpolicy.DepartmentIDEQ(deptID)).
+		SetRemakeDeduction(input.RemakeDeduction).
+		SetRemakeDeductionValue(input.RemakeDeductionValue).
+		SetRemakeAttribution(input.RemakeAttribution).
+		SetRemakeWindowDays(input.RemakeWindowDays).
+		SetIncludeAdjust(input.IncludeAdjust).
+		SetUpdatedBy(userID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		err = r.db.PayrollPolicy.Create().
+			SetDepartmentID(deptID).
+			SetRemakeDeduction(input.RemakeDeduction).
+			SetRemakeDeductionValue(input.RemakeDeductionValue).
+			SetRemakeAttribution(input.RemakeAttribution).
+			SetRemakeWindowDays(input.RemakeWindowDays).
+			SetIncludeAdjust(input.IncludeAdjust).
+			SetUpdatedBy(userID).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.GetPolicy(ctx, deptID)
+}
+
+const workRowColumns = `
+  ip.id,
+  ip.assigned_id,
+  ip.assigned_name,
+  ip.completed_at,
+  o.id,
+  o.code,
+  oi.id,
+  oi.code,
+  p.process_id,
+  p.process_name,
+  oip.product_id,
+  COALESCE(pr.name, oip.product_code),
+  COALESCE(oip.quantity, 1),
+  COALESCE(
+    array_agg(prt.restoration_type_id) FILTER (WHERE prt.restoration_type_id IS NOT NULL),
+    '{}'
+  )`
+
+const workRowJoins = `
+  LEFT JOIN order_item_processes p ON p.id = ip.process_id
+  LEFT JOIN order_item_products oip ON oip.order_item_id = ip.order_item_id
+  LEFT JOIN products pr ON pr.id = oip.product_id
+  LEFT JOIN product_restoration_types prt ON prt.product_id = oip.product_id`
+
+const workRowGroupBy = `ip.id, o.id, oi.id, p.process_id, p.process_name, oip.id, pr.name`
+
+func scanWorkRow(dest *WorkRow, extra ...any) []any {
+	return append(extra,
+		&dest.InProgressID,
+		&dest.StaffID,
+		&dest.StaffName,
+		&dest.CompletedAt,
+		&dest.OrderID,
+		&dest.OrderCode,
+		&dest.OrderItemID,
+		&dest.OrderItemCode,
+		&dest.ProcessID,
+		&dest.ProcessName,
+		&dest.ProductID,
+		&dest.ProductName,
+		&dest.Quantity,
+		(*pq.Int64Array)(&dest.RestorationTypeIDs),
+	)
+}
+
+func (r *payrollRepository) WorkRows(ctx context.Context, deptID int, from, to time.Time, staffID *int64) ([]*WorkRow, error) {
+	q := `
+SELECT` + workRowColumns + `
+FROM order_item_process_in_progresses ip
+JOIN order_items oi ON oi.id = ip.order_item_id AND oi.deleted_at IS NULL
+JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL` + workRowJoins + `
+WHERE
+  ip.completed_at >= $1
+  AND ip.completed_at <  $2
+  AND ip.assigned_id IS NOT NULL
+  AND o.department_id = $3
+  AND ($4::BIGINT IS NULL OR ip.assigned_id = $4::BIGINT)
+GROUP BY ` + workRowGroupBy + `
+ORDER BY ip.assigned_id, ip.completed_at, ip.id, oip.id;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, from, to, deptID, staffID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*WorkRow{}
+	for rows.Next() {
+		var w WorkRow
+		if err := rows.Scan(scanWorkRow(&w)...); err != nil {
+			return nil, err
+		}
+		out = append(out, &w)
+	}
+	return out, rows.Err()
+}
+
+func (r *payrollRepository) RemakeRows(ctx context.Context, deptID int, from, to time.Time) ([]*RemakeRow, error) {
+	q := `
+SELECT
+  rl.id,
+  rl.action,
+  rl.reason,
+  rl.created_at,` + workRowColumns + `
+FROM order_item_remake_logs rl
+JOIN order_items rmk ON rmk.id = rl.item_id AND rmk.deleted_at IS NULL
+JOIN orders o ON o.id = rmk.order_id AND o.deleted_at IS NULL
+JOIN order_items oi ON oi.id = rmk.parent_item_id
+JOIN order_item_process_in_progresses ip
+  ON ip.order_item_id = oi.id
+  AND ip.completed_at IS NOT NULL
+  AND ip.assigned_id IS NOT NULL` + workRowJoins + `
+WHERE
+  rl.created_at >= $1
+  AND rl.created_at <  $2
+  AND o.department_id = $3
+GROUP BY rl.id, ` + workRowGroupBy + `
+ORDER BY rl.id, ip.completed_at, ip.id, oip.id;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, from, to, deptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*RemakeRow{}
+	for rows.Next() {
+		var rr RemakeRow
+		if err := rows.Scan(scanWorkRow(&rr.Work, &rr.RemakeLogID, &rr.Action, &rr.Reason, &rr.CreatedAt)...); err != nil {
+			return nil, err
+		}
+		out = append(out, &rr)
+	}
+	return out, rows.Err()
+}
+
+func (r *payrollRepository) SaveStatements(
+	ctx context.Context,
+	deptID, userID int,
+	input *model.PayrollGenerateDTO,
+	statements []*model.PayrollStatementDTO,
+) error {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	scope := tx.PayrollStatement.Query().
+		Where(
+			payrollstatement.DepartmentIDEQ(deptID),
+			payrollstatement.PeriodStartEQ(input.PeriodStart),
+			payrollstatement.PeriodEndEQ(input.PeriodEnd),
+		)
+	if input.StaffID != nil {
+		scope = scope.Where(payrollstatement.StaffIDEQ(*input.StaffID))
+	}
+	existing, err := scope.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	locked := make(map[int64]struct{})
+	var drafts []int64
+	for _, st := range existing {
+		if st.Status == StatementStatusApproved {
+			locked[st.StaffID] = struct{}{}
+			continue
+		}
+		drafts = append(drafts, st.ID)
+	}
+	if input.StaffID != nil && len(locked) > 0 {
+		err = ErrStatementLocked
+		return err
+	}
+
+	// an approved statement of another period covering part of this one
+	// already paid some of the work; generating again would pay it twice
+	overlapping := tx.PayrollStatement.Query().
+		Where(
+			payrollstatement.DepartmentIDEQ(deptID),
+			payrollstatement.StatusEQ(StatementStatusApproved),
+			payrollstatement.PeriodStartLT(input.PeriodEnd),
+			payrollstatement.PeriodEndGT(input.PeriodStart),
+			payrollstatement.Not(payrollstatement.And(
+				payrollstatement.PeriodStartEQ(input.PeriodStart),
+				payrollstatement.PeriodEndEQ(input.PeriodEnd),
+			)),
+		)
+	if input.StaffID != nil {
+		overlapping = overlapping.Where(payrollstatement.StaffIDEQ(*input.StaffID))
+	} else {
+		staffIDs := make([]int64, 0, len(statements))
+		for _, st := range statements {
+			staffIDs = append(staffIDs, st.StaffID)
+		}
+		overlapping = overlapping.Where(payrollstatement.StaffIDIn(staffIDs...))
+	}
+	var overlaps bool
+	overlaps, err = overlapping.Exist(ctx)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		err = ErrStatementOverlaps
+		return err
+	}
+
+	if len(drafts) > 0 {
+		if _, err = tx.PayrollStatementLine.Delete().
+			Where(payrollstatementline.StatementIDIn(drafts...)).
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err = tx.PayrollStatement.Delete().
+			Where(payrollstatement.IDIn(drafts...)).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, st := range statements {
+		if _, ok := locked[st.StaffID]; ok {
+			continue
+		}
+
+		var entity *generated.PayrollStatement
+		entity, err = tx.PayrollStatement.Create().
+			SetDepartmentID(deptID).
+			SetStaffID(st.StaffID).
+			SetNillableStaffName(st.StaffName).
+			SetPeriodStart(input.PeriodStart).
+			SetPeriodEnd(input.PeriodEnd).
+			SetStatus(StatementStatusDraft).
+			SetUnits(st.Units).
+			SetGross(st.Gross).
+			SetDeductions(st.Deductions).
+			SetNet(st.Net).
+			SetGeneratedBy(userID).
+			Save(ctx)
+		if err != nil {
+			return err
+		}
+
+		bulk := make([]*generated.PayrollStatementLineCreate, 0, len(st.Lines))
+		for _, l := range st.Lines {
+			bulk = append(bulk, tx.PayrollStatementLine.Create().
+				SetStatementID(entity.ID).
+				SetKind(l.Kind).
+				SetInProgressID(l.InProgressID).
+				SetCompletedAt(l.CompletedAt).
+				SetOrderID(l.OrderID).
+				SetNillableOrderCode(l.OrderCode).
+				SetOrderItemID(l.OrderItemID).
+				SetNillableOrderItemCode(l.OrderItemCode).
+				SetNillableProcessID(l.ProcessID).
+				SetNillableProcessName(l.ProcessName).
+				SetNillableProductID(l.ProductID).
+				SetNillableProductName(l.ProductName).
+				SetUnits(l.Units).
+				SetRate(l.Rate).
+				SetAmount(l.Amount).
+				SetNillableRemakeLogID(l.RemakeLogID).
+				SetNillableNote(l.Note))
+		}
+		if len(bulk) > 0 {
+			if _, err = tx.PayrollStatementLine.CreateBulk(bulk...).Save(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *payrollRepository) Approve(ctx context.Context, deptID int, id int64, userID int) (*model.PayrollStatementDTO, error) {
+	n, err := r.db.PayrollStatement.Update().
+		Where(
+			payrollstatement.IDEQ(id),
+			payrollstatement.DepartmentIDEQ(deptID),
+			payrollstatement.StatusEQ(StatementStatusDraft),
+		).
+		SetStatus(StatementStatusApproved).
+		SetApprovedAt(time.Now()).
+		SetApprovedBy(userID).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dto, err := r.GetByID(ctx, deptID, id)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrStatementLocked
+	}
+	return dto, nil
+}
+
+func (r *payrollRepository) GetByID(ctx context.Context, deptID int, id int64) (*model.PayrollStatementDTO, error) {
+	entity, err := r.db.PayrollStatement.Query().
+		Where(
+			payrollstatement.IDEQ(id),
+			payrollstatement.DepartmentIDEQ(deptID),
+		).
+		WithLines(func(q *generated.PayrollStatementLineQuery) {
+			q.Order(
+				generated.Asc(payrollstatementline.FieldKind),
+				generated.Asc(payrollstatementline.FieldCompletedAt),
+				generated.Asc(payrollstatementline.FieldID),
+			)
+		}).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapStatement(entity), nil
+}
+
+func mapStatement(entity *generated.PayrollStatement) *model.PayrollStatementDTO {
+	dto := mapper.MapAs[*generated.PayrollStatement, *model.PayrollStatementDTO](entity)
+	if entity.Edges.Lines != nil {
+		dto.Lines = mapper.MapListAs[*generated.PayrollStatementLine, *model.PayrollStatementLineDTO](entity.Edges.Lines)
+	}
+	return dto
+}
+
+func (r *payrollRepository) List(
+	ctx context.Context,
+	deptID int,
+	periodStart, periodEnd *time.Time,
+	query table.TableQuery,
+) (table.TableListResult[model.PayrollStatementDTO], error) {
+	q := r.db.PayrollStatement.Query().
+		Where(payrollstatement.DepartmentIDEQ(deptID))
+	if periodStart != nil {
+		q = q.Where(payrollstatement.PeriodStartGTE(*periodStart))
+	}
+	if periodEnd != nil {
+		q = q.Where(payrollstatement.PeriodEndLTE(*periodEnd))
+	}
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		payrollstatement.Table,
+		payrollstatement.FieldID,
+		payrollstatement.FieldPeriodStart,
+		func(src []*generated.PayrollStatement) []*model.PayrollStatementDTO {
+			return mapper.MapListAs[*generated.PayrollStatement, *model.PayrollStatementDTO](src)
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.PayrollStatementDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+func (r *payrollRepository) ListWithLines(ctx context.Context, deptID int, periodStart, periodEnd time.Time) ([]*model.PayrollStatementDTO, error) {
+	list, err := r.db.PayrollStatement.Query().
+		Where(
+			payrollstatement.DepartmentIDEQ(deptID),
+			payrollstatement.PeriodStartEQ(periodStart),
+			payrollstatement.PeriodEndEQ(periodEnd),
+		).
+		WithLines(func(q *generated.PayrollStatementLineQuery) {
+			q.Order(
+				generated.Asc(payrollstatementline.FieldKind),
+				generated.Asc(payrollstatementline.FieldCompletedAt),
+				generated.Asc(payrollstatementline.FieldID),
+			)
+		}).
+		Order(generated.Asc(payrollstatement.FieldStaffName), generated.Asc(payrollstatement.FieldStaffID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*model.PayrollStatementDTO, 0, len(list))
+	for _, it := range list {
+		out = append(out, mapStatement(it))
+	}
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/piecerate"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/process"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/restorationtype"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+var ErrPieceRateExists = errors.New("a piece rate already exists for this process, product and restoration type")
+
+type PieceRateRepository interface {
+	List(ctx context.Context, deptID int, processID *int) ([]*model.PieceRateDTO, error)
+	Create(ctx context.Context, deptID int, input *model.PieceRateDTO) (*model.PieceRateDTO, error)
+	Update(ctx context.Context, deptID int, id int, input *model.PieceRateDTO) (*model.PieceRateDTO, error)
+	Delete(ctx context.Context, deptID int, id int) error
+	// Active returns the active rates used to compute a payroll.
+	Active(ctx context.Context, deptID int) ([]*model.PieceRateDTO, error)
+}
+
+type pieceRateRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPieceRateRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) PieceRateRepository {
+	return &pieceRateRepository{db: db, deps: deps}
+}
+
+func (r *pieceRateRepository) List(ctx context.Context, deptID int, processID *int) ([]*model.PieceRateDTO, error) {
+	q := r.db.PieceRate.Query().
+		Where(piecerate.DepartmentIDEQ(deptID))
+	if processID != nil {
+		q = q.Where(piecerate.ProcessIDEQ(*processID))
+	}
+	list, err := q.
+		Order(
+			generated.Asc(piecerate.FieldProcessID),
+			generated.Asc(piecerate.FieldProductID),
+			generated.Asc(piecerate.FieldRestorationTypeID),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.PieceRate, *model.PieceRateDTO](list), nil
+}
+
+func (r *pieceRateRepository) Active(ctx context.Context, deptID int) ([]*model.PieceRateDTO, error) {
+	list, err := r.db.PieceRate.Query().
+		Where(
+			piecerate.DepartmentIDEQ(deptID),
+			piecerate.ActiveEQ(true),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.PieceRate, *model.PieceRateDTO](list), nil
+}
+
+// exists also guards the process default row, which the unique index cannot
+// since its product and restoration type are NULL.
+func (r *pieceRateRepository) exists(ctx context.Context, deptID int, input *model.PieceRateDTO, exceptID int) (bool, error) {
+	q := r.db.PieceRate.Query().
+		Where(
+			piecerate.DepartmentIDEQ(deptID),
+			piecerate.ProcessIDEQ(input.ProcessID),
+			piecerate.IDNEQ(exceptID),
+		)
+	if input.ProductID != nil {
+		q = q.Where(piecerate.ProductIDEQ(*input.ProductID))
+	} else {
+		q = q.Where(piecerate.ProductIDIsNil())
+	}
+	if input.RestorationTypeID != nil {
+		q = q.Where(piecerate.RestorationTypeIDEQ(*input.RestorationTypeID))
+	} else {
+		q = q.Where(piecerate.RestorationTypeIDIsNil())
+	}
+	return q.Exist(ctx)
+}
+
+// names snapshots the process, product and restoration type names.
+func (r *pieceRateRepository) names(ctx context.Context, input *model.PieceRateDTO) error {
+	p, err := r.db.Process.Query().
+		Where(process.IDEQ(input.ProcessID), process.DeletedAtIsNil()).
+		Only(ctx)
+	if err != nil {
+		return err
+	}
+	input.ProcessName = p.Name
+
+	input.ProductName = nil
+	if input.ProductID != nil {
+		prd, err := r.db.Product.Query().
+			Where(product.IDEQ(*input.ProductID), product.DeletedAtIsNil()).
+			Only(ctx)
+		if err != nil {
+			return err
+		}
+		input.ProductName = prd.Name
+	}
+
+	input.RestorationTypeName = nil
+	if input.RestorationTypeID != nil {
+		rt, err := r.db.RestorationType.Query().
+			Where(restorationtype.IDEQ(*input.RestorationTypeID), restorationtype.DeletedAtIsNil()).
+			Only(ctx)
+		if err != nil {
+			return err
+		}
+		input.RestorationTypeName = rt.Name
+	}
+	return nil
+}
+
+func (r *pieceRateRepository) Create(ctx context.Context, deptID int, input *model.PieceRateDTO) (*model.PieceRateDTO, error) {
+	dup, err := r.exists(ctx, deptID, input, 0)
+	if err != nil {
+		return nil, err
+	}
+	if dup {
+		return nil, ErrPieceRateExists
+	}
+	if err := r.names(ctx, input); err != nil {
+		return nil, err
+	}
+
+	entity, err := r.db.PieceRate.Create().
+		SetDepartmentID(deptID).
+		SetProcessID(input.ProcessID).
+		SetNillableProcessName(input.ProcessName).
+		SetNillableProductID(input.ProductID).
+		SetNillableProductName(input.ProductName).
+		SetNillableRestorationTypeID(input.RestorationTypeID).
+		SetNillableRestorationTypeName(input.RestorationTypeName).
+		SetRate(input.Rate).
+		SetActive(input.Active).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.PieceRate, *model.PieceRateDTO](entity), nil
+}
+
+func (r *pieceRateRepository) Update(ctx context.Context, deptID int, id int, input *model.PieceRateDTO) (*model.PieceRateDTO, error) {
+	dup, err := r.exists(ctx, deptID, input, id)
+	if err != nil {
+		return nil, err
+	}
+	if dup {
+		return nil, ErrPieceRateExists
+	}
+	if err := r.names(ctx, input); err != nil {
+		return nil, err
+	}
+
+	up := r.db.PieceRate.UpdateOneID(id).
+		Where(piecerate.DepartmentIDEQ(deptID)).
+		SetProcessID(input.ProcessID).
+		SetNillableProcessName(input.ProcessName).
+		SetRate(input.Rate).
+		SetActive(input.Active)
+	if input.ProductID != nil {
+		up.SetProductID(*input.ProductID).SetNillableProductName(input.ProductName)
+	} else {
+		up.ClearProductID().ClearProductName()
+	}
+	if input.RestorationTypeID != nil {
+		up.SetRestorationTypeID(*input.RestorationTypeID).SetNillableRestorationTypeName(input.RestorationTypeName)
+	} else {
+		up.ClearRestorationTypeID().ClearRestorationTypeName()
+	}
+
+	entity, err := up.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapAs[*generated.PieceRate, *model.PieceRateDTO](entity), nil
+}
+
+func (r *pieceRateRepository) Delete(ctx context.Context, deptID int, id int) error {
+	n, err := r.db.PieceRate.Delete().
+		Where(
+			piecerate.IDEQ(id),
+			piecerate.DepartmentIDEQ(deptID),
+		).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &generated.NotFoundError{}
+	}
+	return nil
+}
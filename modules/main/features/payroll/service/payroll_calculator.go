@@ -0,0 +1,258 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/repository"
+)
+
+const (
+	lineKindEarning   = "earning"
+	lineKindDeduction = "deduction"
+)
+
+// rateTable resolves the rate of a completed step. The most specific active
+// rate wins: process + product + restoration type, then process + product,
+// then process + restoration type, then the process default.
+type rateTable map[int][]*model.PieceRateDTO
+
+func newRateTable(rates []*model.PieceRateDTO) rateTable {
+	t := rateTable{}
+	for _, r := range rates {
+		t[r.ProcessID] = append(t[r.ProcessID], r)
+	}
+	return t
+}
+
+func (t rateTable) resolve(w *repository.WorkRow) float64 {
+	if w.ProcessID == nil {
+		return 0
+	}
+
+	best, bestScore := 0.0, -1
+	for _, r := range t[*w.ProcessID] {
+		score := 0
+		if r.ProductID != nil {
+			if w.ProductID == nil || *r.ProductID != *w.ProductID {
+				continue
+			}
+			score += 2
+		}
+		if r.RestorationTypeID != nil {
+			if !containsID(w.RestorationTypeIDs, int64(*r.RestorationTypeID)) {
+				continue
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = r.Rate, score
+		}
+	}
+	return best
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, it := range ids {
+		if it == id {
+			return true
+		}
+	}
+	return false
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v)
+}
+
+func workUnits(w *repository.WorkRow) int {
+	if w.Quantity < 1 {
+		return 1
+	}
+	return w.Quantity
+}
+
+func lineFromWork(kind string, w *repository.WorkRow, units int, rate, amount float64) *model.PayrollStatementLineDTO {
+	return &model.PayrollStatementLineDTO{
+		Kind:          kind,
+		InProgressID:  w.InProgressID,
+		CompletedAt:   w.CompletedAt,
+		OrderID:       w.OrderID,
+		OrderCode:     w.OrderCode,
+		OrderItemID:   w.OrderItemID,
+		OrderItemCode: w.OrderItemCode,
+		ProcessID:     w.ProcessID,
+		ProcessName:   w.ProcessName,
+		ProductID:     w.ProductID,
+		ProductName:   w.ProductName,
+		Units:         units,
+		Rate:          rate,
+		Amount:        amount,
+	}
+}
+
+// calculatePayroll builds one statement per technician from the steps they
+// completed in the period and the remakes attributed to them by the policy.
+func calculatePayroll(
+	rates []*model.PieceRateDTO,
+	policy *model.PayrollPolicyDTO,
+	work []*repository.WorkRow,
+	remakes []*repository.RemakeRow,
+	staffID *int64,
+) []*model.PayrollStatementDTO {
+	table := newRateTable(rates)
+	byStaff := map[int64]*model.PayrollStatementDTO{}
+	statement := func(id int64, name *string) *model.PayrollStatementDTO {
+		st, ok := byStaff[id]
+		if !ok {
+			st = &model.PayrollStatementDTO{StaffID: id, StaffName: name}
+			byStaff[id] = st
+		}
+		if st.StaffName == nil {
+			st.StaffName = name
+		}
+		return st
+	}
+
+	for _, w := range work {
+		units := workUnits(w)
+		rate := table.resolve(w)
+		st := statement(w.StaffID, w.StaffName)
+		st.Lines = append(st.Lines, lineFromWork(lineKindEarning, w, units, rate, roundAmount(float64(units)*rate)))
+	}
+
+	for _, group := range groupRemakes(remakes) {
+		for _, line := range deductRemake(table, policy, group) {
+			if staffID != nil && line.staffID != *staffID {
+				continue
+			}
+			st := statement(line.staffID, line.staffName)
+			st.Lines = append(st.Lines, line.PayrollStatementLineDTO)
+		}
+	}
+
+	out := make([]*model.PayrollStatementDTO, 0, len(byStaff))
+	for _, st := range byStaff {
+		for _, l := range st.Lines {
+			if l.Kind == lineKindEarning {
+				st.Units += l.Units
+				st.Gross += l.Amount
+			} else {
+				st.Deductions -= l.Amount
+			}
+		}
+		st.Net = st.Gross - st.Deductions
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StaffID < out[j].StaffID })
+	return out
+}
+
+// groupRemakes keeps the remake logs in order with the steps of their
+// original item.
+func groupRemakes(rows []*repository.RemakeRow) [][]*repository.RemakeRow {
+	var out [][]*repository.RemakeRow
+	for _, r := range rows {
+		n := len(out)
+		if n > 0 && out[n-1][0].RemakeLogID == r.RemakeLogID {
+			out[n-1] = append(out[n-1], r)
+			continue
+		}
+		out = append(out, []*repository.RemakeRow{r})
+	}
+	return out
+}
+
+type deductionLine struct {
+	*model.PayrollStatementLineDTO
+	staffID   int64
+	staffName *string
+}
+
+func deductRemake(table rateTable, policy *model.PayrollPolicyDTO, group []*repository.RemakeRow) []deductionLine {
+	head := group[0]
+	if policy.RemakeDeduction == "none" || policy.RemakeDeductionValue <= 0 {
+		return nil
+	}
+	if head.Action == "adjust" && !policy.IncludeAdjust {
+		return nil
+	}
+
+	// Steps completed within the window before the remake was logged.
+	var steps []*repository.RemakeRow
+	for _, r := range group {
+		if r.Work.CompletedAt.After(head.CreatedAt) {
+			continue
+		}
+		if policy.RemakeWindowDays > 0 &&
+			head.CreatedAt.Sub(r.Work.CompletedAt).Hours() > float64(policy.RemakeWindowDays*24) {
+			continue
+		}
+		steps = append(steps, r)
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+
+	if policy.RemakeAttribution != "all" {
+		last := steps[0]
+		for _, r := range steps[1:] {
+			if r.Work.CompletedAt.After(last.Work.CompletedAt) {
+				last = r
+			}
+		}
+		kept := steps[:0]
+		for _, r := range steps {
+			if r.Work.InProgressID == last.Work.InProgressID {
+				kept = append(kept, r)
+			}
+		}
+		steps = kept
+	}
+
+	note := fmt.Sprintf("%s #%d", head.Action, head.RemakeLogID)
+	if head.Reason != nil && *head.Reason != "" {
+		note += ": " + *head.Reason
+	}
+
+	var out []deductionLine
+	add := func(w *repository.WorkRow, units int, rate, amount float64) {
+		l := lineFromWork(lineKindDeduction, w, units, rate, amount)
+		l.RemakeLogID = &head.RemakeLogID
+		l.Note = &note
+		out = append(out, deductionLine{PayrollStatementLineDTO: l, staffID: w.StaffID, staffName: w.StaffName})
+	}
+
+	switch policy.RemakeDeduction {
+	case "percent":
+		for _, r := range steps {
+			units := workUnits(&r.Work)
+			rate := table.resolve(&r.Work)
+			amount := roundAmount(float64(units) * rate * policy.RemakeDeductionValue / 100)
+			if amount == 0 {
+				continue
+			}
+			add(&r.Work, units, rate, -amount)
+		}
+	case "fixed":
+		// One deduction per technician and remake, on their latest step.
+		latest := map[int64]*repository.WorkRow{}
+		var order []int64
+		for _, r := range steps {
+			cur, ok := latest[r.Work.StaffID]
+			if !ok {
+				order = append(order, r.Work.StaffID)
+			}
+			if !ok || r.Work.CompletedAt.After(cur.CompletedAt) {
+				latest[r.Work.StaffID] = &r.Work
+			}
+		}
+		value := roundAmount(policy.RemakeDeductionValue)
+		for _, id := range order {
+			add(latest[id], 0, value, -value)
+		}
+	}
+	return out
+}
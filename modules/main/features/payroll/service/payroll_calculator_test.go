@@ -0,0 +1,203 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/repository"
+)
+
+func intPtr(v int) *int { return &v }
+
+var payrollDay = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func testWork(id, staffID int64, processID, productID, qty int, daysBefore int, restorations ...int64) repository.WorkRow {
+	w := repository.WorkRow{
+		InProgressID:       id,
+		StaffID:            staffID,
+		CompletedAt:        payrollDay.AddDate(0, 0, -daysBefore),
+		ProcessID:          intPtr(processID),
+		Quantity:           qty,
+		RestorationTypeIDs: restorations,
+	}
+	if productID != 0 {
+		w.ProductID = intPtr(productID)
+	}
+	return w
+}
+
+func TestRateTableResolve(t *testing.T) {
+	table := newRateTable([]*model.PieceRateDTO{
+		{ProcessID: 1, Rate: 10},
+		{ProcessID: 1, ProductID: intPtr(100), Rate: 20},
+		{ProcessID: 1, RestorationTypeID: intPtr(7), Rate: 15},
+		{ProcessID: 1, ProductID: intPtr(100), RestorationTypeID: intPtr(7), Rate: 30},
+		{ProcessID: 2, ProductID: intPtr(100), Rate: 50},
+	})
+
+	tests := []struct {
+		name     string
+		work     repository.WorkRow
+		expected float64
+	}{
+		{"process default", testWork(1, 1, 1, 200, 1, 0), 10},
+		{"product rate", testWork(1, 1, 1, 100, 1, 0), 20},
+		{"restoration type rate", testWork(1, 1, 1, 200, 1, 0, 7), 15},
+		{"product and restoration type", testWork(1, 1, 1, 100, 1, 0, 3, 7), 30},
+		{"no product on the step", testWork(1, 1, 2, 0, 1, 0), 0},
+		{"no rate for the process", testWork(1, 1, 9, 100, 1, 0), 0},
+		{"no process", repository.WorkRow{ProductID: intPtr(100)}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := table.resolve(&tt.work); got != tt.expected {
+			t.Errorf("%s: resolve = %v; want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func remake(id int64, action string, steps ...repository.WorkRow) []*repository.RemakeRow {
+	out := make([]*repository.RemakeRow, 0, len(steps))
+	for _, w := range steps {
+		out = append(out, &repository.RemakeRow{RemakeLogID: id, Action: action, CreatedAt: payrollDay, Work: w})
+	}
+	return out
+}
+
+func TestCalculatePayroll(t *testing.T) {
+	rates := []*model.PieceRateDTO{
+		{ProcessID: 1, Rate: 100},
+		{ProcessID: 2, Rate: 200},
+	}
+	// staff 1 designed (2 units) then staff 2 milled (1 unit, quantity 0)
+	design := testWork(1, 1, 1, 0, 2, 5)
+	mill := testWork(2, 2, 2, 0, 0, 3)
+	work := []*repository.WorkRow{&design, &mill}
+
+	type totals struct {
+		units                  int
+		gross, deductions, net float64
+	}
+	tests := []struct {
+		name     string
+		policy   model.PayrollPolicyDTO
+		work     []*repository.WorkRow
+		remakes  []*repository.RemakeRow
+		staffID  *int64
+		expected map[int64]totals
+	}{
+		{
+			name:    "earnings only",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "none"},
+			remakes: remake(1, "remake", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 0, 200},
+				2: {1, 200, 0, 200},
+			},
+		},
+		{
+			name:    "percent on the last step",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "percent", RemakeDeductionValue: 50, RemakeAttribution: "last"},
+			remakes: remake(1, "remake", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 0, 200},
+				2: {1, 200, 100, 100},
+			},
+		},
+		{
+			name:    "percent on every step",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "percent", RemakeDeductionValue: 50, RemakeAttribution: "all"},
+			remakes: remake(1, "remake", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 100, 100},
+				2: {1, 200, 100, 100},
+			},
+		},
+		{
+			name:    "fixed once per technician",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "fixed", RemakeDeductionValue: 30, RemakeAttribution: "all"},
+			remakes: remake(1, "remake", design, testWork(3, 1, 1, 0, 1, 4), mill),
+			expected: map[int64]totals{
+				1: {2, 200, 30, 170},
+				2: {1, 200, 30, 170},
+			},
+		},
+		{
+			name:    "steps outside the window not charged",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "percent", RemakeDeductionValue: 50, RemakeAttribution: "all", RemakeWindowDays: 4},
+			remakes: remake(1, "remake", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 0, 200},
+				2: {1, 200, 100, 100},
+			},
+		},
+		{
+			name:    "adjust ignored by default",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "fixed", RemakeDeductionValue: 30},
+			remakes: remake(1, "adjust", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 0, 200},
+				2: {1, 200, 0, 200},
+			},
+		},
+		{
+			name:    "adjust charged when included",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "fixed", RemakeDeductionValue: 30, IncludeAdjust: true},
+			remakes: remake(1, "adjust", design, mill),
+			expected: map[int64]totals{
+				1: {2, 200, 0, 200},
+				2: {1, 200, 30, 170},
+			},
+		},
+		{
+			name:   "each remake charged separately",
+			policy: model.PayrollPolicyDTO{RemakeDeduction: "fixed", RemakeDeductionValue: 30},
+			remakes: append(remake(1, "remake", design, mill),
+				remake(2, "remake", design)...),
+			expected: map[int64]totals{
+				1: {2, 200, 30, 170},
+				2: {1, 200, 30, 170},
+			},
+		},
+		{
+			name:    "deduction of another technician filtered out",
+			policy:  model.PayrollPolicyDTO{RemakeDeduction: "percent", RemakeDeductionValue: 50, RemakeAttribution: "all"},
+			work:    []*repository.WorkRow{&design},
+			remakes: remake(1, "remake", design, mill),
+			staffID: &design.StaffID,
+			expected: map[int64]totals{
+				1: {2, 200, 100, 100},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		w := work
+		if tt.work != nil {
+			w = tt.work
+		}
+		out := calculatePayroll(rates, &tt.policy, w, tt.remakes, tt.staffID)
+		if len(out) != len(tt.expected) {
+			t.Errorf("%s: got %d statements; want %d", tt.name, len(out), len(tt.expected))
+			continue
+		}
+		for _, st := range out {
+			want := tt.expected[st.StaffID]
+			got := totals{st.Units, st.Gross, st.Deductions, st.Net}
+			if got != want {
+				t.Errorf("%s: staff %d = %+v; want %+v", tt.name, st.StaffID, got, want)
+			}
+		}
+	}
+}
+
+func TestGroupRemakes(t *testing.T) {
+	w := testWork(1, 1, 1, 0, 1, 0)
+	rows := append(append(remake(1, "remake", w, w), remake(2, "remake", w)...), remake(1, "remake", w)...)
+
+	groups := groupRemakes(rows)
+	if len(groups) != 3 || len(groups[0]) != 2 || len(groups[1]) != 1 || len(groups[2]) != 1 {
+		t.Errorf("groups = %d; want sizes [2 1 1]", len(groups))
+	}
+}
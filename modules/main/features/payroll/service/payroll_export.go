@@ -0,0 +1,78 @@
+package service
+
+import (
+	"github.com/xuri/excelize/v2"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+)
+
+const (
+	summarySheet = "Summary"
+	linesSheet   = "Lines"
+)
+
+var (
+	summaryHeader = []any{"Staff ID", "Staff", "Period start", "Period end", "Status", "Units", "Gross", "Deductions", "Net", "Approved at"}
+	linesHeader   = []any{"Staff ID", "Staff", "Kind", "Completed at", "Order", "Item", "Process", "Product", "Units", "Rate", "Amount", "Note"}
+)
+
+// BuildPayrollWorkbook writes a summary row per statement and every line of
+// the statements on a second sheet.
+func BuildPayrollWorkbook(statements []*model.PayrollStatementDTO) (*excelize.File, error) {
+	x := excelize.NewFile()
+	if err := x.SetSheetName("Sheet1", summarySheet); err != nil {
+		return nil, err
+	}
+	if _, err := x.NewSheet(linesSheet); err != nil {
+		return nil, err
+	}
+
+	setRow := func(sheet string, row int, values []any) error {
+		cell, err := excelize.CoordinatesToCellName(1, row)
+		if err != nil {
+			return err
+		}
+		return x.SetSheetRow(sheet, cell, &values)
+	}
+
+	if err := setRow(summarySheet, 1, summaryHeader); err != nil {
+		return nil, err
+	}
+	if err := setRow(linesSheet, 1, linesHeader); err != nil {
+		return nil, err
+	}
+
+	lineRow := 2
+	for i, st := range statements {
+		var approvedAt any
+		if st.ApprovedAt != nil {
+			approvedAt = *st.ApprovedAt
+		}
+		if err := setRow(summarySheet, i+2, []any{
+			st.StaffID, deref(st.StaffName), st.PeriodStart, st.PeriodEnd, st.Status,
+			st.Units, st.Gross, st.Deductions, st.Net, approvedAt,
+		}); err != nil {
+			return nil, err
+		}
+
+		for _, l := range st.Lines {
+			if err := setRow(linesSheet, lineRow, []any{
+				st.StaffID, deref(st.StaffName), l.Kind, l.CompletedAt,
+				deref(l.OrderCode), deref(l.OrderItemCode), deref(l.ProcessName), deref(l.ProductName),
+				l.Units, l.Rate, l.Amount, deref(l.Note),
+			}); err != nil {
+				return nil, err
+			}
+			lineRow++
+		}
+	}
+
+	return x, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/payroll/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+var (
+	ErrInvalidPeriod     = errors.New("period_end must be after period_start")
+	ErrInvalidPolicy     = errors.New("invalid payroll policy")
+	ErrInvalidPieceRate  = errors.New("process_id is required and rate must not be negative")
+	ErrNothingToGenerate = errors.New("no completed work or remakes in the period")
+)
+
+type PayrollService interface {
+	ListPieceRates(ctx context.Context, deptID int, processID *int) ([]*model.PieceRateDTO, error)
+	CreatePieceRate(ctx context.Context, deptID int, input *model.PieceRateDTO) (*model.PieceRateDTO, error)
+	UpdatePieceRate(ctx context.Context, deptID int, id int, input *model.PieceRateDTO) (*model.PieceRateDTO, error)
+	DeletePieceRate(ctx context.Context, deptID int, id int) error
+
+	GetPolicy(ctx context.Context, deptID int) (*model.PayrollPolicyDTO, error)
+	SavePolicy(ctx context.Context, deptID, userID int, input *model.PayrollPolicyDTO) (*model.PayrollPolicyDTO, error)
+
+	Generate(ctx context.Context, deptID, userID int, input *model.PayrollGenerateDTO) ([]*model.PayrollStatementDTO, error)
+	Approve(ctx context.Context, deptID int, id int64, userID int) (*model.PayrollStatementDTO, error)
+	GetByID(ctx context.Context, deptID int, id int64) (*model.PayrollStatementDTO, error)
+	List(ctx context.Context, deptID int, periodStart, periodEnd *time.Time, query table.TableQuery) (table.TableListResult[model.PayrollStatementDTO], error)
+	ListWithLines(ctx context.Context, deptID int, periodStart, periodEnd time.Time) ([]*model.PayrollStatementDTO, error)
+}
+
+type payrollService struct {
+	rates repository.PieceRateRepository
+	repo  repository.PayrollRepository
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPayrollService(
+	rates repository.PieceRateRepository,
+	repo repository.PayrollRepository,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) PayrollService {
+	return &payrollService{rates: rates, repo: repo, deps: deps}
+}
+
+func (s *payrollService) ListPieceRates(ctx context.Context, deptID int, processID *int) ([]*model.PieceRateDTO, error) {
+	return s.rates.List(ctx, deptID, processID)
+}
+
+func (s *payrollService) CreatePieceRate(ctx context.Context, deptID int, input *model.PieceRateDTO) (*model.PieceRateDTO, error) {
+	if input.ProcessID <= 0 || input.Rate < 0 {
+		return nil, ErrInvalidPieceRate
+	}
+	return s.rates.Create(ctx, deptID, input)
+}
+
+func (s *payrollService) UpdatePieceRate(ctx context.Context, deptID int, id int, input *model.PieceRateDTO) (*model.PieceRateDTO, error) {
+	if input.ProcessID <= 0 || input.Rate < 0 {
+		return nil, ErrInvalidPieceRate
+	}
+	return s.rates.Update(ctx, deptID, id, input)
+}
+
+func (s *payrollService) DeletePieceRate(ctx context.Context, deptID int, id int) error {
+	return s.rates.Delete(ctx, deptID, id)
+}
+
+func (s *payrollService) GetPolicy(ctx context.Context, deptID int) (*model.PayrollPolicyDTO, error) {
+	return s.repo.GetPolicy(ctx, deptID)
+}
+
+func (s *payrollService) SavePolicy(ctx context.Context, deptID, userID int, input *model.PayrollPolicyDTO) (*model.PayrollPolicyDTO, error) {
+	switch input.RemakeDeduction {
+	case "none", "fixed":
+	case "percent":
+		if input.RemakeDeductionValue > 100 {
+			return nil, ErrInvalidPolicy
+		}
+	default:
+		return nil, ErrInvalidPolicy
+	}
+	if input.RemakeAttribution == "" {
+		input.RemakeAttribution = "last"
+	}
+	if input.RemakeAttribution != "last" && input.RemakeAttribution != "all" {
+		return nil, ErrInvalidPolicy
+	}
+	if input.RemakeDeductionValue < 0 || input.RemakeWindowDays < 0 {
+		return nil, ErrInvalidPolicy
+	}
+	return s.repo.SavePolicy(ctx, deptID, userID, input)
+}
+
+// Generate recomputes the draft statements of the period. Approved
+// statements are locked and left untouched.
+func (s *payrollService) Generate(ctx context.Context, deptID, userID int, input *model.PayrollGenerateDTO) ([]*model.PayrollStatementDTO, error) {
+	if !input.PeriodEnd.After(input.PeriodStart) {
+		return nil, ErrInvalidPeriod
+	}
+
+	rates, err := s.rates.Active(ctx, deptID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.repo.GetPolicy(ctx, deptID)
+	if err != nil {
+		return nil, err
+	}
+	work, err := s.repo.WorkRows(ctx, deptID, input.PeriodStart, input.PeriodEnd, input.StaffID)
+	if err != nil {
+		return nil, err
+	}
+	var remakes []*repository.RemakeRow
+	if policy.RemakeDeduction != "none" {
+		remakes, err = s.repo.RemakeRows(ctx, deptID, input.PeriodStart, input.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	statements := calculatePayroll(rates, policy, work, remakes, input.StaffID)
+	if len(statements) == 0 {
+		return nil, ErrNothingToGenerate
+	}
+	if err := s.repo.SaveStatements(ctx, deptID, userID, input, statements); err != nil {
+		return nil, err
+	}
+	return s.repo.ListWithLines(ctx, deptID, input.PeriodStart, input.PeriodEnd)
+}
+
+func (s *payrollService) Approve(ctx context.Context, deptID int, id int64, userID int) (*model.PayrollStatementDTO, error) {
+	return s.repo.Approve(ctx, deptID, id, userID)
+}
+
+func (s *payrollService) GetByID(ctx context.Context, deptID int, id int64) (*model.PayrollStatementDTO, error) {
+	return s.repo.GetByID(ctx, deptID, id)
+}
+
+func (s *payrollService) List(
+	ctx context.Context,
+	deptID int,
+	periodStart, periodEnd *time.Time,
+	query table.TableQuery,
+) (table.TableListResult[model.PayrollStatementDTO], error) {
+	return s.repo.List(ctx, deptID, periodStart, periodEnd, query)
+}
+
+func (s *payrollService) ListWithLines(ctx context.Context, deptID int, periodStart, periodEnd time.Time) ([]*model.PayrollStatementDTO, error) {
+	return s.repo.ListWithLines(ctx, deptID, periodStart, periodEnd)
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/service"
+	productrepo "github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type PriceListHandler struct {
+	svc  service.PriceListService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPriceListHandler(svc service.PriceListService, deps *module.ModuleDeps[config.ModuleConfig]) *PriceListHandler {
+	return &PriceListHandler{svc: svc, deps: deps}
+}
+
+func (h *PriceListHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/price-list/list", h.List)
+	app.RouterGet(router, "/:dept_id<int>/price-list/resolve", h.Resolve)
+	app.RouterGet(router, "/:dept_id<int>/price-list/:id<int>", h.GetByID)
+	app.RouterPost(router, "/:dept_id<int>/price-list", h.Create)
+	app.RouterPut(router, "/:dept_id<int>/price-list/:id<int>", h.Update)
+	app.RouterDelete(router, "/:dept_id<int>/price-list/:id<int>", h.Delete)
+}
+
+func (h *PriceListHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	q := table.ParseTableQuery(c, 20)
+	deptID, _ := utils.GetDeptIDInt(c)
+	clinicID := utils.GetQueryAsInt(c, "clinic_id")
+	var clinicPtr *int
+	if clinicID > 0 {
+		clinicPtr = &clinicID
+	}
+	res, err := h.svc.List(c.UserContext(), deptID, clinicPtr, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *PriceListHandler) GetByID(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.GetByID(c.UserContext(), deptID, id)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "price list not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PriceListHandler) Resolve(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.view", "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	productID := utils.GetQueryAsInt(c, "product_id")
+	if productID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid product_id")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	pc := repository.PriceContext{DepartmentID: deptID, At: time.Now()}
+	if clinicID := utils.GetQueryAsInt(c, "clinic_id"); clinicID > 0 {
+		pc.ClinicID = &clinicID
+	}
+	if dentistID := utils.GetQueryAsInt(c, "dentist_id"); dentistID > 0 {
+		pc.DentistID = &dentistID
+	}
+	if raw := utils.GetQueryAsString(c, "at"); raw != "" {
+		at, err := utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid at")
+		}
+		pc.At = at
+	}
+
+	res, err := h.svc.Resolve(c.UserContext(), pc, productID, utils.GetQueryAsInt(c, "quantity"))
+	if err != nil {
+		if errors.Is(err, productrepo.ErrProductNotFound) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *PriceListHandler) Create(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	payload, err := app.ParseBody[model.PriceListUpsertDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Create(c.UserContext(), deptID, payload)
+	if err != nil {
+		return h.responseUpsertError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *PriceListHandler) Update(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.PriceListUpsertDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	payload.DTO.ID = id
+
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	dto, err := h.svc.Update(c.UserContext(), deptID, payload)
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "price list not found")
+		}
+		return h.responseUpsertError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *PriceListHandler) Delete(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "price_list.delete"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.Delete(c.UserContext(), deptID, id); err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "price list not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *PriceListHandler) responseUpsertError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, repository.ErrInvalidPriceList) ||
+		errors.Is(err, repository.ErrInvalidPriceListItem) ||
+		errors.Is(err, repository.ErrDuplicatePriceListTier) {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -0,0 +1,35 @@
+package price_list
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/service"
+	productrepo "github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	productservice "github.com/khiemnd777/andy_api/modules/main/features/product/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "price_list" }
+func (feature) Priority() int { return 61 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	entClient := deps.Ent.(*generated.Client)
+	priceRepo := productrepo.NewProductPriceRepository(entClient, deps)
+	priceSvc := productservice.NewProductPriceService(priceRepo, deps)
+
+	repo := repository.NewPriceListRepository(entClient, deps)
+	svc := service.NewPriceListService(repo, priceSvc, deps)
+	h := handler.NewPriceListHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
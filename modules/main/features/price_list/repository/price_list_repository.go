@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/predicate"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/pricelist"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/pricelistitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+var (
+	ErrInvalidPriceList       = errors.New("price list requires a name and effective_to after effective_from")
+	ErrInvalidPriceListItem   = errors.New("price list item requires product_id, min_quantity >= 1 and price >= 0")
+	ErrDuplicatePriceListTier = errors.New("price list has duplicate product and min_quantity")
+)
+
+// PriceContext identifies who is buying and when the price must be valid.
+type PriceContext struct {
+	DepartmentID int
+	ClinicID     *int
+	DentistID    *int
+	At           time.Time
+}
+
+type PriceLine struct {
+	ProductID int
+	Quantity  int
+}
+
+type ResolvedPrice struct {
+	Price       float64
+	PriceListID int
+}
+
+type PriceListRepository interface {
+	Create(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error)
+	Update(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error)
+	GetByID(ctx context.Context, deptID int, id int) (*model.PriceListDTO, error)
+	List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.PriceListDTO], error)
+	Delete(ctx context.Context, deptID int, id int) error
+	// Resolve returns the contract price per product id; products without a
+	// matching price list entry are omitted so callers keep their own price.
+	Resolve(ctx context.Context, pc PriceContext, lines []PriceLine) (map[int]*ResolvedPrice, error)
+}
+
+type priceListRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPriceListRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) PriceListRepository {
+	return &priceListRepository{db: db, deps: deps}
+}
+
+func (r *priceListRepository) Create(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error) {
+	in := &input.DTO
+	if err := validatePriceList(in); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	entity, err := tx.PriceList.Create().
+		SetDepartmentID(deptID).
+		SetName(in.Name).
+		SetNillableClinicID(in.ClinicID).
+		SetNillableClinicName(in.ClinicName).
+		SetNillableDentistID(in.DentistID).
+		SetNillableDentistName(in.DentistName).
+		SetEffectiveFrom(in.EffectiveFrom).
+		SetNillableEffectiveTo(in.EffectiveTo).
+		SetActive(in.Active).
+		SetNillableNote(in.Note).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.replaceItems(ctx, tx, entity.ID, in.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	out := mapper.MapAs[*generated.PriceList, *model.PriceListDTO](entity)
+	out.Items = items
+	return out, nil
+}
+
+func (r *priceListRepository) Update(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error) {
+	in := &input.DTO
+	if err := validatePriceList(in); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	q := tx.PriceList.UpdateOneID(in.ID).
+		Where(
+			pricelist.DepartmentIDEQ(deptID),
+			pricelist.DeletedAtIsNil(),
+		).
+		SetName(in.Name).
+		SetEffectiveFrom(in.EffectiveFrom).
+		SetActive(in.Active).
+		SetNillableNote(in.Note)
+
+	// scope and end date may be cleared
+	if in.ClinicID != nil {
+		q.SetClinicID(*in.ClinicID).SetNillableClinicName(in.ClinicName)
+	} else {
+		q.ClearClinicID().ClearClinicName()
+	}
+	if in.DentistID != nil {
+		q.SetDentistID(*in.DentistID).SetNillableDentistName(in.DentistName)
+	} else {
+		q.ClearDentistID().ClearDentistName()
+	}
+	if in.EffectiveTo != nil {
+		q.SetEffectiveTo(*in.EffectiveTo)
+	} else {
+		q.ClearEffectiveTo()
+	}
+
+	entity, err := q.Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.replaceItems(ctx, tx, entity.ID, in.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	out := mapper.MapAs[*generated.PriceList, *model.PriceListDTO](entity)
+	out.Items = items
+	return out, nil
+}
+
+func (r *priceListRepository) GetByID(ctx context.Context, deptID int, id int) (*model.PriceListDTO, error) {
+	entity, err := r.db.PriceList.Query().
+		Where(
+			pricelist.ID(id),
+			pricelist.DepartmentIDEQ(deptID),
+			pricelist.DeletedAtIsNil(),
+		).
+		WithItems(func(q *generated.PriceListItemQuery) {
+			q.Order(pricelistitem.ByProductID(), pricelistitem.ByMinQuantity())
+		}).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dto := mapper.MapAs[*generated.PriceList, *model.PriceListDTO](entity)
+	dto.Items = mapper.MapListAs[*generated.PriceListItem, *model.PriceListItemDTO](entity.Edges.Items)
+	return dto, nil
+}
+
+func (r *priceListRepository) List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.PriceListDTO], error) {
+	q := r.db.PriceList.Query().
+		Where(
+			pricelist.DepartmentIDEQ(deptID),
+			pricelist.DeletedAtIsNil(),
+		)
+	if clinicID != nil {
+		q = q.Where(pricelist.ClinicIDEQ(*clinicID))
+	}
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		pricelist.Table,
+		pricelist.FieldID,
+		pricelist.FieldEffectiveFrom,
+		func(src []*generated.PriceList) []*model.PriceListDTO {
+			return mapper.MapListAs[*generated.PriceList, *model.PriceListDTO](src)
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.PriceListDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+func (r *priceListRepository) Delete(ctx context.Context, deptID int, id int) error {
+	return r.db.PriceList.UpdateOneID(id).
+		Where(
+			pricelist.DepartmentIDEQ(deptID),
+			pricelist.DeletedAtIsNil(),
+		).
+		SetDeletedAt(time.Now()).
+		Exec(ctx)
+}
+
+func (r *priceListRepository) Resolve(ctx context.Context, pc PriceContext, lines []PriceLine) (map[int]*ResolvedPrice, error) {
+	out := make(map[int]*ResolvedPrice, len(lines))
+	if len(lines) == 0 {
+		return out, nil
+	}
+
+	// volume tiers count the whole quantity of a product
+	qtyByProduct := make(map[int]int, len(lines))
+	productIDs := make([]int, 0, len(lines))
+	for _, l := range lines {
+		if l.ProductID <= 0 {
+			continue
+		}
+		if _, ok := qtyByProduct[l.ProductID]; !ok {
+			productIDs = append(productIDs, l.ProductID)
+		}
+		qty := l.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		qtyByProduct[l.ProductID] += qty
+	}
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+
+	scopes := []predicate.PriceList{
+		pricelist.And(pricelist.ClinicIDIsNil(), pricelist.DentistIDIsNil()),
+	}
+	if pc.ClinicID != nil {
+		scopes = append(scopes, pricelist.And(pricelist.ClinicIDEQ(*pc.ClinicID), pricelist.DentistIDIsNil()))
+	}
+	if pc.DentistID != nil {
+		dentistScope := pricelist.ClinicIDIsNil()
+		if pc.ClinicID != nil {
+			dentistScope = pricelist.Or(pricelist.ClinicIDIsNil(), pricelist.ClinicIDEQ(*pc.ClinicID))
+		}
+		scopes = append(scopes, pricelist.And(pricelist.DentistIDEQ(*pc.DentistID), dentistScope))
+	}
+
+	lists, err := r.db.PriceList.Query().
+		Where(
+			pricelist.DepartmentIDEQ(pc.DepartmentID),
+			pricelist.DeletedAtIsNil(),
+			pricelist.ActiveEQ(true),
+			pricelist.EffectiveFromLTE(pc.At),
+			pricelist.Or(pricelist.EffectiveToIsNil(), pricelist.EffectiveToGT(pc.At)),
+			pricelist.Or(scopes...),
+		).
+		WithItems(func(q *generated.PriceListItemQuery) {
+			q.Where(pricelistitem.ProductIDIn(productIDs...))
+		}).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// most specific scope first, then the most recent list
+	sort.SliceStable(lists, func(i, j int) bool {
+		si, sj := scopeRank(lists[i]), scopeRank(lists[j])
+		if si != sj {
+			return si > sj
+		}
+		if !lists[i].EffectiveFrom.Equal(lists[j].EffectiveFrom) {
+			return lists[i].EffectiveFrom.After(lists[j].EffectiveFrom)
+		}
+		return lists[i].ID > lists[j].ID
+	})
+
+	for _, productID := range productIDs {
+		qty := qtyByProduct[productID]
+		for _, l := range lists {
+			if item := pickTier(l.Edges.Items, productID, qty); item != nil {
+				out[productID] = &ResolvedPrice{Price: item.Price, PriceListID: l.ID}
+				break
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// -- helpers
+
+func (r *priceListRepository) replaceItems(
+	ctx context.Context,
+	tx *generated.Tx,
+	priceListID int,
+	items []*model.PriceListItemDTO,
+) ([]*model.PriceListItemDTO, error) {
+	if _, err := tx.PriceListItem.Delete().
+		Where(pricelistitem.PriceListIDEQ(priceListID)).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return []*model.PriceListItemDTO{}, nil
+	}
+
+	productIDs := make([]int, 0, len(items))
+	for _, it := range items {
+		productIDs = append(productIDs, it.ProductID)
+	}
+	products, err := tx.Product.Query().
+		Where(product.IDIn(productIDs...)).
+		Select(product.FieldID, product.FieldCode, product.FieldName).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	productByID := make(map[int]*generated.Product, len(products))
+	for _, p := range products {
+		productByID[p.ID] = p
+	}
+
+	bulk := make([]*generated.PriceListItemCreate, 0, len(items))
+	for _, it := range items {
+		c := tx.PriceListItem.Create().
+			SetPriceListID(priceListID).
+			SetProductID(it.ProductID).
+			SetMinQuantity(it.MinQuantity).
+			SetPrice(it.Price)
+		if p, ok := productByID[it.ProductID]; ok {
+			c.SetNillableProductCode(p.Code).SetNillableProductName(p.Name)
+		}
+		bulk = append(bulk, c)
+	}
+
+	saved, err := tx.PriceListItem.CreateBulk(bulk...).Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.PriceListItem, *model.PriceListItemDTO](saved), nil
+}
+
+func validatePriceList(in *model.PriceListDTO) error {
+	if in.Name == "" || in.EffectiveFrom.IsZero() {
+		return ErrInvalidPriceList
+	}
+	if in.EffectiveTo != nil && !in.EffectiveTo.After(in.EffectiveFrom) {
+		return ErrInvalidPriceList
+	}
+
+	type tierKey struct{ productID, minQty int }
+	seen := make(map[tierKey]struct{}, len(in.Items))
+	for _, it := range in.Items {
+		if it == nil {
+			return ErrInvalidPriceListItem
+		}
+		if it.MinQuantity == 0 {
+			it.MinQuantity = 1
+		}
+		if it.ProductID <= 0 || it.MinQuantity < 1 || it.Price < 0 {
+			return ErrInvalidPriceListItem
+		}
+		k := tierKey{it.ProductID, it.MinQuantity}
+		if _, ok := seen[k]; ok {
+			return ErrDuplicatePriceListTier
+		}
+		seen[k] = struct{}{}
+	}
+	return nil
+}
+
+// dentist > clinic > department default
+func scopeRank(l *generated.PriceList) int {
+	switch {
+	case l.DentistID != nil:
+		return 2
+	case l.ClinicID != nil:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// pickTier returns the highest tier whose min_quantity is reached.
+func pickTier(items []*generated.PriceListItem, productID int, qty int) *generated.PriceListItem {
+	var best *generated.PriceListItem
+	for _, it := range items {
+		if it.ProductID != productID || it.MinQuantity > qty {
+			continue
+		}
+		if best == nil || it.MinQuantity > best.MinQuantity {
+			best = it
+		}
+	}
+	return best
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+	_ "github.com/lib/pq"
+
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+)
+
+func TestPickTier(t *testing.T) {
+	items := []*generated.PriceListItem{
+		{ProductID: 100, MinQuantity: 1, Price: 90},
+		{ProductID: 100, MinQuantity: 10, Price: 70},
+		{ProductID: 100, MinQuantity: 5, Price: 80},
+		{ProductID: 200, MinQuantity: 1, Price: 50},
+	}
+
+	tests := []struct {
+		name      string
+		productID int
+		qty       int
+		expected  float64
+	}{
+		{"first tier", 100, 1, 90},
+		{"below the next tier", 100, 4, 90},
+		{"tier reached", 100, 5, 80},
+		{"highest tier reached", 100, 12, 70},
+		{"other product", 200, 12, 50},
+	}
+
+	for _, tt := range tests {
+		got := pickTier(items, tt.productID, tt.qty)
+		if got == nil || got.Price != tt.expected {
+			t.Errorf("%s: pickTier = %v; want price %v", tt.name, got, tt.expected)
+		}
+	}
+
+	if got := pickTier(items, 300, 1); got != nil {
+		t.Errorf("unknown product: pickTier = %v; want nil", got)
+	}
+	if got := pickTier([]*generated.PriceListItem{{ProductID: 100, MinQuantity: 5, Price: 80}}, 100, 2); got != nil {
+		t.Errorf("no tier reached: pickTier = %v; want nil", got)
+	}
+}
+
+// Resolve runs against Postgres; TEST_DATABASE_URL points at a scratch
+// database. The tables are created as temporary tables, which shadow the real
+// ones for the single connection the test uses.
+func testClient(t *testing.T) (*generated.Client, *sql.DB) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return generated.NewClient(generated.Driver(entsql.OpenDB("postgres", db))), db
+}
+
+const resolveTestSchema = `
+CREATE TEMP TABLE price_lists (
+  id INT PRIMARY KEY, department_id INT, name TEXT,
+  clinic_id INT, clinic_name TEXT, dentist_id INT, dentist_name TEXT,
+  effective_from TIMESTAMPTZ, effective_to TIMESTAMPTZ, active BOOLEAN, note TEXT,
+  created_at TIMESTAMPTZ DEFAULT now(), updated_at TIMESTAMPTZ DEFAULT now(), deleted_at TIMESTAMPTZ
+);
+CREATE TEMP TABLE price_list_items (
+  id INT PRIMARY KEY, price_list_id INT, product_id INT, product_code TEXT, product_name TEXT,
+  min_quantity INT, price DOUBLE PRECISION,
+  created_at TIMESTAMPTZ DEFAULT now(), updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+INSERT INTO price_lists (id, department_id, name, clinic_id, effective_from, effective_to, active, deleted_at) VALUES
+  (1, 5, 'Q1',       NULL, '2026-01-01', '2026-04-01', true,  NULL),
+  (2, 5, 'Q2',       NULL, '2026-04-01', NULL,         true,  NULL),
+  (3, 5, 'Clinic 7', 7,    '2026-01-01', NULL,         true,  NULL),
+  (4, 5, 'Draft',    7,    '2026-01-01', NULL,         false, NULL),
+  (5, 6, 'Other',    NULL, '2026-01-01', NULL,         true,  NULL),
+  (6, 5, 'Removed',  NULL, '2026-01-01', NULL,         true,  '2026-02-01');
+INSERT INTO price_list_items (id, price_list_id, product_id, min_quantity, price) VALUES
+  (10, 1, 100, 1, 90), (11, 1, 100, 5, 80),
+  (20, 2, 100, 1, 95),
+  (30, 3, 200, 1, 50),
+  (40, 4, 100, 1, 10),
+  (50, 5, 100, 1, 1),
+  (60, 6, 200, 1, 5);
+`
+
+func TestResolve(t *testing.T) {
+	client, db := testClient(t)
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, resolveTestSchema); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	repo := NewPriceListRepository(client, nil)
+
+	clinic := 7
+	march := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	type want struct {
+		price  float64
+		listID int
+	}
+	tests := []struct {
+		name     string
+		pc       PriceContext
+		lines    []PriceLine
+		expected map[int]want
+	}{
+		{
+			name:     "list effective at the date",
+			pc:       PriceContext{DepartmentID: 5, At: march},
+			lines:    []PriceLine{{ProductID: 100, Quantity: 1}},
+			expected: map[int]want{100: {90, 1}},
+		},
+		{
+			name:     "effective_to is exclusive",
+			pc:       PriceContext{DepartmentID: 5, At: april},
+			lines:    []PriceLine{{ProductID: 100, Quantity: 1}},
+			expected: map[int]want{100: {95, 2}},
+		},
+		{
+			name:     "tier counts the whole quantity of a product",
+			pc:       PriceContext{DepartmentID: 5, At: march},
+			lines:    []PriceLine{{ProductID: 100, Quantity: 3}, {ProductID: 100, Quantity: 2}},
+			expected: map[int]want{100: {80, 1}},
+		},
+		{
+			name:     "clinic list, inactive list ignored",
+			pc:       PriceContext{DepartmentID: 5, ClinicID: &clinic, At: march},
+			lines:    []PriceLine{{ProductID: 100, Quantity: 1}, {ProductID: 200, Quantity: 1}},
+			expected: map[int]want{100: {90, 1}, 200: {50, 3}},
+		},
+		{
+			name:     "clinic list needs the clinic, deleted list ignored",
+			pc:       PriceContext{DepartmentID: 5, At: march},
+			lines:    []PriceLine{{ProductID: 200, Quantity: 1}},
+			expected: map[int]want{},
+		},
+		{
+			name:     "before any list",
+			pc:       PriceContext{DepartmentID: 5, At: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)},
+			lines:    []PriceLine{{ProductID: 100, Quantity: 1}},
+			expected: map[int]want{},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.Resolve(ctx, tt.pc, tt.lines)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s: resolved %d products; want %d", tt.name, len(got), len(tt.expected))
+			continue
+		}
+		for productID, w := range tt.expected {
+			rp, ok := got[productID]
+			if !ok || rp.Price != w.price || rp.PriceListID != w.listID {
+				t.Errorf("%s: product %d = %+v; want price %v from list %d", tt.name, productID, rp, w.price, w.listID)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	productservice "github.com/khiemnd777/andy_api/modules/main/features/product/service"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type PriceListService interface {
+	Create(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error)
+	Update(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error)
+	GetByID(ctx context.Context, deptID int, id int) (*model.PriceListDTO, error)
+	List(ctx context.Context, deptID int, clinicID *int, query table.TableQuery) (table.TableListResult[model.PriceListDTO], error)
+	Delete(ctx context.Context, deptID int, id int) error
+	Resolve(ctx context.Context, pc repository.PriceContext, productID int, quantity int) (*model.ResolvedPriceDTO, error)
+}
+
+type priceListService struct {
+	repo     repository.PriceListRepository
+	priceSvc productservice.ProductPriceService
+	deps     *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPriceListService(
+	repo repository.PriceListRepository,
+	priceSvc productservice.ProductPriceService,
+	deps *module.ModuleDeps[config.ModuleConfig],
+) PriceListService {
+	return &priceListService{repo: repo, priceSvc: priceSvc, deps: deps}
+}
+
+func kPriceListByID(deptID, id int) string {
+	return fmt.Sprintf("price_list:dpt%d:id:%d", deptID, id)
+}
+
+func kPriceListAll() string {
+	return "price_list:list:*"
+}
+
+func kPriceList(deptID int, clinicID *int, q table.TableQuery) string {
+	orderBy := ""
+	if q.OrderBy != nil {
+		orderBy = *q.OrderBy
+	}
+	cid := 0
+	if clinicID != nil {
+		cid = *clinicID
+	}
+	return fmt.Sprintf("price_list:list:dpt%d:c%d:l%d:p%d:o%s:d%s", deptID, cid, q.Limit, q.Page, orderBy, q.Direction)
+}
+
+func (s *priceListService) Create(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error) {
+	dto, err := s.repo.Create(ctx, deptID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kPriceListAll())
+
+	return dto, nil
+}
+
+func (s *priceListService) Update(ctx context.Context, deptID int, input *model.PriceListUpsertDTO) (*model.PriceListDTO, error) {
+	dto, err := s.repo.Update(ctx, deptID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kPriceListByID(deptID, dto.ID), kPriceListAll())
+
+	return dto, nil
+}
+
+func (s *priceListService) GetByID(ctx context.Context, deptID int, id int) (*model.PriceListDTO, error) {
+	return cache.Get(kPriceListByID(deptID, id), cache.TTLMedium, func() (*model.PriceListDTO, error) {
+		return s.repo.GetByID(ctx, deptID, id)
+	})
+}
+
+func (s *priceListService) List(ctx context.Context, deptID int, clinicID *int, q table.TableQuery) (table.TableListResult[model.PriceListDTO], error) {
+	type boxed = table.TableListResult[model.PriceListDTO]
+	key := kPriceList(deptID, clinicID, q)
+
+	ptr, err := cache.Get(key, cache.TTLMedium, func() (*boxed, error) {
+		res, e := s.repo.List(ctx, deptID, clinicID, q)
+		if e != nil {
+			return nil, e
+		}
+		return &res, nil
+	})
+	if err != nil {
+		var zero boxed
+		return zero, err
+	}
+	return *ptr, nil
+}
+
+func (s *priceListService) Delete(ctx context.Context, deptID int, id int) error {
+	if err := s.repo.Delete(ctx, deptID, id); err != nil {
+		return err
+	}
+
+	cache.InvalidateKeys(kPriceListByID(deptID, id), kPriceListAll())
+
+	return nil
+}
+
+// Resolve falls back to the product base price when no price list applies.
+func (s *priceListService) Resolve(ctx context.Context, pc repository.PriceContext, productID int, quantity int) (*model.ResolvedPriceDTO, error) {
+	if pc.At.IsZero() {
+		pc.At = time.Now()
+	}
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	resolved, err := s.repo.Resolve(ctx, pc, []repository.PriceLine{{ProductID: productID, Quantity: quantity}})
+	if err != nil {
+		return nil, err
+	}
+	if rp, ok := resolved[productID]; ok {
+		listID := rp.PriceListID
+		return &model.ResolvedPriceDTO{
+			ProductID:   productID,
+			Quantity:    quantity,
+			Price:       rp.Price,
+			PriceListID: &listID,
+			Source:      "price_list",
+		}, nil
+	}
+
+	base, err := s.priceSvc.BasePrice(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ResolvedPriceDTO{
+		ProductID: productID,
+		Quantity:  quantity,
+		Price:     base.Price,
+		Source:    "base",
+	}, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/printing/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type PrintHandler struct {
+	svc  service.PrintService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPrintHandler(svc service.PrintService, deps *module.ModuleDeps[config.ModuleConfig]) *PrintHandler {
+	return &PrintHandler{svc: svc, deps: deps}
+}
+
+func (h *PrintHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/print/order-item/:id<int>/ticket", h.Ticket)
+	app.RouterGet(router, "/:dept_id<int>/print/order-item/:id<int>/label", h.Label)
+	app.RouterGet(router, "/:dept_id<int>/print/order-item/:id<int>/label/zpl", h.LabelZPL)
+	app.RouterPost(router, "/:dept_id<int>/print/tickets", h.Tickets)
+	app.RouterPost(router, "/:dept_id<int>/print/labels", h.Labels)
+	app.RouterPost(router, "/:dept_id<int>/print/labels/zpl", h.LabelsZPL)
+}
+
+func (h *PrintHandler) Ticket(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.TicketsPDF(c.UserContext(), deptID, []int64{int64(id)})
+	return h.send(c, out, err, "application/pdf", "ticket", "pdf")
+}
+
+func (h *PrintHandler) Label(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.LabelsPDF(c.UserContext(), deptID, []int64{int64(id)}, c.QueryInt("skip", 0))
+	return h.send(c, out, err, "application/pdf", "label", "pdf")
+}
+
+func (h *PrintHandler) LabelZPL(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.LabelsZPL(c.UserContext(), deptID, []int64{int64(id)})
+	return h.send(c, out, err, "text/plain; charset=utf-8", "label", "zpl")
+}
+
+func (h *PrintHandler) Tickets(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PrintBatchDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	if len(payload.OrderItemIDs) == 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "order_item_ids is required")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.TicketsPDF(c.UserContext(), deptID, payload.OrderItemIDs)
+	return h.send(c, out, err, "application/pdf", "tickets", "pdf")
+}
+
+func (h *PrintHandler) Labels(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PrintBatchDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	if len(payload.OrderItemIDs) == 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "order_item_ids is required")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.LabelsPDF(c.UserContext(), deptID, payload.OrderItemIDs, c.QueryInt("skip", 0))
+	return h.send(c, out, err, "application/pdf", "labels", "pdf")
+}
+
+func (h *PrintHandler) LabelsZPL(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	payload, err := app.ParseBody[model.PrintBatchDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	if len(payload.OrderItemIDs) == 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "order_item_ids is required")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	out, err := h.svc.LabelsZPL(c.UserContext(), deptID, payload.OrderItemIDs)
+	return h.send(c, out, err, "text/plain; charset=utf-8", "labels", "zpl")
+}
+
+func (h *PrintHandler) send(c *fiber.Ctx, out []byte, err error, contentType, name, ext string) error {
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNothingToPrint):
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		case errors.Is(err, service.ErrBatchTooLarge):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	filename := fmt.Sprintf("%s_%d.%s", name, time.Now().Unix(), ext)
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	return c.Status(fiber.StatusOK).Send(out)
+}
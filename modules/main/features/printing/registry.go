@@ -0,0 +1,29 @@
+package printing
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/printing/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/printing/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/printing/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "printing" }
+func (feature) Priority() int { return 80 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	repo := repository.NewPrintRepository(deps.Ent.(*generated.Client), deps)
+	svc := service.NewPrintService(repo, deps)
+	h := handler.NewPrintHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
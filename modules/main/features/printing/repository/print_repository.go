@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/order"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitem"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocess"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemproduct"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type PrintRepository interface {
+	Tickets(ctx context.Context, deptID int, orderItemIDs []int64) ([]*model.PrintTicketDTO, error)
+}
+
+type printRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPrintRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) PrintRepository {
+	return &printRepository{db: db, deps: deps}
+}
+
+// Tickets loads the printable data of the given order items of the
+// department, keeping the requested order. Unknown ids are skipped.
+func (r *printRepository) Tickets(ctx context.Context, deptID int, orderItemIDs []int64) ([]*model.PrintTicketDTO, error) {
+	if len(orderItemIDs) == 0 {
+		return []*model.PrintTicketDTO{}, nil
+	}
+
+	items, err := r.db.OrderItem.Query().
+		Where(
+			orderitem.IDIn(orderItemIDs...),
+			orderitem.DeletedAtIsNil(),
+			orderitem.HasOrderWith(
+				order.DepartmentID(deptID),
+				order.DeletedAtIsNil(),
+			),
+		).
+		WithOrder().
+		WithProducts(func(q *generated.OrderItemProductQuery) {
+			q.WithProduct().Order(orderitemproduct.ByID())
+		}).
+		WithProcesses(func(q *generated.OrderItemProcessQuery) {
+			q.Order(orderitemprocess.ByStepNumber())
+		}).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*generated.OrderItem, len(items))
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+
+	out := make([]*model.PrintTicketDTO, 0, len(items))
+	for _, id := range orderItemIDs {
+		it, ok := byID[id]
+		if !ok {
+			continue
+		}
+		delete(byID, id) // duplicates print once
+		out = append(out, toTicket(it))
+	}
+
+	return out, nil
+}
+
+func toTicket(it *generated.OrderItem) *model.PrintTicketDTO {
+	t := &model.PrintTicketDTO{
+		OrderID:     it.OrderID,
+		OrderItemID: it.ID,
+		RemakeCount: it.RemakeCount,
+	}
+	if it.Code != nil {
+		t.OrderItemCode = *it.Code
+	}
+
+	if o := it.Edges.Order; o != nil {
+		t.OrderCode = o.Code
+		t.ClinicName = o.ClinicName
+		t.DentistName = o.DentistName
+		t.PatientName = o.PatientName
+		t.DeliveryDate = o.DeliveryDate
+	}
+
+	// the item carries its own due date and priority once it is in production
+	if raw, ok := it.CustomFields["delivery_date"].(string); ok && raw != "" {
+		if d, err := utils.ParseDate(raw); err == nil {
+			t.DeliveryDate = &d
+		}
+	}
+	if p, ok := it.CustomFields["priority"].(string); ok && p != "" {
+		t.Priority = &p
+	}
+
+	for _, rel := range it.Edges.Products {
+		dto := mapper.MapAs[*generated.OrderItemProduct, *model.OrderItemProductDTO](rel)
+		if p := rel.Edges.Product; p != nil {
+			dto.ProductName = p.Name
+			if dto.ProductCode == nil {
+				dto.ProductCode = p.Code
+			}
+		}
+		t.Products = append(t.Products, dto)
+	}
+
+	// optional steps that do not apply to the item are left off the ticket
+	processes := make([]*generated.OrderItemProcess, 0, len(it.Edges.Processes))
+	for _, p := range it.Edges.Processes {
+		if s, _ := p.CustomFields["status"].(string); s != "skipped" {
+			processes = append(processes, p)
+		}
+	}
+	t.Processes = mapper.MapListAs[*generated.OrderItemProcess, *model.OrderItemProcessDTO](processes)
+
+	return t
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+)
+
+// A4 sheet of 3 x 8 labels, 70 x 37 mm each, no page margins.
+const (
+	labelCols = 3
+	labelRows = 8
+	labelW    = 70.0
+	labelH    = 37.0
+	labelTop  = (297.0 - labelRows*labelH) / 2
+)
+
+// renderLabelsPDF fills label sheets with one QR label per order item. skip
+// leaves the first positions empty so a partly used sheet can be reused.
+func renderLabelsPDF(cfg *config.ModuleConfig, tickets []*model.PrintTicketDTO, skip int) ([]byte, error) {
+	doc := newPDF(cfg, "A4")
+	doc.SetMargins(0, 0, 0)
+	doc.SetAutoPageBreak(false, 0)
+
+	perPage := labelCols * labelRows
+	if skip < 0 || skip >= perPage {
+		skip = 0
+	}
+
+	const (
+		pad    = 3.0
+		qrSide = labelH - 2*pad
+	)
+
+	for i, t := range tickets {
+		pos := (i + skip) % perPage
+		if i == 0 || pos == 0 {
+			doc.AddPage()
+		}
+
+		x := float64(pos%labelCols) * labelW
+		y := labelTop + float64(pos/labelCols)*labelH
+
+		if err := doc.qr(fmt.Sprintf("label-%d", i), t.OrderItemCode, x+pad, y+pad, qrSide); err != nil {
+			return nil, err
+		}
+
+		textX := x + pad + qrSide + 2
+		textW := labelW - (textX - x) - pad
+
+		doc.SetXY(textX, y+pad+1)
+		doc.font("B", 9)
+		doc.CellFormat(textW, 5, t.OrderItemCode, "", 2, "L", false, 0, "")
+
+		doc.font("", 7)
+		lines := []string{
+			deref(t.PatientName),
+			deref(t.DentistName),
+			deref(t.ClinicName),
+		}
+		if t.DeliveryDate != nil {
+			lines = append(lines, t.DeliveryDate.Format(dateLayout))
+		}
+		for _, l := range lines {
+			if l == "" {
+				continue
+			}
+			doc.SetX(textX)
+			doc.CellFormat(textW, 4, fit(doc, doc.text(l), textW), "", 2, "L", false, 0, "")
+		}
+	}
+
+	return doc.bytes()
+}
+
+// fit truncates s so it does not overflow a label cell of width w.
+func fit(doc *pdfDoc, s string, w float64) string {
+	if doc.GetStringWidth(s) <= w {
+		return s
+	}
+	ellipsis := "..."
+	if doc.utf8 {
+		ellipsis = "…"
+	}
+	r := []rune(s)
+	for len(r) > 0 && doc.GetStringWidth(string(r)+ellipsis) > w {
+		r = r[:len(r)-1]
+	}
+	return string(r) + ellipsis
+}
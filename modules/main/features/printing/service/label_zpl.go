@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+// renderLabelsZPL emits one 50 x 30 mm label per order item for 203 dpi
+// thermal printers (8 dots/mm). The QR code is drawn by the printer itself.
+// Resident printer fonts have no Vietnamese glyphs, so text is transliterated.
+func renderLabelsZPL(tickets []*model.PrintTicketDTO) []byte {
+	var b strings.Builder
+
+	for _, t := range tickets {
+		b.WriteString("^XA\n")
+		b.WriteString("^CI28\n")
+		b.WriteString("^PW400\n")
+		b.WriteString("^LL240\n")
+		fmt.Fprintf(&b, "^FO10,10^BQN,2,4^FDMA,%s^FS\n", zplText(t.OrderItemCode))
+		fmt.Fprintf(&b, "^FO170,20^A0N,30,30^FD%s^FS\n", zplText(t.OrderItemCode))
+
+		y := 60
+		lines := []string{deref(t.PatientName), deref(t.DentistName)}
+		if t.DeliveryDate != nil {
+			lines = append(lines, t.DeliveryDate.Format(dateLayout))
+		}
+		for _, l := range lines {
+			if l == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "^FO170,%d^A0N,22,22^FB220,1,0,L^FD%s^FS\n", y, zplText(l))
+			y += 30
+		}
+
+		b.WriteString("^XZ\n")
+	}
+
+	return []byte(b.String())
+}
+
+// zplText strips diacritics and the ZPL command prefixes from field data.
+func zplText(s string) string {
+	s = utils.RemoveVietnameseDiacritics(s)
+	return strings.NewReplacer("^", " ", "~", " ").Replace(s)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+const dateLayout = "02/01/2006"
+
+// pdfDoc wraps fpdf with the configured font. Without a UTF-8 TTF the core
+// Helvetica font is used, which cannot draw Vietnamese diacritics, so text
+// is transliterated instead of printing garbage.
+type pdfDoc struct {
+	*fpdf.Fpdf
+	family string
+	utf8   bool
+}
+
+func newPDF(cfg *config.ModuleConfig, size string) *pdfDoc {
+	pdf := fpdf.New("P", "mm", size, "")
+	doc := &pdfDoc{Fpdf: pdf, family: "Helvetica"}
+
+	if cfg == nil || cfg.Print.FontPath == "" {
+		return doc
+	}
+	regular, err := os.ReadFile(cfg.Print.FontPath)
+	if err != nil {
+		return doc
+	}
+	bold := regular
+	if cfg.Print.FontBoldPath != "" {
+		if b, err := os.ReadFile(cfg.Print.FontBoldPath); err == nil {
+			bold = b
+		}
+	}
+
+	pdf.AddUTF8FontFromBytes("print", "", regular)
+	pdf.AddUTF8FontFromBytes("print", "B", bold)
+	doc.family = "print"
+	doc.utf8 = true
+
+	return doc
+}
+
+func (d *pdfDoc) font(style string, size float64) {
+	d.SetFont(d.family, style, size)
+}
+
+func (d *pdfDoc) text(s string) string {
+	if d.utf8 {
+		return s
+	}
+	return utils.RemoveVietnameseDiacritics(s)
+}
+
+// qr draws a square QR code of the given side length at x, y.
+func (d *pdfDoc) qr(name, content string, x, y, side float64) error {
+	png, err := qrcode.Encode(content, qrcode.Medium, 512)
+	if err != nil {
+		return err
+	}
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	d.RegisterImageOptionsReader(name, opts, bytes.NewReader(png))
+	d.ImageOptions(name, x, y, side, side, false, opts, 0, "")
+	return nil
+}
+
+func (d *pdfDoc) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
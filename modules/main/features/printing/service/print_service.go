@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/printing/repository"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+// maxBatch caps how many order items one print request may render.
+const maxBatch = 200
+
+var (
+	ErrNothingToPrint = errors.New("no printable order item found")
+	ErrBatchTooLarge  = errors.New("too many order items in one print request")
+)
+
+type PrintService interface {
+	TicketsPDF(ctx context.Context, deptID int, orderItemIDs []int64) ([]byte, error)
+	LabelsPDF(ctx context.Context, deptID int, orderItemIDs []int64, skip int) ([]byte, error)
+	LabelsZPL(ctx context.Context, deptID int, orderItemIDs []int64) ([]byte, error)
+}
+
+type printService struct {
+	repo repository.PrintRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewPrintService(repo repository.PrintRepository, deps *module.ModuleDeps[config.ModuleConfig]) PrintService {
+	return &printService{repo: repo, deps: deps}
+}
+
+func (s *printService) TicketsPDF(ctx context.Context, deptID int, orderItemIDs []int64) ([]byte, error) {
+	tickets, err := s.tickets(ctx, deptID, orderItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return renderTicketsPDF(s.deps.Config, tickets)
+}
+
+func (s *printService) LabelsPDF(ctx context.Context, deptID int, orderItemIDs []int64, skip int) ([]byte, error) {
+	tickets, err := s.tickets(ctx, deptID, orderItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return renderLabelsPDF(s.deps.Config, tickets, skip)
+}
+
+func (s *printService) LabelsZPL(ctx context.Context, deptID int, orderItemIDs []int64) ([]byte, error) {
+	tickets, err := s.tickets(ctx, deptID, orderItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return renderLabelsZPL(tickets), nil
+}
+
+func (s *printService) tickets(ctx context.Context, deptID int, orderItemIDs []int64) ([]*model.PrintTicketDTO, error) {
+	if len(orderItemIDs) > maxBatch {
+		return nil, ErrBatchTooLarge
+	}
+	tickets, err := s.repo.Tickets(ctx, deptID, orderItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		return nil, ErrNothingToPrint
+	}
+	return tickets, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+)
+
+// renderTicketsPDF prints one A5 work ticket per order item: header with the
+// scannable QR code, case details, products with teeth and the process list
+// with a column for the technician to sign off each step.
+func renderTicketsPDF(cfg *config.ModuleConfig, tickets []*model.PrintTicketDTO) ([]byte, error) {
+	doc := newPDF(cfg, "A5")
+	doc.SetMargins(10, 10, 10)
+	doc.SetAutoPageBreak(true, 10)
+
+	const (
+		pageW  = 148.0
+		innerW = pageW - 20
+		qrSide = 30.0
+	)
+
+	for i, t := range tickets {
+		doc.AddPage()
+
+		// header
+		doc.font("B", 15)
+		doc.CellFormat(innerW-qrSide-4, 8, doc.text("PHIẾU SẢN XUẤT"), "", 1, "L", false, 0, "")
+		doc.font("B", 12)
+		doc.CellFormat(innerW-qrSide-4, 7, t.OrderItemCode, "", 1, "L", false, 0, "")
+		if t.RemakeCount > 0 {
+			doc.font("B", 10)
+			doc.SetTextColor(200, 0, 0)
+			doc.CellFormat(innerW-qrSide-4, 6, doc.text(fmt.Sprintf("LÀM LẠI LẦN %d", t.RemakeCount)), "", 1, "L", false, 0, "")
+			doc.SetTextColor(0, 0, 0)
+		}
+		if err := doc.qr(fmt.Sprintf("ticket-%d", i), t.OrderItemCode, pageW-10-qrSide, 10, qrSide); err != nil {
+			return nil, err
+		}
+		doc.SetY(10 + qrSide + 3)
+
+		// case details
+		due := ""
+		if t.DeliveryDate != nil {
+			due = t.DeliveryDate.Format(dateLayout)
+		}
+		rows := [][2]string{
+			{"Mã đơn hàng", deref(t.OrderCode)},
+			{"Phòng khám", deref(t.ClinicName)},
+			{"Nha sĩ", deref(t.DentistName)},
+			{"Bệnh nhân", deref(t.PatientName)},
+			{"Ngày giao", due},
+			{"Ưu tiên", deref(t.Priority)},
+		}
+		for _, r := range rows {
+			doc.font("", 10)
+			doc.CellFormat(30, 6, doc.text(r[0]), "", 0, "L", false, 0, "")
+			doc.font("B", 10)
+			doc.CellFormat(innerW-30, 6, fit(doc, doc.text(r[1]), innerW-32), "", 1, "L", false, 0, "")
+		}
+		doc.Ln(3)
+
+		// products
+		doc.font("B", 10)
+		doc.SetFillColor(230, 230, 230)
+		doc.CellFormat(8, 6, "#", "1", 0, "C", true, 0, "")
+		doc.CellFormat(70, 6, doc.text("Sản phẩm"), "1", 0, "L", true, 0, "")
+		doc.CellFormat(38, 6, doc.text("Vị trí răng"), "1", 0, "L", true, 0, "")
+		doc.CellFormat(innerW-116, 6, "SL", "1", 1, "C", true, 0, "")
+		doc.font("", 9)
+		for n, p := range t.Products {
+			name := deref(p.ProductName)
+			if name == "" {
+				name = deref(p.ProductCode)
+			}
+			doc.CellFormat(8, 6, strconv.Itoa(n+1), "1", 0, "C", false, 0, "")
+			doc.CellFormat(70, 6, fit(doc, doc.text(name), 68), "1", 0, "L", false, 0, "")
+			doc.CellFormat(38, 6, fit(doc, doc.text(deref(p.TeethPosition)), 36), "1", 0, "L", false, 0, "")
+			doc.CellFormat(innerW-116, 6, strconv.Itoa(p.Quantity), "1", 1, "C", false, 0, "")
+		}
+		doc.Ln(3)
+
+		// processes
+		doc.font("B", 10)
+		doc.CellFormat(10, 6, doc.text("Bước"), "1", 0, "C", true, 0, "")
+		doc.CellFormat(48, 6, doc.text("Công đoạn"), "1", 0, "L", true, 0, "")
+		doc.CellFormat(32, 6, doc.text("Bộ phận"), "1", 0, "L", true, 0, "")
+		doc.CellFormat(innerW-90, 6, doc.text("Ký xác nhận"), "1", 1, "L", true, 0, "")
+		doc.font("", 9)
+		for _, p := range t.Processes {
+			doc.CellFormat(10, 7, strconv.Itoa(p.StepNumber), "1", 0, "C", false, 0, "")
+			doc.CellFormat(48, 7, fit(doc, doc.text(deref(p.ProcessName)), 46), "1", 0, "L", false, 0, "")
+			doc.CellFormat(32, 7, fit(doc, doc.text(deref(p.SectionName)), 30), "1", 0, "L", false, 0, "")
+			doc.CellFormat(innerW-90, 7, doc.text(deref(p.AssignedName)), "1", 1, "L", false, 0, "")
+		}
+	}
+
+	return doc.bytes()
+}
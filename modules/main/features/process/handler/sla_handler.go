@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/process/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/process/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type ProcessSLAHandler struct {
+	svc  service.ProcessSLAService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProcessSLAHandler(svc service.ProcessSLAService, deps *module.ModuleDeps[config.ModuleConfig]) *ProcessSLAHandler {
+	return &ProcessSLAHandler{svc: svc, deps: deps}
+}
+
+func (h *ProcessSLAHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/process/sla/breaches", h.Breaches)
+	app.RouterGet(router, "/:dept_id<int>/process/:id<int>/sla", h.List)
+	app.RouterPut(router, "/:dept_id<int>/process/:id<int>/sla", h.Upsert)
+	app.RouterDelete(router, "/:dept_id<int>/process/:id<int>/sla/:sla_id<int>", h.Delete)
+}
+
+func (h *ProcessSLAHandler) List(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "process.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	res, err := h.svc.ListByProcessID(c.UserContext(), id)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *ProcessSLAHandler) Upsert(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "process.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	var payload model.ProcessSLADTO
+	if err := c.BodyParser(&payload); err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	dto, err := h.svc.Upsert(c.UserContext(), id, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidSLA):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case generated.IsNotFound(err):
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "product not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *ProcessSLAHandler) Delete(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "process.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	slaID, _ := utils.GetParamAsInt(c, "sla_id")
+	if id <= 0 || slaID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	if err := h.svc.Delete(c.UserContext(), id, slaID); err != nil {
+		if errors.Is(err, repository.ErrSLANotFound) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *ProcessSLAHandler) Breaches(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "process.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	res, err := h.svc.Breaches(c.UserContext(), deptID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
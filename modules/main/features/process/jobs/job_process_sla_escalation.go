@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/features/process/service"
+	"github.com/khiemnd777/andy_api/shared/logger"
+)
+
+type ProcessSLAEscalationJob struct {
+	svc service.ProcessSLAService
+}
+
+func NewProcessSLAEscalationJob(svc service.ProcessSLAService) *ProcessSLAEscalationJob {
+	return &ProcessSLAEscalationJob{svc: svc}
+}
+
+func (j ProcessSLAEscalationJob) Name() string            { return "ProcessSLAEscalation" }
+func (j ProcessSLAEscalationJob) DefaultSchedule() string { return "@every 5m" }
+func (j ProcessSLAEscalationJob) ConfigKey() string       { return "cron.process_sla_escalation" }
+
+func (j ProcessSLAEscalationJob) Run() error {
+	logger.Debug("[ProcessSLAEscalationJob] Process SLA escalation starting...")
+
+	n, err := j.svc.Escalate(context.Background(), time.Now())
+	if err != nil {
+		logger.Error(fmt.Sprintf("[ProcessSLAEscalationJob] Process SLA escalation failed: %v", err))
+		return err
+	}
+
+	logger.Debug(fmt.Sprintf("[ProcessSLAEscalationJob] Done. %d step(s) escalated.", n))
+	return nil
+}
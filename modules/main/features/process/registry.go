@@ -5,9 +5,11 @@ import (
 
 	"github.com/khiemnd777/andy_api/modules/main/config"
 	"github.com/khiemnd777/andy_api/modules/main/features/process/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/process/jobs"
 	"github.com/khiemnd777/andy_api/modules/main/features/process/repository"
 	"github.com/khiemnd777/andy_api/modules/main/features/process/service"
 	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/cron"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
 	"github.com/khiemnd777/andy_api/shared/module"
@@ -23,6 +25,13 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	svc := service.NewProcessService(repo, deps, cfMgr)
 	h := handler.NewProcessHandler(svc, deps)
 	h.RegisterRoutes(router)
+
+	slaRepo := repository.NewProcessSLARepository(deps.Ent.(*generated.Client), deps.DB, deps)
+	slaSvc := service.NewProcessSLAService(slaRepo, deps)
+	cron.RegisterJob(jobs.NewProcessSLAEscalationJob(slaSvc))
+	slaHandler := handler.NewProcessSLAHandler(slaSvc, deps)
+	slaHandler.RegisterRoutes(router)
+
 	return nil
 }
 
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/orderitemprocessinprogress"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/processsla"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+const (
+	SLAStatusWarning  = "warning"
+	SLAStatusBreached = "breached"
+)
+
+var (
+	ErrInvalidSLA  = errors.New("standard_minutes must be positive and warning_minutes between 0 and standard_minutes")
+	ErrSLANotFound = errors.New("sla not found")
+)
+
+// SLAStep is a running process step together with the SLA that applies to it.
+type SLAStep struct {
+	InProgressID    int64
+	OrderID         *int64
+	OrderItemID     int64
+	OrderItemCode   *string
+	DepartmentID    int
+	ProcessName     *string
+	SectionName     *string
+	LeaderID        *int
+	LeaderName      *string
+	AssignedID      *int64
+	AssignedName    *string
+	StartedAt       time.Time
+	SLAStatus       *string
+	Warned          bool
+	StandardMinutes int
+	WarningMinutes  int
+	HeldSeconds     float64 // hold time of the item since the step started
+}
+
+// Elapsed is the running time of the step with the time on hold left out.
+func (s *SLAStep) Elapsed(now time.Time) time.Duration {
+	return now.Sub(s.StartedAt) - time.Duration(s.HeldSeconds*float64(time.Second))
+}
+
+type ProcessSLARepository interface {
+	ListByProcessID(ctx context.Context, processID int) ([]*model.ProcessSLADTO, error)
+	Upsert(ctx context.Context, processID int, input model.ProcessSLADTO) (*model.ProcessSLADTO, error)
+	Delete(ctx context.Context, processID int, id int) error
+	RunningSteps(ctx context.Context, deptID int, flaggedOnly bool) ([]*SLAStep, error)
+	MarkWarned(ctx context.Context, inProgressID int64, at time.Time) (bool, error)
+	MarkBreached(ctx context.Context, inProgressID int64, at time.Time) (bool, error)
+}
+
+type processSLARepo struct {
+	db    *generated.Client
+	sqlDB *sql.DB
+	deps  *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProcessSLARepository(db *generated.Client, sqlDB *sql.DB, deps *module.ModuleDeps[config.ModuleConfig]) ProcessSLARepository {
+	return &processSLARepo{db: db, sqlDB: sqlDB, deps: deps}
+}
+
+func (r *processSLARepo) ListByProcessID(ctx context.Context, processID int) ([]*model.ProcessSLADTO, error) {
+	items, err := r.db.ProcessSLA.Query().
+		Where(processsla.ProcessID(processID)).
+		Order(processsla.ByID()).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mapper.MapListAs[*generated.ProcessSLA, *model.ProcessSLADTO](items), nil
+}
+
+// Upsert keeps at most one SLA per process and product; a nil product is the
+// process default. The unique index does not cover NULL product ids, so the
+// existing row is looked up explicitly.
+func (r *processSLARepo) Upsert(ctx context.Context, processID int, input model.ProcessSLADTO) (*model.ProcessSLADTO, error) {
+	if input.StandardMinutes <= 0 || input.WarningMinutes < 0 || input.WarningMinutes >= input.StandardMinutes {
+		return nil, ErrInvalidSLA
+	}
+
+	var productName *string
+	if input.ProductID != nil {
+		p, err := r.db.Product.Query().
+			Where(product.ID(*input.ProductID), product.DeletedAtIsNil()).
+			Only(ctx)
+		if err != nil {
+			return nil, err
+		}
+		productName = p.Name
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	q := tx.ProcessSLA.Query().Where(processsla.ProcessID(processID))
+	if input.ProductID != nil {
+		q = q.Where(processsla.ProductID(*input.ProductID))
+	} else {
+		q = q.Where(processsla.ProductIDIsNil())
+	}
+
+	existing, err := q.First(ctx)
+	if err != nil && !generated.IsNotFound(err) {
+		return nil, err
+	}
+
+	var entity *generated.ProcessSLA
+	if existing != nil {
+		entity, err = tx.ProcessSLA.UpdateOneID(existing.ID).
+			SetNillableProductName(productName).
+			SetStandardMinutes(input.StandardMinutes).
+			SetWarningMinutes(input.WarningMinutes).
+			SetUpdatedAt(time.Now()).
+			Save(ctx)
+	} else {
+		entity, err = tx.ProcessSLA.Create().
+			SetProcessID(processID).
+			SetNillableProductID(input.ProductID).
+			SetNillableProductName(productName).
+			SetStandardMinutes(input.StandardMinutes).
+			SetWarningMinutes(input.WarningMinutes).
+			Save(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return mapper.MapAs[*generated.ProcessSLA, *model.ProcessSLADTO](entity), nil
+}
+
+func (r *processSLARepo) Delete(ctx context.Context, processID int, id int) error {
+	n, err := r.db.ProcessSLA.Delete().
+		Where(processsla.ID(id), processsla.ProcessID(processID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSLANotFound
+	}
+	return nil
+}
+
+// RunningSteps lists checked-in, not yet completed steps that have an SLA.
+// Steps created before process_id was recorded fall back to the process name.
+// A product specific SLA wins over the default; when several products of the
+// item match, the longest standard applies. deptID 0 scans every department.
+func (r *processSLARepo) RunningSteps(ctx context.Context, deptID int, flaggedOnly bool) ([]*SLAStep, error) {
+	const q = `
+SELECT
+  ip.id,
+  ip.order_id,
+  ip.order_item_id,
+  ip.order_item_code,
+  o.department_id,
+  oip.process_name,
+  oip.section_name,
+  oip.leader_id,
+  oip.leader_name,
+  ip.assigned_id,
+  ip.assigned_name,
+  ip.started_at,
+  ip.sla_status,
+  ip.sla_warned_at IS NOT NULL AS warned,
+  sla.standard_minutes,
+  sla.warning_minutes,
+  COALESCE(held.seconds, 0)
+FROM order_item_process_in_progresses ip
+JOIN order_item_processes oip ON oip.id = ip.process_id
+JOIN order_items oi ON oi.id = ip.order_item_id
+JOIN orders o ON o.id = oi.order_id
+JOIN LATERAL (
+  SELECT s.standard_minutes, s.warning_minutes
+  FROM process_slas s
+  WHERE
+    s.process_id = COALESCE(oip.process_id, (
+      SELECT p.id
+      FROM processes p
+      WHERE p.name = oip.process_name AND p.deleted_at IS NULL
+      ORDER BY p.id
+      LIMIT 1
+    ))
+    AND (
+      s.product_id IS NULL
+      OR s.product_id = oi.product_id
+      OR s.product_id IN (
+        SELECT oipr.product_id
+        FROM order_item_products oipr
+        WHERE oipr.order_item_id = oi.id
+      )
+    )
+  ORDER BY (s.product_id IS NULL), s.standard_minutes DESC
+  LIMIT 1
+) sla ON TRUE
+LEFT JOIN LATERAL (
+  SELECT SUM(EXTRACT(EPOCH FROM (
+    COALESCE(h.resumed_at, now()) - GREATEST(h.held_at, ip.started_at)
+  )))::float8 AS seconds
+  FROM order_item_holds h
+  WHERE
+    h.order_item_id = ip.order_item_id
+    AND COALESCE(h.resumed_at, now()) > ip.started_at
+) held ON TRUE
+WHERE
+  ($1::INT = 0 OR o.department_id = $1::INT)
+  AND o.deleted_at IS NULL AND oi.deleted_at IS NULL
+  AND ip.started_at IS NOT NULL
+  AND ip.completed_at IS NULL
+  AND ($2::BOOLEAN = FALSE OR ip.sla_status IS NOT NULL)
+ORDER BY ip.started_at;
+`
+
+	rows, err := r.sqlDB.QueryContext(ctx, q, deptID, flaggedOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*SLAStep
+	for rows.Next() {
+		var (
+			it           SLAStep
+			orderID      sql.NullInt64
+			itemCode     sql.NullString
+			processName  sql.NullString
+			sectionName  sql.NullString
+			leaderID     sql.NullInt64
+			leaderName   sql.NullString
+			assignedID   sql.NullInt64
+			assignedName sql.NullString
+			slaStatus    sql.NullString
+		)
+		if err := rows.Scan(
+			&it.InProgressID,
+			&orderID,
+			&it.OrderItemID,
+			&itemCode,
+			&it.DepartmentID,
+			&processName,
+			&sectionName,
+			&leaderID,
+			&leaderName,
+			&assignedID,
+			&assignedName,
+			&it.StartedAt,
+			&slaStatus,
+			&it.Warned,
+			&it.StandardMinutes,
+			&it.WarningMinutes,
+			&it.HeldSeconds,
+		); err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			it.OrderID = &orderID.Int64
+		}
+		if itemCode.Valid {
+			it.OrderItemCode = &itemCode.String
+		}
+		if processName.Valid {
+			it.ProcessName = &processName.String
+		}
+		if sectionName.Valid {
+			it.SectionName = &sectionName.String
+		}
+		if leaderID.Valid {
+			v := int(leaderID.Int64)
+			it.LeaderID = &v
+		}
+		if leaderName.Valid {
+			it.LeaderName = &leaderName.String
+		}
+		if assignedID.Valid {
+			it.AssignedID = &assignedID.Int64
+		}
+		if assignedName.Valid {
+			it.AssignedName = &assignedName.String
+		}
+		if slaStatus.Valid {
+			it.SLAStatus = &slaStatus.String
+		}
+		result = append(result, &it)
+	}
+
+	return result, rows.Err()
+}
+
+// MarkWarned flags the step once; false means another run already did.
+func (r *processSLARepo) MarkWarned(ctx context.Context, inProgressID int64, at time.Time) (bool, error) {
+	n, err := r.db.OrderItemProcessInProgress.Update().
+		Where(
+			orderitemprocessinprogress.ID(inProgressID),
+			orderitemprocessinprogress.SLAWarnedAtIsNil(),
+			orderitemprocessinprogress.SLABreachedAtIsNil(),
+		).
+		SetSLAStatus(SLAStatusWarning).
+		SetSLAWarnedAt(at).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// MarkBreached flags the step once; false means another run already did.
+func (r *processSLARepo) MarkBreached(ctx context.Context, inProgressID int64, at time.Time) (bool, error) {
+	n, err := r.db.OrderItemProcessInProgress.Update().
+		Where(
+			orderitemprocessinprogress.ID(inProgressID),
+			orderitemprocessinprogress.SLABreachedAtIsNil(),
+		).
+		SetSLAStatus(SLAStatusBreached).
+		SetSLABreachedAt(at).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
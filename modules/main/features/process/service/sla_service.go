@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/process/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/modules/notification"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+)
+
+type ProcessSLAService interface {
+	ListByProcessID(ctx context.Context, processID int) ([]*model.ProcessSLADTO, error)
+	Upsert(ctx context.Context, processID int, input model.ProcessSLADTO) (*model.ProcessSLADTO, error)
+	Delete(ctx context.Context, processID int, id int) error
+	Breaches(ctx context.Context, deptID int) ([]*model.ProcessSLABreachDTO, error)
+	Escalate(ctx context.Context, now time.Time) (int, error)
+}
+
+type processSLAService struct {
+	repo repository.ProcessSLARepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProcessSLAService(repo repository.ProcessSLARepository, deps *module.ModuleDeps[config.ModuleConfig]) ProcessSLAService {
+	return &processSLAService{repo: repo, deps: deps}
+}
+
+func kProcessSLAByProcessID(processID int) string {
+	return fmt.Sprintf("process:sla:%d", processID)
+}
+
+func (s *processSLAService) ListByProcessID(ctx context.Context, processID int) ([]*model.ProcessSLADTO, error) {
+	return cache.GetList(kProcessSLAByProcessID(processID), cache.TTLMedium, func() ([]*model.ProcessSLADTO, error) {
+		return s.repo.ListByProcessID(ctx, processID)
+	})
+}
+
+func (s *processSLAService) Upsert(ctx context.Context, processID int, input model.ProcessSLADTO) (*model.ProcessSLADTO, error) {
+	dto, err := s.repo.Upsert(ctx, processID, input)
+	if err != nil {
+		return nil, err
+	}
+	cache.InvalidateKeys(kProcessSLAByProcessID(processID))
+	return dto, nil
+}
+
+func (s *processSLAService) Delete(ctx context.Context, processID int, id int) error {
+	if err := s.repo.Delete(ctx, processID, id); err != nil {
+		return err
+	}
+	cache.InvalidateKeys(kProcessSLAByProcessID(processID))
+	return nil
+}
+
+// Breaches lists running steps of the department that are past their warning
+// threshold or their standard duration, the longest running first.
+func (s *processSLAService) Breaches(ctx context.Context, deptID int) ([]*model.ProcessSLABreachDTO, error) {
+	steps, err := s.repo.RunningSteps(ctx, deptID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	out := make([]*model.ProcessSLABreachDTO, 0, len(steps))
+	for _, st := range steps {
+		startedAt := st.StartedAt
+		out = append(out, &model.ProcessSLABreachDTO{
+			InProgressID:    st.InProgressID,
+			OrderID:         st.OrderID,
+			OrderItemID:     st.OrderItemID,
+			OrderItemCode:   st.OrderItemCode,
+			ProcessName:     st.ProcessName,
+			SectionName:     st.SectionName,
+			LeaderID:        st.LeaderID,
+			LeaderName:      st.LeaderName,
+			AssignedID:      st.AssignedID,
+			AssignedName:    st.AssignedName,
+			StartedAt:       &startedAt,
+			SLAStatus:       *st.SLAStatus,
+			ElapsedMinutes:  int(st.Elapsed(now).Minutes()),
+			StandardMinutes: st.StandardMinutes,
+		})
+	}
+	return out, nil
+}
+
+// Escalate flags running steps that crossed their warning threshold or their
+// standard duration, time on hold not counted, and notifies the section
+// leader and the assignee. Each level is claimed with a conditional update,
+// so a step is only announced once per level even when runs overlap. Returns
+// the number of escalations.
+func (s *processSLAService) Escalate(ctx context.Context, now time.Time) (int, error) {
+	steps, err := s.repo.RunningSteps(ctx, 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	escalated := 0
+	depts := make(map[int]struct{})
+	for _, st := range steps {
+		if st.SLAStatus != nil && *st.SLAStatus == repository.SLAStatusBreached {
+			continue
+		}
+
+		elapsed := st.Elapsed(now)
+		standard := time.Duration(st.StandardMinutes) * time.Minute
+		warning := time.Duration(st.StandardMinutes-st.WarningMinutes) * time.Minute
+
+		var (
+			claimed bool
+			level   string
+		)
+		switch {
+		case elapsed >= standard:
+			claimed, err = s.repo.MarkBreached(ctx, st.InProgressID, now)
+			level = repository.SLAStatusBreached
+		case st.WarningMinutes > 0 && !st.Warned && elapsed >= warning:
+			claimed, err = s.repo.MarkWarned(ctx, st.InProgressID, now)
+			level = repository.SLAStatusWarning
+		default:
+			continue
+		}
+		if err != nil {
+			return escalated, err
+		}
+		if !claimed {
+			continue
+		}
+
+		s.notify(st, level, elapsed)
+		depts[st.DepartmentID] = struct{}{}
+		escalated++
+	}
+
+	for deptID := range depts {
+		realtime.BroadcastToDept(deptID, "process:sla", nil)
+	}
+
+	return escalated, nil
+}
+
+func (s *processSLAService) notify(st *repository.SLAStep, level string, elapsed time.Duration) {
+	receivers := make([]int, 0, 2)
+	if st.LeaderID != nil && *st.LeaderID > 0 {
+		receivers = append(receivers, *st.LeaderID)
+	}
+	if st.AssignedID != nil && *st.AssignedID > 0 {
+		assignedID := int(*st.AssignedID)
+		if len(receivers) == 0 || receivers[0] != assignedID {
+			receivers = append(receivers, assignedID)
+		}
+	}
+
+	data := map[string]any{
+		"in_progress_id":   st.InProgressID,
+		"order_id":         st.OrderID,
+		"order_item_id":    st.OrderItemID,
+		"order_item_code":  st.OrderItemCode,
+		"process_name":     st.ProcessName,
+		"section_name":     st.SectionName,
+		"assigned_name":    st.AssignedName,
+		"elapsed_minutes":  int(elapsed.Minutes()),
+		"standard_minutes": st.StandardMinutes,
+	}
+	for _, receiverID := range receivers {
+		notification.Notify(receiverID, 0, "process:sla_"+level, data)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	pricelistrepo "github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type ProductPriceHandler struct {
+	svc  service.ProductPriceService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductPriceHandler(svc service.ProductPriceService, deps *module.ModuleDeps[config.ModuleConfig]) *ProductPriceHandler {
+	return &ProductPriceHandler{svc: svc, deps: deps}
+}
+
+func (h *ProductPriceHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterPost(router, "/:dept_id<int>/product/price/total", h.TotalPrice)
+	app.RouterPost(router, "/:dept_id<int>/product/price/raw-total", h.RawTotalPrice)
+	app.RouterGet(router, "/:dept_id<int>/product/:id<int>/price", h.GetPrice)
+}
+
+// RegisterContractRoutes serves the /api/product paths the shared price
+// client calls; the department is the one of the caller's token.
+func (h *ProductPriceHandler) RegisterContractRoutes(router fiber.Router) {
+	router.Use(h.requireDepartment)
+	app.RouterPost(router, "/price/total", h.TotalPrice)
+	app.RouterPost(router, "/price/raw-total", h.RawTotalPrice)
+	app.RouterGet(router, "/:id<int>/price", h.GetPrice)
+}
+
+func (h *ProductPriceHandler) requireDepartment(c *fiber.Ctx) error {
+	if deptID, ok := utils.GetDeptIDInt(c); !ok || deptID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusUnauthorized, nil, "unauthorized")
+	}
+	return c.Next()
+}
+
+func (h *ProductPriceHandler) TotalPrice(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "product.view", "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	req, err := app.ParseBody[model.ProductPriceTotalRequestDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	total, err := h.svc.TotalPrice(c.UserContext(), h.priceContext(c, req.ClinicID, req.DentistID), req.Items)
+	if err != nil {
+		return h.responsePriceError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(model.ProductPriceTotalDTO{TotalPrice: total})
+}
+
+func (h *ProductPriceHandler) RawTotalPrice(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "product.view", "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+
+	req, err := app.ParseBody[model.ProductPriceTotalRequestDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	total, err := h.svc.RawTotalPrice(c.UserContext(), h.priceContext(c, req.ClinicID, req.DentistID), req.Items)
+	if err != nil {
+		return h.responsePriceError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(model.ProductPriceTotalDTO{TotalPrice: total})
+}
+
+func (h *ProductPriceHandler) GetPrice(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "product.view", "order.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	var clinicID, dentistID *int
+	if v := utils.GetQueryAsInt(c, "clinic_id"); v > 0 {
+		clinicID = &v
+	}
+	if v := utils.GetQueryAsInt(c, "dentist_id"); v > 0 {
+		dentistID = &v
+	}
+
+	dto, err := h.svc.GetPrice(c.UserContext(), h.priceContext(c, clinicID, dentistID), id)
+	if err != nil {
+		return h.responsePriceError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+// priceContext prices for the caller's department as of now, with the price
+// lists of the clinic and dentist when given.
+func (h *ProductPriceHandler) priceContext(c *fiber.Ctx, clinicID, dentistID *int) pricelistrepo.PriceContext {
+	deptID, _ := utils.GetDeptIDInt(c)
+	return pricelistrepo.PriceContext{
+		DepartmentID: deptID,
+		ClinicID:     clinicID,
+		DentistID:    dentistID,
+		At:           time.Now(),
+	}
+}
+
+func (h *ProductPriceHandler) responsePriceError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, repository.ErrProductNotFound) {
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+)
+
+type ProductProcessGraphHandler struct {
+	svc  service.ProductProcessGraphService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductProcessGraphHandler(svc service.ProductProcessGraphService, deps *module.ModuleDeps[config.ModuleConfig]) *ProductProcessGraphHandler {
+	return &ProductProcessGraphHandler{svc: svc, deps: deps}
+}
+
+func (h *ProductProcessGraphHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/product/:id<int>/process-graph", h.Get)
+	app.RouterPut(router, "/:dept_id<int>/product/:id<int>/process-graph", h.Replace)
+}
+
+func (h *ProductProcessGraphHandler) Get(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "product.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	dto, err := h.svc.Get(c.UserContext(), id)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *ProductProcessGraphHandler) Replace(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "product.update"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	payload, err := app.ParseBody[model.ProductProcessGraphDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+
+	dto, err := h.svc.Replace(c.UserContext(), id, payload.Steps)
+	if err != nil {
+		return h.responseError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *ProductProcessGraphHandler) responseError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound):
+		return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+	case errors.Is(err, service.ErrInvalidProcessGraph):
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+	}
+	return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/khiemnd777/andy_api/modules/main/registry"
 	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
 	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/middleware"
 	"github.com/khiemnd777/andy_api/shared/module"
 )
 
@@ -23,6 +24,17 @@ func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.Modu
 	svc := service.NewProductService(repo, deps, cfMgr)
 	h := handler.NewProductHandler(svc, deps)
 	h.RegisterRoutes(router)
+
+	priceRepo := repository.NewProductPriceRepository(deps.Ent.(*generated.Client), deps)
+	priceSvc := service.NewProductPriceService(priceRepo, deps)
+	priceHandler := handler.NewProductPriceHandler(priceSvc, deps)
+	priceHandler.RegisterRoutes(router)
+	priceHandler.RegisterContractRoutes(deps.App.Group("/api/product", middleware.RequireAuth()))
+
+	graphRepo := repository.NewProductProcessGraphRepository(deps.Ent.(*generated.Client), deps)
+	graphSvc := service.NewProductProcessGraphService(graphRepo, deps)
+	graphHandler := handler.NewProductProcessGraphHandler(graphSvc, deps)
+	graphHandler.RegisterRoutes(router)
 	return nil
 }
 
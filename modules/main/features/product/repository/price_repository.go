@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+var ErrProductNotFound = errors.New("product not found")
+
+type ProductPriceRepository interface {
+	// GetBasePrices returns the base price of each product; a variant without
+	// its own retail_price falls back to its template. Unknown ids are omitted.
+	GetBasePrices(ctx context.Context, productIDs []int) (map[int]float64, error)
+}
+
+type productPriceRepo struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductPriceRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) ProductPriceRepository {
+	return &productPriceRepo{db: db, deps: deps}
+}
+
+func (r *productPriceRepo) GetBasePrices(ctx context.Context, productIDs []int) (map[int]float64, error) {
+	out := make(map[int]float64, len(productIDs))
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+
+	products, err := r.db.Product.Query().
+		Where(
+			product.IDIn(productIDs...),
+			product.DeletedAtIsNil(),
+		).
+		Select(product.FieldID, product.FieldTemplateID, product.FieldRetailPrice).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// variants -> template
+	pending := make(map[int]int)
+	templateIDs := make([]int, 0)
+	for _, p := range products {
+		if p.RetailPrice != nil {
+			out[p.ID] = *p.RetailPrice
+			continue
+		}
+		if p.TemplateID == nil {
+			out[p.ID] = 0
+			continue
+		}
+		pending[p.ID] = *p.TemplateID
+		templateIDs = append(templateIDs, *p.TemplateID)
+	}
+
+	if len(pending) == 0 {
+		return out, nil
+	}
+
+	templates, err := r.db.Product.Query().
+		Where(
+			product.IDIn(templateIDs...),
+			product.DeletedAtIsNil(),
+		).
+		Select(product.FieldID, product.FieldRetailPrice).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	templatePrices := make(map[int]float64, len(templates))
+	for _, t := range templates {
+		if t.RetailPrice != nil {
+			templatePrices[t.ID] = *t.RetailPrice
+		}
+	}
+	for id, templateID := range pending {
+		out[id] = templatePrices[templateID]
+	}
+
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/process"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/product"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/productprocess"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type ProductProcessGraphRepository interface {
+	Get(ctx context.Context, productID int) (*model.ProductProcessGraphDTO, error)
+	// Replace swaps the whole graph of the product; an empty list removes it
+	// so the product falls back to its category process list.
+	Replace(ctx context.Context, productID int, steps []*model.ProductProcessStepDTO) (*model.ProductProcessGraphDTO, error)
+	// ExistingProcessIDs returns which of the given process ids exist.
+	ExistingProcessIDs(ctx context.Context, processIDs []int) (map[int]bool, error)
+}
+
+type productProcessGraphRepo struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductProcessGraphRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) ProductProcessGraphRepository {
+	return &productProcessGraphRepo{db: db, deps: deps}
+}
+
+func (r *productProcessGraphRepo) Get(ctx context.Context, productID int) (*model.ProductProcessGraphDTO, error) {
+	exists, err := r.db.Product.Query().
+		Where(product.IDEQ(productID), product.DeletedAtIsNil()).
+		Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+
+	rows, err := r.db.ProductProcess.Query().
+		Where(productprocess.ProductIDEQ(productID)).
+		WithProcess(func(q *generated.ProcessQuery) {
+			q.Select(process.FieldID, process.FieldName)
+		}).
+		Order(
+			productprocess.ByDisplayOrder(sql.OrderNullsLast()),
+			productprocess.ByID(),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &model.ProductProcessGraphDTO{
+		ProductID: productID,
+		Steps:     make([]*model.ProductProcessStepDTO, 0, len(rows)),
+	}
+	for _, row := range rows {
+		step := &model.ProductProcessStepDTO{
+			ProcessID:    row.ProcessID,
+			DisplayOrder: row.DisplayOrder,
+			DependsOn:    row.DependsOn,
+			Condition:    row.Condition,
+		}
+		if step.DependsOn == nil {
+			step.DependsOn = []int{}
+		}
+		if row.Edges.Process != nil {
+			step.ProcessName = row.Edges.Process.Name
+		}
+		out.Steps = append(out.Steps, step)
+	}
+	return out, nil
+}
+
+func (r *productProcessGraphRepo) Replace(ctx context.Context, productID int, steps []*model.ProductProcessStepDTO) (*model.ProductProcessGraphDTO, error) {
+	exists, err := r.db.Product.Query().
+		Where(product.IDEQ(productID), product.DeletedAtIsNil()).
+		Exist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ProductProcess.Delete().
+		Where(productprocess.ProductIDEQ(productID)).
+		Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(steps) > 0 {
+		builders := make([]*generated.ProductProcessCreate, 0, len(steps))
+		for i, s := range steps {
+			order := i + 1
+			deps := s.DependsOn
+			if deps == nil {
+				deps = []int{}
+			}
+			b := tx.ProductProcess.Create().
+				SetProductID(productID).
+				SetProcessID(s.ProcessID).
+				SetDisplayOrder(order).
+				SetDependsOn(deps)
+			if len(s.Condition) > 0 {
+				b.SetCondition(s.Condition)
+			}
+			builders = append(builders, b)
+		}
+		if _, err = tx.ProductProcess.CreateBulk(builders...).Save(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.Get(ctx, productID)
+}
+
+func (r *productProcessGraphRepo) ExistingProcessIDs(ctx context.Context, processIDs []int) (map[int]bool, error) {
+	out := make(map[int]bool, len(processIDs))
+	if len(processIDs) == 0 {
+		return out, nil
+	}
+
+	ids, err := r.db.Process.Query().
+		Where(process.IDIn(processIDs...), process.DeletedAtIsNil()).
+		IDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		out[id] = true
+	}
+	return out, nil
+}
@@ -78,7 +78,8 @@ func (r *productRepo) Create(ctx context.Context, input *model.ProductUpsertDTO)
 		SetNillableCode(in.Code).
 		SetNillableName(in.Name).
 		SetNillableCategoryID(in.CategoryID).
-		SetNillableCategoryName(in.CategoryName)
+		SetNillableCategoryName(in.CategoryName).
+		SetNillableRetailPrice(in.RetailPrice)
 
 	if in.TemplateID == nil {
 		q.SetIsTemplate(true).
@@ -178,7 +179,8 @@ func (r *productRepo) Update(ctx context.Context, input *model.ProductUpsertDTO)
 		SetNillableCode(in.Code).
 		SetNillableName(in.Name).
 		SetNillableCategoryID(in.CategoryID).
-		SetNillableCategoryName(in.CategoryName)
+		SetNillableCategoryName(in.CategoryName).
+		SetNillableRetailPrice(in.RetailPrice)
 
 	if in.TemplateID == nil {
 		q.SetIsTemplate(true)
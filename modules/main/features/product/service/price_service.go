@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	pricelistrepo "github.com/khiemnd777/andy_api/modules/main/features/price_list/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+// Prices are resolved as on an order: the price list of pc (see
+// pricelistrepo.Resolve) wins over the product base price. A zero
+// pc.DepartmentID prices at base.
+type ProductPriceService interface {
+	// BasePrice is the product retail price, or its template's for a variant.
+	BasePrice(ctx context.Context, productID int) (*model.ProductPriceDTO, error)
+	GetPrice(ctx context.Context, pc pricelistrepo.PriceContext, productID int) (*model.ProductPriceDTO, error)
+	// TotalPrice is the amount payable now: items with a pre_order_percent
+	// in (0, 100) only count that share of their line total.
+	TotalPrice(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO) (float64, error)
+	// RawTotalPrice is the full line total, ignoring pre_order_percent.
+	RawTotalPrice(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO) (float64, error)
+	CalculateTotal(prices []float64, quantities []int) float64
+}
+
+type productPriceService struct {
+	repo          repository.ProductPriceRepository
+	priceListRepo pricelistrepo.PriceListRepository
+	deps          *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductPriceService(repo repository.ProductPriceRepository, deps *module.ModuleDeps[config.ModuleConfig]) ProductPriceService {
+	return &productPriceService{
+		repo:          repo,
+		priceListRepo: pricelistrepo.NewPriceListRepository(deps.Ent.(*generated.Client), deps),
+		deps:          deps,
+	}
+}
+
+func kProductPrice(id int) string {
+	return fmt.Sprintf("product:price:id:%d", id)
+}
+
+func kProductPriceAll() string {
+	return "product:price:*"
+}
+
+func (s *productPriceService) BasePrice(ctx context.Context, productID int) (*model.ProductPriceDTO, error) {
+	return cache.Get(kProductPrice(productID), cache.TTLMedium, func() (*model.ProductPriceDTO, error) {
+		prices, err := s.repo.GetBasePrices(ctx, []int{productID})
+		if err != nil {
+			return nil, err
+		}
+		price, ok := prices[productID]
+		if !ok {
+			return nil, repository.ErrProductNotFound
+		}
+		return &model.ProductPriceDTO{ProductID: productID, Price: price}, nil
+	})
+}
+
+func (s *productPriceService) GetPrice(ctx context.Context, pc pricelistrepo.PriceContext, productID int) (*model.ProductPriceDTO, error) {
+	prices, err := s.prices(ctx, pc, []model.ProductPriceItemDTO{{ProductID: productID, Quantity: 1}})
+	if err != nil {
+		return nil, err
+	}
+	price, ok := prices[productID]
+	if !ok {
+		return nil, repository.ErrProductNotFound
+	}
+	return &model.ProductPriceDTO{ProductID: productID, Price: price}, nil
+}
+
+func (s *productPriceService) TotalPrice(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO) (float64, error) {
+	return s.total(ctx, pc, items, true)
+}
+
+func (s *productPriceService) RawTotalPrice(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO) (float64, error) {
+	return s.total(ctx, pc, items, false)
+}
+
+func (s *productPriceService) CalculateTotal(prices []float64, quantities []int) float64 {
+	var total float64
+	for i, price := range prices {
+		qty := 0
+		if i < len(quantities) {
+			qty = quantities[i]
+		}
+		total += lineTotal(price, qty)
+	}
+	return total
+}
+
+func (s *productPriceService) total(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO, withPreOrder bool) (float64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	prices, err := s.prices(ctx, pc, items)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, it := range items {
+		price, ok := prices[it.ProductID]
+		if !ok {
+			return 0, fmt.Errorf("%w: %d", repository.ErrProductNotFound, it.ProductID)
+		}
+		line := lineTotal(price, it.Quantity)
+		if withPreOrder {
+			line = applyPreOrderPercent(line, it.PreOrderPercent)
+		}
+		total += line
+	}
+
+	return total, nil
+}
+
+// prices maps product id -> unit price: the base price, overridden by the
+// price list price of pc. Volume tiers count the whole quantity of a product.
+func (s *productPriceService) prices(ctx context.Context, pc pricelistrepo.PriceContext, items []model.ProductPriceItemDTO) (map[int]float64, error) {
+	ids := make([]int, 0, len(items))
+	lines := make([]pricelistrepo.PriceLine, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.ProductID)
+		lines = append(lines, pricelistrepo.PriceLine{ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+
+	prices, err := s.repo.GetBasePrices(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if pc.DepartmentID <= 0 {
+		return prices, nil
+	}
+	if pc.At.IsZero() {
+		pc.At = time.Now()
+	}
+
+	resolved, err := s.priceListRepo.Resolve(ctx, pc, lines)
+	if err != nil {
+		return nil, err
+	}
+	for id, rp := range resolved {
+		// a price list does not make a deleted product priceable
+		if _, ok := prices[id]; ok {
+			prices[id] = rp.Price
+		}
+	}
+	return prices, nil
+}
+
+func lineTotal(price float64, qty int) float64 {
+	if qty <= 0 {
+		qty = 1
+	}
+	return price * float64(qty)
+}
+
+func applyPreOrderPercent(amount float64, percent float64) float64 {
+	if percent <= 0 || percent >= 100 {
+		return amount
+	}
+	return amount * percent / 100
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/product/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+// display_order is capped at 100 in the schema
+const maxGraphSteps = 100
+
+var ErrInvalidProcessGraph = errors.New("invalid process graph")
+
+type ProductProcessGraphService interface {
+	Get(ctx context.Context, productID int) (*model.ProductProcessGraphDTO, error)
+	Replace(ctx context.Context, productID int, steps []*model.ProductProcessStepDTO) (*model.ProductProcessGraphDTO, error)
+}
+
+type productProcessGraphService struct {
+	repo repository.ProductProcessGraphRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewProductProcessGraphService(repo repository.ProductProcessGraphRepository, deps *module.ModuleDeps[config.ModuleConfig]) ProductProcessGraphService {
+	return &productProcessGraphService{repo: repo, deps: deps}
+}
+
+func kProductProcessGraph(productID int) string {
+	return fmt.Sprintf("product:id:%d:process_graph", productID)
+}
+
+func (s *productProcessGraphService) Get(ctx context.Context, productID int) (*model.ProductProcessGraphDTO, error) {
+	return cache.Get(kProductProcessGraph(productID), cache.TTLMedium, func() (*model.ProductProcessGraphDTO, error) {
+		return s.repo.Get(ctx, productID)
+	})
+}
+
+// Replace validates and stores the graph. Existing order items keep the
+// routing they were created with.
+func (s *productProcessGraphService) Replace(ctx context.Context, productID int, steps []*model.ProductProcessStepDTO) (*model.ProductProcessGraphDTO, error) {
+	if err := s.validate(ctx, steps); err != nil {
+		return nil, err
+	}
+
+	out, err := s.repo.Replace(ctx, productID, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kProductProcessGraph(productID))
+	return out, nil
+}
+
+// validate checks that every step is a known process listed once, that
+// dependencies point to steps of the same graph and that there is no cycle.
+func (s *productProcessGraphService) validate(ctx context.Context, steps []*model.ProductProcessStepDTO) error {
+	if len(steps) > maxGraphSteps {
+		return fmt.Errorf("%w: at most %d steps", ErrInvalidProcessGraph, maxGraphSteps)
+	}
+
+	ids := make([]int, 0, len(steps))
+	seen := make(map[int]bool, len(steps))
+	for _, st := range steps {
+		if st == nil || st.ProcessID <= 0 {
+			return fmt.Errorf("%w: process_id is required", ErrInvalidProcessGraph)
+		}
+		if seen[st.ProcessID] {
+			return fmt.Errorf("%w: process %d is listed twice", ErrInvalidProcessGraph, st.ProcessID)
+		}
+		seen[st.ProcessID] = true
+		ids = append(ids, st.ProcessID)
+	}
+
+	existing, err := s.repo.ExistingProcessIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !existing[id] {
+			return fmt.Errorf("%w: process %d not found", ErrInvalidProcessGraph, id)
+		}
+	}
+
+	deps := make(map[int][]int, len(steps))
+	for _, st := range steps {
+		for _, d := range st.DependsOn {
+			if d == st.ProcessID {
+				return fmt.Errorf("%w: process %d depends on itself", ErrInvalidProcessGraph, d)
+			}
+			if !seen[d] {
+				return fmt.Errorf("%w: process %d depends on %d which is not in the graph", ErrInvalidProcessGraph, st.ProcessID, d)
+			}
+		}
+		deps[st.ProcessID] = st.DependsOn
+	}
+
+	// depth-first search; 1 = on the current path, 2 = done
+	state := make(map[int]int, len(steps))
+	var visit func(id int) bool
+	visit = func(id int) bool {
+		switch state[id] {
+		case 1:
+			return false
+		case 2:
+			return true
+		}
+		state[id] = 1
+		for _, d := range deps[id] {
+			if !visit(d) {
+				return false
+			}
+		}
+		state[id] = 2
+		return true
+	}
+	for _, id := range ids {
+		if !visit(id) {
+			return fmt.Errorf("%w: dependency cycle through process %d", ErrInvalidProcessGraph, id)
+		}
+	}
+
+	return nil
+}
@@ -50,6 +50,7 @@ func kProductAll() []string {
 	return []string{
 		kProductListAll(),
 		kProductSearchAll(),
+		kProductPriceAll(),
 	}
 }
 
@@ -62,9 +62,15 @@ func (b *Builder) BuildFromOrderDTO(
 
 	productIDs := collectOrderProductIDs(order)
 
-	shippingAmount := utils.SafeParseFloat(
-		utils.SafeGet(order.CustomFields, "shipping_fee"),
-	)
+	shippingAmount := 0.0
+	if order.ShippingFee != nil {
+		shippingAmount = *order.ShippingFee
+	}
+	if shippingAmount == 0 {
+		shippingAmount = utils.SafeParseFloat(
+			utils.SafeGet(order.CustomFields, "shipping_fee"),
+		)
+	}
 	if shippingAmount == 0 {
 		shippingAmount = utils.SafeParseFloat(
 			utils.SafeGet(order.CustomFields, "shipping_cost"),
@@ -93,6 +99,7 @@ func (b *Builder) BuildFromOrderDTO(
 		RemakeReason:   remakeReason,
 		OriginalTime:   originalTime,
 		ProductIDs:     productIDs,
+		UnitCount:      countOrderUnits(order),
 		ShippingAmount: shippingAmount,
 		SellerID:       sellerID,
 		ClinicID:       clinicID,
@@ -124,3 +131,21 @@ func collectOrderProductIDs(order *model.OrderDTO) []int {
 
 	return out
 }
+
+func countOrderUnits(order *model.OrderDTO) int {
+	if order.LatestOrderItem == nil {
+		return 0
+	}
+	n := 0
+	for _, p := range order.LatestOrderItem.Products {
+		if p == nil {
+			continue
+		}
+		if p.ToothChart != nil && p.ToothChart.Count() > 0 {
+			n += p.ToothChart.Count()
+			continue
+		}
+		n += p.Quantity
+	}
+	return n
+}
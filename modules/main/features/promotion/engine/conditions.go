@@ -54,6 +54,24 @@ func (e *Engine) matchConditions(
 				return nil, PromotionApplyError{Reason: ReasonConditionRemakeReasonNotMet}
 			}
 
+		case promotionmodel.PromotionConditionUnitCountGTE:
+			value, err := parseIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount < value {
+				return nil, PromotionApplyError{Reason: ReasonConditionUnitCountGTENotMet}
+			}
+
+		case promotionmodel.PromotionConditionUnitCountLTE:
+			value, err := parseIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount > value {
+				return nil, PromotionApplyError{Reason: ReasonConditionUnitCountLTENotMet}
+			}
+
 		default:
 			return nil, fmt.Errorf("unsupported condition type: %s", cond.ConditionType)
 		}
@@ -7,12 +7,14 @@ import (
 )
 
 type OrderContext struct {
-	TotalPrice     float64
-	IsRemake       bool
-	RemakeCount    int
-	RemakeReason   string
-	OriginalTime   time.Time
-	ProductIDs     []int
+	TotalPrice   float64
+	IsRemake     bool
+	RemakeCount  int
+	RemakeReason string
+	OriginalTime time.Time
+	ProductIDs   []int
+	// billable units (teeth) across the products of the latest order item
+	UnitCount      int
 	ShippingAmount float64
 	SellerID       int
 	ClinicID       int
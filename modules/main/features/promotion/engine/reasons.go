@@ -23,6 +23,8 @@ const (
 	ReasonConditionRemakeCountLTENotMet   = "condition_remake_count_lte_not_met"
 	ReasonConditionRemakeWithinDaysNotMet = "condition_remake_within_days_not_met"
 	ReasonConditionRemakeReasonNotMet     = "condition_remake_reason_not_met"
+	ReasonConditionUnitCountGTENotMet     = "condition_unit_count_gte_not_met"
+	ReasonConditionUnitCountLTENotMet     = "condition_unit_count_lte_not_met"
 
 	// ===== Discount =====
 	ReasonMinOrderValueNotMet = "min_order_value_not_met"
@@ -25,6 +25,8 @@ const (
 	PromotionConditionRemakeCountLTE   PromotionConditionType = promotioncondition.ConditionTypeREMAKE_COUNT_LTE
 	PromotionConditionRemakeWithinDays PromotionConditionType = promotioncondition.ConditionTypeREMAKE_WITHIN_DAYS
 	PromotionConditionRemakeReason     PromotionConditionType = promotioncondition.ConditionTypeREMAKE_REASON
+	PromotionConditionUnitCountGTE     PromotionConditionType = promotioncondition.ConditionTypeUNIT_COUNT_GTE
+	PromotionConditionUnitCountLTE     PromotionConditionType = promotioncondition.ConditionTypeUNIT_COUNT_LTE
 )
 
 type PromotionDiscountType = promotioncode.DiscountType
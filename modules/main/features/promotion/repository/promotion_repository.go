@@ -30,6 +30,9 @@ type PromotionRepository interface {
 		userID *int,
 		snapshot *model.PromotionSnapshot,
 	) error
+	// ReleaseUsagesByOrderID deletes the usages of an order so the codes can
+	// be used again, and returns the discount they carried.
+	ReleaseUsagesByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (int, error)
 }
 
 type promotionRepository struct {
@@ -406,3 +409,35 @@ func mapPromotionConditions(conditions []*generated.PromotionCondition) []model.
 	}
 	return out
 }
+
+func (r *promotionRepository) ReleaseUsagesByOrderID(ctx context.Context, tx *generated.Tx, orderID int64) (int, error) {
+	client := r.db
+	if tx != nil {
+		client = tx.Client()
+	}
+
+	usages, err := client.PromotionUsage.
+		Query().
+		Where(promotionusage.OrderIDEQ(orderID)).
+		Select(promotionusage.FieldDiscountAmount).
+		All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(usages) == 0 {
+		return 0, nil
+	}
+
+	released := 0
+	for _, u := range usages {
+		released += u.DiscountAmount
+	}
+
+	if _, err := client.PromotionUsage.
+		Delete().
+		Where(promotionusage.OrderIDEQ(orderID)).
+		Exec(ctx); err != nil {
+		return 0, err
+	}
+	return released, nil
+}
@@ -63,7 +63,7 @@ type promotionService struct {
 }
 
 func NewPromotionService(repo repository.PromotionRepository, deps *module.ModuleDeps[config.ModuleConfig]) PromotionService {
-	orderItemProductRepo := orderrepo.NewOrderItemProductRepository(deps.Ent.(*generated.Client))
+	orderItemProductRepo := orderrepo.NewOrderItemProductRepository(deps.Ent.(*generated.Client), deps)
 	promoengine := engine.NewEngine(deps)
 	promoctxbuilder := contextbuilder.NewBuilder(orderItemProductRepo)
 	promoguard := engine.NewGuard(repo)
@@ -277,6 +277,7 @@ type orderPromotionContext struct {
 	RemakeReason   string
 	OriginalTime   time.Time
 	ProductIDs     []int
+	UnitCount      int
 	ShippingAmount float64
 	SellerID       int
 }
@@ -312,7 +313,13 @@ func (s *promotionService) buildOrderPromotionContext(order *model.OrderDTO) ord
 
 	productIDs := collectOrderProductIDs(order)
 
-	shippingAmount := utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_fee"))
+	shippingAmount := 0.0
+	if order.ShippingFee != nil {
+		shippingAmount = *order.ShippingFee
+	}
+	if shippingAmount == 0 {
+		shippingAmount = utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_fee"))
+	}
 	if shippingAmount == 0 {
 		shippingAmount = utils.SafeParseFloat(utils.SafeGet(order.CustomFields, "shipping_cost"))
 	}
@@ -329,11 +336,30 @@ func (s *promotionService) buildOrderPromotionContext(order *model.OrderDTO) ord
 		RemakeReason:   remakeReason,
 		OriginalTime:   originalTime,
 		ProductIDs:     productIDs,
+		UnitCount:      countOrderUnits(order),
 		ShippingAmount: shippingAmount,
 		SellerID:       sellerID,
 	}
 }
 
+func countOrderUnits(order *model.OrderDTO) int {
+	if order.LatestOrderItem == nil {
+		return 0
+	}
+	n := 0
+	for _, p := range order.LatestOrderItem.Products {
+		if p == nil {
+			continue
+		}
+		if p.ToothChart != nil && p.ToothChart.Count() > 0 {
+			n += p.ToothChart.Count()
+			continue
+		}
+		n += p.Quantity
+	}
+	return n
+}
+
 func collectOrderProductIDs(order *model.OrderDTO) []int {
 	seen := map[int]struct{}{}
 	var out []int
@@ -532,6 +558,24 @@ func (s *promotionService) matchConditions(
 				return nil, PromotionApplyError{Reason: engine.ReasonConditionRemakeReasonNotMet}
 			}
 			applied = append(applied, string(cond.ConditionType))
+		case promotionmodel.PromotionConditionUnitCountGTE:
+			value, err := parseIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount < value {
+				return nil, PromotionApplyError{Reason: engine.ReasonConditionUnitCountGTENotMet}
+			}
+			applied = append(applied, string(cond.ConditionType))
+		case promotionmodel.PromotionConditionUnitCountLTE:
+			value, err := parseIntValue(cond.ConditionValue)
+			if err != nil {
+				return nil, err
+			}
+			if orderCtx.UnitCount > value {
+				return nil, PromotionApplyError{Reason: engine.ReasonConditionUnitCountLTENotMet}
+			}
+			applied = append(applied, string(cond.ConditionType))
 		default:
 			return nil, fmt.Errorf("unsupported condition type: %s", cond.ConditionType)
 		}
@@ -68,6 +68,28 @@ var conditionValidators = map[promotionmodel.PromotionConditionType]conditionVal
 		_, err := parseStringList(raw)
 		return err
 	},
+
+	promotionmodel.PromotionConditionUnitCountGTE: func(raw json.RawMessage) error {
+		v, err := parseInt(raw)
+		if err != nil {
+			return err
+		}
+		if v <= 0 {
+			return errors.New("UNIT_COUNT_GTE must be > 0")
+		}
+		return nil
+	},
+
+	promotionmodel.PromotionConditionUnitCountLTE: func(raw json.RawMessage) error {
+		v, err := parseInt(raw)
+		if err != nil {
+			return err
+		}
+		if v <= 0 {
+			return errors.New("UNIT_COUNT_LTE must be > 0")
+		}
+		return nil
+	},
 }
 
 // ----- Scope
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/service"
+	"github.com/khiemnd777/andy_api/shared/app"
+	"github.com/khiemnd777/andy_api/shared/app/client_error"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/middleware/rbac"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type ReceivableHandler struct {
+	svc  service.ReceivableService
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableHandler(svc service.ReceivableService, deps *module.ModuleDeps[config.ModuleConfig]) *ReceivableHandler {
+	return &ReceivableHandler{svc: svc, deps: deps}
+}
+
+func (h *ReceivableHandler) RegisterRoutes(router fiber.Router) {
+	app.RouterGet(router, "/:dept_id<int>/clinic/:clinic_id<int>/payment/list", h.ListPayments)
+	app.RouterGet(router, "/:dept_id<int>/clinic/:clinic_id<int>/payment/:id<int>", h.GetPayment)
+	app.RouterPost(router, "/:dept_id<int>/clinic/:clinic_id<int>/payment", h.CreatePayment)
+	app.RouterDelete(router, "/:dept_id<int>/clinic/:clinic_id<int>/payment/:id<int>", h.DeletePayment)
+	app.RouterGet(router, "/:dept_id<int>/clinic/:clinic_id<int>/receivable/open-documents", h.OpenDocuments)
+	app.RouterGet(router, "/:dept_id<int>/clinic/:clinic_id<int>/receivable/ledger", h.Ledger)
+	app.RouterGet(router, "/:dept_id<int>/clinic/:clinic_id<int>/receivable/summary", h.Summary)
+}
+
+func (h *ReceivableHandler) ListPayments(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	if clinicID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid clinic_id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+	q := table.ParseTableQuery(c, 20)
+
+	res, err := h.svc.ListPayments(c.UserContext(), deptID, clinicID, q)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *ReceivableHandler) GetPayment(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	id, _ := utils.GetParamAsInt(c, "id")
+	if id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+
+	dto, err := h.svc.GetPayment(c.UserContext(), int64(id))
+	if err != nil {
+		if generated.IsNotFound(err) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, "payment not found")
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(dto)
+}
+
+func (h *ReceivableHandler) CreatePayment(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.create"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	if clinicID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid clinic_id")
+	}
+
+	payload, err := app.ParseBody[model.ClinicPaymentUpsertDTO](c)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid body")
+	}
+	payload.DTO.ClinicID = clinicID
+
+	deptID, _ := utils.GetDeptIDInt(c)
+	userID, _ := utils.GetUserIDInt(c)
+
+	dto, err := h.svc.CreatePayment(c.UserContext(), deptID, userID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvalidPaymentMethod),
+			errors.Is(err, repository.ErrInvalidPaymentAmount),
+			errors.Is(err, repository.ErrInvalidAllocation):
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
+		case errors.Is(err, repository.ErrDocumentNotOpen),
+			errors.Is(err, repository.ErrOverAllocation):
+			return client_error.ResponseError(c, fiber.StatusConflict, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto)
+}
+
+func (h *ReceivableHandler) DeletePayment(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.delete"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	id, _ := utils.GetParamAsInt(c, "id")
+	if clinicID <= 0 || id <= 0 {
+		return client_error.ResponseError(c, fiber.StatusNotFound, nil, "invalid id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	if err := h.svc.DeletePayment(c.UserContext(), deptID, clinicID, int64(id)); err != nil {
+		if errors.Is(err, repository.ErrPaymentNotFound) {
+			return client_error.ResponseError(c, fiber.StatusNotFound, err, err.Error())
+		}
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *ReceivableHandler) OpenDocuments(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	if clinicID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid clinic_id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	res, err := h.svc.OpenDocuments(c.UserContext(), deptID, clinicID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *ReceivableHandler) Ledger(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	if clinicID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid clinic_id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	var fromDate, toDate *time.Time
+	if raw := utils.GetQueryAsString(c, "from_date"); raw != "" {
+		d, err := utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid from_date")
+		}
+		fromDate = &d
+	}
+	if raw := utils.GetQueryAsString(c, "to_date"); raw != "" {
+		d, err := utils.ParseDate(raw)
+		if err != nil {
+			return client_error.ResponseError(c, fiber.StatusBadRequest, err, "invalid to_date")
+		}
+		toDate = &d
+	}
+
+	res, err := h.svc.Ledger(c.UserContext(), deptID, clinicID, fromDate, toDate)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
+
+func (h *ReceivableHandler) Summary(c *fiber.Ctx) error {
+	if err := rbac.GuardAnyPermission(c, h.deps.Ent.(*generated.Client), "receivable.view"); err != nil {
+		return client_error.ResponseError(c, fiber.StatusForbidden, err, err.Error())
+	}
+	clinicID, _ := utils.GetParamAsInt(c, "clinic_id")
+	if clinicID <= 0 {
+		return client_error.ResponseError(c, fiber.StatusBadRequest, nil, "invalid clinic_id")
+	}
+	deptID, _ := utils.GetDeptIDInt(c)
+
+	res, err := h.svc.Summary(c.UserContext(), deptID, clinicID)
+	if err != nil {
+		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
+	}
+	return c.Status(fiber.StatusOK).JSON(res)
+}
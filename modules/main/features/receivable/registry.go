@@ -0,0 +1,29 @@
+package receivable
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/handler"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/repository"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/service"
+	"github.com/khiemnd777/andy_api/modules/main/registry"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/metadata/customfields"
+	"github.com/khiemnd777/andy_api/shared/module"
+)
+
+type feature struct{}
+
+func (feature) ID() string    { return "receivable" }
+func (feature) Priority() int { return 76 }
+
+func (feature) Register(router fiber.Router, deps *module.ModuleDeps[config.ModuleConfig], cfMgr *customfields.Manager) error {
+	repo := repository.NewReceivableRepository(deps.Ent.(*generated.Client), deps)
+	svc := service.NewReceivableService(repo, deps)
+	h := handler.NewReceivableHandler(svc, deps)
+	h.RegisterRoutes(router)
+	return nil
+}
+
+func init() { registry.Register(feature{}) }
@@ -0,0 +1,589 @@
+package repository
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinic"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicpayment"
+	"github.com/khiemnd777/andy_api/shared/db/ent/generated/clinicpaymentallocation"
+	"github.com/khiemnd777/andy_api/shared/mapper"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/utils"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+const (
+	PaymentMethodCash         = "cash"
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodCreditNote   = "credit_note"
+
+	// used when the clinic has no "payment_term_days" custom field
+	DefaultPaymentTermDays = 30
+
+	// amounts are VND, anything below is rounding noise
+	amountEpsilon = 0.005
+)
+
+var (
+	ErrInvalidPaymentMethod = errors.New("invalid payment method")
+	ErrInvalidPaymentAmount = errors.New("payment amount must be greater than 0")
+	ErrInvalidAllocation    = errors.New("allocation must target exactly one order or invoice with a positive amount")
+	ErrDocumentNotOpen      = errors.New("allocated document is not open for this clinic")
+	ErrOverAllocation       = errors.New("allocation exceeds the outstanding or payment amount")
+	ErrPaymentNotFound      = errors.New("payment not found")
+)
+
+type ReceivableRepository interface {
+	CreatePayment(ctx context.Context, deptID int, userID int, input *model.ClinicPaymentUpsertDTO) (*model.ClinicPaymentDTO, error)
+	DeletePayment(ctx context.Context, deptID int, clinicID int, id int64) error
+	GetPayment(ctx context.Context, id int64) (*model.ClinicPaymentDTO, error)
+	ListPayments(ctx context.Context, deptID int, clinicID int, query table.TableQuery) (table.TableListResult[model.ClinicPaymentDTO], error)
+	OpenDocuments(ctx context.Context, deptID int, clinicID int) ([]*model.ReceivableDocumentDTO, error)
+	Ledger(ctx context.Context, deptID int, clinicID int, fromDate *time.Time, toDate *time.Time) (*model.ReceivableLedgerDTO, error)
+	Summary(ctx context.Context, deptID int, clinicID int) (*model.ClinicReceivableSummaryDTO, error)
+}
+
+type receivableRepository struct {
+	db   *generated.Client
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableRepository(db *generated.Client, deps *module.ModuleDeps[config.ModuleConfig]) ReceivableRepository {
+	return &receivableRepository{db: db, deps: deps}
+}
+
+// queryer is satisfied by both *generated.Client and *generated.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*stdsql.Rows, error)
+}
+
+// receivableDocsCTE lists the documents a clinic owes money for:
+// issued invoices, plus completed orders that are not invoiced yet.
+// Allocations made against an order are credited to its invoice once invoiced.
+// An invoice holding allocations of its own cannot be voided, so none of them
+// is ever left on a voided invoice.
+const receivableDocsCTE = `
+WITH alloc AS (
+  SELECT a.order_id, a.invoice_id, a.amount
+  FROM clinic_payment_allocations a
+  JOIN clinic_payments p ON p.id = a.payment_id
+  WHERE
+    p.deleted_at IS NULL
+    AND p.department_id = $1
+    AND p.clinic_id = $2
+),
+docs AS (
+  SELECT
+    'invoice'   AS doc_type,
+    i.id        AS doc_id,
+    i.code      AS doc_code,
+    i.issued_at AS doc_date,
+    i.total     AS amount,
+    COALESCE((SELECT SUM(al.amount) FROM alloc al WHERE al.invoice_id = i.id), 0)
+      + COALESCE((
+        SELECT SUM(al.amount)
+        FROM alloc al
+        JOIN orders o ON o.id = al.order_id
+        WHERE o.invoice_id = i.id
+      ), 0) AS paid
+  FROM invoices i
+  WHERE
+    i.department_id = $1
+    AND i.clinic_id = $2
+    AND i.status = 'issued'
+    AND i.deleted_at IS NULL
+  UNION ALL
+  SELECT
+    'order',
+    o.id,
+    o.code,
+    COALESCE(o.delivery_date, o.created_at),
+    COALESCE(o.total_price, 0),
+    COALESCE((SELECT SUM(al.amount) FROM alloc al WHERE al.order_id = o.id), 0)
+  FROM orders o
+  WHERE
+    o.department_id = $1
+    AND o.clinic_id = $2
+    AND o.status_latest = 'completed'
+    AND o.invoice_id IS NULL
+    AND o.deleted_at IS NULL
+)
+`
+
+func (r *receivableRepository) CreatePayment(ctx context.Context, deptID int, userID int, input *model.ClinicPaymentUpsertDTO) (*model.ClinicPaymentDTO, error) {
+	if input == nil {
+		return nil, fmt.Errorf("payment is required")
+	}
+	in := &input.DTO
+
+	switch in.Method {
+	case PaymentMethodCash, PaymentMethodBankTransfer, PaymentMethodCreditNote:
+	default:
+		return nil, ErrInvalidPaymentMethod
+	}
+	if in.Amount <= 0 {
+		return nil, ErrInvalidPaymentAmount
+	}
+	if in.ReceivedAt.IsZero() {
+		in.ReceivedAt = time.Now()
+	}
+
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	clinicEnt, err := tx.Clinic.Query().
+		Where(
+			clinic.ID(in.ClinicID),
+			clinic.DeletedAtIsNil(),
+		).
+		Select(clinic.FieldID, clinic.FieldName).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.lockDocuments(ctx, tx, deptID, in.ClinicID); err != nil {
+		return nil, err
+	}
+
+	docs, err := r.openDocuments(ctx, tx, deptID, in.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := in.Allocations
+	if len(allocations) == 0 && input.AutoAllocate {
+		allocations = autoAllocate(docs, in.Amount)
+	}
+	if err = validateAllocations(docs, allocations, in.Amount); err != nil {
+		return nil, err
+	}
+
+	var createdBy *int
+	if userID > 0 {
+		createdBy = &userID
+	}
+
+	entity, err := tx.ClinicPayment.Create().
+		SetDepartmentID(deptID).
+		SetClinicID(clinicEnt.ID).
+		SetClinicName(clinicEnt.Name).
+		SetMethod(in.Method).
+		SetAmount(in.Amount).
+		SetReceivedAt(in.ReceivedAt).
+		SetNillableReference(in.Reference).
+		SetNillableNote(in.Note).
+		SetNillableCreatedBy(createdBy).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocated []*generated.ClinicPaymentAllocation
+	if len(allocations) > 0 {
+		bulk := make([]*generated.ClinicPaymentAllocationCreate, 0, len(allocations))
+		for _, a := range allocations {
+			bulk = append(bulk, tx.ClinicPaymentAllocation.Create().
+				SetPaymentID(entity.ID).
+				SetNillableOrderID(a.OrderID).
+				SetNillableInvoiceID(a.InvoiceID).
+				SetAmount(a.Amount))
+		}
+		allocated, err = tx.ClinicPaymentAllocation.CreateBulk(bulk...).Save(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entity.Edges.Allocations = allocated
+	return r.mapPayment(entity), nil
+}
+
+func (r *receivableRepository) DeletePayment(ctx context.Context, deptID int, clinicID int, id int64) error {
+	var err error
+	tx, err := r.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			_ = tx.Commit()
+		}
+	}()
+
+	affected, err := tx.ClinicPayment.Update().
+		Where(
+			clinicpayment.ID(id),
+			clinicpayment.DepartmentIDEQ(deptID),
+			clinicpayment.ClinicIDEQ(clinicID),
+			clinicpayment.DeletedAtIsNil(),
+		).
+		SetDeletedAt(time.Now()).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		err = ErrPaymentNotFound
+		return err
+	}
+
+	_, err = tx.ClinicPaymentAllocation.Delete().
+		Where(clinicpaymentallocation.PaymentIDEQ(id)).
+		Exec(ctx)
+	return err
+}
+
+func (r *receivableRepository) GetPayment(ctx context.Context, id int64) (*model.ClinicPaymentDTO, error) {
+	entity, err := r.db.ClinicPayment.Query().
+		Where(
+			clinicpayment.ID(id),
+			clinicpayment.DeletedAtIsNil(),
+		).
+		WithAllocations().
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.mapPayment(entity), nil
+}
+
+func (r *receivableRepository) ListPayments(ctx context.Context, deptID int, clinicID int, query table.TableQuery) (table.TableListResult[model.ClinicPaymentDTO], error) {
+	q := r.db.ClinicPayment.Query().
+		Where(
+			clinicpayment.DepartmentIDEQ(deptID),
+			clinicpayment.ClinicIDEQ(clinicID),
+			clinicpayment.DeletedAtIsNil(),
+		).
+		WithAllocations()
+
+	list, err := table.TableList(
+		ctx,
+		q,
+		query,
+		clinicpayment.Table,
+		clinicpayment.FieldID,
+		clinicpayment.FieldReceivedAt,
+		func(src []*generated.ClinicPayment) []*model.ClinicPaymentDTO {
+			out := make([]*model.ClinicPaymentDTO, 0, len(src))
+			for _, p := range src {
+				out = append(out, r.mapPayment(p))
+			}
+			return out
+		},
+	)
+	if err != nil {
+		var zero table.TableListResult[model.ClinicPaymentDTO]
+		return zero, err
+	}
+	return list, nil
+}
+
+func (r *receivableRepository) OpenDocuments(ctx context.Context, deptID int, clinicID int) ([]*model.ReceivableDocumentDTO, error) {
+	return r.openDocuments(ctx, r.db, deptID, clinicID)
+}
+
+func (r *receivableRepository) Ledger(ctx context.Context, deptID int, clinicID int, fromDate *time.Time, toDate *time.Time) (*model.ReceivableLedgerDTO, error) {
+	const q = receivableDocsCTE + `
+SELECT entry_date, entry_type, ref_id, ref_code, debit, credit
+FROM (
+  SELECT
+    d.doc_date AS entry_date,
+    d.doc_type AS entry_type,
+    d.doc_id   AS ref_id,
+    d.doc_code AS ref_code,
+    d.amount   AS debit,
+    0::float8  AS credit
+  FROM docs d
+  UNION ALL
+  SELECT
+    p.received_at,
+    p.method,
+    p.id,
+    p.reference,
+    0::float8,
+    p.amount
+  FROM clinic_payments p
+  WHERE
+    p.deleted_at IS NULL
+    AND p.department_id = $1
+    AND p.clinic_id = $2
+) e
+WHERE ($3::timestamptz IS NULL OR e.entry_date < $3::timestamptz)
+ORDER BY e.entry_date ASC, e.ref_id ASC;
+`
+
+	rows, err := r.db.QueryContext(ctx, q, deptID, clinicID, toDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := &model.ReceivableLedgerDTO{
+		ClinicID: clinicID,
+		Entries:  make([]*model.ReceivableLedgerEntryDTO, 0),
+	}
+
+	var balance float64
+	for rows.Next() {
+		var (
+			e       model.ReceivableLedgerEntryDTO
+			refCode stdsql.NullString
+		)
+		if err := rows.Scan(&e.EntryDate, &e.EntryType, &e.RefID, &refCode, &e.Debit, &e.Credit); err != nil {
+			return nil, err
+		}
+		if refCode.Valid {
+			e.RefCode = &refCode.String
+		}
+
+		balance += e.Debit - e.Credit
+		e.Balance = balance
+
+		if fromDate != nil && e.EntryDate.Before(*fromDate) {
+			out.OpeningBalance = balance
+			continue
+		}
+		out.Entries = append(out.Entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out.ClosingBalance = balance
+	return out, nil
+}
+
+func (r *receivableRepository) Summary(ctx context.Context, deptID int, clinicID int) (*model.ClinicReceivableSummaryDTO, error) {
+	clinicEnt, err := r.db.Clinic.Query().
+		Where(clinic.ID(clinicID)).
+		Select(clinic.FieldID, clinic.FieldCustomFields).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	termDays := utils.SafeGetInt(clinicEnt.CustomFields, "payment_term_days")
+	if termDays <= 0 {
+		termDays = DefaultPaymentTermDays
+	}
+
+	docs, err := r.openDocuments(ctx, r.db, deptID, clinicID)
+	if err != nil {
+		return nil, err
+	}
+
+	const balanceSQL = receivableDocsCTE + `
+SELECT
+  COALESCE((SELECT SUM(amount) FROM docs), 0)
+  - COALESCE((
+    SELECT SUM(p.amount)
+    FROM clinic_payments p
+    WHERE
+      p.deleted_at IS NULL
+      AND p.department_id = $1
+      AND p.clinic_id = $2
+  ), 0);
+`
+	rows, err := r.db.QueryContext(ctx, balanceSQL, deptID, clinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balance float64
+	if rows.Next() {
+		if err := rows.Scan(&balance); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := &model.ClinicReceivableSummaryDTO{
+		Balance:         balance,
+		PaymentTermDays: termDays,
+	}
+	for _, d := range docs {
+		out.Outstanding += d.Outstanding
+		if d.AgeDays > termDays {
+			out.Overdue += d.Outstanding
+		}
+	}
+	out.UnappliedCredit = math.Max(0, out.Outstanding-balance)
+
+	return out, nil
+}
+
+// -- helpers
+
+// lockDocuments locks the open invoices and uninvoiced completed orders of
+// the clinic until the transaction ends, so concurrent payments allocate
+// against each other's allocations and an invoice cannot be voided meanwhile.
+func (r *receivableRepository) lockDocuments(ctx context.Context, tx *generated.Tx, deptID int, clinicID int) error {
+	const lockSQL = `
+SELECT id FROM invoices
+WHERE
+  department_id = $1
+  AND clinic_id = $2
+  AND status = 'issued'
+  AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE;
+`
+	const lockOrdersSQL = `
+SELECT id FROM orders
+WHERE
+  department_id = $1
+  AND clinic_id = $2
+  AND status_latest = 'completed'
+  AND invoice_id IS NULL
+  AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE;
+`
+	if _, err := tx.ExecContext(ctx, lockSQL, deptID, clinicID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, lockOrdersSQL, deptID, clinicID)
+	return err
+}
+
+func (r *receivableRepository) openDocuments(ctx context.Context, q queryer, deptID int, clinicID int) ([]*model.ReceivableDocumentDTO, error) {
+	const openSQL = receivableDocsCTE + `
+SELECT doc_type, doc_id, doc_code, doc_date, amount, paid
+FROM docs
+WHERE amount - paid > 0.005
+ORDER BY doc_date ASC, doc_id ASC;
+`
+
+	rows, err := q.QueryContext(ctx, openSQL, deptID, clinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	out := make([]*model.ReceivableDocumentDTO, 0)
+	for rows.Next() {
+		var (
+			d    model.ReceivableDocumentDTO
+			code stdsql.NullString
+		)
+		if err := rows.Scan(&d.DocType, &d.DocID, &code, &d.DocDate, &d.Amount, &d.Paid); err != nil {
+			return nil, err
+		}
+		if code.Valid {
+			d.DocCode = &code.String
+		}
+		d.Outstanding = d.Amount - d.Paid
+		d.AgeDays = int(now.Sub(d.DocDate).Hours() / 24)
+		out = append(out, &d)
+	}
+
+	return out, rows.Err()
+}
+
+func (r *receivableRepository) mapPayment(entity *generated.ClinicPayment) *model.ClinicPaymentDTO {
+	dto := mapper.MapAs[*generated.ClinicPayment, *model.ClinicPaymentDTO](entity)
+	dto.Allocations = mapper.MapListAs[*generated.ClinicPaymentAllocation, *model.ClinicPaymentAllocationDTO](entity.Edges.Allocations)
+	for _, a := range dto.Allocations {
+		dto.AllocatedAmount += a.Amount
+	}
+	dto.UnappliedAmount = math.Max(0, dto.Amount-dto.AllocatedAmount)
+	return dto
+}
+
+func docKey(orderID *int64, invoiceID *int64) string {
+	if invoiceID != nil {
+		return fmt.Sprintf("invoice:%d", *invoiceID)
+	}
+	if orderID != nil {
+		return fmt.Sprintf("order:%d", *orderID)
+	}
+	return ""
+}
+
+// autoAllocate settles the oldest open documents first.
+func autoAllocate(docs []*model.ReceivableDocumentDTO, amount float64) []*model.ClinicPaymentAllocationDTO {
+	sorted := make([]*model.ReceivableDocumentDTO, len(docs))
+	copy(sorted, docs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].DocDate.Before(sorted[j].DocDate) })
+
+	out := make([]*model.ClinicPaymentAllocationDTO, 0)
+	remaining := amount
+	for _, d := range sorted {
+		if remaining <= amountEpsilon {
+			break
+		}
+		share := math.Min(remaining, d.Outstanding)
+		if share <= amountEpsilon {
+			continue
+		}
+		docID := d.DocID
+		a := &model.ClinicPaymentAllocationDTO{Amount: share}
+		if d.DocType == "invoice" {
+			a.InvoiceID = &docID
+		} else {
+			a.OrderID = &docID
+		}
+		out = append(out, a)
+		remaining -= share
+	}
+	return out
+}
+
+func validateAllocations(docs []*model.ReceivableDocumentDTO, allocations []*model.ClinicPaymentAllocationDTO, amount float64) error {
+	outstanding := make(map[string]float64, len(docs))
+	for _, d := range docs {
+		id := d.DocID
+		if d.DocType == "invoice" {
+			outstanding[docKey(nil, &id)] = d.Outstanding
+		} else {
+			outstanding[docKey(&id, nil)] = d.Outstanding
+		}
+	}
+
+	var total float64
+	for _, a := range allocations {
+		if a == nil || a.Amount <= 0 || (a.OrderID == nil) == (a.InvoiceID == nil) {
+			return ErrInvalidAllocation
+		}
+		key := docKey(a.OrderID, a.InvoiceID)
+		left, ok := outstanding[key]
+		if !ok {
+			return ErrDocumentNotOpen
+		}
+		if a.Amount > left+amountEpsilon {
+			return ErrOverAllocation
+		}
+		outstanding[key] = left - a.Amount
+		total += a.Amount
+	}
+	if total > amount+amountEpsilon {
+		return ErrOverAllocation
+	}
+	return nil
+}
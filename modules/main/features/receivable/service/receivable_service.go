@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/khiemnd777/andy_api/modules/main/config"
+	model "github.com/khiemnd777/andy_api/modules/main/features/__model"
+	"github.com/khiemnd777/andy_api/modules/main/features/receivable/repository"
+	"github.com/khiemnd777/andy_api/shared/cache"
+	"github.com/khiemnd777/andy_api/shared/module"
+	"github.com/khiemnd777/andy_api/shared/modules/realtime"
+	"github.com/khiemnd777/andy_api/shared/utils/table"
+)
+
+type ReceivableService interface {
+	CreatePayment(ctx context.Context, deptID int, userID int, input *model.ClinicPaymentUpsertDTO) (*model.ClinicPaymentDTO, error)
+	DeletePayment(ctx context.Context, deptID int, clinicID int, id int64) error
+	GetPayment(ctx context.Context, id int64) (*model.ClinicPaymentDTO, error)
+	ListPayments(ctx context.Context, deptID int, clinicID int, query table.TableQuery) (table.TableListResult[model.ClinicPaymentDTO], error)
+	OpenDocuments(ctx context.Context, deptID int, clinicID int) ([]*model.ReceivableDocumentDTO, error)
+	Ledger(ctx context.Context, deptID int, clinicID int, fromDate *time.Time, toDate *time.Time) (*model.ReceivableLedgerDTO, error)
+	Summary(ctx context.Context, deptID int, clinicID int) (*model.ClinicReceivableSummaryDTO, error)
+}
+
+type receivableService struct {
+	repo repository.ReceivableRepository
+	deps *module.ModuleDeps[config.ModuleConfig]
+}
+
+func NewReceivableService(repo repository.ReceivableRepository, deps *module.ModuleDeps[config.ModuleConfig]) ReceivableService {
+	return &receivableService{repo: repo, deps: deps}
+}
+
+func kPaymentByID(id int64) string {
+	return fmt.Sprintf("receivable:payment:id:%d", id)
+}
+
+func kReceivableClinicAll(deptID, clinicID int) string {
+	return fmt.Sprintf("receivable:dpt%d:c%d:*", deptID, clinicID)
+}
+
+// aging cache keys owned by the dashboard feature
+func kReceivableAgingAll(deptID int) string {
+	return fmt.Sprintf("dashboard:receivable_aging:dpt%d:*", deptID)
+}
+
+func kPaymentList(deptID, clinicID int, q table.TableQuery) string {
+	orderBy := ""
+	if q.OrderBy != nil {
+		orderBy = *q.OrderBy
+	}
+	return fmt.Sprintf("receivable:dpt%d:c%d:payments:l%d:p%d:o%s:d%s", deptID, clinicID, q.Limit, q.Page, orderBy, q.Direction)
+}
+
+func kOpenDocuments(deptID, clinicID int) string {
+	return fmt.Sprintf("receivable:dpt%d:c%d:open", deptID, clinicID)
+}
+
+func kLedger(deptID, clinicID int, fromDate, toDate *time.Time) string {
+	from, to := "", ""
+	if fromDate != nil {
+		from = fromDate.Format(time.RFC3339)
+	}
+	if toDate != nil {
+		to = toDate.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("receivable:dpt%d:c%d:ledger:f%s:t%s", deptID, clinicID, from, to)
+}
+
+func kSummary(deptID, clinicID int) string {
+	return fmt.Sprintf("receivable:dpt%d:c%d:summary", deptID, clinicID)
+}
+
+func (s *receivableService) CreatePayment(ctx context.Context, deptID int, userID int, input *model.ClinicPaymentUpsertDTO) (*model.ClinicPaymentDTO, error) {
+	dto, err := s.repo.CreatePayment(ctx, deptID, userID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.InvalidateKeys(kReceivableClinicAll(deptID, dto.ClinicID), kReceivableAgingAll(deptID))
+	realtime.BroadcastToDept(deptID, "dashboard:receivable_aging", nil)
+
+	return dto, nil
+}
+
+func (s *receivableService) DeletePayment(ctx context.Context, deptID int, clinicID int, id int64) error {
+	if err := s.repo.DeletePayment(ctx, deptID, clinicID, id); err != nil {
+		return err
+	}
+
+	cache.InvalidateKeys(kPaymentByID(id))
+	cache.InvalidateKeys(kReceivableClinicAll(deptID, clinicID), kReceivableAgingAll(deptID))
+	realtime.BroadcastToDept(deptID, "dashboard:receivable_aging", nil)
+
+	return nil
+}
+
+func (s *receivableService) GetPayment(ctx context.Context, id int64) (*model.ClinicPaymentDTO, error) {
+	return cache.Get(kPaymentByID(id), cache.TTLMedium, func() (*model.ClinicPaymentDTO, error) {
+		return s.repo.GetPayment(ctx, id)
+	})
+}
+
+func (s *receivableService) ListPayments(ctx context.Context, deptID int, clinicID int, q table.TableQuery) (table.TableListResult[model.ClinicPaymentDTO], error) {
+	type boxed = table.TableListResult[model.ClinicPaymentDTO]
+	key := kPaymentList(deptID, clinicID, q)
+
+	ptr, err := cache.Get(key, cache.TTLShort, func() (*boxed, error) {
+		res, e := s.repo.ListPayments(ctx, deptID, clinicID, q)
+		if e != nil {
+			return nil, e
+		}
+		return &res, nil
+	})
+	if err != nil {
+		var zero boxed
+		return zero, err
+	}
+	return *ptr, nil
+}
+
+func (s *receivableService) OpenDocuments(ctx context.Context, deptID int, clinicID int) ([]*model.ReceivableDocumentDTO, error) {
+	return cache.GetList(kOpenDocuments(deptID, clinicID), cache.TTLShort, func() ([]*model.ReceivableDocumentDTO, error) {
+		return s.repo.OpenDocuments(ctx, deptID, clinicID)
+	})
+}
+
+func (s *receivableService) Ledger(ctx context.Context, deptID int, clinicID int, fromDate *time.Time, toDate *time.Time) (*model.ReceivableLedgerDTO, error) {
+	return cache.Get(kLedger(deptID, clinicID, fromDate, toDate), cache.TTLShort, func() (*model.ReceivableLedgerDTO, error) {
+		return s.repo.Ledger(ctx, deptID, clinicID, fromDate, toDate)
+	})
+}
+
+func (s *receivableService) Summary(ctx context.Context, deptID int, clinicID int) (*model.ClinicReceivableSummaryDTO, error) {
+	return cache.Get(kSummary(deptID, clinicID), cache.TTLShort, func() (*model.ClinicReceivableSummaryDTO, error) {
+		return s.repo.Summary(ctx, deptID, clinicID)
+	})
+}
@@ -60,48 +60,18 @@ func (h *ImportHandler) Import(c *fiber.Ctx) error {
 		return client_error.ResponseError(c, fiber.StatusInternalServerError, err, err.Error())
 	}
 
-	sheet := x.GetSheetName(0)
-	if sheet == "" {
-		return client_error.ResponseError(c, fiber.StatusBadRequest, fmt.Errorf("empty sheet"), "empty sheet")
-	}
-
-	rows, err := x.Rows(sheet)
+	rows, errs, err := h.engine.MapSheet(ctx, profile, x)
 	if err != nil {
-		return client_error.ResponseError(c, fiber.StatusBadRequest, err, "cannot read rows")
+		return client_error.ResponseError(c, fiber.StatusBadRequest, err, err.Error())
 	}
-	defer rows.Close()
 
-	rowIndex := 0
 	created := 0
 	updated := 0
-	var errs []string
-
-	for rows.Next() {
-		rowIndex++
-		cols, err := rows.Columns()
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("row %d: cannot read columns: %v", rowIndex, err))
-			continue
-		}
-
-		if rowIndex == 1 {
-			continue
-		}
-
-		valuesByCol := map[int]string{}
-		for i, cell := range cols {
-			valuesByCol[i+1] = cell
-		}
-
-		mapped, err := h.engine.Mapper.MapExcelRow(ctx, profile.ID, valuesByCol)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("row %d: map error: %v", rowIndex, err))
-			continue
-		}
 
+	for _, mapped := range rows {
 		isCreated, err := h.engine.UpsertMappedRow(ctx, scope, profile, mappings, mapped)
 		if err != nil {
-			errs = append(errs, fmt.Sprintf("row %d: upsert error: %v", rowIndex, err))
+			errs = append(errs, fmt.Sprintf("row %d: upsert error: %v", mapped.Row, err))
 			continue
 		}
 		if isCreated {
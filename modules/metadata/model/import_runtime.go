@@ -16,6 +16,9 @@ type MappedCell struct {
 type MappedRow struct {
 	ProfileID int `json:"profile_id"`
 
+	// số dòng trong sheet (1-based, dòng 1 là header)
+	Row int `json:"row"`
+
 	// core fields → gán thẳng vào struct entity
 	CoreFields map[string]any `json:"core_fields"`
 
@@ -28,3 +31,27 @@ type MappedRow struct {
 	// detail đầy đủ từng cell (để debug, hiển thị lỗi,...)
 	Cells []MappedCell `json:"cells"`
 }
+
+// MappedGroup gom các dòng có cùng giá trị pivot_field thành một bản ghi lồng
+// nhau (vd: 1 đơn hàng nhiều sản phẩm). Mapping có internal_path dạng
+// "<section>.<field>" (vd: "products.quantity") tạo ra một dòng con trong
+// Lines[section] cho mỗi dòng Excel có giá trị; mapping còn lại thuộc bản ghi
+// chính và phải giống nhau giữa các dòng của nhóm.
+type MappedGroup struct {
+	Key  string `json:"key"`
+	Rows []int  `json:"rows"`
+
+	CoreFields map[string]any `json:"core_fields"`
+	// metadata theo metadata_collection_slug (mặc định: scope của profile)
+	MetadataFields map[string]map[string]any `json:"metadata_fields"`
+	ExternalFields map[string]any            `json:"external_fields"`
+
+	Lines map[string][]MappedLine `json:"lines"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+type MappedLine struct {
+	Row    int            `json:"row"`
+	Fields map[string]any `json:"fields"`
+}
@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/xuri/excelize/v2"
+
 	"github.com/khiemnd777/andy_api/modules/metadata/model"
 	"github.com/khiemnd777/andy_api/shared/logger"
 )
@@ -32,6 +34,60 @@ func (e *ImportEngine) SafeIdent(name string) (string, error) {
 	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`, nil
 }
 
+// MapSheet đọc sheet đầu tiên (bỏ dòng header) và map từng dòng theo profile.
+// Dòng lỗi được bỏ qua và trả về trong errs.
+func (e *ImportEngine) MapSheet(
+	ctx context.Context,
+	profile *model.ImportFieldProfile,
+	x *excelize.File,
+) (mapped []*model.MappedRow, errs []string, err error) {
+	sheet := x.GetSheetName(0)
+	if sheet == "" {
+		return nil, nil, fmt.Errorf("empty sheet")
+	}
+
+	rows, err := x.Rows(sheet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read rows: %w", err)
+	}
+	defer rows.Close()
+
+	rowIndex := 0
+	for rows.Next() {
+		rowIndex++
+		cols, err := rows.Columns()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: cannot read columns: %v", rowIndex, err))
+			continue
+		}
+
+		if rowIndex == 1 {
+			continue
+		}
+
+		valuesByCol := map[int]string{}
+		empty := true
+		for i, cell := range cols {
+			valuesByCol[i+1] = cell
+			if strings.TrimSpace(cell) != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+
+		row, err := e.Mapper.MapExcelRow(ctx, profile.ID, valuesByCol)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: map error: %v", rowIndex, err))
+			continue
+		}
+		row.Row = rowIndex
+		mapped = append(mapped, row)
+	}
+	return mapped, errs, nil
+}
+
 func findPivotMapping(
 	profile *model.ImportFieldProfile,
 	mappings []model.ImportFieldMapping,
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/khiemnd777/andy_api/modules/metadata/model"
+)
+
+// splitLinePath tách internal_path dạng "<section>.<field>"; path không có
+// dấu chấm thuộc bản ghi chính.
+func splitLinePath(path string) (section, field string, ok bool) {
+	section, field, ok = strings.Cut(path, ".")
+	if !ok || section == "" || field == "" {
+		return "", "", false
+	}
+	return section, field, true
+}
+
+func mappedValue(row *model.MappedRow, m *model.ImportFieldMapping) any {
+	switch m.InternalKind {
+	case "core":
+		return row.CoreFields[m.InternalPath]
+	case "metadata":
+		return row.MetadataFields[m.InternalPath]
+	case "external":
+		return row.ExternalFields[m.InternalPath]
+	}
+	return nil
+}
+
+func isEmptyValue(v any) bool {
+	return v == nil || fmt.Sprint(v) == ""
+}
+
+// GroupMappedRows gom các dòng đã map theo giá trị pivot_field của profile
+// (giữ thứ tự xuất hiện đầu tiên). Dòng không có pivot bị bỏ qua và trả về
+// trong errs; lỗi thuộc một nhóm (giá trị chính lệch nhau giữa các dòng,
+// thiếu field required) nằm trong MappedGroup.Errors.
+func (e *ImportEngine) GroupMappedRows(
+	profile *model.ImportFieldProfile,
+	mappings []model.ImportFieldMapping,
+	rows []*model.MappedRow,
+) (groups []*model.MappedGroup, errs []string) {
+	byKey := map[string]*model.MappedGroup{}
+
+	for _, row := range rows {
+		_, pivotVal, err := findPivotMapping(profile, mappings, row)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("row %d: %v", row.Row, err))
+			continue
+		}
+		key := strings.TrimSpace(fmt.Sprint(pivotVal))
+
+		g, ok := byKey[key]
+		if !ok {
+			g = &model.MappedGroup{
+				Key:            key,
+				CoreFields:     map[string]any{},
+				MetadataFields: map[string]map[string]any{},
+				ExternalFields: map[string]any{},
+				Lines:          map[string][]model.MappedLine{},
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Rows = append(g.Rows, row.Row)
+
+		lines := map[string]map[string]any{}
+		for i := range mappings {
+			m := &mappings[i]
+			val := mappedValue(row, m)
+
+			if section, field, ok := splitLinePath(m.InternalPath); ok {
+				if isEmptyValue(val) {
+					continue
+				}
+				if lines[section] == nil {
+					lines[section] = map[string]any{}
+				}
+				lines[section][field] = val
+				continue
+			}
+
+			if isEmptyValue(val) {
+				continue
+			}
+			target := g.CoreFields
+			switch m.InternalKind {
+			case "metadata":
+				slug := profile.Scope
+				if m.MetadataCollectionSlug != nil {
+					slug = *m.MetadataCollectionSlug
+				}
+				if g.MetadataFields[slug] == nil {
+					g.MetadataFields[slug] = map[string]any{}
+				}
+				target = g.MetadataFields[slug]
+			case "external":
+				target = g.ExternalFields
+			}
+			if cur, ok := target[m.InternalPath]; ok {
+				if !reflect.DeepEqual(cur, val) {
+					g.Errors = append(g.Errors, fmt.Sprintf("row %d: %s differs from previous rows of %s", row.Row, m.InternalLabel, key))
+				}
+				continue
+			}
+			target[m.InternalPath] = val
+		}
+
+		for section, fields := range lines {
+			g.Lines[section] = append(g.Lines[section], model.MappedLine{Row: row.Row, Fields: fields})
+		}
+
+		// field required của dòng con chỉ xét khi dòng có section đó
+		for i := range mappings {
+			m := &mappings[i]
+			section, _, ok := splitLinePath(m.InternalPath)
+			if !ok || !m.Required || lines[section] == nil {
+				continue
+			}
+			if isEmptyValue(mappedValue(row, m)) {
+				g.Errors = append(g.Errors, fmt.Sprintf("row %d: %s is required", row.Row, m.InternalLabel))
+			}
+		}
+	}
+
+	for _, g := range groups {
+		for i := range mappings {
+			m := &mappings[i]
+			if _, _, ok := splitLinePath(m.InternalPath); ok || !m.Required {
+				continue
+			}
+			var val any
+			switch m.InternalKind {
+			case "core":
+				val = g.CoreFields[m.InternalPath]
+			case "metadata":
+				slug := profile.Scope
+				if m.MetadataCollectionSlug != nil {
+					slug = *m.MetadataCollectionSlug
+				}
+				val = g.MetadataFields[slug][m.InternalPath]
+			case "external":
+				val = g.ExternalFields[m.InternalPath]
+			}
+			if isEmptyValue(val) {
+				g.Errors = append(g.Errors, fmt.Sprintf("%s: %s is required", g.Key, m.InternalLabel))
+			}
+		}
+	}
+
+	return groups, errs
+}
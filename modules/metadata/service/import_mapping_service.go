@@ -217,7 +217,7 @@ func (s *ImportFieldMappingService) ResolveProfileAndMappings(
 
 	perms, _ := utils.GetPermSetFromClaims(c)
 	profPerms := utils.NormalizeSplit(prof.Permission, ",")
-	if rbac.HasAnyPerm(perms, profPerms...) {
+	if len(profPerms) > 0 && !rbac.HasAnyPerm(perms, profPerms...) {
 		return nil, nil, fmt.Errorf("forbidden: missing permission")
 	}
 
@@ -38,6 +38,14 @@ func (Clinic) Fields() []ent.Field {
 		field.Bool("active").
 			Default(true),
 
+		// staff member in charge of the clinic relationship
+		field.Int("account_owner_id").
+			Optional().
+			Nillable(),
+		field.String("account_owner_name").
+			Optional().
+			Nillable(),
+
 		field.JSON("custom_fields", map[string]any{}).
 			Optional().
 			Default(map[string]any{}),
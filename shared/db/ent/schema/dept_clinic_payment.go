@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type ClinicPayment struct {
+	ent.Schema
+}
+
+func (ClinicPayment) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+
+		field.Int("clinic_id"),
+		field.String("clinic_name").
+			Optional().
+			Nillable(),
+
+		field.String("method"), // cash | bank_transfer | credit_note
+
+		field.Float("amount"),
+
+		field.Time("received_at").
+			Default(time.Now),
+
+		// bank transaction id, credit note number...
+		field.String("reference").
+			Optional().
+			Nillable(),
+
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		field.Int("created_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (ClinicPayment) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("allocations", ClinicPaymentAllocation.Type),
+	}
+}
+
+func (ClinicPayment) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "clinic_id", "received_at"),
+		index.Fields("deleted_at"),
+	}
+}
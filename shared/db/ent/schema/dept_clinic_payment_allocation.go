@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type ClinicPaymentAllocation struct {
+	ent.Schema
+}
+
+func (ClinicPaymentAllocation) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int64("payment_id"),
+
+		// exactly one of order_id | invoice_id
+		field.Int64("order_id").
+			Optional().
+			Nillable(),
+		field.Int64("invoice_id").
+			Optional().
+			Nillable(),
+
+		field.Float("amount"),
+
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (ClinicPaymentAllocation) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("payment", ClinicPayment.Type).
+			Ref("allocations").
+			Field("payment_id").
+			Required().
+			Unique(),
+	}
+}
+
+func (ClinicPaymentAllocation) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("payment_id"),
+		index.Fields("order_id"),
+		index.Fields("invoice_id"),
+	}
+}
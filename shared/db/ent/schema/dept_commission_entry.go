@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type CommissionEntry struct {
+	ent.Schema
+}
+
+func (CommissionEntry) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int64("statement_id"),
+
+		field.String("kind"), // commission | bonus | reversal
+
+		// what the entry was computed from
+		field.String("source"), // invoice_item | payment_allocation | order | order_item
+		field.Int64("source_id"),
+		field.Time("occurred_at"),
+
+		// snapshot
+		field.Int64("order_id"),
+		field.String("order_code").
+			Optional().
+			Nillable(),
+		field.Int64("order_item_id").
+			Optional().
+			Nillable(),
+		field.Int("product_id").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+		field.Int("rule_id").
+			Optional().
+			Nillable(),
+
+		field.Float("base_amount").
+			Default(0),
+		field.Float("amount").
+			Default(0), // negative for reversals
+
+		// reversals only
+		field.Int64("reverses_entry_id").
+			Optional().
+			Nillable(),
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (CommissionEntry) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "commission_entries"},
+	}
+}
+
+func (CommissionEntry) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("statement", CommissionStatement.Type).
+			Ref("entries").
+			Field("statement_id").
+			Required().
+			Unique(),
+	}
+}
+
+func (CommissionEntry) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("statement_id"),
+		index.Fields("order_id"),
+		index.Fields("reverses_entry_id"),
+	}
+}
@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// CommissionRule is what a referrer earns on the orders they brought in.
+// The most specific active rule of an invoiced product applies.
+type CommissionRule struct {
+	ent.Schema
+}
+
+func (CommissionRule) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.String("name").
+			Optional().
+			Nillable(),
+
+		// scope; nil = any
+		field.Int("ref_user_id").
+			Optional().
+			Nillable(),
+		field.Int("product_id").
+			Optional().
+			Nillable(),
+		field.Int("category_id").
+			Optional().
+			Nillable(),
+
+		field.String("rate_type").
+			Default("percent"), // percent of the net amount | fixed per unit
+		field.Float("rate").
+			Default(0),
+
+		// bonus paid once on the first order of a new clinic
+		field.Float("first_order_bonus").
+			Default(0),
+		// most this rule pays on one order; nil = no cap
+		field.Float("cap_per_order").
+			Optional().
+			Nillable(),
+
+		field.Bool("active").
+			Default(true),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (CommissionRule) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "active"),
+	}
+}
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// CommissionSetting holds the department wide commission options.
+type CommissionSetting struct {
+	ent.Schema
+}
+
+func (CommissionSetting) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		// commissions accrue when the order is invoiced or when it is paid
+		field.String("basis").
+			Default("invoiced"), // invoiced | paid
+		// most a referrer earns in a month, reversals aside; nil = no cap
+		field.Float("monthly_cap").
+			Optional().
+			Nillable(),
+
+		field.Int("updated_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (CommissionSetting) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id").Unique(),
+	}
+}
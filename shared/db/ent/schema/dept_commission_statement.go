@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type CommissionStatement struct {
+	ent.Schema
+}
+
+func (CommissionStatement) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+
+		field.Int("ref_user_id"),
+		field.String("ref_user_name").
+			Optional().
+			Nillable(),
+
+		// month [period_start, period_end)
+		field.Time("period_start"),
+		field.Time("period_end"),
+
+		field.String("basis"), // invoiced | paid
+
+		// approved statements are locked
+		field.String("status").
+			Default("draft"), // draft | approved
+
+		field.Float("base_total").
+			Default(0),
+		field.Float("commission").
+			Default(0),
+		field.Float("bonus").
+			Default(0),
+		// amount held back by the monthly cap
+		field.Float("capped").
+			Default(0),
+		field.Float("reversals").
+			Default(0), // negative
+		field.Float("total").
+			Default(0),
+
+		field.Int("generated_by").
+			Optional().
+			Nillable(),
+		field.Time("approved_at").
+			Optional().
+			Nillable(),
+		field.Int("approved_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (CommissionStatement) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("entries", CommissionEntry.Type),
+	}
+}
+
+func (CommissionStatement) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "ref_user_id", "period_start").Unique(),
+		index.Fields("department_id", "period_start"),
+		index.Fields("status"),
+	}
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type DeliveryItem struct {
+	ent.Schema
+}
+
+func (DeliveryItem) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int64("run_id"),
+
+		field.Int64("order_id"),
+		field.Int64("order_item_id"),
+		field.String("order_item_code").
+			Optional().
+			Nillable(),
+
+		field.Int("clinic_id").
+			Optional().
+			Nillable(),
+		field.String("clinic_name").
+			Optional().
+			Nillable(),
+
+		field.String("status").
+			Default("packed"), // packed | out_for_delivery | delivered | failed
+
+		field.Float("shipping_fee").
+			Default(0),
+
+		field.String("recipient_name").
+			Optional().
+			Nillable(),
+
+		field.String("failure_reason").
+			Optional().
+			Nillable(),
+
+		field.Time("delivered_at").
+			Optional().
+			Nillable(),
+
+		// proof of delivery: photo ids from the photo module
+		field.JSON("proof_photo_ids", []int{}).
+			Optional(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (DeliveryItem) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("run", DeliveryRun.Type).
+			Ref("items").
+			Field("run_id").
+			Unique().
+			Required(),
+	}
+}
+
+func (DeliveryItem) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("order_item_id").Unique(),
+		index.Fields("order_id"),
+		index.Fields("run_id", "status"),
+	}
+}
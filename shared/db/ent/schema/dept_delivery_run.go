@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type DeliveryRun struct {
+	ent.Schema
+}
+
+func (DeliveryRun) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+
+		field.String("route"),
+
+		// courier (staff)
+		field.Int("courier_id").
+			Optional().
+			Nillable(),
+		field.String("courier_name").
+			Optional().
+			Nillable(),
+
+		field.String("vehicle").
+			Optional().
+			Nillable(),
+
+		field.Time("run_date"),
+
+		field.String("status").
+			Default("planned"), // planned | dispatched | completed
+
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		field.Int("created_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (DeliveryRun) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("items", DeliveryItem.Type),
+	}
+}
+
+func (DeliveryRun) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "run_date"),
+		index.Fields("courier_id"),
+		index.Fields("deleted_at"),
+	}
+}
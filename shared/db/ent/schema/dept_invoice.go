@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type Invoice struct {
+	ent.Schema
+}
+
+func (Invoice) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+
+		// sequential per department
+		field.String("code"),
+
+		field.Int("clinic_id"),
+		field.String("clinic_name").
+			Optional().
+			Nillable(),
+
+		// billing period [period_start, period_end)
+		field.Time("period_start"),
+		field.Time("period_end"),
+
+		field.String("status").
+			Default("issued"), // issued | void
+
+		field.Float("subtotal").
+			Default(0),
+		field.Float("discount_total").
+			Default(0),
+		field.Float("total").
+			Default(0),
+
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		field.Time("issued_at").
+			Default(time.Now),
+		field.Int("issued_by").
+			Optional().
+			Nillable(),
+
+		field.Time("voided_at").
+			Optional().
+			Nillable(),
+		field.Int("voided_by").
+			Optional().
+			Nillable(),
+		field.String("void_reason").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (Invoice) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("items", InvoiceItem.Type),
+	}
+}
+
+func (Invoice) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "code").Unique(),
+		index.Fields("department_id", "clinic_id", "period_start"),
+		index.Fields("status"),
+		index.Fields("deleted_at"),
+	}
+}
@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type InvoiceItem struct {
+	ent.Schema
+}
+
+func (InvoiceItem) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int64("invoice_id"),
+
+		field.Int64("order_id"),
+		field.String("order_code").
+			Optional().
+			Nillable(),
+		field.Int64("order_item_id"),
+		field.String("order_item_code").
+			Optional().
+			Nillable(),
+
+		// snapshot
+		field.Int("product_id"),
+		field.String("product_code").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+		field.String("teeth_position").
+			Optional().
+			Nillable(),
+		field.Int("quantity").
+			Default(1),
+		field.Float("unit_price").
+			Default(0),
+		field.Float("discount_amount").
+			Default(0),
+		field.Float("line_total").
+			Default(0),
+
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (InvoiceItem) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("invoice", Invoice.Type).
+			Ref("items").
+			Field("invoice_id").
+			Required().
+			Unique(),
+	}
+}
+
+func (InvoiceItem) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("invoice_id"),
+		index.Fields("order_id"),
+	}
+}
@@ -113,6 +113,11 @@ func (Order) Fields() []ent.Field {
 			Nillable().
 			Optional(),
 
+		// Shipping fee: sum of delivery item fees, maintained by the delivery feature
+		field.Float("shipping_fee").
+			Nillable().
+			Optional(),
+
 		field.Time("delivery_date").
 			Nillable().
 			Optional(),
@@ -125,6 +130,11 @@ func (Order) Fields() []ent.Field {
 			Nillable().
 			Optional(),
 
+		// Invoice lock: set while the order belongs to an issued invoice
+		field.Int64("invoice_id").
+			Nillable().
+			Optional(),
+
 		// times
 		field.Time("created_at").
 			Default(time.Now),
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderCancelReason is a reason code a lab picks from when it cancels an
+// order or some of its items.
+type OrderCancelReason struct {
+	ent.Schema
+}
+
+func (OrderCancelReason) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.String("code"),
+		field.String("name"),
+
+		// fee charged when the cancel request does not give one
+		field.Float("default_fee").
+			Default(0),
+
+		field.Bool("active").
+			Default(true),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (OrderCancelReason) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "code").
+			Unique(),
+	}
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderCancellation records one cancellation of a whole order or of some of
+// its items, with what was released on the way.
+type OrderCancellation struct {
+	ent.Schema
+}
+
+func (OrderCancellation) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+		field.Int64("order_id"),
+		field.String("order_code").
+			Optional().
+			Nillable(),
+
+		field.String("scope").
+			Default("order"), // order | items
+		field.JSON("order_item_ids", []int64{}).
+			Optional(),
+
+		// reason snapshot
+		field.Int("reason_id").
+			Optional().
+			Nillable(),
+		field.String("reason_code"),
+		field.String("reason_name").
+			Optional().
+			Nillable(),
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		// billed on the next invoice of the clinic
+		field.Float("fee").
+			Default(0),
+
+		// released side effects
+		field.Int("released_discount").
+			Default(0),
+		field.Int("closed_in_progresses").
+			Default(0),
+		field.Int("closed_loaners").
+			Default(0),
+
+		field.Int("cancelled_by").
+			Optional().
+			Nillable(),
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (OrderCancellation) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("order_id"),
+		index.Fields("department_id", "created_at"),
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
 )
 
 type OrderItemFile struct {
@@ -23,9 +24,42 @@ func (OrderItemFile) Fields() []ent.Field {
 		field.Int64("order_item_id"),
 
 		field.String("file_url"),
-		field.String("file_type").Optional(), // scan_stl | photo | cad | video
+		field.String("file_type").Optional(), // scan_stl | scan_ply | scan_obj | cad | photo | video
 		field.String("description").Optional(),
 
+		field.String("file_name").
+			Optional().
+			Nillable(), // original name as uploaded
+		field.String("mime_type").
+			Optional().
+			Nillable(),
+		field.Int64("size").
+			Default(0),
+		field.String("checksum").
+			Optional().
+			Nillable(), // sha256, hex
+		// a newer upload replacing this file bumps the version and links back
+		field.Int("version").
+			Default(1),
+		field.Int64("previous_id").
+			Optional().
+			Nillable(),
+		field.Time("replaced_at").
+			Optional().
+			Nillable(),
+		field.JSON("meta", map[string]any{}).
+			Optional(), // mesh info of scans: triangle count, bounding box, units
+		// PNG renders of scans, stored with the photo module's thumbnail and
+		// medium sizes: [{"view": "front", "file": "<name>.png"}]
+		field.String("preview_status").
+			Optional().
+			Nillable(), // pending | done | failed
+		field.JSON("previews", []map[string]string{}).
+			Optional(),
+		field.Int("uploaded_by").
+			Optional().
+			Nillable(),
+
 		field.Time("created_at").
 			Default(time.Now),
 	}
@@ -40,3 +74,11 @@ func (OrderItemFile) Edges() []ent.Edge {
 			Unique(),
 	}
 }
+
+func (OrderItemFile) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("order_item_id", "replaced_at"),
+		index.Fields("order_item_id", "checksum"),
+		index.Fields("preview_status", "created_at"),
+	}
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderItemHold is one period an order item spent on hold. The SLA clock of
+// the item is suspended between held_at and resumed_at.
+type OrderItemHold struct {
+	ent.Schema
+}
+
+func (OrderItemHold) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+		field.Int64("order_id"),
+		field.Int64("order_item_id"),
+		field.String("order_item_code").
+			Optional().
+			Nillable(),
+
+		// status the item is resumed from
+		field.String("from_status"),
+
+		field.String("reason").
+			NotEmpty(),
+		field.String("note").
+			Optional().
+			Nillable(),
+		field.Time("expected_resume_at").
+			Optional().
+			Nillable(),
+
+		field.Time("held_at").
+			Default(time.Now),
+		field.Int("held_by").
+			Optional().
+			Nillable(),
+
+		// nil while the item is still on hold
+		field.Time("resumed_at").
+			Optional().
+			Nillable(),
+		field.Int("resumed_by").
+			Optional().
+			Nillable(),
+		field.String("resume_note").
+			Optional().
+			Nillable(),
+
+		// set once the clinic's account owner was told the hold is too long
+		field.Time("alerted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (OrderItemHold) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("order_item_id", "held_at"),
+		index.Fields("department_id", "resumed_at"),
+	}
+}
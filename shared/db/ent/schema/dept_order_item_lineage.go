@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderItemLineage records a split of an order item into a new child item or
+// a merge of one item into another, with the product and material rows that
+// moved. Both orders keep seeing the event on their timeline.
+type OrderItemLineage struct {
+	ent.Schema
+}
+
+func (OrderItemLineage) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+		field.String("kind"), // split | merge
+
+		field.Int64("source_order_id"),
+		field.Int64("source_order_item_id"),
+		field.String("source_code").
+			Optional().
+			Nillable(),
+
+		field.Int64("target_order_id"),
+		field.Int64("target_order_item_id"),
+		field.String("target_code").
+			Optional().
+			Nillable(),
+
+		field.JSON("order_item_product_ids", []int{}).
+			Optional(),
+		field.JSON("order_item_material_ids", []int{}).
+			Optional(),
+
+		field.String("note").
+			Optional().
+			Nillable(),
+		field.Int("created_by").
+			Optional().
+			Nillable(),
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (OrderItemLineage) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("source_order_id"),
+		index.Fields("target_order_id"),
+		index.Fields("source_order_item_id"),
+		index.Fields("target_order_item_id"),
+	}
+}
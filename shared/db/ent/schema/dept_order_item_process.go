@@ -32,12 +32,22 @@ func (OrderItemProcess) Fields() []ent.Field {
 			Nillable().
 			Optional(),
 
+		// master process the step was created from
+		field.Int("process_id").
+			Nillable().
+			Optional(),
+
 		field.String("process_name").
 			Nillable().
 			Optional(),
 
 		field.Int("step_number"),
 
+		// predecessors (master process ids) copied from the product graph;
+		// nil on items routed linearly by step_number
+		field.JSON("depends_on", []int{}).
+			Optional(),
+
 		field.Int64("assigned_id").
 			Optional().Nillable(),
 
@@ -53,7 +63,7 @@ func (OrderItemProcess) Fields() []ent.Field {
 			Nillable(),
 
 		field.String("status").
-			Default("pending"), // pending | in_progress | paused | qc | completed | rework | issue
+			Default("pending"), // pending | in_progress | paused | qc | completed | rework | issue | skipped
 
 		field.String("color").
 			MaxLen(8).
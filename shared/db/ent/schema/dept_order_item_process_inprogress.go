@@ -79,6 +79,15 @@ func (OrderItemProcessInProgress) Fields() []ent.Field {
 			Optional().Nillable(),
 		field.Time("completed_at").
 			Optional().Nillable(),
+
+		// SLA escalation, set once per level by the SLA job
+		field.String("sla_status").
+			Optional().
+			Nillable(), // warning | breached
+		field.Time("sla_warned_at").
+			Optional().Nillable(),
+		field.Time("sla_breached_at").
+			Optional().Nillable(),
 		field.Time("updated_at").
 			Default(time.Now).UpdateDefault(time.Now),
 	}
@@ -5,6 +5,7 @@ import (
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/index"
+	"github.com/khiemnd777/andy_api/shared/toothchart"
 )
 
 type OrderItemProduct struct {
@@ -33,9 +34,12 @@ func (OrderItemProduct) Fields() []ent.Field {
 			Optional().
 			Nillable(),
 
+		// FDI text of tooth_chart when present, kept for lists and printing
 		field.String("teeth_position").
 			Optional().
 			Nillable(),
+		field.JSON("tooth_chart", &toothchart.Chart{}).
+			Optional(),
 
 		field.String("note").
 			Optional().
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderStatusHistory records every status transition of an order, an order
+// item or one of its processes.
+type OrderStatusHistory struct {
+	ent.Schema
+}
+
+func (OrderStatusHistory) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.String("entity"), // order | order_item | order_item_process
+		field.Int64("entity_id"),
+
+		// cache
+		field.Int64("order_id").
+			Optional().
+			Nillable(),
+		field.Int64("order_item_id").
+			Optional().
+			Nillable(),
+
+		field.String("from_status").
+			Optional().
+			Nillable(),
+		field.String("to_status"),
+
+		field.Int("changed_by").
+			Optional().
+			Nillable(),
+
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable(),
+	}
+}
+
+func (OrderStatusHistory) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("entity", "entity_id", "created_at"),
+		index.Fields("order_id", "created_at"),
+	}
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OrderTemplate is a named order a clinic or a dentist sends again and
+// again. Content holds the products, tooth selection, materials, custom
+// fields and process routing a new order is created from.
+type OrderTemplate struct {
+	ent.Schema
+}
+
+func (OrderTemplate) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.String("name"),
+
+		// owner; nil = shared by the whole lab
+		field.Int("clinic_id").
+			Optional().
+			Nillable(),
+		field.String("clinic_name").
+			Optional().
+			Nillable(),
+		field.Int("dentist_id").
+			Optional().
+			Nillable(),
+		field.String("dentist_name").
+			Optional().
+			Nillable(),
+
+		field.JSON("content", map[string]any{}).
+			Optional(),
+
+		field.Int("created_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (OrderTemplate) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "deleted_at"),
+		index.Fields("clinic_id"),
+		index.Fields("dentist_id"),
+	}
+}
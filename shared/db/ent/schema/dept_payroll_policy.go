@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// PayrollPolicy is how remakes are deducted from the technicians who worked
+// on the original item, one row per department.
+type PayrollPolicy struct {
+	ent.Schema
+}
+
+func (PayrollPolicy) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.String("remake_deduction").
+			Default("none"), // none | percent | fixed
+		// percent of the original earnings, or the fixed amount per remake
+		field.Float("remake_deduction_value").
+			Default(0),
+		field.String("remake_attribution").
+			Default("last"), // last | all technicians of the original item
+		// only remakes within this many days of the step count; 0 = any
+		field.Int("remake_window_days").
+			Default(0),
+		field.Bool("include_adjust").
+			Default(false),
+
+		field.Int("updated_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (PayrollPolicy) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "payroll_policies"},
+	}
+}
+
+func (PayrollPolicy) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id").Unique(),
+	}
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type PayrollStatement struct {
+	ent.Schema
+}
+
+func (PayrollStatement) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int("department_id"),
+
+		field.Int64("staff_id"),
+		field.String("staff_name").
+			Optional().
+			Nillable(),
+
+		// payroll period [period_start, period_end)
+		field.Time("period_start"),
+		field.Time("period_end"),
+
+		// approved statements are locked
+		field.String("status").
+			Default("draft"), // draft | approved
+
+		field.Int("units").
+			Default(0),
+		field.Float("gross").
+			Default(0),
+		field.Float("deductions").
+			Default(0),
+		field.Float("net").
+			Default(0),
+
+		field.Int("generated_by").
+			Optional().
+			Nillable(),
+		field.Time("approved_at").
+			Optional().
+			Nillable(),
+		field.Int("approved_by").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (PayrollStatement) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("lines", PayrollStatementLine.Type),
+	}
+}
+
+func (PayrollStatement) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "staff_id", "period_start", "period_end").Unique(),
+		index.Fields("department_id", "period_start"),
+		index.Fields("status"),
+	}
+}
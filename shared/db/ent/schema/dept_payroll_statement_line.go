@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type PayrollStatementLine struct {
+	ent.Schema
+}
+
+func (PayrollStatementLine) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Unique().
+			SchemaType(map[string]string{
+				"postgres": "bigserial",
+			}),
+
+		field.Int64("statement_id"),
+
+		field.String("kind"), // earning | deduction
+
+		// the completed step; for deductions, the step on the original item
+		field.Int64("in_progress_id"),
+		field.Time("completed_at"),
+
+		// snapshot
+		field.Int64("order_id"),
+		field.String("order_code").
+			Optional().
+			Nillable(),
+		field.Int64("order_item_id"),
+		field.String("order_item_code").
+			Optional().
+			Nillable(),
+		field.Int("process_id").
+			Optional().
+			Nillable(),
+		field.String("process_name").
+			Optional().
+			Nillable(),
+		field.Int("product_id").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+
+		field.Int("units").
+			Default(0),
+		field.Float("rate").
+			Default(0),
+		field.Float("amount").
+			Default(0), // negative for deductions
+
+		// deductions only
+		field.Int64("remake_log_id").
+			Optional().
+			Nillable(),
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		field.Time("created_at").
+			Default(time.Now),
+	}
+}
+
+func (PayrollStatementLine) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("statement", PayrollStatement.Type).
+			Ref("lines").
+			Field("statement_id").
+			Required().
+			Unique(),
+	}
+}
+
+func (PayrollStatementLine) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("statement_id"),
+	}
+}
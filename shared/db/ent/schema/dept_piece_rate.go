@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// PieceRate is the pay per completed unit of a process step.
+// A row without product_id and restoration_type_id is the process default;
+// product rows override restoration type rows, which override the default.
+type PieceRate struct {
+	ent.Schema
+}
+
+func (PieceRate) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.Int("process_id"),
+		field.String("process_name").
+			Optional().
+			Nillable(),
+
+		field.Int("product_id").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+
+		field.Int("restoration_type_id").
+			Optional().
+			Nillable(),
+		field.String("restoration_type_name").
+			Optional().
+			Nillable(),
+
+		field.Float("rate").
+			Default(0),
+
+		field.Bool("active").
+			Default(true),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (PieceRate) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "process_id", "product_id", "restoration_type_id").Unique(),
+	}
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type PriceList struct {
+	ent.Schema
+}
+
+func (PriceList) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("department_id"),
+
+		field.String("name"),
+
+		// scope: no clinic and no dentist = department default
+		field.Int("clinic_id").
+			Optional().
+			Nillable(),
+		field.String("clinic_name").
+			Optional().
+			Nillable(),
+		field.Int("dentist_id").
+			Optional().
+			Nillable(),
+		field.String("dentist_name").
+			Optional().
+			Nillable(),
+
+		// validity: [effective_from, effective_to)
+		field.Time("effective_from"),
+		field.Time("effective_to").
+			Optional().
+			Nillable(),
+
+		field.Bool("active").
+			Default(true),
+
+		field.String("note").
+			Optional().
+			Nillable(),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+func (PriceList) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.To("items", PriceListItem.Type),
+	}
+}
+
+func (PriceList) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("department_id", "clinic_id", "dentist_id", "effective_from"),
+		index.Fields("deleted_at"),
+	}
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+type PriceListItem struct {
+	ent.Schema
+}
+
+func (PriceListItem) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("price_list_id"),
+
+		field.Int("product_id"),
+		field.String("product_code").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+
+		// volume tier: applies from this quantity upwards
+		field.Int("min_quantity").
+			Default(1),
+
+		field.Float("price"),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (PriceListItem) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("price_list", PriceList.Type).
+			Ref("items").
+			Field("price_list_id").
+			Required().
+			Unique(),
+	}
+}
+
+func (PriceListItem) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("price_list_id", "product_id", "min_quantity").
+			Unique(),
+		index.Fields("product_id"),
+	}
+}
@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// ProcessSLA is the expected duration of a process step.
+// A row without product_id is the process default; product rows override it.
+type ProcessSLA struct {
+	ent.Schema
+}
+
+func (ProcessSLA) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("process_id"),
+
+		field.Int("product_id").
+			Optional().
+			Nillable(),
+		field.String("product_name").
+			Optional().
+			Nillable(),
+
+		field.Int("standard_minutes"),
+
+		// notify ahead of the breach; 0 disables the warning
+		field.Int("warning_minutes").
+			Default(0),
+
+		// times
+		field.Time("created_at").
+			Default(time.Now),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now),
+	}
+}
+
+func (ProcessSLA) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "process_slas"},
+	}
+}
+
+func (ProcessSLA) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("process_id", "product_id").Unique(),
+	}
+}
@@ -34,6 +34,11 @@ func (Product) Fields() []ent.Field {
 			Optional().
 			Nillable(),
 
+		// price: variants without their own price inherit the template's
+		field.Float("retail_price").
+			Optional().
+			Nillable(),
+
 		// activated
 		field.Bool("active").
 			Default(true),
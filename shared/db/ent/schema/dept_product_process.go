@@ -9,7 +9,8 @@ import (
 	"entgo.io/ent/schema/index"
 )
 
-// deprecated
+// ProductProcess is a node of the product's process graph. When a product has
+// rows here they replace the linear category process list for its items.
 type ProductProcess struct {
 	ent.Schema
 }
@@ -22,6 +23,15 @@ func (ProductProcess) Fields() []ent.Field {
 			Max(100).
 			Optional().
 			Nillable(),
+		// master process ids that must be completed (or skipped) first;
+		// empty means the step can start right away
+		field.JSON("depends_on", []int{}).
+			Optional(),
+		// order item custom field values the step requires, e.g.
+		// {"restoration_type": ["zirconia", "emax"]}; the step is skipped
+		// when they do not match
+		field.JSON("condition", map[string]any{}).
+			Optional(),
 		field.Time("created_at").Default(time.Now),
 	}
 }
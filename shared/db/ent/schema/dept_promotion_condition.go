@@ -16,7 +16,7 @@ func (PromotionCondition) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int("promo_code_id"),
 		field.Enum("condition_type").
-			Values("ORDER_IS_REMAKE", "REMAKE_COUNT_LTE", "REMAKE_WITHIN_DAYS", "REMAKE_REASON").
+			Values("ORDER_IS_REMAKE", "REMAKE_COUNT_LTE", "REMAKE_WITHIN_DAYS", "REMAKE_REASON", "UNIT_COUNT_GTE", "UNIT_COUNT_LTE").
 			Immutable(),
 		field.JSON("condition_value", json.RawMessage{}).
 			Optional().
@@ -0,0 +1,71 @@
+package toothchart
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	ShadeVitaClassical = "vita_classical"
+	ShadeVita3DMaster  = "vita_3d_master"
+)
+
+type Shade struct {
+	System string `json:"system"` // vita_classical | vita_3d_master
+	Value  string `json:"value"`  // A2, 2M2, ...
+}
+
+var shadeGuides = map[string][]string{
+	ShadeVitaClassical: {
+		"A1", "A2", "A3", "A3.5", "A4",
+		"B1", "B2", "B3", "B4",
+		"C1", "C2", "C3", "C4",
+		"D2", "D3", "D4",
+	},
+	ShadeVita3DMaster: {
+		"0M1", "0M2", "0M3",
+		"1M1", "1M2",
+		"2L1.5", "2L2.5", "2M1", "2M2", "2M3", "2R1.5", "2R2.5",
+		"3L1.5", "3L2.5", "3M1", "3M2", "3M3", "3R1.5", "3R2.5",
+		"4L1.5", "4L2.5", "4M1", "4M2", "4M3", "4R1.5", "4R2.5",
+		"5M1", "5M2", "5M3",
+	},
+}
+
+// ShadeGuides lists the valid shades per system.
+func ShadeGuides() map[string][]string {
+	out := make(map[string][]string, len(shadeGuides))
+	for k, v := range shadeGuides {
+		out[k] = slices.Clone(v)
+	}
+	return out
+}
+
+func (s *Shade) normalize() {
+	s.System = strings.ToLower(strings.TrimSpace(s.System))
+	s.Value = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s.Value), ",", "."))
+	if s.System == "" {
+		s.System = detectShadeSystem(s.Value)
+	}
+}
+
+func (s *Shade) Validate() error {
+	guide, ok := shadeGuides[s.System]
+	if !ok {
+		return fmt.Errorf("%w: unknown shade system %q", ErrInvalidChart, s.System)
+	}
+	if !slices.Contains(guide, s.Value) {
+		return fmt.Errorf("%w: %q is not a %s shade", ErrInvalidChart, s.Value, s.System)
+	}
+	return nil
+}
+
+func detectShadeSystem(value string) string {
+	for system, guide := range shadeGuides {
+		if slices.Contains(guide, value) {
+			return system
+		}
+	}
+	return ""
+}
@@ -0,0 +1,258 @@
+// Package toothchart is the structured tooth selection of an order item
+// product: teeth in FDI notation grouped into units (a single tooth or a
+// bridge span with its pontics) plus the shade. Every tooth of a unit,
+// pontics included, is one billable unit.
+package toothchart
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidChart = errors.New("invalid tooth chart")
+
+type Chart struct {
+	Units []Unit `json:"units"`
+	Shade *Shade `json:"shade,omitempty"`
+}
+
+// Unit is one restoration: a single tooth, or a bridge span listed in arch
+// order from the patient's right to left.
+type Unit struct {
+	Teeth   []int `json:"teeth"`
+	Pontics []int `json:"pontics,omitempty"`
+}
+
+func (u Unit) IsBridge() bool { return len(u.Teeth) > 1 }
+
+// Count is the number of billable units of the chart.
+func (c *Chart) Count() int {
+	if c == nil {
+		return 0
+	}
+	n := 0
+	for _, u := range c.Units {
+		n += len(u.Teeth)
+	}
+	return n
+}
+
+// Normalize sorts the teeth of every unit in arch order and the units by
+// their first tooth, then validates the chart.
+func (c *Chart) Normalize() error {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Units {
+		u := &c.Units[i]
+		sort.SliceStable(u.Teeth, func(a, b int) bool { return archPos(u.Teeth[a]) < archPos(u.Teeth[b]) })
+		sort.SliceStable(u.Pontics, func(a, b int) bool { return archPos(u.Pontics[a]) < archPos(u.Pontics[b]) })
+	}
+	sort.SliceStable(c.Units, func(a, b int) bool {
+		ua, ub := c.Units[a], c.Units[b]
+		if len(ua.Teeth) == 0 || len(ub.Teeth) == 0 {
+			return len(ua.Teeth) > len(ub.Teeth)
+		}
+		if archOf(ua.Teeth[0]) != archOf(ub.Teeth[0]) {
+			return archOf(ua.Teeth[0]) < archOf(ub.Teeth[0])
+		}
+		return archPos(ua.Teeth[0]) < archPos(ub.Teeth[0])
+	})
+	if c.Shade != nil {
+		c.Shade.normalize()
+	}
+	return c.Validate()
+}
+
+func (c *Chart) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Units) == 0 {
+		return fmt.Errorf("%w: no teeth selected", ErrInvalidChart)
+	}
+
+	seen := map[int]struct{}{}
+	for _, u := range c.Units {
+		if len(u.Teeth) == 0 {
+			return fmt.Errorf("%w: empty unit", ErrInvalidChart)
+		}
+		for _, t := range u.Teeth {
+			if !ValidFDI(t) {
+				return fmt.Errorf("%w: %d is not an FDI tooth number", ErrInvalidChart, t)
+			}
+			if _, dup := seen[t]; dup {
+				return fmt.Errorf("%w: tooth %d is selected twice", ErrInvalidChart, t)
+			}
+			seen[t] = struct{}{}
+		}
+		if err := u.validateSpan(); err != nil {
+			return err
+		}
+	}
+
+	if c.Shade != nil {
+		if err := c.Shade.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u Unit) validateSpan() error {
+	if !u.IsBridge() {
+		if len(u.Pontics) > 0 {
+			return fmt.Errorf("%w: pontic %d outside a bridge", ErrInvalidChart, u.Pontics[0])
+		}
+		return nil
+	}
+
+	arch := archOf(u.Teeth[0])
+	for i, t := range u.Teeth {
+		if archOf(t) != arch {
+			return fmt.Errorf("%w: bridge %s crosses arches", ErrInvalidChart, u.FDI())
+		}
+		if i > 0 && archPos(t) != archPos(u.Teeth[i-1])+1 {
+			return fmt.Errorf("%w: bridge %s is not continuous", ErrInvalidChart, u.FDI())
+		}
+	}
+
+	for _, p := range u.Pontics {
+		if !slices.Contains(u.Teeth, p) {
+			return fmt.Errorf("%w: pontic %d is not part of bridge %s", ErrInvalidChart, p, u.FDI())
+		}
+	}
+	if len(u.Pontics) >= len(u.Teeth) {
+		return fmt.Errorf("%w: bridge %s has no abutment", ErrInvalidChart, u.FDI())
+	}
+	return nil
+}
+
+// ValidFDI reports whether n is a permanent (11-48) or primary (51-85)
+// tooth in FDI notation.
+func ValidFDI(n int) bool {
+	q, t := n/10, n%10
+	switch {
+	case q >= 1 && q <= 4:
+		return t >= 1 && t <= 8
+	case q >= 5 && q <= 8:
+		return t >= 1 && t <= 5
+	}
+	return false
+}
+
+// archOf numbers the four arches: permanent upper/lower, primary upper/lower.
+func archOf(n int) int {
+	switch n / 10 {
+	case 1, 2:
+		return 0
+	case 3, 4:
+		return 1
+	case 5, 6:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// archPos is the position along the arch from the patient's right to left.
+func archPos(n int) int {
+	q, t := n/10, n%10
+	switch q {
+	case 1, 4:
+		return 8 - t
+	case 2, 3:
+		return 7 + t
+	case 5, 8:
+		return 5 - t
+	default:
+		return 4 + t
+	}
+}
+
+// Universal converts an FDI tooth number to the Universal numbering system:
+// 1-32 for permanent teeth, A-T for primary teeth.
+func Universal(n int) (string, error) {
+	if !ValidFDI(n) {
+		return "", fmt.Errorf("%w: %d is not an FDI tooth number", ErrInvalidChart, n)
+	}
+	q, t := n/10, n%10
+	switch q {
+	case 1:
+		return strconv.Itoa(9 - t), nil
+	case 2:
+		return strconv.Itoa(8 + t), nil
+	case 3:
+		return strconv.Itoa(25 - t), nil
+	case 4:
+		return strconv.Itoa(24 + t), nil
+	case 5:
+		return string(rune('A' + 5 - t)), nil
+	case 6:
+		return string(rune('E' + t)), nil
+	case 7:
+		return string(rune('K' + 5 - t)), nil
+	default:
+		return string(rune('O' + t)), nil
+	}
+}
+
+// FDI renders the unit as "11" or "14-16 (15 pontic)".
+func (u Unit) FDI() string {
+	return u.format(strconv.Itoa)
+}
+
+func (u Unit) format(name func(int) string) string {
+	if len(u.Teeth) == 0 {
+		return ""
+	}
+	s := name(u.Teeth[0])
+	if u.IsBridge() {
+		s += "-" + name(u.Teeth[len(u.Teeth)-1])
+	}
+	if len(u.Pontics) > 0 {
+		ps := make([]string, len(u.Pontics))
+		for i, p := range u.Pontics {
+			ps[i] = name(p)
+		}
+		label := "pontic"
+		if len(ps) > 1 {
+			label = "pontics"
+		}
+		s += " (" + strings.Join(ps, ", ") + " " + label + ")"
+	}
+	return s
+}
+
+// String is the FDI text kept in teeth_position for lists, tickets and
+// invoices, e.g. "11, 14-16 (15 pontic); A2".
+func (c *Chart) String() string {
+	return c.render(strconv.Itoa)
+}
+
+// UniversalString renders the chart in Universal numbering.
+func (c *Chart) UniversalString() string {
+	return c.render(func(n int) string {
+		s, _ := Universal(n)
+		return s
+	})
+}
+
+func (c *Chart) render(name func(int) string) string {
+	if c == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(c.Units))
+	for _, u := range c.Units {
+		parts = append(parts, u.format(name))
+	}
+	s := strings.Join(parts, ", ")
+	if c.Shade != nil && c.Shade.Value != "" {
+		s += "; " + c.Shade.Value
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package toothchart
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidFDI(t *testing.T) {
+	tests := []struct {
+		input    int
+		expected bool
+	}{
+		{11, true},
+		{18, true},
+		{48, true},
+		{19, false},
+		{10, false},
+		{51, true},
+		{85, true},
+		{56, false},
+		{91, false},
+		{0, false},
+		{-11, false},
+	}
+	for _, tt := range tests {
+		if got := ValidFDI(tt.input); got != tt.expected {
+			t.Errorf("ValidFDI(%d) = %v; want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestUniversal(t *testing.T) {
+	tests := []struct {
+		input    int
+		expected string
+	}{
+		{18, "1"},
+		{11, "8"},
+		{21, "9"},
+		{28, "16"},
+		{38, "17"},
+		{31, "24"},
+		{41, "25"},
+		{48, "32"},
+		{55, "A"},
+		{51, "E"},
+		{61, "F"},
+		{65, "J"},
+		{75, "K"},
+		{71, "O"},
+		{81, "P"},
+		{85, "T"},
+	}
+	for _, tt := range tests {
+		got, err := Universal(tt.input)
+		if err != nil || got != tt.expected {
+			t.Errorf("Universal(%d) = %q, %v; want %q", tt.input, got, err, tt.expected)
+		}
+	}
+
+	if _, err := Universal(19); !errors.Is(err, ErrInvalidChart) {
+		t.Errorf("Universal(19) err = %v; want ErrInvalidChart", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		chart     Chart
+		fdi       string
+		universal string
+		count     int
+	}{
+		{
+			name:      "single tooth",
+			chart:     Chart{Units: []Unit{{Teeth: []int{11}}}},
+			fdi:       "11",
+			universal: "8",
+			count:     1,
+		},
+		{
+			name:      "bridge listed out of order",
+			chart:     Chart{Units: []Unit{{Teeth: []int{16, 14, 15}, Pontics: []int{15}}}},
+			fdi:       "16-14 (15 pontic)",
+			universal: "3-5 (4 pontic)",
+			count:     3,
+		},
+		{
+			name:      "bridge across the midline",
+			chart:     Chart{Units: []Unit{{Teeth: []int{21, 11, 12, 22}, Pontics: []int{21, 11}}}},
+			fdi:       "12-22 (11, 21 pontics)",
+			universal: "7-10 (8, 9 pontics)",
+			count:     4,
+		},
+		{
+			name: "units sorted by arch then position",
+			chart: Chart{Units: []Unit{
+				{Teeth: []int{36}},
+				{Teeth: []int{21}},
+				{Teeth: []int{13}},
+			}},
+			fdi:       "13, 21, 36",
+			universal: "6, 9, 19",
+			count:     3,
+		},
+		{
+			name:      "shade normalized and detected",
+			chart:     Chart{Units: []Unit{{Teeth: []int{11}}}, Shade: &Shade{Value: " a3,5 "}},
+			fdi:       "11; A3.5",
+			universal: "8; A3.5",
+			count:     1,
+		},
+		{
+			name:      "3d master shade",
+			chart:     Chart{Units: []Unit{{Teeth: []int{46}}}, Shade: &Shade{System: " VITA_3D_MASTER ", Value: "2m2"}},
+			fdi:       "46; 2M2",
+			universal: "30; 2M2",
+			count:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		c := tt.chart
+		if err := c.Normalize(); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got := c.String(); got != tt.fdi {
+			t.Errorf("%s: String() = %q; want %q", tt.name, got, tt.fdi)
+		}
+		if got := c.UniversalString(); got != tt.universal {
+			t.Errorf("%s: UniversalString() = %q; want %q", tt.name, got, tt.universal)
+		}
+		if got := c.Count(); got != tt.count {
+			t.Errorf("%s: Count() = %d; want %d", tt.name, got, tt.count)
+		}
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart Chart
+	}{
+		{"no units", Chart{}},
+		{"empty unit", Chart{Units: []Unit{{}}}},
+		{"not an fdi number", Chart{Units: []Unit{{Teeth: []int{19}}}}},
+		{"tooth twice", Chart{Units: []Unit{{Teeth: []int{11}}, {Teeth: []int{11}}}}},
+		{"pontic on a single tooth", Chart{Units: []Unit{{Teeth: []int{11}, Pontics: []int{11}}}}},
+		{"gap in bridge", Chart{Units: []Unit{{Teeth: []int{14, 16}}}}},
+		{"bridge across arches", Chart{Units: []Unit{{Teeth: []int{11, 41}}}}},
+		{"pontic outside the bridge", Chart{Units: []Unit{{Teeth: []int{14, 15}, Pontics: []int{16}}}}},
+		{"bridge without abutment", Chart{Units: []Unit{{Teeth: []int{14, 15}, Pontics: []int{14, 15}}}}},
+		{"unknown shade", Chart{Units: []Unit{{Teeth: []int{11}}}, Shade: &Shade{Value: "Z9"}}},
+		{"shade of another system", Chart{Units: []Unit{{Teeth: []int{11}}}, Shade: &Shade{System: ShadeVitaClassical, Value: "2M2"}}},
+	}
+
+	for _, tt := range tests {
+		c := tt.chart
+		if err := c.Normalize(); !errors.Is(err, ErrInvalidChart) {
+			t.Errorf("%s: err = %v; want ErrInvalidChart", tt.name, err)
+		}
+	}
+}
+
+func TestNilChart(t *testing.T) {
+	var c *Chart
+	if c.Count() != 0 || c.String() != "" || c.Normalize() != nil || c.Validate() != nil {
+		t.Errorf("nil chart should count 0, render empty and validate")
+	}
+}